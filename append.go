@@ -0,0 +1,68 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+// YAMLToJSONAppend is like YAMLToJSON, but appends the JSON to dst and
+// returns the extended buffer, so that a buffer can be reused across calls
+// instead of allocating the output of each one. The contents of dst are
+// left as is.
+func YAMLToJSONAppend(dst, src []byte) ([]byte, error) {
+	jsonObj, err := yamlToJSONObject(src, nil, yaml.Unmarshal, nil)
+	if err != nil {
+		return dst, err
+	}
+	buf := bytes.NewBuffer(dst)
+	if err := json.NewEncoder(buf).Encode(jsonObj); err != nil {
+		return dst, fmt.Errorf("error converting YAML to JSON: %w", err)
+	}
+	// Encode ends the value with a newline, which Marshal doesn't.
+	out := buf.Bytes()
+	return out[:len(out)-1], nil
+}
+
+// MarshalAppend is like Marshal, but appends the YAML to dst and returns
+// the extended buffer, so that a buffer can be reused across calls instead
+// of allocating the output of each one. The contents of dst are left as
+// is.
+func MarshalAppend(dst []byte, obj interface{}) ([]byte, error) {
+	jsonBytes, err := json.Marshal(obj)
+	if err != nil {
+		return dst, fmt.Errorf("error marshaling into JSON: %w", err)
+	}
+	var jsonObj interface{}
+	// See JSONToYAML for why yaml.Unmarshal is used.
+	if err := yaml.Unmarshal(jsonBytes, &jsonObj); err != nil {
+		return dst, fmt.Errorf("error converting JSON to YAML: %w", err)
+	}
+	buf := bytes.NewBuffer(dst)
+	e := yaml.NewEncoder(buf)
+	if err := e.Encode(jsonObj); err != nil {
+		return dst, fmt.Errorf("error converting JSON to YAML: %w", err)
+	}
+	if err := e.Close(); err != nil {
+		return dst, fmt.Errorf("error converting JSON to YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
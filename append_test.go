@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"testing"
+)
+
+func TestYAMLToJSONAppend(t *testing.T) {
+	y := []byte("b: [1, x]\na: {c: true}\n")
+	expected, err := YAMLToJSON(y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 0, 256)
+	buf = append(buf, "prefix "...)
+	out, err := YAMLToJSONAppend(buf, y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "prefix "+string(expected) {
+		t.Errorf("unexpected output %q", out)
+	}
+	if &out[0] != &buf[0] {
+		t.Errorf("expected the buffer to be reused")
+	}
+
+	out, err = YAMLToJSONAppend(out, []byte("a: [b\n"))
+	if err == nil || string(out) != "prefix "+string(expected) {
+		t.Errorf("expected an error and the buffer left as is, got %q, %v", out, err)
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		_, _ = YAMLToJSONAppend(buf[:0], y)
+	})
+	jsonAllocs := testing.AllocsPerRun(10, func() {
+		_, _ = YAMLToJSON(y)
+	})
+	if allocs > jsonAllocs {
+		t.Errorf("expected at most %v allocations, got %v", jsonAllocs, allocs)
+	}
+}
+
+func TestMarshalAppend(t *testing.T) {
+	obj := map[string]interface{}{"b": []int{1, 2}, "a": "x"}
+	expected, err := Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := MarshalAppend([]byte("# header\n"), obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "# header\n"+string(expected) {
+		t.Errorf("unexpected output %q", out)
+	}
+
+	if _, err := MarshalAppend(nil, make(chan int)); err == nil {
+		t.Errorf("expected an error")
+	}
+}
@@ -0,0 +1,189 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+// A YAMLToJSONOpt is an option of YAMLToJSONWithOptions.
+type YAMLToJSONOpt func(*yamlToJSONOptions)
+
+type yamlToJSONOptions struct {
+	disallowDuplicateKeys bool
+	disallowNonStringKeys bool
+	largeIntsAsStrings    bool
+	nonFiniteAsStrings    bool
+	warn                  WarningHandler
+}
+
+// DisallowDuplicateKeys makes the conversion fail when mapping keys are
+// repeated, as YAMLToJSONStrict does, with a DuplicateKeyError listing
+// them.
+func DisallowDuplicateKeys(o *yamlToJSONOptions) {
+	o.disallowDuplicateKeys = true
+}
+
+// WarnDuplicateKeys passes the mapping keys that are repeated to warn, as
+// YAMLToJSONWithWarnings does, unless DisallowDuplicateKeys is given too.
+func WarnDuplicateKeys(warn WarningHandler) YAMLToJSONOpt {
+	return func(o *yamlToJSONOptions) {
+		o.warn = warn
+	}
+}
+
+// DisallowNonStringKeys makes the conversion fail when a mapping key is
+// not a string, instead of converting int, bool and float keys to
+// strings.
+func DisallowNonStringKeys(o *yamlToJSONOptions) {
+	o.disallowNonStringKeys = true
+}
+
+// LargeIntegersAsStrings writes the integers that a float64 can't hold
+// exactly, beyond +/- 2^53, as JSON strings, so that they keep their
+// precision when decoded by JSON libraries that use floating-point
+// numbers.
+func LargeIntegersAsStrings(o *yamlToJSONOptions) {
+	o.largeIntsAsStrings = true
+}
+
+// NonFiniteFloatsAsStrings writes the .inf, -.inf and .nan floats, which
+// JSON can't hold, as the strings ".inf", "-.inf" and ".nan" instead of
+// failing.
+func NonFiniteFloatsAsStrings(o *yamlToJSONOptions) {
+	o.nonFiniteAsStrings = true
+}
+
+// YAMLToJSONWithOptions is like YAMLToJSON, but lets the options control
+// the handling of repeated and non-string mapping keys and of the numbers
+// that JSON can't hold well. Without options, it returns what YAMLToJSON
+// does.
+func YAMLToJSONWithOptions(y []byte, opts ...YAMLToJSONOpt) ([]byte, error) {
+	var o yamlToJSONOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	unmarshalFn := yaml.Unmarshal
+	if o.disallowDuplicateKeys {
+		unmarshalFn = yaml.UnmarshalStrict
+	}
+	var yamlObj interface{}
+	if err := unmarshalFn(y, &yamlObj); err != nil {
+		err = fmt.Errorf("error converting YAML to JSON: %w", err)
+		if o.disallowDuplicateKeys {
+			if keys := findDuplicateKeys(y); len(keys) > 0 {
+				return nil, &DuplicateKeyError{Keys: keys, err: err}
+			}
+		}
+		return nil, err
+	}
+	if o.disallowNonStringKeys {
+		if err := checkStringKeys(yamlObj); err != nil {
+			return nil, fmt.Errorf("error converting YAML to JSON: %w", err)
+		}
+	}
+
+	jsonObj, err := convertToJSONableObject(yamlObj, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error converting YAML to JSON: %w", err)
+	}
+	if o.largeIntsAsStrings || o.nonFiniteAsStrings {
+		jsonObj = convertNumbers(jsonObj, &o)
+	}
+	j, err := json.Marshal(jsonObj)
+	if err != nil {
+		return nil, fmt.Errorf("error converting YAML to JSON: %w", err)
+	}
+
+	if o.warn != nil && !o.disallowDuplicateKeys {
+		warnDuplicateKeys(y, o.warn)
+	}
+	return j, nil
+}
+
+// checkStringKeys returns an error for the first mapping key of yamlObj
+// that isn't a string.
+func checkStringKeys(yamlObj interface{}) error {
+	switch v := yamlObj.(type) {
+	case map[interface{}]interface{}:
+		for k, e := range v {
+			if _, ok := k.(string); !ok {
+				return fmt.Errorf("unsupported map key of type: %s, key: %+#v", reflect.TypeOf(k), k)
+			}
+			if err := checkStringKeys(e); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, e := range v {
+			if err := checkStringKeys(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// maxExactInt is the largest integer that a float64 holds exactly, along
+// with every smaller one.
+const maxExactInt = 1 << 53
+
+// convertNumbers converts the numbers of jsonObj to strings as requested
+// by o, in place.
+func convertNumbers(jsonObj interface{}, o *yamlToJSONOptions) interface{} {
+	switch v := jsonObj.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = convertNumbers(e, o)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = convertNumbers(e, o)
+		}
+	case int:
+		if o.largeIntsAsStrings && (int64(v) > maxExactInt || int64(v) < -maxExactInt) {
+			return strconv.Itoa(v)
+		}
+	case int64:
+		if o.largeIntsAsStrings && (v > maxExactInt || v < -maxExactInt) {
+			return strconv.FormatInt(v, 10)
+		}
+	case uint64:
+		if o.largeIntsAsStrings && v > maxExactInt {
+			return strconv.FormatUint(v, 10)
+		}
+	case float64:
+		if o.nonFiniteAsStrings {
+			switch {
+			case math.IsInf(v, 1):
+				return ".inf"
+			case math.IsInf(v, -1):
+				return "-.inf"
+			case math.IsNaN(v):
+				return ".nan"
+			}
+		}
+	}
+	return jsonObj
+}
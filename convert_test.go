@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestYAMLToJSONWithOptions(t *testing.T) {
+	tests := map[string]struct {
+		yaml     string
+		opts     []YAMLToJSONOpt
+		expected string
+		err      string
+	}{
+		"no options": {
+			yaml:     "a: 1\nb: [9007199254740993, x]\n1: true\n",
+			expected: `{"1":true,"a":1,"b":[9007199254740993,"x"]}`,
+		},
+		"duplicate keys": {
+			yaml:     "a: 1\na: 2\n",
+			expected: `{"a":2}`,
+		},
+		"disallow duplicate keys": {
+			yaml: "a: 1\na: 2\n",
+			opts: []YAMLToJSONOpt{DisallowDuplicateKeys},
+			err:  `error converting YAML to JSON: yaml: unmarshal errors:`,
+		},
+		"disallow non-string keys": {
+			yaml: "a: {1: x}\n",
+			opts: []YAMLToJSONOpt{DisallowNonStringKeys},
+			err:  `error converting YAML to JSON: unsupported map key of type: int, key: 1`,
+		},
+		"large integers": {
+			yaml:     "a: [9007199254740992, 9007199254740993, -9007199254740993, 18446744073709551615, 1.5]\n",
+			opts:     []YAMLToJSONOpt{LargeIntegersAsStrings},
+			expected: `{"a":[9007199254740992,"9007199254740993","-9007199254740993","18446744073709551615",1.5]}`,
+		},
+		"non-finite floats": {
+			yaml: "a: [.inf, -.inf, .nan]\n",
+			err:  `error converting YAML to JSON: json: unsupported value: +Inf`,
+		},
+		"non-finite floats as strings": {
+			yaml:     "a: [.inf, -.inf, .nan, 1.5]\n",
+			opts:     []YAMLToJSONOpt{NonFiniteFloatsAsStrings},
+			expected: `{"a":[".inf","-.inf",".nan",1.5]}`,
+		},
+	}
+	for name, test := range tests {
+		j, err := YAMLToJSONWithOptions([]byte(test.yaml), test.opts...)
+		if test.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), test.err) {
+				t.Errorf("%s: expected error %q, got %v", name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if string(j) != test.expected {
+			t.Errorf("%s: expected %s, got %s", name, test.expected, j)
+		}
+	}
+
+	_, err := YAMLToJSONWithOptions([]byte("a: 1\na: 2\n"), DisallowDuplicateKeys)
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) || len(dupErr.Keys) != 1 || dupErr.Keys[0].Line != 2 {
+		t.Errorf("expected a DuplicateKeyError, got %v", err)
+	}
+
+	var warnings []string
+	j, err := YAMLToJSONWithOptions([]byte("a: 1\na: 2\n"), WarnDuplicateKeys(func(w string) {
+		warnings = append(warnings, w)
+	}))
+	if err != nil || string(j) != `{"a":2}` {
+		t.Errorf("unexpected result %s, %v", j, err)
+	}
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "line 2: ") {
+		t.Errorf("unexpected warnings %q", warnings)
+	}
+}
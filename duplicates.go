@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"strconv"
+
+	yamlv3 "sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+// A DuplicateKey describes a mapping key that is repeated in a document.
+type DuplicateKey struct {
+	// Key holds the text of the key.
+	Key string
+
+	// Path holds the location of the repeated key in the document, such
+	// as "spec.containers[0].name".
+	Path string
+
+	// Line and Column hold the position of the repeated key, and
+	// FirstLine and FirstColumn the position of the key it repeats.
+	Line        int
+	Column      int
+	FirstLine   int
+	FirstColumn int
+}
+
+// A DuplicateKeyError is returned by YAMLToJSONStrict, and by
+// YAMLToJSONWithOptions given DisallowDuplicateKeys, when mapping keys are
+// repeated, listing them in document order. Its message is the one of
+// the error it holds, as reported by the YAML parser.
+type DuplicateKeyError struct {
+	Keys []DuplicateKey
+	err  error
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error reported by the YAML parser.
+func (e *DuplicateKeyError) Unwrap() error {
+	return e.err
+}
+
+// findDuplicateKeys returns the keys repeated in the mappings of the first
+// document of y, or nil if it can't be parsed.
+func findDuplicateKeys(y []byte) []DuplicateKey {
+	var keys []DuplicateKey
+	seen := make(map[*yamlv3.Node]map[string]*yamlv3.Node)
+	walkKeys(y, func(m, k *yamlv3.Node, path string) {
+		if k.Kind != yamlv3.ScalarNode {
+			return
+		}
+		if seen[m] == nil {
+			seen[m] = make(map[string]*yamlv3.Node)
+		}
+		if first, ok := seen[m][k.Value]; ok {
+			keys = append(keys, DuplicateKey{
+				Key:         k.Value,
+				Path:        path,
+				Line:        k.Line,
+				Column:      k.Column,
+				FirstLine:   first.Line,
+				FirstColumn: first.Column,
+			})
+		} else {
+			seen[m][k.Value] = k
+		}
+	})
+	return keys
+}
+
+// walkKeys calls fn, in document order, with each key of the mappings of
+// the first document of y, the mapping holding it and its path. It
+// returns false if y can't be parsed.
+func walkKeys(y []byte, fn func(m, k *yamlv3.Node, path string)) bool {
+	var doc yamlv3.Node
+	if yamlv3.Unmarshal(y, &doc) != nil {
+		return false
+	}
+	var walk func(n *yamlv3.Node, path string)
+	walk = func(n *yamlv3.Node, path string) {
+		switch n.Kind {
+		case yamlv3.DocumentNode:
+			for _, c := range n.Content {
+				walk(c, path)
+			}
+		case yamlv3.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				k := n.Content[i]
+				keyPath := k.Value
+				if path != "" {
+					keyPath = path + "." + k.Value
+				}
+				fn(n, k, keyPath)
+				walk(n.Content[i+1], keyPath)
+			}
+		case yamlv3.SequenceNode:
+			for i, c := range n.Content {
+				walk(c, path+"["+strconv.Itoa(i)+"]")
+			}
+		}
+	}
+	walk(&doc, "")
+	return true
+}
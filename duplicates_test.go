@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestYAMLToJSONStrictDuplicateKeys(t *testing.T) {
+	y := []byte(`name: a
+spec:
+  containers:
+  - name: web
+    image: nginx
+    name: api
+name: b
+`)
+	_, err := YAMLToJSONStrict(y)
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("expected a DuplicateKeyError, got %v", err)
+	}
+	expected := []DuplicateKey{
+		{Key: "name", Path: "spec.containers[0].name", Line: 6, Column: 5, FirstLine: 4, FirstColumn: 5},
+		{Key: "name", Path: "name", Line: 7, Column: 1, FirstLine: 1, FirstColumn: 1},
+	}
+	if !reflect.DeepEqual(dupErr.Keys, expected) {
+		t.Errorf("expected keys %+v, got %+v", expected, dupErr.Keys)
+	}
+	if err.Error() != errors.Unwrap(err).Error() {
+		t.Errorf("unexpected message %q", err)
+	}
+
+	// Other errors are returned as before.
+	_, err = YAMLToJSONStrict([]byte("a: [1"))
+	if err == nil || errors.As(err, &dupErr) {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+}
@@ -0,0 +1,445 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+
+	yamlv3 "sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+// Document is a document of a YAML stream read by a DocumentFilter,
+// along with the fields of the Kubernetes object it holds.
+type Document struct {
+	// Raw holds the original text of the document, starting with its
+	// "---" line, if any. Concatenating the Raw text of documents gives
+	// a valid YAML stream.
+	Raw []byte
+
+	// Index holds the position of the document in the stream, counting
+	// from zero and including the documents that didn't match.
+	Index int
+
+	// Line holds the line of the stream where Raw starts.
+	Line int
+
+	APIVersion string
+	Kind       string
+	Name       string
+	Namespace  string
+	Labels     map[string]string
+}
+
+// A DocumentPredicate reports whether a document should be selected.
+type DocumentPredicate func(doc *Document) bool
+
+// MatchKind returns a predicate that selects documents of any of the
+// given kinds.
+func MatchKind(kinds ...string) DocumentPredicate {
+	return func(doc *Document) bool {
+		return containsString(kinds, doc.Kind)
+	}
+}
+
+// MatchName returns a predicate that selects documents with any of the
+// given names.
+func MatchName(names ...string) DocumentPredicate {
+	return func(doc *Document) bool {
+		return containsString(names, doc.Name)
+	}
+}
+
+// MatchNamespace returns a predicate that selects documents in any of the
+// given namespaces.
+func MatchNamespace(namespaces ...string) DocumentPredicate {
+	return func(doc *Document) bool {
+		return containsString(namespaces, doc.Namespace)
+	}
+}
+
+// MatchLabels returns a predicate that selects documents with all of the
+// given labels, as an equality-based label selector does.
+func MatchLabels(labels map[string]string) DocumentPredicate {
+	return func(doc *Document) bool {
+		for k, v := range labels {
+			if l, ok := doc.Labels[k]; !ok || l != v {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAll returns a predicate that selects documents selected by all of
+// the given predicates.
+func MatchAll(predicates ...DocumentPredicate) DocumentPredicate {
+	return func(doc *Document) bool {
+		for _, p := range predicates {
+			if !p(doc) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// A DocumentFilter reads the documents of a YAML stream, such as a set of
+// Kubernetes manifests, and returns those selected by a predicate with
+// their original text, so that they can be written back without being
+// encoded again. Documents without content are skipped.
+type DocumentFilter struct {
+	r     *bufio.Reader
+	match DocumentPredicate
+	index int
+	line  int
+	next  []byte
+}
+
+// NewDocumentFilter returns a filter of the documents read from r. A nil
+// match selects every document.
+func NewDocumentFilter(r io.Reader, match DocumentPredicate) *DocumentFilter {
+	return &DocumentFilter{r: bufio.NewReader(r), match: match}
+}
+
+// Next returns the next selected document, or io.EOF once the stream
+// has been read. Documents are parsed to read the fields of the objects
+// they hold, and a syntax error in one of them is returned as an error.
+// Fields that aren't strings are ignored.
+func (f *DocumentFilter) Next() (*Document, error) {
+	for {
+		raw, err := f.read()
+		if err != nil {
+			return nil, err
+		}
+		index, line := f.index, f.line+1
+		f.index++
+		f.line += bytes.Count(raw, []byte("\n"))
+		var obj struct {
+			APIVersion interface{} `yaml:"apiVersion"`
+			Kind       interface{} `yaml:"kind"`
+			Metadata   struct {
+				Name      interface{}            `yaml:"name"`
+				Namespace interface{}            `yaml:"namespace"`
+				Labels    map[string]interface{} `yaml:"labels"`
+			} `yaml:"metadata"`
+		}
+		var node yamlv3.Node
+		if err := yamlv3.Unmarshal(raw, &node); err != nil {
+			return nil, fmt.Errorf("document %d: %v", index, err)
+		}
+		if len(node.Content) == 0 || node.Content[0].ShortTag() == "!!null" {
+			continue
+		}
+		// Type errors leave the fields they concern unset.
+		_ = node.Decode(&obj)
+		doc := &Document{
+			Raw:        raw,
+			Index:      index,
+			Line:       line,
+			APIVersion: stringOf(obj.APIVersion),
+			Kind:       stringOf(obj.Kind),
+			Name:       stringOf(obj.Metadata.Name),
+			Namespace:  stringOf(obj.Metadata.Namespace),
+		}
+		for k, v := range obj.Metadata.Labels {
+			if s, ok := v.(string); ok {
+				if doc.Labels == nil {
+					doc.Labels = make(map[string]string)
+				}
+				doc.Labels[k] = s
+			}
+		}
+		if f.match == nil || f.match(doc) {
+			return doc, nil
+		}
+	}
+}
+
+func stringOf(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// read returns the text of the next document, up to the next line that
+// starts with a "---" document marker, other than the one following the
+// directives of the document, or up to and including the next line that
+// starts with a "..." document end marker, neither of which can appear in
+// content.
+func (f *DocumentFilter) read() ([]byte, error) {
+	doc := f.next
+	f.next = nil
+	for {
+		line, err := f.r.ReadBytes('\n')
+		if len(line) > 0 && isDocumentStart(line) && len(doc) > 0 {
+			// The marker after directives starts their document.
+			if start, directives := documentPrefix(doc); start || !directives {
+				f.next = line
+				return doc, nil
+			}
+		}
+		doc = append(doc, line...)
+		if isDocumentEnd(line) && err == nil {
+			return doc, nil
+		}
+		if err == io.EOF {
+			if len(doc) == 0 {
+				return nil, io.EOF
+			}
+			return doc, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func isDocumentStart(line []byte) bool {
+	return isMarker(line, "---")
+}
+
+func isDocumentEnd(line []byte) bool {
+	return isMarker(line, "...")
+}
+
+func isMarker(line []byte, marker string) bool {
+	if !bytes.HasPrefix(line, []byte(marker)) {
+		return false
+	}
+	return len(line) == 3 || line[3] == ' ' || line[3] == '\t' || line[3] == '\r' || line[3] == '\n'
+}
+
+// documentPrefix reports whether the document text raw starts with a
+// "---" marker, and whether it has directives before it, past the blank
+// and comment lines that may come first.
+func documentPrefix(raw []byte) (start, directives bool) {
+	for len(raw) > 0 {
+		line := raw
+		if i := bytes.IndexByte(raw, '\n'); i >= 0 {
+			line, raw = raw[:i+1], raw[i+1:]
+		} else {
+			raw = nil
+		}
+		switch trimmed := bytes.TrimSpace(line); {
+		case isDocumentStart(line):
+			return true, directives
+		case line[0] == '%':
+			directives = true
+		case len(trimmed) > 0 && trimmed[0] != '#':
+			return false, directives
+		}
+	}
+	return false, directives
+}
+
+// SortDocuments returns the documents of the YAML stream data in the
+// order set by less, keeping the order of the documents that compare
+// equal, so that the result is deterministic. The text of each document
+// is kept as is, except for the "---" line added before documents that
+// don't start with one once they no longer come first. Documents without
+// content are dropped.
+func SortDocuments(data []byte, less func(a, b *Document) bool) ([]byte, error) {
+	var docs []*Document
+	f := NewDocumentFilter(bytes.NewReader(data), nil)
+	for {
+		doc, err := f.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		return less(docs[i], docs[j])
+	})
+	return joinDocuments(docs), nil
+}
+
+// joinDocuments returns the text of a stream made of docs, adding the
+// "---" line of documents that don't start with one, and the "..." line
+// that must end the document before one with directives.
+func joinDocuments(docs []*Document) []byte {
+	var out []byte
+	ended := false
+	for i, doc := range docs {
+		if len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+		if start, directives := documentPrefix(doc.Raw); i > 0 && directives && !ended {
+			out = append(out, "...\n"...)
+		} else if i > 0 && !start {
+			out = append(out, "---\n"...)
+		}
+		out = append(out, doc.Raw...)
+		ended = endsDocument(doc.Raw)
+	}
+	return out
+}
+
+// endsDocument reports whether the document text raw ends with a "..."
+// document end marker.
+func endsDocument(raw []byte) bool {
+	raw = bytes.TrimRight(raw, "\r\n")
+	return isDocumentEnd(raw[bytes.LastIndexByte(raw, '\n')+1:])
+}
+
+// A Duplicate describes a document of a stream with the same content as
+// an earlier one. Documents have the same content if they are equal once
+// converted to JSON, whatever their layout, comments and the order of
+// their mapping keys.
+type Duplicate struct {
+	// Index and Line hold the position of the duplicate document, as in
+	// Document.
+	Index int
+	Line  int
+
+	// OriginalIndex and OriginalLine hold the position of the first
+	// document with the same content.
+	OriginalIndex int
+	OriginalLine  int
+}
+
+// FindDuplicates returns the documents of the YAML stream data that have
+// the same content as an earlier one, in stream order.
+func FindDuplicates(data []byte) ([]Duplicate, error) {
+	_, dups, err := findDuplicates(data)
+	return dups, err
+}
+
+// DropDuplicates returns the YAML stream data without the documents that
+// have the same content as an earlier one, along with those documents.
+// The text of the documents that are kept is left as is, as done by
+// SortDocuments.
+func DropDuplicates(data []byte) ([]byte, []Duplicate, error) {
+	docs, dups, err := findDuplicates(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return joinDocuments(docs), dups, nil
+}
+
+// findDuplicates returns the documents of data without duplicates, and
+// the duplicates.
+func findDuplicates(data []byte) ([]*Document, []Duplicate, error) {
+	var docs []*Document
+	var dups []Duplicate
+	seen := make(map[[sha256.Size]byte]*Document)
+	f := NewDocumentFilter(bytes.NewReader(data), nil)
+	for {
+		doc, err := f.Next()
+		if err == io.EOF {
+			return docs, dups, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		j, err := YAMLToJSON(doc.Raw)
+		if err != nil {
+			return nil, nil, fmt.Errorf("document %d: %v", doc.Index, err)
+		}
+		// JSON objects are written with sorted keys.
+		sum := sha256.Sum256(j)
+		if orig, ok := seen[sum]; ok {
+			dups = append(dups, Duplicate{
+				Index:         doc.Index,
+				Line:          doc.Line,
+				OriginalIndex: orig.Index,
+				OriginalLine:  orig.Line,
+			})
+			continue
+		}
+		seen[sum] = doc
+		docs = append(docs, doc)
+	}
+}
+
+// installOrder holds the kinds of Kubernetes objects in the order they
+// are best created in, so that objects are created after those they
+// depend on.
+var installOrder = []string{
+	"Namespace",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"CustomResourceDefinition",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+}
+
+// InstallOrder is a less function for SortDocuments that orders objects
+// by kind, so that namespaces, service accounts, secrets, custom resource
+// definitions and the like come before the workloads that use them, then
+// by namespace and name. Unknown kinds come last, in alphabetical order.
+func InstallOrder(a, b *Document) bool {
+	ka, kb := kindRank(a.Kind), kindRank(b.Kind)
+	switch {
+	case ka != kb:
+		return ka < kb
+	case a.Kind != b.Kind:
+		return a.Kind < b.Kind
+	case a.Namespace != b.Namespace:
+		return a.Namespace < b.Namespace
+	}
+	return a.Name < b.Name
+}
+
+func kindRank(kind string) int {
+	for i, k := range installOrder {
+		if k == kind {
+			return i
+		}
+	}
+	return len(installOrder)
+}
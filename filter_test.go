@@ -0,0 +1,275 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const filterStream = `# Manifests for the web app.
+apiVersion: v1
+kind: Service
+metadata:
+  name:   web      # oddly spaced
+  labels: {app: web, tier: frontend}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+---
+# Nothing here.
+--- !!map
+apiVersion: v1
+kind: ConfigMap
+metadata: {name: settings, labels: {app: web, tier: [1]}}
+data:
+  text: |
+    --not a separator
+...
+`
+
+func TestDocumentFilter(t *testing.T) {
+	tests := map[string]struct {
+		match DocumentPredicate
+		index []int
+	}{
+		"all":       {nil, []int{0, 1, 3}},
+		"kind":      {MatchKind("Deployment", "ConfigMap"), []int{1, 3}},
+		"name":      {MatchName("web"), []int{0, 1}},
+		"namespace": {MatchNamespace("prod"), []int{1}},
+		"labels":    {MatchLabels(map[string]string{"app": "web", "tier": "frontend"}), []int{0}},
+		"all of":    {MatchAll(MatchName("web"), MatchLabels(map[string]string{"app": "web"}), MatchKind("Service")), []int{0}},
+	}
+	for name, test := range tests {
+		f := NewDocumentFilter(strings.NewReader(filterStream), test.match)
+		var index []int
+		var raw string
+		for {
+			doc, err := f.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", name, err)
+			}
+			index = append(index, doc.Index)
+			raw += string(doc.Raw)
+		}
+		if len(index) != len(test.index) {
+			t.Errorf("%s: expected documents %v, got %v", name, test.index, index)
+			continue
+		}
+		for i := range index {
+			if index[i] != test.index[i] {
+				t.Errorf("%s: expected documents %v, got %v", name, test.index, index)
+				break
+			}
+		}
+		if name == "all" && raw != strings.Replace(filterStream, "---\n# Nothing here.\n", "", 1) {
+			t.Errorf("%s: unexpected text:\n%s", name, raw)
+		}
+	}
+
+	f := NewDocumentFilter(strings.NewReader(filterStream), MatchKind("ConfigMap"))
+	doc, err := f.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.APIVersion != "v1" || doc.Name != "settings" || len(doc.Labels) != 1 || doc.Labels["app"] != "web" {
+		t.Errorf("unexpected fields: %+v", doc)
+	}
+	if !strings.HasPrefix(string(doc.Raw), "--- !!map\n") || !strings.HasSuffix(string(doc.Raw), "...\n") {
+		t.Errorf("unexpected text: %q", doc.Raw)
+	}
+	if _, err := f.Next(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+
+	f = NewDocumentFilter(strings.NewReader("kind: A\n---\nkind: [B\n"), nil)
+	if _, err := f.Next(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Next(); err == nil || !strings.HasPrefix(err.Error(), "document 1: yaml: ") {
+		t.Errorf("expected a syntax error in document 1, got %v", err)
+	}
+}
+
+func TestDocumentFilterEndMarker(t *testing.T) {
+	data := "kind: A\n...\nkind: B\n... # end\n%YAML 1.2\n---\nkind: C\n"
+	f := NewDocumentFilter(strings.NewReader(data), nil)
+	var kinds, raw []string
+	var lines []int
+	for {
+		doc, err := f.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		kinds = append(kinds, doc.Kind)
+		raw = append(raw, string(doc.Raw))
+		lines = append(lines, doc.Line)
+	}
+	expected := []string{"kind: A\n...\n", "kind: B\n... # end\n", "%YAML 1.2\n---\nkind: C\n"}
+	if !reflect.DeepEqual(raw, expected) {
+		t.Errorf("expected documents %q, got %q", expected, raw)
+	}
+	if !reflect.DeepEqual(kinds, []string{"A", "B", "C"}) || !reflect.DeepEqual(lines, []int{1, 3, 5}) {
+		t.Errorf("unexpected kinds %v or lines %v", kinds, lines)
+	}
+
+	// Documents keep their end markers when sorted, and the document with
+	// directives is still preceded by one.
+	out, err := SortDocuments([]byte("%YAML 1.2\n---\nkind: B\n...\nkind: A\n"), func(a, b *Document) bool {
+		return a.Kind > b.Kind
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "%YAML 1.2\n---\nkind: B\n...\n---\nkind: A\n" {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+	out, err = SortDocuments([]byte("%YAML 1.2\n---\nkind: B\n...\nkind: A\n"), func(a, b *Document) bool {
+		return a.Kind < b.Kind
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "kind: A\n...\n%YAML 1.2\n---\nkind: B\n...\n" {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestSortDocuments(t *testing.T) {
+	data := `kind: Deployment
+metadata: {name: web, namespace: prod}
+---
+kind: Widget
+metadata: {name: a}
+---
+# The namespace.
+kind: Namespace
+metadata: {name: prod}
+--- # second deployment
+kind: Deployment
+metadata: {name: api, namespace: prod}
+---
+kind: Gadget
+---
+kind: ServiceAccount
+metadata: {name: web, namespace: prod}`
+	out, err := SortDocuments([]byte(data), InstallOrder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `---
+# The namespace.
+kind: Namespace
+metadata: {name: prod}
+---
+kind: ServiceAccount
+metadata: {name: web, namespace: prod}
+--- # second deployment
+kind: Deployment
+metadata: {name: api, namespace: prod}
+---
+kind: Deployment
+metadata: {name: web, namespace: prod}
+---
+kind: Gadget
+---
+kind: Widget
+metadata: {name: a}
+`
+	if string(out) != expected {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	// Documents that compare equal keep their order.
+	out, err = SortDocuments([]byte(data), func(a, b *Document) bool { return false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != data {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+}
+
+func TestDropDuplicates(t *testing.T) {
+	data := `kind: ConfigMap
+metadata: {name: a}
+data: {x: "1", y: "2"}
+---
+kind: ConfigMap
+metadata: {name: b}
+--- # same as the first one
+kind: ConfigMap
+data:
+  y: "2"
+  x: "1"
+metadata:
+  name: a
+---
+kind: ConfigMap
+metadata: {name: a}
+data: {x: 1, y: "2"}
+---
+kind: ConfigMap
+metadata: {name: b}
+`
+	out, dups, err := DropDuplicates([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Duplicate{
+		{Index: 2, Line: 7, OriginalIndex: 0, OriginalLine: 1},
+		{Index: 4, Line: 18, OriginalIndex: 1, OriginalLine: 4},
+	}
+	if !reflect.DeepEqual(dups, expected) {
+		t.Errorf("expected duplicates %+v, got %+v", expected, dups)
+	}
+	expectedOut := `kind: ConfigMap
+metadata: {name: a}
+data: {x: "1", y: "2"}
+---
+kind: ConfigMap
+metadata: {name: b}
+---
+kind: ConfigMap
+metadata: {name: a}
+data: {x: 1, y: "2"}
+`
+	if string(out) != expectedOut {
+		t.Errorf("unexpected output:\n%s", out)
+	}
+
+	found, err := FindDuplicates([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected duplicates %+v, got %+v", expected, found)
+	}
+}
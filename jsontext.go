@@ -0,0 +1,222 @@
+// This file contains changes that are only compatible with the
+// encoding/json/v2 experiment, enabled with GOEXPERIMENT=jsonv2.
+
+//go:build goexperiment.jsonv2
+// +build goexperiment.jsonv2
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/json/jsontext"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+// YAMLToJSONValue is like YAMLToJSON, but returns the JSON as a
+// jsontext.Value.
+func YAMLToJSONValue(y []byte) (jsontext.Value, error) {
+	var buf bytes.Buffer
+	// Strings are escaped as by json.Marshal.
+	enc := jsontext.NewEncoder(&buf, jsontext.EscapeForHTML(true), jsontext.EscapeForJS(true))
+	if err := WriteJSONTokens(enc, y); err != nil {
+		return nil, err
+	}
+	// The encoder ends top-level values with a newline.
+	return jsontext.Value(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+}
+
+// WriteJSONTokens converts the YAML y to JSON as YAMLToJSON does, and
+// writes it to enc token by token, rather than as a JSON text that would
+// have to be parsed again. Strings are escaped as set by the options of
+// enc. Nothing is written if y can't be converted.
+func WriteJSONTokens(enc *jsontext.Encoder, y []byte) error {
+	jsonObj, err := yamlToJSONObject(y, nil, yaml.Unmarshal, nil)
+	if err != nil {
+		return err
+	}
+	// Check the value before writing any of it, so that enc isn't left
+	// in the middle of a value.
+	if err := checkJSONTokens(jsonObj); err != nil {
+		return fmt.Errorf("error converting YAML to JSON: %w", err)
+	}
+	return writeJSONTokens(enc, jsonObj)
+}
+
+// checkJSONTokens returns the error encoding/json would report for the
+// numbers of v that JSON can't hold.
+func checkJSONTokens(v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, item := range v {
+			if err := checkJSONTokens(item); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := checkJSONTokens(item); err != nil {
+				return err
+			}
+		}
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return &json.UnsupportedValueError{Str: strconv.FormatFloat(v, 'g', -1, 64)}
+		}
+	}
+	return nil
+}
+
+// writeJSONTokens writes v, as returned by convertToJSONableObject, to
+// enc. Object members are written ordered by name, as by json.Marshal.
+func writeJSONTokens(enc *jsontext.Encoder, v interface{}) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if err := enc.WriteToken(jsontext.BeginObject); err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if err := enc.WriteToken(jsontext.String(k)); err != nil {
+				return err
+			}
+			if err := writeJSONTokens(enc, v[k]); err != nil {
+				return err
+			}
+		}
+		return enc.WriteToken(jsontext.EndObject)
+	case []interface{}:
+		if err := enc.WriteToken(jsontext.BeginArray); err != nil {
+			return err
+		}
+		for _, item := range v {
+			if err := writeJSONTokens(enc, item); err != nil {
+				return err
+			}
+		}
+		return enc.WriteToken(jsontext.EndArray)
+	case nil:
+		return enc.WriteToken(jsontext.Null)
+	case bool:
+		return enc.WriteToken(jsontext.Bool(v))
+	case string:
+		return enc.WriteToken(jsontext.String(v))
+	case int:
+		return enc.WriteToken(jsontext.Int(int64(v)))
+	case int64:
+		return enc.WriteToken(jsontext.Int(v))
+	case uint64:
+		return enc.WriteToken(jsontext.Uint(v))
+	case float64:
+		return enc.WriteToken(jsontext.Float(v))
+	default:
+		j, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		return enc.WriteValue(j)
+	}
+}
+
+// JSONValueToYAML is like JSONToYAML, but takes the JSON as a
+// jsontext.Value.
+func JSONValueToYAML(v jsontext.Value) ([]byte, error) {
+	return JSONToYAML(v)
+}
+
+// ReadJSONTokens reads the next JSON value from dec token by token, and
+// converts it to YAML as JSONToYAML does.
+func ReadJSONTokens(dec *jsontext.Decoder) ([]byte, error) {
+	jsonObj, err := readJSONTokens(dec)
+	if err != nil {
+		return nil, fmt.Errorf("error converting JSON to YAML: %w", err)
+	}
+	yamlBytes, err := yaml.Marshal(jsonObj)
+	if err != nil {
+		return nil, fmt.Errorf("error converting JSON to YAML: %w", err)
+	}
+	return yamlBytes, nil
+}
+
+// readJSONTokens reads the next JSON value from dec into the value
+// yaml.Unmarshal would decode it into.
+func readJSONTokens(dec *jsontext.Decoder) (interface{}, error) {
+	tok, err := dec.ReadToken()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	switch tok.Kind() {
+	case jsontext.KindBeginObject:
+		m := make(map[interface{}]interface{})
+		for dec.PeekKind() != jsontext.KindEndObject {
+			name, err := dec.ReadToken()
+			if err != nil {
+				return nil, err
+			}
+			// The token is only valid until the next read.
+			key := name.String()
+			value, err := readJSONTokens(dec)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = value
+		}
+		_, err := dec.ReadToken()
+		return m, err
+	case jsontext.KindBeginArray:
+		s := []interface{}{}
+		for dec.PeekKind() != jsontext.KindEndArray {
+			item, err := readJSONTokens(dec)
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, item)
+		}
+		_, err := dec.ReadToken()
+		return s, err
+	case jsontext.KindNull:
+		return nil, nil
+	case jsontext.KindFalse, jsontext.KindTrue:
+		return tok.Bool(), nil
+	case jsontext.KindNumber:
+		s := tok.String()
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, nil
+		}
+		if n, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return n, nil
+		}
+		return strconv.ParseFloat(s, 64)
+	default:
+		return tok.String(), nil
+	}
+}
@@ -0,0 +1,106 @@
+//go:build goexperiment.jsonv2
+// +build goexperiment.jsonv2
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"bytes"
+	"encoding/json/jsontext"
+	"strings"
+	"testing"
+)
+
+func TestYAMLToJSONValue(t *testing.T) {
+	for _, y := range []string{
+		"",
+		"a: 1\nb: [x, 2.5, -3, 18446744073709551615, true, null]\nc: {d: 1e-7, \"e\\\"\": \"<&>\"}\n",
+		"1: one\n2.5: two\nfalse: three\n",
+		"- 1.0\n- 1e21\n- 123456789012345678\n",
+	} {
+		expected, err := YAMLToJSON([]byte(y))
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := YAMLToJSONValue([]byte(y))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(v) != string(expected) {
+			t.Errorf("for %q, expected %s, got %s", y, expected, v)
+		}
+	}
+
+	// Values that JSON can't hold fail before anything is written.
+	var buf bytes.Buffer
+	enc := jsontext.NewEncoder(&buf)
+	if err := WriteJSONTokens(enc, []byte("a: [1, .nan]")); err == nil {
+		t.Error("expected an error for .nan")
+	}
+	if err := WriteJSONTokens(enc, []byte("a: [1")); err == nil {
+		t.Error("expected a syntax error")
+	}
+	if buf.Len() != 0 || enc.StackDepth() != 0 {
+		t.Errorf("expected nothing to be written, got %q", buf.String())
+	}
+
+	// Documents are written as a stream of values.
+	for _, y := range []string{"a: 1", "- b"} {
+		if err := WriteJSONTokens(enc, []byte(y)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buf.String() != "{\"a\":1}\n[\"b\"]\n" {
+		t.Errorf("unexpected stream %q", buf.String())
+	}
+}
+
+func TestReadJSONTokens(t *testing.T) {
+	stream := `{"b": [1, 2.5, 1e3, -0, 18446744073709551615, "yes", true, null], "a": {"c": {}}}
+[]
+"x"`
+	dec := jsontext.NewDecoder(strings.NewReader(stream))
+	for _, j := range strings.Split(stream, "\n") {
+		expected, err := JSONToYAML([]byte(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+		y, err := ReadJSONTokens(dec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(y) != string(expected) {
+			t.Errorf("for %s, expected %q, got %q", j, expected, y)
+		}
+		y, err = JSONValueToYAML(jsontext.Value(j))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(y) != string(expected) {
+			t.Errorf("for %s, expected %q, got %q", j, expected, y)
+		}
+	}
+	if _, err := ReadJSONTokens(dec); err == nil {
+		t.Error("expected an error at the end of the stream")
+	}
+
+	dec = jsontext.NewDecoder(strings.NewReader(`{"a": [1`))
+	if _, err := ReadJSONTokens(dec); err == nil {
+		t.Error("expected an error for a truncated value")
+	}
+}
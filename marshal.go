@@ -0,0 +1,461 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+// A MarshalOpt is an option of MarshalWithOptions and JSONToYAMLWithOptions.
+type MarshalOpt func(*marshalOptions)
+
+type marshalOptions struct {
+	orderedKeys      bool
+	omitEmpty        bool
+	omitEmptyObjects bool
+	collections      CollectionStyle
+}
+
+// OrderedKeys keeps the keys of each JSON object in the order they are
+// written in, instead of sorting them alphabetically. With Marshal, struct
+// fields then come in declaration order, while the keys of Go maps are
+// still sorted, as json.Marshal writes them.
+func OrderedKeys(o *marshalOptions) {
+	o.orderedKeys = true
+}
+
+// OmitEmpty leaves out the struct fields with empty values, as if they all
+// had the omitempty option: false, 0, null, empty strings, and empty
+// arrays and maps. The entries of Go maps are kept. With
+// JSONToYAMLWithOptions, which has no Go types to go by, the empty values
+// of every object are left out. The values that are written by a
+// json.Marshaler, or held by an interface, are left as is, as their
+// content isn't made of struct fields.
+func OmitEmpty(o *marshalOptions) {
+	o.omitEmpty = true
+}
+
+// OmitEmptyObjects is like OmitEmpty, but also leaves out the struct
+// fields whose value is an object without keys, such as a struct whose
+// fields were all left out, so that nothing is written for an empty
+// nested object.
+func OmitEmptyObjects(o *marshalOptions) {
+	o.omitEmpty = true
+	o.omitEmptyObjects = true
+}
+
+// A CollectionStyle tells how nil and empty maps and slices are written.
+// See Collections.
+type CollectionStyle int
+
+const (
+	// CollectionsAsIs writes nil maps and slices as null, and empty ones
+	// as {} and [], as Marshal and JSONToYAML do.
+	CollectionsAsIs CollectionStyle = iota
+
+	// CollectionsAsEmpty writes nil maps and slices as {} and [].
+	CollectionsAsEmpty
+
+	// CollectionsAsNull writes empty maps and slices as null.
+	CollectionsAsNull
+
+	// CollectionsOmitted leaves out the object keys holding nil or empty
+	// maps and slices. Array items are written as with CollectionsAsIs.
+	CollectionsOmitted
+)
+
+// Collections sets how nil and empty maps and slices are written, for all
+// of them rather than as set by the omitempty option of struct fields,
+// which still applies. Marshal writes nil maps and slices as null, which
+// JSON can't tell from a nil pointer: with JSONToYAMLWithOptions, a null
+// is only taken as a nil collection by CollectionsOmitted, while empty
+// objects, which are empty structs as often as maps, are taken as empty
+// maps.
+func Collections(style CollectionStyle) MarshalOpt {
+	return func(o *marshalOptions) {
+		o.collections = style
+	}
+}
+
+// MarshalWithOptions is like Marshal, but lets the options control how the
+// YAML is written.
+func MarshalWithOptions(obj interface{}, opts ...MarshalOpt) ([]byte, error) {
+	jsonBytes, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling into JSON: %w", err)
+	}
+
+	o := newMarshalOptions(opts)
+	if o == (marshalOptions{}) {
+		return JSONToYAML(jsonBytes)
+	}
+	return jsonToYAML(jsonBytes, &jsonType{t: reflect.TypeOf(obj)}, &o)
+}
+
+// JSONToYAMLWithOptions is like JSONToYAML, but lets the options control
+// how the YAML is written.
+func JSONToYAMLWithOptions(j []byte, opts ...MarshalOpt) ([]byte, error) {
+	o := newMarshalOptions(opts)
+	if o == (marshalOptions{}) {
+		return JSONToYAML(j)
+	}
+	return jsonToYAML(j, nil, &o)
+}
+
+func newMarshalOptions(opts []MarshalOpt) marshalOptions {
+	var o marshalOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// jsonToYAML converts j to YAML as requested by o. The type of the value
+// j was marshaled from, if known, tells which objects are structs.
+func jsonToYAML(j []byte, t *jsonType, o *marshalOptions) ([]byte, error) {
+	var jsonObj interface{}
+	var err error
+	if o.orderedKeys {
+		jsonObj, err = decodeOrderedJSON(j)
+	} else {
+		// See JSONToYAML for why yaml.Unmarshal is used.
+		err = yaml.Unmarshal(j, &jsonObj)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error converting JSON to YAML: %w", err)
+	}
+	if o.collections != CollectionsAsIs {
+		jsonObj = renderCollections(jsonObj, t, o)
+	}
+	if o.omitEmpty {
+		jsonObj = omitEmpty(jsonObj, t, o)
+	}
+	yamlBytes, err := yaml.Marshal(jsonObj)
+	if err != nil {
+		return nil, fmt.Errorf("error converting JSON to YAML: %w", err)
+	}
+	return yamlBytes, nil
+}
+
+// decodeOrderedJSON decodes the JSON value j with its objects as
+// yaml.MapSlice values, which keep the order of their keys, and its
+// integers as int64 or uint64 values, as JSONToYAML does.
+func decodeOrderedJSON(j []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.UseNumber()
+	v, err := decodeOrderedValue(d)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("invalid JSON: unexpected data after the top-level value")
+	}
+	return v, nil
+}
+
+func decodeOrderedValue(d *json.Decoder) (interface{}, error) {
+	tok, err := d.Token()
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			m := yaml.MapSlice{}
+			index := map[json.Token]int{}
+			for d.More() {
+				key, err := d.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeOrderedValue(d)
+				if err != nil {
+					return nil, err
+				}
+				// As with encoding/json, the last of duplicate keys
+				// wins, but it keeps the position of the first.
+				if i, ok := index[key]; ok {
+					m[i].Value = value
+					continue
+				}
+				index[key] = len(m)
+				m = append(m, yaml.MapItem{Key: key, Value: value})
+			}
+			_, err := d.Token()
+			return m, err
+		case '[':
+			s := []interface{}{}
+			for d.More() {
+				value, err := decodeOrderedValue(d)
+				if err != nil {
+					return nil, err
+				}
+				s = append(s, value)
+			}
+			_, err := d.Token()
+			return s, err
+		}
+		return nil, fmt.Errorf("invalid JSON: unexpected %v", tok)
+	case json.Number:
+		if i, err := tok.Int64(); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(tok.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		return tok.Float64()
+	}
+	return tok, nil
+}
+
+// A jsonType is the Go type a JSON value was marshaled from, which tells
+// the struct fields of its objects from the entries of Go maps. A nil
+// jsonType stands for an unknown type, as with JSONToYAMLWithOptions,
+// whose objects are all taken as structs, while a nil t stands for a value
+// whose content isn't made of struct fields and map entries, which is
+// left as is.
+type jsonType struct {
+	t reflect.Type
+}
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// resolve returns the type p without pointers, or a nil type if the JSON
+// for p isn't written from its struct fields and map entries.
+func (p *jsonType) resolve() *jsonType {
+	if p == nil || p.t == nil {
+		return p
+	}
+	t := p.t
+	for {
+		if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) ||
+			reflect.PtrTo(t).Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+			return &jsonType{}
+		}
+		if t.Kind() != reflect.Ptr {
+			break
+		}
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Interface {
+		return &jsonType{}
+	}
+	return &jsonType{t: t}
+}
+
+// kind returns the kind of the resolved type p, or reflect.Invalid if it's
+// not known.
+func (p *jsonType) kind() reflect.Kind {
+	if p == nil || p.t == nil {
+		return reflect.Invalid
+	}
+	return p.t.Kind()
+}
+
+// key returns the type of the value of the key in an object of the
+// resolved type p, and whether the key is a struct field.
+func (p *jsonType) key(key interface{}) (*jsonType, bool) {
+	switch p.kind() {
+	case reflect.Invalid:
+		return nil, true
+	case reflect.Map:
+		return &jsonType{t: p.t.Elem()}, false
+	case reflect.Struct:
+		name, _ := key.(string)
+		for _, f := range cachedTypeFields(p.t) {
+			if f.name == name {
+				return &jsonType{t: f.typ}, true
+			}
+		}
+	}
+	return &jsonType{}, false
+}
+
+// item returns the type of the items of an array of the resolved type p.
+func (p *jsonType) item() *jsonType {
+	switch p.kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Slice, reflect.Array:
+		return &jsonType{t: p.t.Elem()}
+	}
+	return &jsonType{}
+}
+
+// A jsonVisitor returns the value to use for a value of type p held by
+// the key of an object, which is a struct field if field is set, or by an
+// array if inArray is set, and whether to keep the key.
+type jsonVisitor func(v interface{}, p *jsonType, field, inArray bool) (interface{}, bool)
+
+// walkJSON replaces the values held by the objects and arrays of v, whose
+// type is p, by the values returned by visit, depth first, in place.
+// Values left as is by their type aren't visited.
+func walkJSON(v interface{}, p *jsonType, visit jsonVisitor) interface{} {
+	p = p.resolve()
+	if p != nil && p.t == nil {
+		return v
+	}
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		for key, value := range v {
+			vt, field := p.key(key)
+			value, keep := visit(walkJSON(value, vt, visit), vt, field, false)
+			if !keep {
+				delete(v, key)
+				continue
+			}
+			v[key] = value
+		}
+	case yaml.MapSlice:
+		kept := v[:0]
+		for _, item := range v {
+			vt, field := p.key(item.Key)
+			value, keep := visit(walkJSON(item.Value, vt, visit), vt, field, false)
+			if keep {
+				kept = append(kept, yaml.MapItem{Key: item.Key, Value: value})
+			}
+		}
+		return kept
+	case []interface{}:
+		it := p.item()
+		for i, item := range v {
+			v[i], _ = visit(walkJSON(item, it, visit), it, false, true)
+		}
+	}
+	return v
+}
+
+// omitEmpty removes the empty values of the struct fields of v, of type p.
+func omitEmpty(v interface{}, p *jsonType, o *marshalOptions) interface{} {
+	return walkJSON(v, p, func(v interface{}, p *jsonType, field, inArray bool) (interface{}, bool) {
+		return v, !field || !isEmptyJSON(v, p, o)
+	})
+}
+
+// isEmptyJSON returns whether v, the value of a struct field of type p, is
+// empty as the omitempty option of encoding/json defines it, or, as
+// requested by o, an object without keys.
+func isEmptyJSON(v interface{}, p *jsonType, o *marshalOptions) bool {
+	switch v := v.(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case bool:
+		return !v
+	case int:
+		return v == 0
+	case int64:
+		return v == 0
+	case uint64:
+		return v == 0
+	case float64:
+		return v == 0
+	case []interface{}:
+		return len(v) == 0
+	case map[interface{}]interface{}:
+		return len(v) == 0 && (o.omitEmptyObjects || p.resolve().kind() == reflect.Map)
+	case yaml.MapSlice:
+		return len(v) == 0 && (o.omitEmptyObjects || p.resolve().kind() == reflect.Map)
+	}
+	return false
+}
+
+// renderCollections replaces the nil and empty maps and slices of v, of
+// type p, as requested by o.
+func renderCollections(v interface{}, p *jsonType, o *marshalOptions) interface{} {
+	visit := func(v interface{}, p *jsonType, field, inArray bool) (interface{}, bool) {
+		kind := emptyCollection(v, p)
+		if kind == reflect.Invalid {
+			return v, true
+		}
+		switch o.collections {
+		case CollectionsAsEmpty:
+			if v == nil && kind == reflect.Map {
+				return yaml.MapSlice{}, true
+			}
+			if v == nil && kind == reflect.Slice {
+				return []interface{}{}, true
+			}
+		case CollectionsAsNull:
+			return nil, true
+		case CollectionsOmitted:
+			return v, inArray
+		}
+		return v, true
+	}
+	// The value itself is handled as an array item, which can't be
+	// omitted.
+	v, _ = visit(walkJSON(v, p, visit), p, false, true)
+	return v
+}
+
+// emptyCollection returns reflect.Map or reflect.Slice if v, of type p, is
+// a nil or empty map or slice, or reflect.Invalid otherwise. Without a
+// type, null is a nil collection of an unknown kind, reflect.Interface,
+// and empty objects are maps.
+func emptyCollection(v interface{}, p *jsonType) reflect.Kind {
+	p = p.resolve()
+	if p != nil && p.t == nil {
+		return reflect.Invalid
+	}
+	kind := p.kind()
+	if kind == reflect.Slice && p.t.Elem().Kind() == reflect.Uint8 {
+		// Byte slices are written as base64 strings.
+		return reflect.Invalid
+	}
+	switch v := v.(type) {
+	case nil:
+		switch kind {
+		case reflect.Map, reflect.Slice:
+			return kind
+		case reflect.Invalid:
+			if p == nil {
+				return reflect.Interface
+			}
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return reflect.Slice
+		}
+	case map[interface{}]interface{}:
+		if len(v) == 0 && kind != reflect.Struct {
+			return reflect.Map
+		}
+	case yaml.MapSlice:
+		if len(v) == 0 && kind != reflect.Struct {
+			return reflect.Map
+		}
+	}
+	return reflect.Invalid
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"testing"
+)
+
+func TestMarshalOrderedKeys(t *testing.T) {
+	type spec struct {
+		Replicas int               `json:"replicas"`
+		Labels   map[string]string `json:"labels"`
+		Image    string            `json:"image"`
+	}
+	obj := struct {
+		Kind string `json:"kind"`
+		Spec spec   `json:"spec"`
+		Data []byte `json:"data"`
+	}{"Deployment", spec{3, map[string]string{"b": "2", "a": "1"}, "nginx"}, []byte("hi")}
+
+	y, err := MarshalWithOptions(obj, OrderedKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `kind: Deployment
+spec:
+  replicas: 3
+  labels:
+    a: "1"
+    b: "2"
+  image: nginx
+data: aGk=
+`
+	if string(y) != expected {
+		t.Errorf("unexpected output:\n%s", y)
+	}
+
+	y, err = MarshalWithOptions(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sorted, _ := Marshal(obj); string(y) != string(sorted) {
+		t.Errorf("unexpected output without options:\n%s", y)
+	}
+}
+
+func TestJSONToYAMLOrderedKeys(t *testing.T) {
+	tests := []struct {
+		json, yaml string
+	}{
+		{`{"z": 1, "a": [true, null, 1.5, {"y": "x", "b": 18446744073709551615}], "m": {}}`,
+			"z: 1\na:\n- true\n- null\n- 1.5\n- \"y\": x\n  b: 18446744073709551615\nm: {}\n"},
+		{`[1, "2", []]`, "- 1\n- \"2\"\n- []\n"},
+		{`"text"`, "text\n"},
+		{`{"b": 1, "a": 2, "b": {"c": 3, "c": 4}}`, "b:\n  c: 4\na: 2\n"},
+	}
+	for _, test := range tests {
+		y, err := JSONToYAMLWithOptions([]byte(test.json), OrderedKeys)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.json, err)
+			continue
+		}
+		if string(y) != test.yaml {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", test.json, test.yaml, y)
+		}
+	}
+
+	for _, bad := range []string{`{"a": 1`, `{"a": 1} 2`, `{"a" 1}`, ``} {
+		if _, err := JSONToYAMLWithOptions([]byte(bad), OrderedKeys); err == nil {
+			t.Errorf("%q: expected an error", bad)
+		}
+	}
+}
+
+type omitEmptyRaw struct{}
+
+func (omitEmptyRaw) MarshalJSON() ([]byte, error) {
+	return []byte(`{"zero": 0}`), nil
+}
+
+func TestMarshalOmitEmpty(t *testing.T) {
+	type probe struct {
+		Path string `json:"path"`
+		Port int    `json:"port"`
+	}
+	type container struct {
+		Name       string            `json:"name"`
+		Args       []string          `json:"args"`
+		Env        map[string]string `json:"env"`
+		Privileged bool              `json:"privileged"`
+		Probe      *probe            `json:"probe"`
+		Resources  struct {
+			Limits map[string]string `json:"limits"`
+		} `json:"resources"`
+		Extra interface{}  `json:"extra"`
+		Raw   omitEmptyRaw `json:"raw"`
+	}
+	obj := struct {
+		Kind       string            `json:"kind"`
+		Replicas   int               `json:"replicas"`
+		Containers []container       `json:"containers"`
+		Labels     map[string]string `json:"labels"`
+	}{
+		Kind: "Pod",
+		Containers: []container{{
+			Name:  "web",
+			Args:  []string{},
+			Env:   map[string]string{"EMPTY": ""},
+			Probe: &probe{},
+			Extra: map[string]interface{}{"zero": 0},
+		}},
+		Labels: map[string]string{},
+	}
+
+	y, err := MarshalWithOptions(obj, OmitEmpty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `containers:
+- env:
+    EMPTY: ""
+  extra:
+    zero: 0
+  name: web
+  probe: {}
+  raw:
+    zero: 0
+  resources: {}
+kind: Pod
+`
+	if string(y) != expected {
+		t.Errorf("unexpected output with OmitEmpty:\n%s", y)
+	}
+
+	y, err = MarshalWithOptions(obj, OmitEmptyObjects, OrderedKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `kind: Pod
+containers:
+- name: web
+  env:
+    EMPTY: ""
+  extra:
+    zero: 0
+  raw:
+    zero: 0
+`
+	if string(y) != expected {
+		t.Errorf("unexpected output with OmitEmptyObjects:\n%s", y)
+	}
+
+	y, err = JSONToYAMLWithOptions([]byte(`{"a": {"b": "", "c": {}}, "d": [{"e": 0}], "f": 1}`), OmitEmpty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "a:\n  c: {}\nd:\n- {}\nf: 1\n"; string(y) != expected {
+		t.Errorf("unexpected output for JSON:\n%s", y)
+	}
+}
+
+func TestMarshalCollections(t *testing.T) {
+	type spec struct {
+		NilMap     map[string]string `json:"nilMap"`
+		EmptyMap   map[string]string `json:"emptyMap"`
+		NilSlice   []string          `json:"nilSlice"`
+		EmptySlice []string          `json:"emptySlice"`
+		NilPtr     *int              `json:"nilPtr"`
+		Struct     struct{}          `json:"struct"`
+		Bytes      []byte            `json:"bytes"`
+		Items      [][]string        `json:"items"`
+	}
+	obj := spec{EmptyMap: map[string]string{}, EmptySlice: []string{}, Items: [][]string{nil, {}}}
+	tests := map[CollectionStyle]string{
+		CollectionsAsIs: `bytes: null
+emptyMap: {}
+emptySlice: []
+items:
+- null
+- []
+nilMap: null
+nilPtr: null
+nilSlice: null
+struct: {}
+`,
+		CollectionsAsEmpty: `bytes: null
+emptyMap: {}
+emptySlice: []
+items:
+- []
+- []
+nilMap: {}
+nilPtr: null
+nilSlice: []
+struct: {}
+`,
+		CollectionsAsNull: `bytes: null
+emptyMap: null
+emptySlice: null
+items:
+- null
+- null
+nilMap: null
+nilPtr: null
+nilSlice: null
+struct: {}
+`,
+		CollectionsOmitted: `bytes: null
+items:
+- null
+- []
+nilPtr: null
+struct: {}
+`,
+	}
+	for style, expected := range tests {
+		y, err := MarshalWithOptions(obj, Collections(style))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(y) != expected {
+			t.Errorf("style %d: unexpected output:\n%s", style, y)
+		}
+	}
+
+	y, err := MarshalWithOptions(map[string]int(nil), Collections(CollectionsAsEmpty))
+	if err != nil || string(y) != "{}\n" {
+		t.Errorf("unexpected output for a nil map: %q, %v", y, err)
+	}
+
+	j := []byte(`{"a": null, "b": {}, "c": [], "d": [{}], "e": 1}`)
+	tests = map[CollectionStyle]string{
+		CollectionsAsEmpty: "a: null\nb: {}\nc: []\nd:\n- {}\ne: 1\n",
+		CollectionsAsNull:  "a: null\nb: null\nc: null\nd:\n- null\ne: 1\n",
+		CollectionsOmitted: "d:\n- {}\ne: 1\n",
+	}
+	for style, expected := range tests {
+		y, err := JSONToYAMLWithOptions(j, Collections(style))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(y) != expected {
+			t.Errorf("JSON, style %d: unexpected output:\n%s", style, y)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	yamlv3 "sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+// SniffTypeMeta returns the apiVersion, kind, metadata.name and
+// metadata.namespace fields of the Kubernetes object in the first document
+// of data. The document is read as a stream of events, without building it
+// in memory or decoding it, and reading stops as soon as the four fields
+// have been found, so that objects can be routed cheaply. Missing fields
+// are returned as empty strings, and so are fields whose value isn't a
+// scalar. An error is returned if data is not valid YAML up to the point
+// where reading stopped, or if the document is not a mapping.
+func SniffTypeMeta(data []byte) (apiVersion, kind, name, namespace string, err error) {
+	p := yamlv3.NewParser(bytes.NewReader(data))
+	defer p.Close()
+	s := &typeMetaSniffer{parser: p, found: make(map[*string]bool)}
+	for {
+		ev, err := s.next()
+		if err != nil {
+			return "", "", "", "", err
+		}
+		switch ev.Kind {
+		case yamlv3.StreamEndEvent:
+			return "", "", "", "", nil
+		case yamlv3.MappingStartEvent:
+			err := s.mapping(func(key string) (*string, bool) {
+				switch key {
+				case "apiVersion":
+					return &apiVersion, false
+				case "kind":
+					return &kind, false
+				case "metadata":
+					return nil, true
+				}
+				return nil, false
+			}, func() error {
+				return s.mapping(func(key string) (*string, bool) {
+					switch key {
+					case "name":
+						return &name, false
+					case "namespace":
+						return &namespace, false
+					}
+					return nil, false
+				}, nil)
+			})
+			if err != nil && err != errSniffDone {
+				return "", "", "", "", err
+			}
+			return apiVersion, kind, name, namespace, nil
+		case yamlv3.StreamStartEvent, yamlv3.DocumentStartEvent:
+		default:
+			return "", "", "", "", fmt.Errorf("yaml: line %d: expected a mapping for the object, found %v", ev.Start.Line, ev.Kind)
+		}
+	}
+}
+
+// errSniffDone stops a typeMetaSniffer once every field has been found.
+var errSniffDone = errors.New("all fields found")
+
+// typeMetaSniffer reads the fields of a Kubernetes object from events.
+type typeMetaSniffer struct {
+	parser *yamlv3.Parser
+	found  map[*string]bool
+}
+
+func (s *typeMetaSniffer) next() (yamlv3.Event, error) {
+	ev, err := s.parser.Next()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return ev, err
+}
+
+// mapping reads the entries of the mapping whose start event was just
+// read. For each key, field returns where to store its scalar value, or
+// whether the value is the mapping to read with nested. Once every field
+// of the object has been stored, it returns errSniffDone.
+func (s *typeMetaSniffer) mapping(field func(key string) (*string, bool), nested func() error) error {
+	for {
+		key, err := s.next()
+		if err != nil {
+			return err
+		}
+		if key.Kind == yamlv3.MappingEndEvent {
+			return nil
+		}
+		var dst *string
+		var isNested bool
+		if key.Kind == yamlv3.ScalarEvent {
+			dst, isNested = field(key.Value)
+		} else if err := s.skip(key); err != nil {
+			return err
+		}
+		value, err := s.next()
+		if err != nil {
+			return err
+		}
+		switch {
+		case dst != nil && value.Kind == yamlv3.ScalarEvent:
+			*dst = value.Value
+			s.found[dst] = true
+			// apiVersion, kind, name and namespace.
+			if len(s.found) == 4 {
+				return errSniffDone
+			}
+		case isNested && value.Kind == yamlv3.MappingStartEvent:
+			if err := nested(); err != nil {
+				return err
+			}
+		default:
+			if err := s.skip(value); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// skip reads past the end of the collection started by ev, if any.
+func (s *typeMetaSniffer) skip(ev yamlv3.Event) error {
+	depth := 0
+	for {
+		switch ev.Kind {
+		case yamlv3.MappingStartEvent, yamlv3.SequenceStartEvent:
+			depth++
+		case yamlv3.MappingEndEvent, yamlv3.SequenceEndEvent:
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+		var err error
+		if ev, err = s.next(); err != nil {
+			return err
+		}
+	}
+}
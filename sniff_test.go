@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"testing"
+)
+
+func TestSniffTypeMeta(t *testing.T) {
+	tests := map[string]struct {
+		yaml                              string
+		apiVersion, kind, name, namespace string
+		err                               string
+	}{
+		"deployment": {
+			yaml: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels: {app: web}
+  name: web
+  namespace: prod
+spec:
+  replicas: 3
+`,
+			apiVersion: "apps/v1", kind: "Deployment", name: "web", namespace: "prod",
+		},
+		"metadata first": {
+			yaml:       "metadata: {namespace: ns, name: n}\nkind: Pod\napiVersion: v1\n",
+			apiVersion: "v1", kind: "Pod", name: "n", namespace: "ns",
+		},
+		"cluster scoped": {
+			yaml:       "---\napiVersion: v1\nkind: Namespace\nmetadata:\n  name: prod\n---\nkind: Other\n",
+			apiVersion: "v1", kind: "Namespace", name: "prod",
+		},
+		"json": {
+			yaml:       `{"kind":"Service","apiVersion":"v1","metadata":{"name":"svc"}}`,
+			apiVersion: "v1", kind: "Service", name: "svc",
+		},
+		"non-scalar fields": {
+			yaml:      "kind: [Pod]\nspec:\n  kind: Pod\nmetadata:\n  name: {a: b}\n  namespace: ns\n",
+			namespace: "ns",
+		},
+		"stops early": {
+			yaml:       "apiVersion: v1\nkind: Pod\nmetadata: {name: n, namespace: ns}\nspec: [unterminated\n",
+			apiVersion: "v1", kind: "Pod", name: "n", namespace: "ns",
+		},
+		"empty": {
+			yaml: "",
+		},
+		"not a mapping": {
+			yaml: "- kind: Pod\n",
+			err:  "yaml: line 1: expected a mapping for the object, found sequence start",
+		},
+		"invalid": {
+			yaml: "kind: Pod\nmetadata: {name: [\n",
+			err:  "yaml: line 2: did not find expected node content",
+		},
+	}
+	for name, test := range tests {
+		apiVersion, kind, objName, namespace, err := SniffTypeMeta([]byte(test.yaml))
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: expected error %q, got %v", name, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		got := []string{apiVersion, kind, objName, namespace}
+		want := []string{test.apiVersion, test.kind, test.name, test.namespace}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%s: expected %q, got %q", name, want, got)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,42 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"io"
+)
+
+// DecodeEach calls fn with the text of each document of the YAML stream
+// read from r, in order, as split by a DocumentFilter: documents start
+// at lines beginning with a "---" marker, and those without content are
+// skipped. Reading stops at the first error returned by fn, which is
+// returned as is, or at the first document that isn't valid YAML.
+func DecodeEach(r io.Reader, fn func(doc []byte) error) error {
+	f := NewDocumentFilter(r, nil)
+	for {
+		doc, err := f.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(doc.Raw); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeEach(t *testing.T) {
+	var docs []string
+	err := DecodeEach(strings.NewReader(filterStream), func(doc []byte) error {
+		docs = append(docs, string(doc))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 3 || !strings.HasPrefix(docs[2], "--- !!map\n") {
+		t.Errorf("unexpected documents %q", docs)
+	}
+	if strings.Join(docs, "") != strings.Replace(filterStream, "---\n# Nothing here.\n", "", 1) {
+		t.Errorf("unexpected documents %q", docs)
+	}
+
+	stop := errors.New("stop")
+	count := 0
+	err = DecodeEach(strings.NewReader(filterStream), func(doc []byte) error {
+		count++
+		return stop
+	})
+	if err != stop || count != 1 {
+		t.Errorf("expected the callback error after one document, got %v after %d", err, count)
+	}
+
+	err = DecodeEach(strings.NewReader("a: 1\n---\nb: [\n"), func(doc []byte) error {
+		return nil
+	})
+	if err == nil || !strings.HasPrefix(err.Error(), "document 1: ") {
+		t.Errorf("expected a syntax error in document 1, got %v", err)
+	}
+}
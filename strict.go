@@ -0,0 +1,296 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	yamlv3 "sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+// An UnknownField describes a mapping key that doesn't match any field of
+// the struct its mapping is decoded into.
+type UnknownField struct {
+	// Name holds the text of the key.
+	Name string
+
+	// Path holds the location of the key in the document, such as
+	// "spec.containers[0].imagePulPolicy".
+	Path string
+
+	// Line and Column hold the position of the key, or zero if it can't
+	// be told.
+	Line   int
+	Column int
+
+	// Suggestion holds the name of the field closest to Name, if it's
+	// close enough to be a likely misspelling of it.
+	Suggestion string
+}
+
+// message describes f, without its position.
+func (f *UnknownField) message() string {
+	msg := fmt.Sprintf("unknown field %q", f.Path)
+	if f.Suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", f.Suggestion)
+	}
+	return msg
+}
+
+// A StrictError is returned by UnmarshalStrict when the document breaks
+// the rules of strict decoding. It lists every violation found in the
+// document rather than only the first, so that they can all be fixed at
+// once.
+type StrictError struct {
+	// DuplicateKeys holds the mapping keys that are repeated, in
+	// document order.
+	DuplicateKeys []DuplicateKey
+
+	// UnknownFields holds the keys that don't match a field of obj, in
+	// document order.
+	UnknownFields []UnknownField
+}
+
+func (e *StrictError) Error() string {
+	type violation struct {
+		line int
+		msg  string
+	}
+	var vs []violation
+	for _, k := range e.DuplicateKeys {
+		vs = append(vs, violation{k.Line, fmt.Sprintf("mapping key %q already defined at line %d", k.Path, k.FirstLine)})
+	}
+	for _, f := range e.UnknownFields {
+		vs = append(vs, violation{f.Line, f.message()})
+	}
+	// Violations whose line is unknown come last.
+	sort.SliceStable(vs, func(i, j int) bool {
+		return vs[j].line == 0 && vs[i].line != 0 || vs[i].line != 0 && vs[i].line < vs[j].line
+	})
+	var b strings.Builder
+	b.WriteString("strict decoding errors:")
+	for _, v := range vs {
+		b.WriteString("\n  ")
+		if v.line > 0 {
+			fmt.Fprintf(&b, "line %d: ", v.line)
+		}
+		b.WriteString(v.msg)
+	}
+	return b.String()
+}
+
+// unmarshalStrict implements UnmarshalStrict. Repeated keys and unknown
+// fields are looked for over the whole document before decoding, and
+// returned together in a StrictError.
+func unmarshalStrict(yamlBytes []byte, obj interface{}, opts ...JSONOpt) error {
+	var strictErr StrictError
+	jsonTarget := reflect.ValueOf(obj)
+	jsonBytes, err := yamlToJSONTarget(yamlBytes, &jsonTarget, yaml.UnmarshalStrict, nil)
+	if err != nil {
+		strictErr.DuplicateKeys = findDuplicateKeys(yamlBytes)
+		if len(strictErr.DuplicateKeys) == 0 {
+			return fmt.Errorf("error converting YAML to JSON: %w", err)
+		}
+		jsonBytes, err = yamlToJSONTarget(yamlBytes, &jsonTarget, yaml.Unmarshal, nil)
+		if err != nil {
+			return fmt.Errorf("error converting YAML to JSON: %w", err)
+		}
+	}
+	if fields, err := findUnknownFields(jsonBytes, reflect.TypeOf(obj)); err == nil && len(fields) > 0 {
+		strictErr.UnknownFields = locateFields(yamlBytes, fields)
+	}
+	if len(strictErr.DuplicateKeys) > 0 || len(strictErr.UnknownFields) > 0 {
+		return &strictErr
+	}
+
+	err = jsonUnmarshal(jsonBytes, obj, append(opts, DisallowUnknownFields)...)
+	if err != nil {
+		return fmt.Errorf("error unmarshaling JSON: %w", err)
+	}
+	return nil
+}
+
+// withFieldSuggestion adds to err, if it reports a field of the JSON
+// value j that doesn't exist in obj, the name of the field it's likely a
+// misspelling of.
+func withFieldSuggestion(err error, j []byte, obj interface{}) error {
+	const prefix = "json: unknown field "
+	msg := err.Error()
+	if !strings.HasPrefix(msg, prefix) {
+		return err
+	}
+	name, uerr := strconv.Unquote(msg[len(prefix):])
+	if uerr != nil {
+		return err
+	}
+	fields, ferr := findUnknownFields(j, reflect.TypeOf(obj))
+	if ferr != nil {
+		return err
+	}
+	for _, f := range fields {
+		if f.Name == name {
+			if f.Suggestion != "" {
+				return fmt.Errorf("%w, did you mean %q?", err, f.Suggestion)
+			}
+			break
+		}
+	}
+	return err
+}
+
+// findUnknownFields returns the keys of the objects of the JSON value j
+// that encoding/json doesn't decode into a value of type t, for lack of a
+// struct field for them, in the order they come in.
+func findUnknownFields(j []byte, t reflect.Type) ([]UnknownField, error) {
+	v, err := decodeOrderedJSON(j)
+	if err != nil {
+		return nil, err
+	}
+	var fields []UnknownField
+	collectUnknownFields(v, t, "", &fields)
+	return fields, nil
+}
+
+// locateFields sets the position of fields, found in the JSON conversion
+// of y, to the one of their key in y, and returns them in document order,
+// followed by those whose key isn't found.
+func locateFields(y []byte, fields []UnknownField) []UnknownField {
+	keys := make(map[string]*yamlv3.Node)
+	walkKeys(y, func(_, k *yamlv3.Node, path string) {
+		// The value of a repeated key is the last one.
+		keys[path] = k
+	})
+	for i := range fields {
+		if k, ok := keys[fields[i].Path]; ok {
+			fields[i].Line, fields[i].Column = k.Line, k.Column
+		}
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		a, b := fields[i], fields[j]
+		if a.Line == 0 || b.Line == 0 {
+			return b.Line == 0 && a.Line != 0
+		}
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return fields
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+func collectUnknownFields(v interface{}, t reflect.Type, path string, fields *[]UnknownField) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || reflect.PtrTo(t).Implements(jsonUnmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return
+	}
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		for _, item := range v {
+			key, _ := item.Key.(string)
+			keyPath := key
+			if path != "" {
+				keyPath = path + "." + key
+			}
+			switch t.Kind() {
+			case reflect.Map:
+				collectUnknownFields(item.Value, t.Elem(), keyPath, fields)
+			case reflect.Struct:
+				if f := jsonField(t, key); f != nil {
+					collectUnknownFields(item.Value, f.typ, keyPath, fields)
+				} else {
+					*fields = append(*fields, UnknownField{Name: key, Path: keyPath, Suggestion: closestField(t, key)})
+				}
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, item := range v {
+				collectUnknownFields(item, t.Elem(), path+"["+strconv.Itoa(i)+"]", fields)
+			}
+		}
+	}
+}
+
+// jsonField returns the field of the struct type t that encoding/json
+// decodes the value of key into, if any.
+func jsonField(t reflect.Type, key string) *field {
+	var f *field
+	fields := cachedTypeFields(t)
+	for i := range fields {
+		ff := &fields[i]
+		if ff.name == key {
+			return ff
+		}
+		if f == nil && ff.equalFold(ff.nameBytes, []byte(key)) {
+			f = ff
+		}
+	}
+	return f
+}
+
+// closestField returns the name of the field of the struct type t closest
+// to name, if it's close enough to be a likely misspelling of it.
+func closestField(t reflect.Type, name string) string {
+	var closest string
+	best := len([]rune(name))/2 + 1
+	for _, f := range cachedTypeFields(t) {
+		d := editDistance(name, f.name)
+		if d < best || d == best && closest != "" && f.name < closest {
+			closest, best = f.name, d
+		}
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur := prev + cost
+			if row[j]+1 < cur {
+				cur = row[j] + 1
+			}
+			if row[j-1]+1 < cur {
+				cur = row[j-1] + 1
+			}
+			prev, row[j] = row[j], cur
+		}
+	}
+	return row[len(rb)]
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestUnknownFieldSuggestion(t *testing.T) {
+	type Container struct {
+		Name            string `json:"name"`
+		Image           string `json:"image"`
+		ImagePullPolicy string `json:"imagePullPolicy"`
+	}
+	type Spec struct {
+		Containers []Container     `json:"containers"`
+		Labels     map[string]bool `json:"labels"`
+	}
+	tests := map[string]struct {
+		yaml string
+		err  string
+	}{
+		"misspelt": {
+			yaml: "containers:\n- name: a\n  imagePulPolicy: Always\n",
+			err:  "strict decoding errors:\n  line 3: unknown field \"containers[0].imagePulPolicy\", did you mean \"imagePullPolicy\"?",
+		},
+		"unrelated": {
+			yaml: "containers:\n- name: a\n  command: [sh]\n",
+			err:  "strict decoding errors:\n  line 3: unknown field \"containers[0].command\"",
+		},
+		"all of them": {
+			yaml: "labels: {imag: true}\ncontainer: []\ncontainers:\n- imag: b\n",
+			err: "strict decoding errors:\n" +
+				"  line 2: unknown field \"container\", did you mean \"containers\"?\n" +
+				"  line 4: unknown field \"containers[0].imag\", did you mean \"image\"?",
+		},
+	}
+	for name, test := range tests {
+		var s Spec
+		err := UnmarshalStrict([]byte(test.yaml), &s)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%s: expected error %q, got %v", name, test.err, err)
+		}
+	}
+
+	var s Spec
+	var warnings []string
+	err := UnmarshalWithWarnings([]byte("containers:\n- nme: a\n"), &s, func(w string) { warnings = append(warnings, w) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0] != `line 2: unknown field "containers[0].nme", did you mean "name"?` {
+		t.Errorf("unexpected warnings: %q", warnings)
+	}
+}
+
+func TestUnmarshalStrictAggregatesErrors(t *testing.T) {
+	type Container struct {
+		Name  string `json:"name"`
+		Image string `json:"image"`
+	}
+	type Spec struct {
+		Replicas   int         `json:"replicas"`
+		Containers []Container `json:"containers"`
+	}
+	y := []byte(`replicas: 1
+containers:
+- name: web
+  imag: nginx
+  name: api
+  ports: [80]
+replica: 2
+replicas: 3
+`)
+	s := Spec{Replicas: 5}
+	err := UnmarshalStrict(y, &s)
+	var strictErr *StrictError
+	if !errors.As(err, &strictErr) {
+		t.Fatalf("expected a StrictError, got %v", err)
+	}
+	expectedKeys := []DuplicateKey{
+		{Key: "name", Path: "containers[0].name", Line: 5, Column: 3, FirstLine: 3, FirstColumn: 3},
+		{Key: "replicas", Path: "replicas", Line: 8, Column: 1, FirstLine: 1, FirstColumn: 1},
+	}
+	if !reflect.DeepEqual(strictErr.DuplicateKeys, expectedKeys) {
+		t.Errorf("expected duplicate keys %+v, got %+v", expectedKeys, strictErr.DuplicateKeys)
+	}
+	expectedFields := []UnknownField{
+		{Name: "imag", Path: "containers[0].imag", Line: 4, Column: 3, Suggestion: "image"},
+		{Name: "ports", Path: "containers[0].ports", Line: 6, Column: 3},
+		{Name: "replica", Path: "replica", Line: 7, Column: 1, Suggestion: "replicas"},
+	}
+	if !reflect.DeepEqual(strictErr.UnknownFields, expectedFields) {
+		t.Errorf("expected unknown fields %+v, got %+v", expectedFields, strictErr.UnknownFields)
+	}
+	expected := `strict decoding errors:
+  line 4: unknown field "containers[0].imag", did you mean "image"?
+  line 5: mapping key "containers[0].name" already defined at line 3
+  line 6: unknown field "containers[0].ports"
+  line 7: unknown field "replica", did you mean "replicas"?
+  line 8: mapping key "replicas" already defined at line 1`
+	if err.Error() != expected {
+		t.Errorf("expected error %q, got %q", expected, err)
+	}
+	if s.Replicas != 5 || s.Containers != nil {
+		t.Errorf("expected the value to be left untouched, got %+v", s)
+	}
+
+	// Unknown fields are listed in document order, rather than in the
+	// order of the keys of the JSON conversion.
+	err = UnmarshalStrict([]byte("zone: a\nreplicas: 1\nannotations: {}\n"), &s)
+	if !errors.As(err, &strictErr) {
+		t.Fatalf("expected a StrictError, got %v", err)
+	}
+	expectedFields = []UnknownField{
+		{Name: "zone", Path: "zone", Line: 1, Column: 1},
+		{Name: "annotations", Path: "annotations", Line: 3, Column: 1},
+	}
+	if !reflect.DeepEqual(strictErr.UnknownFields, expectedFields) {
+		t.Errorf("expected unknown fields %+v, got %+v", expectedFields, strictErr.UnknownFields)
+	}
+
+	// Other errors are returned as before.
+	err = UnmarshalStrict([]byte("replicas: [1"), &s)
+	if err == nil || errors.As(err, &strictErr) {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+	err = UnmarshalStrict([]byte("replicas: a"), &s)
+	if err == nil || errors.As(err, &strictErr) {
+		t.Errorf("expected a type error, got %v", err)
+	}
+}
@@ -23,6 +23,7 @@
 package yaml
 
 import (
+	"bytes"
 	"io"
 )
 
@@ -54,6 +55,22 @@ func yaml_parser_initialize(parser *yaml_parser_t) bool {
 	return true
 }
 
+// [Go] Reset a parser object for a new input, keeping its settings and
+// reusing its buffers.
+func yaml_parser_reset(parser *yaml_parser_t) {
+	*parser = yaml_parser_t{
+		raw_buffer:  parser.raw_buffer[:0],
+		buffer:      parser.buffer[:0],
+		tokens:      parser.tokens[:0],
+		indents:     parser.indents[:0],
+		simple_keys: parser.simple_keys[:0],
+		states:      parser.states[:0],
+		marks:       parser.marks[:0],
+		max_depth:   parser.max_depth,
+		templates:   parser.templates,
+	}
+}
+
 // Destroy a parser object.
 func yaml_parser_delete(parser *yaml_parser_t) {
 	*parser = yaml_parser_t{}
@@ -71,7 +88,31 @@ func yaml_string_read_handler(parser *yaml_parser_t, buffer []byte) (n int, err
 
 // Reader read handler.
 func yaml_reader_read_handler(parser *yaml_parser_t, buffer []byte) (n int, err error) {
-	return parser.input_reader.Read(buffer)
+	n, err = parser.input_reader.Read(buffer)
+	yaml_parser_keep_input(parser, buffer[:n])
+	return n, err
+}
+
+// [Go] The amount of recently read file input data kept for error reporting.
+const input_seen_size = 64 * 1024
+
+// [Go] Keep the recently read file input data, so that errors may quote
+// the lines they refer to. Whole lines are dropped once twice the size
+// has been reached.
+func yaml_parser_keep_input(parser *yaml_parser_t, data []byte) {
+	parser.input_seen = append(parser.input_seen, data...)
+	if len(parser.input_seen) <= 2*input_seen_size {
+		return
+	}
+	drop := len(parser.input_seen) - input_seen_size
+	i := bytes.IndexByte(parser.input_seen[drop:], '\n')
+	if i < 0 {
+		return
+	}
+	drop += i + 1
+	parser.input_seen_pos += drop
+	parser.input_seen_line += bytes.Count(parser.input_seen[:drop], []byte{'\n'})
+	parser.input_seen = append(parser.input_seen[:0], parser.input_seen[drop:]...)
 }
 
 // Set a string input.
@@ -112,6 +153,28 @@ func yaml_emitter_initialize(emitter *yaml_emitter_t) {
 	}
 }
 
+// [Go] Reset an emitter object for a new output, keeping its settings and
+// reusing its buffers.
+func yaml_emitter_reset(emitter *yaml_emitter_t) {
+	*emitter = yaml_emitter_t{
+		buffer:                  emitter.buffer,
+		raw_buffer:              emitter.raw_buffer[:0],
+		states:                  emitter.states[:0],
+		events:                  emitter.events[:0],
+		indents:                 emitter.indents[:0],
+		canonical:               emitter.canonical,
+		best_indent:             emitter.best_indent,
+		best_width:              emitter.best_width,
+		binary_width:            emitter.binary_width,
+		unicode:                 emitter.unicode,
+		line_break:              emitter.line_break,
+		best_mapping_indent:     emitter.best_mapping_indent,
+		best_sequence_indent:    emitter.best_sequence_indent,
+		compact_sequence_indent: emitter.compact_sequence_indent,
+		templates:               emitter.templates,
+	}
+}
+
 // Destroy an emitter object.
 func yaml_emitter_delete(emitter *yaml_emitter_t) {
 	*emitter = yaml_emitter_t{}
@@ -163,7 +226,7 @@ func yaml_emitter_set_canonical(emitter *yaml_emitter_t, canonical bool) {
 
 // Set the indentation increment.
 func yaml_emitter_set_indent(emitter *yaml_emitter_t, indent int) {
-	if indent < 2 || indent > 9 {
+	if indent < 2 {
 		indent = 2
 	}
 	emitter.best_indent = indent
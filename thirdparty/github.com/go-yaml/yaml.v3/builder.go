@@ -0,0 +1,223 @@
+//
+// Copyright (c) 2011-2019 Canonical Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+// The functions and methods below build Node trees programmatically, as
+// in:
+//
+//	doc := yaml.NewMapNode().
+//		Set("name", yaml.Str("web")).
+//		Set("ports", yaml.NewSeqNode(yaml.Int(80), yaml.Int(443)).SetStyle(yaml.FlowStyle)).
+//		SetComment("The web service.")
+//
+// The methods setting a property of a node return the node itself, so
+// that calls may be chained.
+
+// NewDocumentNode returns a document node holding root.
+func NewDocumentNode(root *Node) *Node {
+	return &Node{Kind: DocumentNode, Content: []*Node{root}}
+}
+
+// NewMapNode returns an empty mapping node, to which entries may be added
+// with Set.
+func NewMapNode() *Node {
+	return &Node{Kind: MappingNode, Tag: mapTag}
+}
+
+// NewSeqNode returns a sequence node holding items.
+func NewSeqNode(items ...*Node) *Node {
+	return &Node{Kind: SequenceNode, Tag: seqTag, Content: items}
+}
+
+// Str returns a string scalar node, as set by SetString.
+func Str(s string) *Node {
+	n := &Node{}
+	n.SetString(s)
+	return n
+}
+
+// Int returns an int scalar node, as set by SetInt.
+func Int(i int64) *Node {
+	n := &Node{}
+	n.SetInt(i)
+	return n
+}
+
+// Float returns a float scalar node, as set by SetFloat.
+func Float(f float64) *Node {
+	n := &Node{}
+	n.SetFloat(f)
+	return n
+}
+
+// Bool returns a bool scalar node, as set by SetBool.
+func Bool(b bool) *Node {
+	n := &Node{}
+	n.SetBool(b)
+	return n
+}
+
+// Null returns a null scalar node, as set by SetNull.
+func Null() *Node {
+	n := &Node{}
+	n.SetNull()
+	return n
+}
+
+// Alias returns an alias node referring to n, whose anchor must be set.
+func Alias(n *Node) *Node {
+	return &Node{Kind: AliasNode, Value: n.Anchor, Alias: n}
+}
+
+// Set sets the value of the key in the mapping node n, replacing the
+// value of the first entry with that key if any, or adding an entry
+// otherwise. Set panics if n is not a mapping node.
+func (n *Node) Set(key string, value *Node) *Node {
+	if n.Kind != MappingNode {
+		panic("yaml: cannot set a key in a node that is not a mapping")
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if k := n.Content[i]; k.Kind == ScalarNode && k.Value == key {
+			n.Content[i+1] = value
+			return n
+		}
+	}
+	n.Content = append(n.Content, Str(key), value)
+	return n
+}
+
+// Append adds items to the sequence node n. Append panics if n is not a
+// sequence node.
+func (n *Node) Append(items ...*Node) *Node {
+	if n.Kind != SequenceNode {
+		panic("yaml: cannot append to a node that is not a sequence")
+	}
+	n.Content = append(n.Content, items...)
+	return n
+}
+
+// SetComment sets the head comment of n, written in the lines before it.
+// The comment of a mapping entry is set on its key node.
+func (n *Node) SetComment(comment string) *Node {
+	n.HeadComment = comment
+	return n
+}
+
+// SetLineComment sets the line comment of n, written at the end of its
+// line.
+func (n *Node) SetLineComment(comment string) *Node {
+	n.LineComment = comment
+	return n
+}
+
+// SetFootComment sets the foot comment of n, written in the lines after
+// it.
+func (n *Node) SetFootComment(comment string) *Node {
+	n.FootComment = comment
+	return n
+}
+
+// SetStyle sets the style of n.
+func (n *Node) SetStyle(style Style) *Node {
+	n.Style = style
+	return n
+}
+
+// SetTag sets the tag of n.
+func (n *Node) SetTag(tag string) *Node {
+	n.Tag = tag
+	return n
+}
+
+// SetAnchor sets the anchor of n, which Alias nodes may refer to.
+func (n *Node) SetAnchor(anchor string) *Node {
+	n.Anchor = anchor
+	return n
+}
+
+// A NodeOption changes how NodeOf represents a value.
+type NodeOption func(o *nodeOptions)
+
+type nodeOptions struct {
+	levels map[int]Style
+	all    Style
+}
+
+// scalarStyles holds the styles that apply to scalars, of which a node
+// may only have one.
+const scalarStyles = DoubleQuotedStyle | SingleQuotedStyle | LiteralStyle | FoldedStyle
+
+// addStyle returns the styles in old, with style added, replacing the
+// scalar style in old if style has one.
+func addStyle(old, style Style) Style {
+	if style&scalarStyles != 0 {
+		old &^= scalarStyles
+	}
+	return old | style
+}
+
+// StyleAt makes NodeOf apply style to the values at the given nesting
+// level, where the value itself is at level 0, the items and mapping
+// values within it at level 1, and so on, or at every level if level is
+// negative. The FlowStyle applies to mappings and sequences, while the
+// quoting, literal and folded styles apply to string scalars. A scalar
+// style given for a level replaces the one given for every level, if
+// any. Mapping keys are left as encoded.
+func StyleAt(level int, style Style) NodeOption {
+	return func(o *nodeOptions) {
+		if level < 0 {
+			o.all = addStyle(o.all, style)
+			return
+		}
+		if o.levels == nil {
+			o.levels = make(map[int]Style)
+		}
+		o.levels[level] = addStyle(o.levels[level], style)
+	}
+}
+
+// NodeOf returns the node representing v, as set by Node.Encode, with
+// the styles requested by opts applied.
+func NodeOf(v interface{}, opts ...NodeOption) (*Node, error) {
+	n := &Node{}
+	if err := n.Encode(v); err != nil {
+		return nil, err
+	}
+	var o nodeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.apply(n, 0)
+	return n, nil
+}
+
+// apply sets the styles for the level on n and the values within it.
+func (o *nodeOptions) apply(n *Node, level int) {
+	style := addStyle(o.all, o.levels[level])
+	switch n.Kind {
+	case MappingNode, SequenceNode:
+		n.Style |= style & FlowStyle
+		for i, ni := range n.Content {
+			if n.Kind == SequenceNode || i%2 == 1 {
+				o.apply(ni, level+1)
+			}
+		}
+	case ScalarNode:
+		if style&scalarStyles != 0 && n.ShortTag() == strTag {
+			n.Style = addStyle(n.Style, style&scalarStyles)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package yaml_test
+
+import (
+	"math"
+
+	. "gopkg.in/check.v1"
+	"sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+func (s *S) TestNodeBuilder(c *C) {
+	base := yaml.NewMapNode().Set("replicas", yaml.Int(2)).SetAnchor("base")
+	doc := yaml.NewMapNode().
+		Set("name", yaml.Str("web").SetLineComment("# the name")).
+		Set("ports", yaml.NewSeqNode(yaml.Int(80), yaml.Int(443)).SetStyle(yaml.FlowStyle)).
+		Set("ratio", yaml.Float(0.5)).
+		Set("debug", yaml.Bool(false)).
+		Set("owner", yaml.Null()).
+		Set("base", base).
+		Set("copy", yaml.Alias(base)).
+		Set("args", yaml.NewSeqNode().Append(yaml.Str("-v"), yaml.Str("true"))).
+		Set("cert", yaml.Str("line 1\nline 2\n")).
+		Set("name", yaml.Str("api")).
+		SetComment("# The service.")
+	doc.Content[0].SetComment("# Its name.")
+
+	data, err := yaml.Marshal(yaml.NewDocumentNode(doc))
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, `# The service.
+# Its name.
+name: api
+ports: [80, 443]
+ratio: 0.5
+debug: false
+owner: null
+base: &base
+    replicas: 2
+copy: *base
+args:
+    - -v
+    - "true"
+cert: |
+    line 1
+    line 2
+`)
+
+	var v struct {
+		Name  string
+		Ports []int
+		Copy  map[string]int
+	}
+	c.Assert(doc.Decode(&v), IsNil)
+	c.Assert(v.Name, Equals, "api")
+	c.Assert(v.Ports, DeepEquals, []int{80, 443})
+	c.Assert(v.Copy, DeepEquals, map[string]int{"replicas": 2})
+
+	c.Assert(yaml.Float(math.Inf(-1)).Value, Equals, "-.inf")
+	c.Assert(func() { yaml.NewSeqNode().Set("a", yaml.Null()) }, PanicMatches, "yaml: cannot set a key in a node that is not a mapping")
+	c.Assert(func() { yaml.NewMapNode().Append(yaml.Null()) }, PanicMatches, "yaml: cannot append to a node that is not a sequence")
+}
+
+func (s *S) TestNodeOf(c *C) {
+	v := map[string]interface{}{
+		"name":  "web",
+		"cert":  "line 1\nline 2\n",
+		"args":  []string{"-v", "--port", "80"},
+		"env":   map[string]interface{}{"A": "1", "B": []int{1, 2}},
+		"count": 3,
+	}
+	n, err := yaml.NodeOf(v, yaml.StyleAt(1, yaml.FlowStyle), yaml.StyleAt(-1, yaml.DoubleQuotedStyle), yaml.StyleAt(1, yaml.LiteralStyle))
+	c.Assert(err, IsNil)
+	data, err := yaml.Marshal(n)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, `args: ["-v", "--port", "80"]
+cert: |
+    line 1
+    line 2
+count: 3
+env: {A: "1", B: [1, 2]}
+name: |-
+    web
+`)
+
+	n, err = yaml.NodeOf([]interface{}{"a", []string{"b"}}, yaml.StyleAt(-1, yaml.FlowStyle), yaml.StyleAt(-1, yaml.SingleQuotedStyle))
+	c.Assert(err, IsNil)
+	data, err = yaml.Marshal(n)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "['a', ['b']]\n")
+}
@@ -16,14 +16,21 @@
 package yaml
 
 import (
+	"bytes"
+	"context"
 	"encoding"
 	"encoding/base64"
 	"fmt"
 	"io"
 	"math"
+	"math/big"
+	"path"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // ----------------------------------------------------------------------------
@@ -36,6 +43,36 @@ type parser struct {
 	anchors  map[string]*Node
 	doneInit bool
 	textless bool
+	schema   Schema
+	source   string
+
+	// nodes counts the nodes parsed, for DecodeStats.
+	nodes int
+
+	// ctx is checked for cancellation before parsing each event, if set.
+	ctx context.Context
+
+	// spans holds the positions of the nodes in the text, if set. The
+	// end of block collections is not recorded.
+	spans map[*Node]nodeSpan
+
+	// stream receives the elements of the sequence at its path instead
+	// of the sequence node, if set. The path of the node being parsed is
+	// then tracked in path.
+	stream *sequenceStream
+	path   []string
+}
+
+// A sequenceStream is the target of Decoder.StreamSequence.
+type sequenceStream struct {
+	path []string
+	fn   func(item *Node) error
+}
+
+// nodeSpan holds the character indexes where the text of a node starts,
+// including its anchor and tag, and ends.
+type nodeSpan struct {
+	start, end int
 }
 
 func newParser(b []byte) *parser {
@@ -59,6 +96,19 @@ func newParserFromReader(r io.Reader) *parser {
 	return &p
 }
 
+// reset prepares the parser for reading a new stream from r, keeping its
+// settings.
+func (p *parser) reset(r io.Reader) {
+	if p.event.typ != yaml_NO_EVENT {
+		yaml_event_delete(&p.event)
+	}
+	yaml_parser_reset(&p.parser)
+	yaml_parser_set_input_reader(&p.parser, r)
+	p.doc = nil
+	p.anchors = nil
+	p.doneInit = false
+}
+
 func (p *parser) init() {
 	if p.doneInit {
 		return
@@ -79,6 +129,7 @@ func (p *parser) destroy() {
 // checks that it's of the expected type.
 func (p *parser) expect(e yaml_event_type_t) {
 	if p.event.typ == yaml_NO_EVENT {
+		p.checkContext()
 		if !yaml_parser_parse(&p.parser, &p.event) {
 			p.fail()
 		}
@@ -100,38 +151,105 @@ func (p *parser) peek() yaml_event_type_t {
 	if p.event.typ != yaml_NO_EVENT {
 		return p.event.typ
 	}
+	p.checkContext()
 	if !yaml_parser_parse(&p.parser, &p.event) {
 		p.fail()
 	}
 	return p.event.typ
 }
 
+// checkContext fails with the error of the parser context, if any, once
+// it's done.
+func (p *parser) checkContext() {
+	if p.ctx != nil {
+		if err := p.ctx.Err(); err != nil {
+			fail(err)
+		}
+	}
+}
+
 func (p *parser) fail() {
-	var where string
-	var line int
+	if p.parser.depth_exceeded > 0 {
+		fail(&MaxDepthError{
+			Max:    p.parser.depth_exceeded,
+			Line:   p.parser.context_mark.line + 1,
+			Column: p.parser.context_mark.column + 1,
+		})
+	}
+	err := &SyntaxError{Message: "unknown problem parsing YAML content", Offset: -1}
 	if p.parser.context_mark.line != 0 {
-		line = p.parser.context_mark.line
+		err.line = p.parser.context_mark.line
 		// Scanner errors don't iterate line before returning error
 		if p.parser.error == yaml_SCANNER_ERROR {
-			line++
+			err.line++
 		}
 	} else if p.parser.problem_mark.line != 0 {
-		line = p.parser.problem_mark.line
+		err.line = p.parser.problem_mark.line
 		// Scanner errors don't iterate line before returning error
 		if p.parser.error == yaml_SCANNER_ERROR {
-			line++
+			err.line++
 		}
 	}
-	if line != 0 {
-		where = "line " + strconv.Itoa(line) + ": "
-	}
-	var msg string
 	if len(p.parser.problem) > 0 {
-		msg = p.parser.problem
-	} else {
-		msg = "unknown problem parsing YAML content"
+		err.Message = p.parser.problem
+		p.setPosition(err, p.parser.problem_mark)
+		p.diagnose(err)
+	}
+	fail(err)
+}
+
+// setPosition sets the position of err to mark, along with the text of
+// its line, if available.
+func (p *parser) setPosition(err *SyntaxError, mark yaml_mark_t) {
+	err.Line = mark.line + 1
+	err.Column = mark.column + 1
+	err.Offset, err.Snippet, err.Caret = -1, "", 0
+	if line, offset, ok := p.sourceLine(mark); ok {
+		err.Offset = offset
+		err.Snippet = string(line)
+		err.Caret = utf8.RuneCount(line)
+		if mark.column < err.Caret {
+			err.Caret = mark.column
+		}
+	}
+}
+
+// sourceLine returns the text of the input line holding mark, and the
+// byte offset of mark in the input.
+func (p *parser) sourceLine(mark yaml_mark_t) (line []byte, offset int, ok bool) {
+	if p.parser.encoding != yaml_UTF8_ENCODING {
+		return nil, 0, false
+	}
+	src, n := p.parser.input, 0
+	if p.parser.input_reader != nil {
+		src, offset, n = p.parser.input_seen, p.parser.input_seen_pos, p.parser.input_seen_line
+	}
+	if offset == 0 && bytes.HasPrefix(src, []byte(bom_UTF8)) {
+		src = src[len(bom_UTF8):]
+		offset = len(bom_UTF8)
+	}
+	if mark.line < n {
+		return nil, 0, false
+	}
+	for ; n < mark.line; n++ {
+		i := bytes.IndexByte(src, '\n')
+		if i < 0 {
+			return nil, 0, false
+		}
+		src = src[i+1:]
+		offset += i + 1
+	}
+	line = src
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	line = bytes.TrimSuffix(line, []byte{'\r'})
+	for i, rest := 0, line; i < mark.column && len(rest) > 0; i++ {
+		_, size := utf8.DecodeRune(rest)
+		offset += size
+		rest = rest[size:]
 	}
-	failf("%s%s", where, msg)
+	return line, offset, true
 }
 
 func (p *parser) anchor(n *Node, anchor []byte) {
@@ -172,14 +290,20 @@ func (p *parser) node(kind Kind, defaultTag, tag, value string) *Node {
 	} else if defaultTag != "" {
 		tag = defaultTag
 	} else if kind == ScalarNode {
-		tag, _ = resolve("", value)
+		var ok bool
+		tag, _, ok = p.schema.resolvePlain(value)
+		if !ok {
+			failf("line %d: cannot resolve plain scalar `%s` with the JSON schema", p.event.start_mark.line+1, value)
+		}
 	}
 	n := &Node{
-		Kind:  kind,
-		Tag:   tag,
-		Value: value,
-		Style: style,
+		Kind:   kind,
+		Tag:    tag,
+		Value:  value,
+		Style:  style,
+		Source: p.source,
 	}
+	p.nodes++
 	if !p.textless {
 		n.Line = p.event.start_mark.line + 1
 		n.Column = p.event.start_mark.column + 1
@@ -187,9 +311,49 @@ func (p *parser) node(kind Kind, defaultTag, tag, value string) *Node {
 		n.LineComment = string(p.event.line_comment)
 		n.FootComment = string(p.event.foot_comment)
 	}
+	if p.spans != nil {
+		p.spans[n] = nodeSpan{p.event.start_mark.index, p.event.end_mark.index}
+	}
 	return n
 }
 
+// flowEnd records the end of the flow collection n, at its end event.
+func (p *parser) flowEnd(n *Node) {
+	if p.spans != nil && n.Style&FlowStyle != 0 {
+		p.spans[n] = nodeSpan{p.spans[n].start, p.event.end_mark.index}
+	}
+}
+
+// emptyLinesBefore returns the number of empty lines right above the given
+// block collection entry and its head comment, leaving out those that are
+// implied when encoding the prior entry, which begins with the prior node
+// and ends with the last node.
+func (p *parser) emptyLinesBefore(n, prior, last *Node) int {
+	line := n.Line - 1
+	if n.HeadComment != "" {
+		line -= strings.Count(n.HeadComment, "\n") + 1
+	}
+	lines := p.parser.empty_lines
+	count := 0
+	for i := sort.SearchInts(lines, line) - 1; i >= 0 && lines[i] == line-1-count; i-- {
+		count++
+	}
+	if prior.FootComment != "" {
+		// The encoder separates foot comments from what follows.
+		count--
+	}
+	if last.Kind == ScalarNode && last.Style&(LiteralStyle|FoldedStyle) != 0 {
+		// Kept trailing line breaks are part of the value.
+		if breaks := len(last.Value) - len(strings.TrimRight(last.Value, "\n")); breaks > 1 {
+			count -= breaks - 1
+		}
+	}
+	if count < 0 {
+		count = 0
+	}
+	return count
+}
+
 func (p *parser) parseChild(parent *Node) *Node {
 	child := p.parse()
 	parent.Content = append(parent.Content, child)
@@ -199,25 +363,202 @@ func (p *parser) parseChild(parent *Node) *Node {
 func (p *parser) document() *Node {
 	n := p.node(DocumentNode, "", "", "")
 	p.doc = n
+	if v := p.event.version_directive; v != nil {
+		n.Version = fmt.Sprintf("%d.%d", v.major, v.minor)
+	}
+	for _, td := range p.event.tag_directives {
+		n.TagDirectives = append(n.TagDirectives, TagDirective{Handle: string(td.handle), Prefix: string(td.prefix)})
+	}
+	n.LineComment = string(p.event.line_comment)
 	p.expect(yaml_DOCUMENT_START_EVENT)
+	p.path = p.path[:0]
 	p.parseChild(n)
 	if p.peek() == yaml_DOCUMENT_END_EVENT {
 		n.FootComment = string(p.event.foot_comment)
 	}
 	p.expect(yaml_DOCUMENT_END_EVENT)
+	p.parser.empty_lines = p.parser.empty_lines[:0]
 	return n
 }
 
+// skipDocument advances past the next document using the scanner alone,
+// without producing events or nodes for its content. It reports false if
+// the stream has no more documents.
+func (p *parser) skipDocument() bool {
+	p.init()
+	if p.peek() == yaml_STREAM_END_EVENT {
+		return false
+	}
+	p.expect(yaml_DOCUMENT_START_EVENT)
+	parser := &p.parser
+	for {
+		token := peek_token(parser)
+		if token == nil {
+			p.fail()
+		}
+		if token.typ == yaml_DOCUMENT_END_TOKEN {
+			skip_token(parser)
+			break
+		}
+		if token.typ == yaml_STREAM_END_TOKEN || token.typ == yaml_DOCUMENT_START_TOKEN ||
+			token.typ == yaml_VERSION_DIRECTIVE_TOKEN || token.typ == yaml_TAG_DIRECTIVE_TOKEN {
+			break
+		}
+		skip_token(parser)
+	}
+	// Leave the parser as if the document end had been parsed, with the
+	// pending comments belonging to the skipped document.
+	parser.state = yaml_PARSE_DOCUMENT_START_STATE
+	parser.states = parser.states[:0]
+	parser.marks = parser.marks[:0]
+	parser.tag_directives = parser.tag_directives[:0]
+	parser.head_comment = nil
+	parser.line_comment = nil
+	parser.foot_comment = nil
+	parser.tail_comment = nil
+	parser.stem_comment = nil
+	parser.close_comment = nil
+	parser.empty_lines = parser.empty_lines[:0]
+	return true
+}
+
+// findPath parses the node at the current position of the event stream
+// down to the node found at path, which is made of mapping keys and
+// sequence indexes, and returns it, or nil if there's no such node. The
+// events following the node found are left unparsed.
+func (p *parser) findPath(path []string) *Node {
+	if len(path) == 0 {
+		return p.parse()
+	}
+	switch p.peek() {
+	case yaml_MAPPING_START_EVENT:
+		p.expect(yaml_MAPPING_START_EVENT)
+		merges := &Node{Kind: MappingNode}
+		for p.peek() != yaml_MAPPING_END_EVENT {
+			k := p.parse()
+			if k.Kind == ScalarNode && k.Value == path[0] && !isMerge(k) {
+				return p.findPath(path[1:])
+			}
+			v := p.parse()
+			if isMerge(k) {
+				merges.Content = append(merges.Content, k, v)
+			}
+		}
+		p.expect(yaml_MAPPING_END_EVENT)
+		// Keys defined by the mapping itself take precedence over merged ones.
+		return findPath(merges, path)
+	case yaml_SEQUENCE_START_EVENT:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 {
+			return nil
+		}
+		p.expect(yaml_SEQUENCE_START_EVENT)
+		for j := 0; p.peek() != yaml_SEQUENCE_END_EVENT; j++ {
+			if j == i {
+				return p.findPath(path[1:])
+			}
+			p.parse()
+		}
+		p.expect(yaml_SEQUENCE_END_EVENT)
+		return nil
+	}
+	return findPath(p.parse(), path)
+}
+
+// findPath returns the node found at path in the tree rooted at n, or nil
+// if there's no such node.
+func findPath(n *Node, path []string) *Node {
+	for n != nil && n.Kind == AliasNode {
+		n = n.Alias
+	}
+	if n == nil || len(path) == 0 {
+		return n
+	}
+	switch n.Kind {
+	case DocumentNode:
+		if len(n.Content) == 1 {
+			return findPath(n.Content[0], path)
+		}
+	case MappingNode:
+		content, _ := expandMapping(n, make(map[*Node]*Node))
+		for i := 0; i+1 < len(content); i += 2 {
+			if k := content[i]; k.Kind == ScalarNode && k.Value == path[0] {
+				return findPath(content[i+1], path[1:])
+			}
+		}
+	case SequenceNode:
+		if i, err := strconv.Atoi(path[0]); err == nil && i >= 0 && i < len(n.Content) {
+			return findPath(n.Content[i], path[1:])
+		}
+	}
+	return nil
+}
+
 func (p *parser) alias() *Node {
 	n := p.node(AliasNode, "", "", string(p.event.anchor))
 	n.Alias = p.anchors[n.Value]
 	if n.Alias == nil {
-		failf("unknown anchor '%s' referenced", n.Value)
+		if anchor := p.closestAnchor(n.Value); anchor != "" {
+			failf("line %d, column %d: unknown anchor '%s' referenced, did you mean '%s'?", n.Line, n.Column, n.Value, anchor)
+		}
+		failf("line %d, column %d: unknown anchor '%s' referenced", n.Line, n.Column, n.Value)
 	}
 	p.expect(yaml_ALIAS_EVENT)
 	return n
 }
 
+// closestAnchor returns the defined anchor with the name closest to name,
+// if it's close enough to be a likely misspelling of it.
+func (p *parser) closestAnchor(name string) string {
+	anchors := make([]string, 0, len(p.anchors))
+	for anchor := range p.anchors {
+		anchors = append(anchors, anchor)
+	}
+	return closestName(name, anchors)
+}
+
+// closestName returns the name among names closest to name, if it's close
+// enough to be a likely misspelling of it.
+func closestName(name string, names []string) string {
+	var closest string
+	best := len([]rune(name))/2 + 1
+	for _, n := range names {
+		d := editDistance(name, n)
+		if d < best || d == best && closest != "" && n < closest {
+			closest, best = n, d
+		}
+	}
+	return closest
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur := prev + cost
+			if row[j]+1 < cur {
+				cur = row[j] + 1
+			}
+			if row[j-1]+1 < cur {
+				cur = row[j-1] + 1
+			}
+			prev, row[j] = row[j], cur
+		}
+	}
+	return row[len(rb)]
+}
+
 func (p *parser) scalar() *Node {
 	var parsedStyle = p.event.scalar_style()
 	var nodeStyle Style
@@ -243,23 +584,84 @@ func (p *parser) scalar() *Node {
 	}
 	n := p.node(ScalarNode, defaultTag, nodeTag, nodeValue)
 	n.Style |= nodeStyle
+	switch p.event.chomping {
+	case -1:
+		n.Chomping = StripChomping
+	case 1:
+		n.Chomping = KeepChomping
+	}
+	n.IndentIndicator = int(p.event.increment)
+	if !p.textless && nodeStyle == 0 {
+		n.Lexeme = numericLexeme(n.Tag, nodeValue)
+	}
 	p.anchor(n, p.event.anchor)
 	p.expect(yaml_SCALAR_EVENT)
 	return n
 }
 
+// pushPath appends key, a mapping key or sequence index, to the path of
+// the node being parsed, when it's tracked.
+func (p *parser) pushPath(key string) {
+	if p.stream != nil {
+		p.path = append(p.path, key)
+	}
+}
+
+// popPath removes the last key from the path of the node being parsed,
+// when it's tracked.
+func (p *parser) popPath() {
+	if p.stream != nil {
+		p.path = p.path[:len(p.path)-1]
+	}
+}
+
+// streaming reports whether the sequence being parsed is the one whose
+// elements are streamed.
+func (p *parser) streaming() bool {
+	if p.stream == nil || len(p.path) != len(p.stream.path) {
+		return false
+	}
+	for i, key := range p.path {
+		if key != p.stream.path[i] {
+			return false
+		}
+	}
+	return true
+}
+
 func (p *parser) sequence() *Node {
 	n := p.node(SequenceNode, seqTag, string(p.event.tag), "")
 	if p.event.sequence_style()&yaml_FLOW_SEQUENCE_STYLE != 0 {
 		n.Style |= FlowStyle
 	}
 	p.anchor(n, p.event.anchor)
+	n.OpenComment = string(p.event.open_comment)
 	p.expect(yaml_SEQUENCE_START_EVENT)
-	for p.peek() != yaml_SEQUENCE_END_EVENT {
+	if p.streaming() {
+		for i := 0; p.peek() != yaml_SEQUENCE_END_EVENT; i++ {
+			// The path of the element tells apart the sequences within it.
+			p.pushPath(strconv.Itoa(i))
+			item := p.parse()
+			p.popPath()
+			if err := p.stream.fn(item); err != nil {
+				fail(err)
+			}
+		}
+	}
+	for i := 0; p.peek() != yaml_SEQUENCE_END_EVENT; i++ {
+		p.pushPath(strconv.Itoa(i))
 		p.parseChild(n)
+		p.popPath()
+	}
+	if n.Style&FlowStyle == 0 && !p.textless {
+		for i := 1; i < len(n.Content); i++ {
+			n.Content[i].EmptyLinesBefore = p.emptyLinesBefore(n.Content[i], n.Content[i-1], n.Content[i-1])
+		}
 	}
 	n.LineComment = string(p.event.line_comment)
 	n.FootComment = string(p.event.foot_comment)
+	n.CloseComment = string(p.event.close_comment)
+	p.flowEnd(n)
 	p.expect(yaml_SEQUENCE_END_EVENT)
 	return n
 }
@@ -272,6 +674,7 @@ func (p *parser) mapping() *Node {
 		n.Style |= FlowStyle
 	}
 	p.anchor(n, p.event.anchor)
+	n.OpenComment = string(p.event.open_comment)
 	p.expect(yaml_MAPPING_START_EVENT)
 	for p.peek() != yaml_MAPPING_END_EVENT {
 		k := p.parseChild(n)
@@ -282,7 +685,9 @@ func (p *parser) mapping() *Node {
 				k.FootComment = ""
 			}
 		}
+		p.pushPath(k.Value)
 		v := p.parseChild(n)
+		p.popPath()
 		if k.FootComment == "" && v.FootComment != "" {
 			k.FootComment = v.FootComment
 			v.FootComment = ""
@@ -296,10 +701,17 @@ func (p *parser) mapping() *Node {
 	}
 	n.LineComment = string(p.event.line_comment)
 	n.FootComment = string(p.event.foot_comment)
+	n.CloseComment = string(p.event.close_comment)
 	if n.Style&FlowStyle == 0 && n.FootComment != "" && len(n.Content) > 1 {
 		n.Content[len(n.Content)-2].FootComment = n.FootComment
 		n.FootComment = ""
 	}
+	if block && !p.textless {
+		for i := 2; i+1 < len(n.Content); i += 2 {
+			n.Content[i].EmptyLinesBefore = p.emptyLinesBefore(n.Content[i], n.Content[i-2], n.Content[i-1])
+		}
+	}
+	p.flowEnd(n)
 	p.expect(yaml_MAPPING_END_EVENT)
 	return n
 }
@@ -310,7 +722,7 @@ func (p *parser) mapping() *Node {
 type decoder struct {
 	doc     *Node
 	aliases map[*Node]bool
-	terrors []string
+	terrors []*UnmarshalError
 
 	stringMapType  reflect.Type
 	generalMapType reflect.Type
@@ -320,16 +732,60 @@ type decoder struct {
 	decodeCount int
 	aliasCount  int
 	aliasDepth  int
+
+	reportUnknown bool
+	unknownFields []UnknownField
+	duplicateKeys DuplicateKeyPolicy
+	maxAliases    int
+	durations     DurationFormat
+	foldFields    bool
+	mergeKeys     MergeKeyPolicy
+	schema        Schema
+	plainStrings  bool
+	stringKeys    bool
+	copyAliases   bool
+	resolvers     map[string]TagResolver
+	lookup        func(name string) (string, bool)
+	include       func(path string) ([]byte, error)
+	maxIncludes   int
+	warn          func(w Warning)
+	types         *TypeRegistry
+	unions        map[reflect.Type]*TypeRegistry
+
+	// discriminator holds the discriminator key set by the tag of the
+	// struct field being decoded, if any.
+	discriminator string
+
+	// includes holds the paths of the files being included, from the
+	// outermost one.
+	includes []string
+
+	// mapSlice is set while decoding a MapSlice, so that the mappings
+	// in its values are decoded as MapSlice values too.
+	mapSlice bool
+
+	// partial is set while decoding the mappings merged with a merge
+	// key, which don't have to hold the required fields of a struct.
+	partial bool
+
+	// path holds the mapping keys and sequence indexes leading to
+	// the value being decoded.
+	path []string
 }
 
 var (
-	nodeType       = reflect.TypeOf(Node{})
-	durationType   = reflect.TypeOf(time.Duration(0))
-	stringMapType  = reflect.TypeOf(map[string]interface{}{})
-	generalMapType = reflect.TypeOf(map[interface{}]interface{}{})
-	ifaceType      = generalMapType.Elem()
-	timeType       = reflect.TypeOf(time.Time{})
-	ptrTimeType    = reflect.TypeOf(&time.Time{})
+	nodeType        = reflect.TypeOf(Node{})
+	rawNodeType     = reflect.TypeOf(RawNode{})
+	emptyStructType = reflect.TypeOf(struct{}{})
+	orderedMapType  = reflect.TypeOf(OrderedMap{})
+	pairsType       = reflect.TypeOf(Pairs{})
+	mapSliceType    = reflect.TypeOf(MapSlice{})
+	durationType    = reflect.TypeOf(time.Duration(0))
+	stringMapType   = reflect.TypeOf(map[string]interface{}{})
+	generalMapType  = reflect.TypeOf(map[interface{}]interface{}{})
+	ifaceType       = generalMapType.Elem()
+	timeType        = reflect.TypeOf(time.Time{})
+	ptrTimeType     = reflect.TypeOf(&time.Time{})
 )
 
 func newDecoder() *decoder {
@@ -354,13 +810,146 @@ func (d *decoder) terror(n *Node, tag string, out reflect.Value) {
 			value = " `" + value + "`"
 		}
 	}
-	d.terrors = append(d.terrors, fmt.Sprintf("line %d: cannot unmarshal %s%s into %s", n.Line, shortTag(tag), value, out.Type()))
+	d.terrors = append(d.terrors, &UnmarshalError{
+		Message:      fmt.Sprintf("line %d: cannot unmarshal %s%s into %s", n.Line, shortTag(tag), value, out.Type()),
+		Path:         d.pathString(""),
+		Line:         n.Line,
+		Column:       n.Column,
+		ExpectedType: out.Type(),
+		ActualTag:    shortTag(tag),
+	})
+}
+
+// expand returns a copy of the scalar n with the variables in its value
+// replaced as set by Decoder.ExpandVariables, and the tag of plain scalars
+// resolved again.
+func (d *decoder) expand(n *Node) *Node {
+	var value []byte
+	s := n.Value
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			value = append(value, s[i])
+			continue
+		}
+		if s[i+1] == '$' {
+			value = append(value, '$')
+			i++
+			continue
+		}
+		var name string
+		end := i + 1
+		if s[end] == '{' {
+			if j := strings.IndexByte(s[end:], '}'); j > 0 && isVariableName(s[end+1:end+j]) {
+				name = s[end+1 : end+j]
+				end += j + 1
+			}
+		} else {
+			for end < len(s) && isVariableChar(s[end], end == i+1) {
+				end++
+			}
+			name = s[i+1 : end]
+		}
+		if name == "" {
+			value = append(value, s[i])
+			continue
+		}
+		v, ok := d.lookup(name)
+		if !ok && d.knownFields {
+			d.terrors = append(d.terrors, &UnmarshalError{
+				Message: fmt.Sprintf("line %d: variable %q is not defined", n.Line, name),
+				Path:    d.pathString(""),
+				Line:    n.Line,
+				Column:  n.Column,
+			})
+		}
+		value = append(value, v...)
+		i = end - 1
+	}
+	if string(value) == n.Value {
+		return n
+	}
+	expanded := *n
+	expanded.Value = string(value)
+	if n.Style&(SingleQuotedStyle|DoubleQuotedStyle|LiteralStyle|FoldedStyle) == 0 {
+		expanded.Tag = strTag
+		if tag, _, ok := d.schema.resolvePlain(expanded.Value); ok {
+			expanded.Tag = tag
+		}
+	}
+	return &expanded
+}
+
+func isVariableName(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isVariableChar(s[i], i == 0) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isVariableChar(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
+
+// warnf passes a warning about the node n at the given path to the
+// warning handler, if any.
+func (d *decoder) warnf(n *Node, path string, format string, args ...interface{}) {
+	if d.warn == nil {
+		return
+	}
+	d.warn(Warning{
+		Message: fmt.Sprintf("line %d: ", n.Line) + fmt.Sprintf(format, args...),
+		Path:    path,
+		Line:    n.Line,
+		Column:  n.Column,
+	})
+}
+
+// keyError records an error about the mapping key n of the value out.
+func (d *decoder) keyError(n *Node, out reflect.Value, format string, args ...interface{}) {
+	d.terrors = append(d.terrors, &UnmarshalError{
+		Message:      fmt.Sprintf(format, args...),
+		Path:         d.pathString(n.Value),
+		Line:         n.Line,
+		Column:       n.Column,
+		ExpectedType: out.Type(),
+	})
+}
+
+// pushPath appends a mapping key or a sequence index such as "[1]"
+// to the path of the value being decoded.
+func (d *decoder) pushPath(elem string) {
+	d.path = append(d.path, elem)
+}
+
+func (d *decoder) popPath() {
+	d.path = d.path[:len(d.path)-1]
+}
+
+// pathString returns the path of the value being decoded, followed by
+// the given mapping key if it's not empty.
+func (d *decoder) pathString(key string) string {
+	var b strings.Builder
+	for _, elem := range d.path {
+		if b.Len() > 0 && !strings.HasPrefix(elem, "[") {
+			b.WriteByte('.')
+		}
+		b.WriteString(elem)
+	}
+	if key != "" {
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(key)
+	}
+	return b.String()
 }
 
 func (d *decoder) callUnmarshaler(n *Node, u Unmarshaler) (good bool) {
 	err := u.UnmarshalYAML(n)
 	if e, ok := err.(*TypeError); ok {
-		d.terrors = append(d.terrors, e.Errors...)
+		d.terrors = append(d.terrors, e.details()...)
 		return false
 	}
 	if err != nil {
@@ -377,12 +966,12 @@ func (d *decoder) callObsoleteUnmarshaler(n *Node, u obsoleteUnmarshaler) (good
 		if len(d.terrors) > terrlen {
 			issues := d.terrors[terrlen:]
 			d.terrors = d.terrors[:terrlen]
-			return &TypeError{issues}
+			return newTypeError(issues)
 		}
 		return nil
 	})
 	if e, ok := err.(*TypeError); ok {
-		d.terrors = append(d.terrors, e.Errors...)
+		d.terrors = append(d.terrors, e.details()...)
 		return false
 	}
 	if err != nil {
@@ -476,24 +1065,69 @@ func allowedAliasRatio(decodeCount int) float64 {
 	}
 }
 
+// excessiveAliasing reports whether the values decoded through aliases
+// exceed the configured budget, or the default ratio if none is set.
+func (d *decoder) excessiveAliasing() bool {
+	switch {
+	case d.maxAliases > 0:
+		return d.aliasCount > d.maxAliases
+	case d.maxAliases < 0:
+		return false
+	}
+	return d.aliasCount > 100 && d.decodeCount > 1000 && float64(d.aliasCount)/float64(d.decodeCount) > allowedAliasRatio(d.decodeCount)
+}
+
 func (d *decoder) unmarshal(n *Node, out reflect.Value) (good bool) {
 	d.decodeCount++
 	if d.aliasDepth > 0 {
 		d.aliasCount++
 	}
-	if d.aliasCount > 100 && d.decodeCount > 1000 && float64(d.aliasCount)/float64(d.decodeCount) > allowedAliasRatio(d.decodeCount) {
+	if d.excessiveAliasing() {
 		failf("document contains excessive aliasing")
 	}
 	if out.Type() == nodeType {
+		if d.mergeKeys == MergeKeyExpand {
+			n = expandMerges(n, make(map[*Node]*Node))
+		}
 		out.Set(reflect.ValueOf(n).Elem())
 		return true
 	}
+	if out.Type() == rawNodeType {
+		// Null values are kept too, unlike with the Unmarshaler interface.
+		out.Set(reflect.ValueOf(RawNode{n}))
+		return true
+	}
 	switch n.Kind {
 	case DocumentNode:
 		return d.document(n, out)
 	case AliasNode:
 		return d.alias(n, out)
 	}
+	if d.include != nil && n.Kind == ScalarNode && n.Tag == includeTag {
+		return d.includeFile(n, out)
+	}
+	if len(d.resolvers) > 0 {
+		if resolve := d.resolvers[n.ShortTag()]; resolve != nil {
+			return d.resolve(n, resolve, out)
+		}
+	}
+	if out.Kind() == reflect.Interface && (d.types != nil || d.unions != nil) {
+		if types := d.unions[out.Type()]; types != nil {
+			factory := types.factory(n, d.discriminator)
+			if factory == nil && n.ShortTag() != nullTag {
+				// The value is not a member of the union.
+				d.terror(n, "", out)
+				return false
+			}
+			if factory != nil {
+				return d.registered(n, factory, out)
+			}
+		} else if d.types != nil {
+			if factory := d.types.factory(n, d.discriminator); factory != nil {
+				return d.registered(n, factory, out)
+			}
+		}
+	}
 	out, unmarshaled, good := d.prepare(n, out)
 	if unmarshaled {
 		return good
@@ -516,6 +1150,78 @@ func (d *decoder) unmarshal(n *Node, out reflect.Value) (good bool) {
 	return good
 }
 
+// resolve decodes n into out as the value returned by the registered
+// resolver for its tag.
+func (d *decoder) resolve(n *Node, resolve TagResolver, out reflect.Value) bool {
+	v, err := resolve(n)
+	if err != nil {
+		fail(err)
+	}
+	if v == nil {
+		return d.null(out)
+	}
+	rv := reflect.ValueOf(v)
+	for out.Kind() == reflect.Ptr && !rv.Type().AssignableTo(out.Type()) {
+		if out.IsNil() {
+			out.Set(reflect.New(out.Type().Elem()))
+		}
+		out = out.Elem()
+	}
+	switch {
+	case rv.Type().AssignableTo(out.Type()):
+		out.Set(rv)
+	case kindClass(rv.Kind()) == kindClass(out.Kind()) && rv.Type().ConvertibleTo(out.Type()):
+		if overflows(rv, out) {
+			d.terror(n, "", out)
+			return false
+		}
+		out.Set(rv.Convert(out.Type()))
+	default:
+		d.terror(n, "", out)
+		return false
+	}
+	return true
+}
+
+// kindClass returns reflect.Int for the integer kinds, reflect.Float64
+// for the float kinds, and k otherwise, so that the values of kinds in
+// the same class may be converted to one another.
+func kindClass(k reflect.Kind) reflect.Kind {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.Int
+	case reflect.Float32, reflect.Float64:
+		return reflect.Float64
+	}
+	return k
+}
+
+// overflows reports whether v, a value of the same kind class as out,
+// cannot be represented by out's type without loss of range.
+func overflows(v, out reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i := v.Int()
+		switch out.Kind() {
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return i < 0 || out.OverflowUint(uint64(i))
+		}
+		return out.OverflowInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		switch out.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return u > math.MaxInt64 || out.OverflowInt(int64(u))
+		}
+		return out.OverflowUint(u)
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		return !math.IsInf(f, 0) && out.OverflowFloat(f)
+	}
+	return false
+}
+
 func (d *decoder) document(n *Node, out reflect.Value) (good bool) {
 	if len(n.Content) == 1 {
 		d.doc = n
@@ -525,6 +1231,66 @@ func (d *decoder) document(n *Node, out reflect.Value) (good bool) {
 	return false
 }
 
+const (
+	includeTag = "!include"
+
+	defaultMaxIncludes = 32
+)
+
+// includeFile decodes into out the first document of the file named by
+// the !include scalar n. Errors in the file are reported with its path.
+func (d *decoder) includeFile(n *Node, out reflect.Value) (good bool) {
+	name := n.Value
+	if len(d.includes) > 0 && !path.IsAbs(name) {
+		name = path.Join(path.Dir(d.includes[len(d.includes)-1]), name)
+	}
+	for i, included := range d.includes {
+		if included == name {
+			chain := append(append([]string(nil), d.includes[i:]...), name)
+			failf("line %d: !include cycle: %s", n.Line, strings.Join(chain, " -> "))
+		}
+	}
+	max := d.maxIncludes
+	if max <= 0 {
+		max = defaultMaxIncludes
+	}
+	if len(d.includes) == max {
+		failf("line %d: cannot include %q: exceeds the maximum depth of %d files", n.Line, name, max)
+	}
+	data, err := d.include(name)
+	if err != nil {
+		failf("line %d: cannot include %q: %v", n.Line, name, err)
+	}
+	doc, err := parseIncluded(data, d.schema)
+	if err != nil {
+		failf("%s: %v", name, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	if doc == nil {
+		return d.null(out)
+	}
+	savedDoc, terrors := d.doc, len(d.terrors)
+	d.includes = append(d.includes, name)
+	defer func() {
+		d.doc = savedDoc
+		d.includes = d.includes[:len(d.includes)-1]
+		for _, e := range d.terrors[terrors:] {
+			// Errors from nested files already have their path.
+			if strings.HasPrefix(e.Message, "line ") {
+				e.Message = name + ": " + e.Message
+			}
+		}
+	}()
+	return d.unmarshal(doc, out)
+}
+
+func parseIncluded(data []byte, schema Schema) (doc *Node, err error) {
+	defer handleErr(&err)
+	p := newParser(data)
+	defer p.destroy()
+	p.schema = schema
+	return p.parse(), nil
+}
+
 func (d *decoder) alias(n *Node, out reflect.Value) (good bool) {
 	if d.aliases[n] {
 		// TODO this could actually be allowed in some circumstances.
@@ -532,12 +1298,35 @@ func (d *decoder) alias(n *Node, out reflect.Value) (good bool) {
 	}
 	d.aliases[n] = true
 	d.aliasDepth++
-	good = d.unmarshal(n.Alias, out)
+	target := n.Alias
+	if d.copyAliases {
+		target = copyNode(target)
+	}
+	good = d.unmarshal(target, out)
 	d.aliasDepth--
 	delete(d.aliases, n)
 	return good
 }
 
+// copyNode returns a deep copy of n, except for the alias nodes within it,
+// which are kept so that decoding them is still checked for recursion.
+func copyNode(n *Node) *Node {
+	if n.Kind == AliasNode {
+		return n
+	}
+	c := *n
+	if n.Content != nil {
+		c.Content = make([]*Node, len(n.Content))
+		for i, ni := range n.Content {
+			c.Content[i] = copyNode(ni)
+		}
+	}
+	if n.TagDirectives != nil {
+		c.TagDirectives = append([]TagDirective(nil), n.TagDirectives...)
+	}
+	return &c
+}
+
 var zeroValue reflect.Value
 
 func resetMap(out reflect.Value) {
@@ -557,19 +1346,82 @@ func (d *decoder) null(out reflect.Value) bool {
 	return false
 }
 
+// plainString reports whether n is an untagged plain scalar to be decoded
+// as a string into interface values.
+func (d *decoder) plainString(n *Node) bool {
+	return d.plainStrings && n.Kind == ScalarNode && n.Style&(TaggedStyle|SingleQuotedStyle|DoubleQuotedStyle|LiteralStyle|FoldedStyle) == 0
+}
+
+var writerType = reflect.TypeOf((*io.Writer)(nil)).Elem()
+
+// binaryWriter returns the io.Writer held by out, if it's an interface
+// value whose type embeds io.Writer, to write binary data to.
+func binaryWriter(out reflect.Value) (io.Writer, bool) {
+	if out.Kind() != reflect.Interface || out.IsNil() || !out.Type().Implements(writerType) {
+		return nil, false
+	}
+	return out.Elem().Interface().(io.Writer), true
+}
+
+var bytesType = reflect.TypeOf([]byte(nil))
+
+func isByteSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && bytesType.ConvertibleTo(t)
+}
+
+func isTextUnmarshaler(out reflect.Value) bool {
+	if !out.CanAddr() {
+		return false
+	}
+	_, ok := out.Addr().Interface().(encoding.TextUnmarshaler)
+	return ok
+}
+
+// writeBinary decodes the base64 text of a binary value to w as it's
+// decoded.
+func (d *decoder) writeBinary(text string, w io.Writer) bool {
+	_, err := io.Copy(w, base64.NewDecoder(base64.StdEncoding, strings.NewReader(text)))
+	if _, ok := err.(base64.CorruptInputError); ok {
+		failf("!!binary value contains invalid base64 data")
+	}
+	if err != nil {
+		fail(fmt.Errorf("yaml: cannot write binary value: %w", err))
+	}
+	return true
+}
+
 func (d *decoder) scalar(n *Node, out reflect.Value) bool {
+	if d.lookup != nil && n.Style&TaggedStyle == 0 && strings.IndexByte(n.Value, '$') >= 0 {
+		n = d.expand(n)
+	}
 	var tag string
 	var resolved interface{}
-	if n.indicatedString() {
+	if n.indicatedString() || d.plainString(n) && out.Kind() == reflect.Interface {
 		tag = strTag
 		resolved = n.Value
 	} else {
-		tag, resolved = resolve(n.Tag, n.Value)
+		tag = n.Tag
+		if d.schema != DefaultSchema && n.Style == 0 {
+			// The tags that the default schema implies, as when
+			// decoding text into a Node, are resolved again.
+			if rtag, _ := resolve("", n.Value); shortTag(tag) == rtag {
+				tag = ""
+			}
+		}
+		tag, resolved = d.schema.resolve(tag, n.Value)
 		if tag == binaryTag {
+			if w, ok := binaryWriter(out); ok {
+				return d.writeBinary(resolved.(string), w)
+			}
 			data, err := base64.StdEncoding.DecodeString(resolved.(string))
 			if err != nil {
 				failf("!!binary value contains invalid base64 data")
 			}
+			if isByteSlice(out.Type()) && !isTextUnmarshaler(out) {
+				// Avoid copying the data into a string.
+				out.Set(reflect.ValueOf(data).Convert(out.Type()))
+				return true
+			}
 			resolved = string(data)
 		}
 	}
@@ -616,6 +1468,12 @@ func (d *decoder) scalar(n *Node, out reflect.Value) bool {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		// This used to work in v2, but it's very unfriendly.
 		isDuration := out.Type() == durationType
+		if isDuration && d.durations != DurationString {
+			if dur, ok := numericDuration(resolved, d.durations); ok {
+				out.SetInt(int64(dur))
+				return true
+			}
+		}
 
 		switch resolved := resolved.(type) {
 		case int:
@@ -688,18 +1546,30 @@ func (d *decoder) scalar(n *Node, out reflect.Value) bool {
 			}
 		}
 	case reflect.Float32, reflect.Float64:
+		exact := new(big.Float)
 		switch resolved := resolved.(type) {
 		case int:
 			out.SetFloat(float64(resolved))
-			return true
+			exact.SetInt64(int64(resolved))
 		case int64:
 			out.SetFloat(float64(resolved))
-			return true
+			exact.SetInt64(resolved)
 		case uint64:
 			out.SetFloat(float64(resolved))
-			return true
+			exact.SetUint64(resolved)
 		case float64:
 			out.SetFloat(resolved)
+			if !math.IsInf(out.Float(), 0) || math.IsInf(resolved, 0) {
+				return true
+			}
+			exact.SetFloat64(resolved)
+		default:
+			exact = nil
+		}
+		if exact != nil {
+			if exact.Cmp(big.NewFloat(out.Float())) != 0 {
+				d.warnf(n, d.pathString(""), "value %s is stored as %v in %s", n.Value, out.Float(), out.Type())
+			}
 			return true
 		}
 	case reflect.Struct:
@@ -721,7 +1591,55 @@ func settableValueOf(i interface{}) reflect.Value {
 	return sv
 }
 
+// numericDuration converts a resolved number into a duration in the
+// unit defined by format.
+func numericDuration(resolved interface{}, format DurationFormat) (time.Duration, bool) {
+	var f float64
+	switch resolved := resolved.(type) {
+	case int:
+		f = float64(resolved)
+		if format == DurationNanoseconds {
+			return time.Duration(resolved), true
+		}
+	case int64:
+		f = float64(resolved)
+		if format == DurationNanoseconds {
+			return time.Duration(resolved), true
+		}
+	case uint64:
+		f = float64(resolved)
+		if format == DurationNanoseconds && resolved <= math.MaxInt64 {
+			return time.Duration(resolved), true
+		}
+	case float64:
+		f = resolved
+	default:
+		return 0, false
+	}
+	if format == DurationSeconds {
+		f *= float64(time.Second)
+	}
+	if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, false
+	}
+	return time.Duration(f), true
+}
+
 func (d *decoder) sequence(n *Node, out reflect.Value) (good bool) {
+	switch {
+	case out.Type() == orderedMapType || out.Type() == pairsType:
+		return d.pairs(n, out)
+	case out.Kind() == reflect.Interface && n.ShortTag() == omapTag:
+		v := reflect.New(orderedMapType).Elem()
+		good = d.pairs(n, v)
+		out.Set(v)
+		return good
+	case out.Kind() == reflect.Interface && n.ShortTag() == pairsTag:
+		v := reflect.New(pairsType).Elem()
+		good = d.pairs(n, v)
+		out.Set(v)
+		return good
+	}
 	l := len(n.Content)
 
 	var iface reflect.Value
@@ -745,7 +1663,10 @@ func (d *decoder) sequence(n *Node, out reflect.Value) (good bool) {
 	j := 0
 	for i := 0; i < l; i++ {
 		e := reflect.New(et).Elem()
-		if ok := d.unmarshal(n.Content[i], e); ok {
+		d.pushPath("[" + strconv.Itoa(i) + "]")
+		ok := d.unmarshal(n.Content[i], e)
+		d.popPath()
+		if ok {
 			out.Index(j).Set(e)
 			j++
 		}
@@ -759,16 +1680,152 @@ func (d *decoder) sequence(n *Node, out reflect.Value) (good bool) {
 	return true
 }
 
+// pairs decodes the !!omap or !!pairs sequence n into out, which is
+// either an OrderedMap or Pairs.
+func (d *decoder) pairs(n *Node, out reflect.Value) (good bool) {
+	var pairs []Pair
+	var keys []*Node
+	for i, ni := range n.Content {
+		d.pushPath("[" + strconv.Itoa(i) + "]")
+		if ni.Kind == AliasNode && ni.Alias != nil {
+			ni = ni.Alias
+		}
+		if ni.Kind != MappingNode || len(ni.Content) != 2 {
+			d.terror(ni, "", out)
+			d.popPath()
+			continue
+		}
+		key, value := ni.Content[0], ni.Content[1]
+		if out.Type() == orderedMapType && d.uniqueKeys && d.definedKey(key, keys, out) {
+			d.popPath()
+			continue
+		}
+		keys = append(keys, key)
+		var pair Pair
+		if d.unmarshal(key, reflect.ValueOf(&pair.Key).Elem()) {
+			d.pushPath(key.Value)
+			d.unmarshal(value, reflect.ValueOf(&pair.Value).Elem())
+			d.popPath()
+			pairs = append(pairs, pair)
+		}
+		d.popPath()
+	}
+	out.Set(reflect.ValueOf(pairs).Convert(out.Type()))
+	return true
+}
+
+// mappingPairs decodes the mapping n in order into out, which is either
+// an OrderedMap or Pairs.
+func (d *decoder) mappingPairs(n *Node, out reflect.Value, skip []bool) (good bool) {
+	var pairs []Pair
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if skip != nil && skip[i] {
+			continue
+		}
+		var pair Pair
+		if d.unmarshal(n.Content[i], reflect.ValueOf(&pair.Key).Elem()) {
+			d.pushPath(n.Content[i].Value)
+			d.unmarshal(n.Content[i+1], reflect.ValueOf(&pair.Value).Elem())
+			d.popPath()
+			pairs = append(pairs, pair)
+		}
+	}
+	out.Set(reflect.ValueOf(pairs).Convert(out.Type()))
+	return true
+}
+
+// mapSliceItems decodes the mapping n in order into out, which is either
+// a MapSlice or an interface{} to hold one.
+func (d *decoder) mapSliceItems(n *Node, out reflect.Value, skip []bool) (good bool) {
+	content := n.Content
+	for i := 0; i < len(n.Content); i += 2 {
+		if d.isMerge(n.Content[i]) {
+			content, _ = expandMapping(n, make(map[*Node]*Node))
+			skip = nil
+			break
+		}
+	}
+	mapSlice := d.mapSlice
+	d.mapSlice = true
+	defer func() { d.mapSlice = mapSlice }()
+	var items MapSlice
+	for i := 0; i+1 < len(content); i += 2 {
+		if skip != nil && skip[i] {
+			continue
+		}
+		key, value := content[i], content[i+1]
+		item := MapItem{
+			HeadComment: key.HeadComment,
+			LineComment: key.LineComment,
+			FootComment: key.FootComment,
+		}
+		if item.LineComment == "" {
+			item.LineComment = value.LineComment
+		}
+		if d.unmarshal(key, reflect.ValueOf(&item.Key).Elem()) {
+			d.pushPath(key.Value)
+			ok := d.unmarshal(value, reflect.ValueOf(&item.Value).Elem())
+			d.popPath()
+			if ok {
+				items = append(items, item)
+			}
+		}
+	}
+	out.Set(reflect.ValueOf(items))
+	return true
+}
+
+// definedKey reports whether key repeats one of keys, recording an error
+// unless the duplicate key policy says otherwise. Only the first of the
+// repeated keys is kept with a policy other than DuplicateKeyError.
+func (d *decoder) definedKey(key *Node, keys []*Node, out reflect.Value) bool {
+	for _, k := range keys {
+		if k.Kind == key.Kind && k.Value == key.Value {
+			if d.duplicateKeys == DuplicateKeyError {
+				d.keyError(key, out, "line %d, column %d: mapping key %#v already defined at line %d, column %d", key.Line, key.Column, key.Value, k.Line, k.Column)
+			} else {
+				d.warnf(key, d.pathString(key.Value), "mapping key %#v already defined at line %d, ignoring this value", key.Value, k.Line)
+			}
+			return true
+		}
+	}
+	return false
+}
+
 func (d *decoder) mapping(n *Node, out reflect.Value) (good bool) {
+	// Only the mapping merged itself is partial, not the values in it.
+	partial := d.partial
+	d.partial = false
 	l := len(n.Content)
+	// skip[i] is set for the keys ignored by the duplicate key policy.
+	var skip []bool
 	if d.uniqueKeys {
 		nerrs := len(d.terrors)
 		for i := 0; i < l; i += 2 {
 			ni := n.Content[i]
 			for j := i + 2; j < l; j += 2 {
 				nj := n.Content[j]
-				if ni.Kind == nj.Kind && ni.Value == nj.Value {
-					d.terrors = append(d.terrors, fmt.Sprintf("line %d: mapping key %#v already defined at line %d", nj.Line, nj.Value, ni.Line))
+				if ni.Kind != nj.Kind || ni.Value != nj.Value {
+					continue
+				}
+				if skip == nil && d.duplicateKeys != DuplicateKeyError {
+					skip = make([]bool, l)
+				}
+				switch d.duplicateKeys {
+				case DuplicateKeyTakeFirst:
+					if skip[j] {
+						continue
+					}
+					skip[j] = true
+					d.warnf(nj, d.pathString(nj.Value), "mapping key %#v already defined at line %d, ignoring this value", nj.Value, ni.Line)
+				case DuplicateKeyTakeLast:
+					if skip[i] {
+						continue
+					}
+					skip[i] = true
+					d.warnf(ni, d.pathString(ni.Value), "mapping key %#v defined again at line %d, ignoring this value", ni.Value, nj.Line)
+				default:
+					d.keyError(nj, out, "line %d, column %d: mapping key %#v already defined at line %d, column %d", nj.Line, nj.Column, nj.Value, ni.Line, ni.Column)
 				}
 			}
 		}
@@ -776,14 +1833,20 @@ func (d *decoder) mapping(n *Node, out reflect.Value) (good bool) {
 			return false
 		}
 	}
+	if out.Type() == orderedMapType || out.Type() == pairsType {
+		return d.mappingPairs(n, out, skip)
+	}
+	if out.Type() == mapSliceType || d.mapSlice && out.Kind() == reflect.Interface && out.NumMethod() == 0 {
+		return d.mapSliceItems(n, out, skip)
+	}
 	switch out.Kind() {
 	case reflect.Struct:
-		return d.mappingStruct(n, out)
+		return d.mappingStruct(n, out, skip, partial)
 	case reflect.Map:
 		// okay
 	case reflect.Interface:
 		iface := out
-		if isStringMap(n) {
+		if d.stringKeys || d.isStringMap(n) {
 			out = reflect.MakeMap(d.stringMapType)
 		} else {
 			out = reflect.MakeMap(d.generalMapType)
@@ -813,13 +1876,23 @@ func (d *decoder) mapping(n *Node, out reflect.Value) (good bool) {
 		out.Set(reflect.MakeMap(outt))
 		mapIsNew = true
 	}
+	set := n.ShortTag() == setTag
 	for i := 0; i < l; i += 2 {
-		if isMerge(n.Content[i]) {
+		if d.isMerge(n.Content[i]) {
 			d.merge(n.Content[i+1], out)
 			continue
 		}
+		if skip != nil && skip[i] {
+			continue
+		}
 		k := reflect.New(kt).Elem()
-		if d.unmarshal(n.Content[i], k) {
+		var good bool
+		if d.stringKeys && kt.Kind() == reflect.String {
+			good = d.stringKey(n.Content[i], k)
+		} else {
+			good = d.unmarshal(n.Content[i], k)
+		}
+		if good {
 			kkind := k.Kind()
 			if kkind == reflect.Interface {
 				kkind = k.Elem().Kind()
@@ -828,7 +1901,17 @@ func (d *decoder) mapping(n *Node, out reflect.Value) (good bool) {
 				failf("invalid map key: %#v", k.Interface())
 			}
 			e := reflect.New(et).Elem()
-			if d.unmarshal(n.Content[i+1], e) || n.Content[i+1].ShortTag() == nullTag && (mapIsNew || !out.MapIndex(k).IsValid()) {
+			d.pushPath(n.Content[i].Value)
+			var ok bool
+			if set && et.Kind() == reflect.Bool && n.Content[i+1].ShortTag() == nullTag {
+				// Set members are present.
+				e.SetBool(true)
+				ok = true
+			} else {
+				ok = d.unmarshal(n.Content[i+1], e)
+			}
+			d.popPath()
+			if ok || n.Content[i+1].ShortTag() == nullTag && (mapIsNew || !out.MapIndex(k).IsValid()) {
 				out.SetMapIndex(k, e)
 			}
 		}
@@ -838,20 +1921,62 @@ func (d *decoder) mapping(n *Node, out reflect.Value) (good bool) {
 	return true
 }
 
-func isStringMap(n *Node) bool {
+// stringKey decodes the mapping key n into the string out, rendering
+// keys that are not strings in a canonical form, such as "null" for
+// nulls, "true" for booleans and "31" for the 0x1F integer.
+func (d *decoder) stringKey(n *Node, out reflect.Value) bool {
+	var key interface{}
+	if !d.unmarshal(n, reflect.ValueOf(&key).Elem()) {
+		return false
+	}
+	var s string
+	switch key := key.(type) {
+	case nil:
+		s = "null"
+	case string:
+		s = key
+	case bool:
+		s = strconv.FormatBool(key)
+	case int:
+		s = strconv.Itoa(key)
+	case int64:
+		s = strconv.FormatInt(key, 10)
+	case uint64:
+		s = strconv.FormatUint(key, 10)
+	case float64:
+		switch {
+		case math.IsInf(key, +1):
+			s = ".inf"
+		case math.IsInf(key, -1):
+			s = "-.inf"
+		case math.IsNaN(key):
+			s = ".nan"
+		default:
+			s = strconv.FormatFloat(key, 'g', -1, 64)
+		}
+	case time.Time:
+		s = key.Format(time.RFC3339Nano)
+	default:
+		failf("invalid map key: %#v", key)
+	}
+	out.SetString(s)
+	return true
+}
+
+func (d *decoder) isStringMap(n *Node) bool {
 	if n.Kind != MappingNode {
 		return false
 	}
 	l := len(n.Content)
 	for i := 0; i < l; i += 2 {
-		if n.Content[i].ShortTag() != strTag {
+		if n.Content[i].ShortTag() != strTag && !d.plainString(n.Content[i]) {
 			return false
 		}
 	}
 	return true
 }
 
-func (d *decoder) mappingStruct(n *Node, out reflect.Value) (good bool) {
+func (d *decoder) mappingStruct(n *Node, out reflect.Value, skip []bool, partial bool) (good bool) {
 	sinfo, err := getStructInfo(out.Type())
 	if err != nil {
 		panic(err)
@@ -871,26 +1996,37 @@ func (d *decoder) mappingStruct(n *Node, out reflect.Value) (good bool) {
 	}
 
 	var doneFields []bool
-	if d.uniqueKeys {
+	if d.uniqueKeys || sinfo.Required {
 		doneFields = make([]bool, len(sinfo.FieldsList))
 	}
+	// inlineRest holds the keys and values decoded into the inline interface.
+	var inlineRest []*Node
 	name := settableValueOf("")
 	l := len(n.Content)
 	for i := 0; i < l; i += 2 {
 		ni := n.Content[i]
-		if isMerge(ni) {
+		if d.isMerge(ni) {
 			d.merge(n.Content[i+1], out)
 			continue
 		}
+		if skip != nil && skip[i] {
+			continue
+		}
 		if !d.unmarshal(ni, name) {
 			continue
 		}
-		if info, ok := sinfo.FieldsMap[name.String()]; ok {
+		if info, ok := sinfo.field(name.String(), d.foldFields); ok {
 			if d.uniqueKeys {
 				if doneFields[info.Id] {
-					d.terrors = append(d.terrors, fmt.Sprintf("line %d: field %s already set in type %s", ni.Line, name.String(), out.Type()))
+					if d.duplicateKeys != DuplicateKeyError {
+						d.warnf(ni, d.pathString(name.String()), "field %s already set in type %s, ignoring this value", name.String(), out.Type())
+						continue
+					}
+					d.keyError(ni, out, "line %d: field %s already set in type %s", ni.Line, name.String(), out.Type())
 					continue
 				}
+			}
+			if doneFields != nil {
 				doneFields[info.Id] = true
 			}
 			var field reflect.Value
@@ -899,33 +2035,142 @@ func (d *decoder) mappingStruct(n *Node, out reflect.Value) (good bool) {
 			} else {
 				field = d.fieldByIndex(n, out, info.Inline)
 			}
+			d.pushPath(name.String())
+			discriminator := d.discriminator
+			d.discriminator = info.Discriminator
 			d.unmarshal(n.Content[i+1], field)
+			d.discriminator = discriminator
+			d.popPath()
 		} else if sinfo.InlineMap != -1 {
 			if inlineMap.IsNil() {
 				inlineMap.Set(reflect.MakeMap(inlineMap.Type()))
 			}
 			value := reflect.New(elemType).Elem()
+			d.pushPath(name.String())
 			d.unmarshal(n.Content[i+1], value)
+			d.popPath()
 			inlineMap.SetMapIndex(name, value)
-		} else if d.knownFields {
-			d.terrors = append(d.terrors, fmt.Sprintf("line %d: field %s not found in type %s", ni.Line, name.String(), out.Type()))
+		} else if sinfo.InlineIface != -1 {
+			inlineRest = append(inlineRest, ni, n.Content[i+1])
+		} else if d.knownFields || d.reportUnknown || d.warn != nil {
+			field := UnknownField{
+				Name:       name.String(),
+				Path:       d.pathString(name.String()),
+				Line:       ni.Line,
+				Column:     ni.Column,
+				Suggestion: closestName(name.String(), sinfo.keys()),
+			}
+			if !d.knownFields {
+				if d.reportUnknown {
+					d.unknownFields = append(d.unknownFields, field)
+				}
+				d.warnf(ni, field.Path, "field %s not found in type %s%s", field.Name, out.Type(), field.didYouMean())
+				continue
+			}
+			d.terrors = append(d.terrors, &UnmarshalError{
+				Message:      fmt.Sprintf("line %d: field %s not found in type %s%s", ni.Line, field.Name, out.Type(), field.didYouMean()),
+				Path:         field.Path,
+				Line:         field.Line,
+				Column:       field.Column,
+				ExpectedType: out.Type(),
+				UnknownField: &field,
+			})
 		}
 	}
+	if sinfo.InlineIface != -1 {
+		rest := &Node{Kind: MappingNode, Tag: mapTag, Line: n.Line, Column: n.Column, Content: inlineRest}
+		d.inlineIface(rest, out.Field(sinfo.InlineIface))
+	}
+	if sinfo.Required && !partial {
+		d.checkRequired(n, out, sinfo, doneFields)
+	}
 	return true
 }
 
+// checkRequired records an error for each field of out with the required
+// flag that isn't set by the mapping n, directly or through merge keys.
+func (d *decoder) checkRequired(n *Node, out reflect.Value, sinfo *structInfo, doneFields []bool) {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if d.isMerge(n.Content[i]) {
+			d.mergedFields(n.Content[i+1], sinfo, doneFields)
+		}
+	}
+	for _, info := range sinfo.FieldsList {
+		if info.Required && !doneFields[info.Id] {
+			d.terrors = append(d.terrors, &UnmarshalError{
+				Message:      fmt.Sprintf("line %d: missing required field %s in type %s", n.Line, info.Key, out.Type()),
+				Path:         d.pathString(info.Key),
+				Line:         n.Line,
+				Column:       n.Column,
+				ExpectedType: out.Type(),
+			})
+		}
+	}
+}
+
+// mergedFields marks in doneFields the fields set by the keys of the
+// mappings merged from n.
+func (d *decoder) mergedFields(n *Node, sinfo *structInfo, doneFields []bool) {
+	for n.Kind == AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	switch n.Kind {
+	case MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			if d.isMerge(k) {
+				d.mergedFields(n.Content[i+1], sinfo, doneFields)
+			} else if info, ok := sinfo.field(k.Value, d.foldFields); ok {
+				doneFields[info.Id] = true
+			}
+		}
+	case SequenceNode:
+		for _, c := range n.Content {
+			d.mergedFields(c, sinfo, doneFields)
+		}
+	}
+}
+
+// inlineIface decodes the keys in n into the dynamic value of the inline
+// interface field, or into a new map if the interface is nil.
+func (d *decoder) inlineIface(n *Node, field reflect.Value) {
+	if field.IsNil() {
+		if len(n.Content) > 0 {
+			d.unmarshal(n, field)
+		}
+		return
+	}
+	value := field.Elem()
+	if value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value = reflect.New(value.Type().Elem())
+			field.Set(value)
+		}
+		d.unmarshal(n, value.Elem())
+		return
+	}
+	kopy := reflect.New(value.Type()).Elem()
+	kopy.Set(value)
+	if d.unmarshal(n, kopy) {
+		field.Set(kopy)
+	}
+}
+
 func failWantMap() {
 	failf("map merge requires map or sequence of maps as the value")
 }
 
 func (d *decoder) merge(n *Node, out reflect.Value) {
+	defer func() { d.partial = false }()
 	switch n.Kind {
 	case MappingNode:
+		d.partial = true
 		d.unmarshal(n, out)
 	case AliasNode:
 		if n.Alias != nil && n.Alias.Kind != MappingNode {
 			failWantMap()
 		}
+		d.partial = true
 		d.unmarshal(n, out)
 	case SequenceNode:
 		// Step backwards as earlier nodes take precedence.
@@ -938,6 +2183,7 @@ func (d *decoder) merge(n *Node, out reflect.Value) {
 			} else if ni.Kind != MappingNode {
 				failWantMap()
 			}
+			d.partial = true
 			d.unmarshal(ni, out)
 		}
 	default:
@@ -948,3 +2194,95 @@ func (d *decoder) merge(n *Node, out reflect.Value) {
 func isMerge(n *Node) bool {
 	return n.Kind == ScalarNode && n.Value == "<<" && (n.Tag == "" || n.Tag == "!" || shortTag(n.Tag) == mergeTag)
 }
+
+func (d *decoder) isMerge(n *Node) bool {
+	return d.mergeKeys != MergeKeyKeep && isMerge(n)
+}
+
+// expandMerges returns n with the merge keys in its mappings replaced by
+// the merged entries, copying the nodes that change. The expanded copies
+// are recorded in done, so aliases may refer to them.
+func expandMerges(n *Node, done map[*Node]*Node) *Node {
+	if e, ok := done[n]; ok {
+		return e
+	}
+	switch n.Kind {
+	case AliasNode:
+		done[n] = n
+		if n.Alias != nil {
+			if alias := expandMerges(n.Alias, done); alias != n.Alias {
+				e := *n
+				e.Alias = alias
+				done[n] = &e
+			}
+		}
+	case DocumentNode, SequenceNode, MappingNode:
+		// Register n first, as aliases within it may refer to it.
+		done[n] = n
+		var content []*Node
+		changed := false
+		if n.Kind == MappingNode {
+			content, changed = expandMapping(n, done)
+		} else {
+			for _, ni := range n.Content {
+				e := expandMerges(ni, done)
+				changed = changed || e != ni
+				content = append(content, e)
+			}
+		}
+		if changed {
+			e := *n
+			e.Content = content
+			done[n] = &e
+		}
+	default:
+		return n
+	}
+	return done[n]
+}
+
+func expandMapping(n *Node, done map[*Node]*Node) (content []*Node, changed bool) {
+	defined := make(map[string]bool)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if ni := n.Content[i]; ni.Kind == ScalarNode && !isMerge(ni) {
+			defined[ni.Value] = true
+		}
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		ni, nv := n.Content[i], n.Content[i+1]
+		if !isMerge(ni) {
+			e := expandMerges(nv, done)
+			changed = changed || e != nv
+			content = append(content, ni, e)
+			continue
+		}
+		changed = true
+		var sources []*Node
+		if nv.Kind == SequenceNode {
+			sources = nv.Content
+		} else {
+			sources = []*Node{nv}
+		}
+		// Earlier mappings take precedence.
+		for _, source := range sources {
+			source = expandMerges(source, done)
+			if source.Kind == AliasNode && source.Alias != nil {
+				source = source.Alias
+			}
+			if source.Kind != MappingNode {
+				failWantMap()
+			}
+			for j := 0; j+1 < len(source.Content); j += 2 {
+				key := source.Content[j]
+				if key.Kind == ScalarNode {
+					if defined[key.Value] {
+						continue
+					}
+					defined[key.Value] = true
+				}
+				content = append(content, key, source.Content[j+1])
+			}
+		}
+	}
+	return content, changed
+}
@@ -17,12 +17,15 @@ package yaml_test
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"math"
+	"net"
 	"reflect"
 	"strings"
+	"testing/iotest"
 	"time"
 
 	. "gopkg.in/check.v1"
@@ -586,6 +589,25 @@ var unmarshalTests = []struct {
 		}{1, map[string]int{"b": 2, "c": 3}},
 	},
 
+	// Fall back to json tags.
+	{
+		"x: 1\nb: 2\nc: 3\n",
+		&struct {
+			A int `json:"x"`
+			B int `json:"b,omitempty,string"`
+			C int `json:"-"`
+		}{A: 1, B: 2},
+	},
+
+	// Interface inlining
+	{
+		"a: 1\nb: 2\nc: 3\n",
+		&struct {
+			A int
+			C interface{} `yaml:",inline"`
+		}{1, map[string]interface{}{"b": 2, "c": 3}},
+	},
+
 	// bug 1243827
 	{
 		"a: -b_c",
@@ -642,6 +664,42 @@ var unmarshalTests = []struct {
 		map[string]string{"a": strings.Repeat("\x00", 52)},
 	},
 
+	// Ordered mappings.
+	{
+		"a: !!omap [b: 1, a: [2]]\n",
+		map[string]interface{}{"a": yaml.OrderedMap{{"b", 1}, {"a", []interface{}{2}}}},
+	}, {
+		"a: !!pairs\n- b: 1\n- b: 2\n",
+		map[string]interface{}{"a": yaml.Pairs{{"b", 1}, {"b", 2}}},
+	}, {
+		"a: !!pairs [b: 1, c: 2]\n",
+		map[string]yaml.OrderedMap{"a": {{"b", 1}, {"c", 2}}},
+	}, {
+		"a: {c: 1, b: 2}\n",
+		map[string]yaml.Pairs{"a": {{"c", 1}, {"b", 2}}},
+	},
+
+	// MapSlice.
+	{
+		"b: 1\na: {d: 2, c: 3}\n",
+		&yaml.MapSlice{{Key: "b", Value: 1}, {Key: "a", Value: yaml.MapSlice{{Key: "d", Value: 2}, {Key: "c", Value: 3}}}},
+	}, {
+		"v: {b: 1, a: 2}\n",
+		map[string]yaml.MapSlice{"v": {{Key: "b", Value: 1}, {Key: "a", Value: 2}}},
+	},
+
+	// Sets.
+	{
+		"a: !!set {x, z}\n",
+		map[string]map[string]struct{}{"a": {"x": {}, "z": {}}},
+	}, {
+		"a: !!set\n  ? x\n  ? z\n",
+		map[string]map[string]bool{"a": {"x": true, "z": true}},
+	}, {
+		"a: !!set {1, 2}\n",
+		map[string]map[int]bool{"a": {1: true, 2: true}},
+	},
+
 	// Issue #39.
 	{
 		"a:\n b:\n  c: d\n",
@@ -663,6 +721,13 @@ var unmarshalTests = []struct {
 		"a: 2015-02-24T18:19:39Z\n",
 		map[string]textUnmarshaler{"a": textUnmarshaler{"2015-02-24T18:19:39Z"}},
 	},
+	{
+		"a: 10.0.0.1\nb: [1.2.3.4, \"::1\"]\n",
+		&struct {
+			A net.IP
+			B []net.IP
+		}{net.IPv4(10, 0, 0, 1), []net.IP{net.IPv4(1, 2, 3, 4), net.ParseIP("::1")}},
+	},
 
 	// Timestamps
 	{
@@ -690,18 +755,21 @@ var unmarshalTests = []struct {
 		"a: 2015-02-24 18:19:39\n",
 		map[string]time.Time{"a": time.Date(2015, 2, 24, 18, 19, 39, 0, time.UTC)},
 	},
-	// Some cases not currently handled. Uncomment these when
-	// the code is fixed.
-	//	{
-	//		// space separated with time zone
-	//		"a: 2001-12-14 21:59:43.10 -5",
-	//		map[string]interface{}{"a": time.Date(2001, 12, 14, 21, 59, 43, .1e9, time.UTC)},
-	//	},
-	//	{
-	//		// arbitrary whitespace between fields
-	//		"a: 2001-12-14 \t\t \t21:59:43.10 \t Z",
-	//		map[string]interface{}{"a": time.Date(2001, 12, 14, 21, 59, 43, .1e9, time.UTC)},
-	//	},
+	{
+		// space separated with time zone
+		"a: 2001-12-14 21:59:43.10 -5",
+		map[string]time.Time{"a": time.Date(2001, 12, 14, 21, 59, 43, .1e9, time.FixedZone("", -5*60*60))},
+	},
+	{
+		// space separated with full time zone
+		"a: 2001-12-14 21:59:43.10+05:30",
+		map[string]time.Time{"a": time.Date(2001, 12, 14, 21, 59, 43, .1e9, time.FixedZone("", 5*60*60+30*60))},
+	},
+	{
+		// arbitrary whitespace between fields
+		"a: 2001-12-14 \t\t \t21:59:43.10 \t Z",
+		map[string]interface{}{"a": time.Date(2001, 12, 14, 21, 59, 43, .1e9, time.UTC)},
+	},
 	{
 		// explicit string tag
 		"a: !!str 2015-01-01",
@@ -916,6 +984,48 @@ func (s *S) TestDecoderReadError(c *C) {
 	c.Assert(err, ErrorMatches, `yaml: input error: some read error`)
 }
 
+type failingWriter struct{}
+
+var errWriteFailed = errors.New("write failed")
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWriteFailed
+}
+
+func (s *S) TestUnmarshalBinary(c *C) {
+	type bytesType []byte
+	var v struct {
+		A []byte
+		B bytesType
+		C io.Writer
+		D string
+	}
+	var buf bytes.Buffer
+	v.C = &buf
+	data := "a: !!binary aGVsbG8=\nb: !!binary d29ybGQ=\nc: !!binary |\n  Zmlyc3Qg\n  bGluZQ==\nd: !!binary dGV4dA==\n"
+	err := yaml.Unmarshal([]byte(data), &v)
+	c.Assert(err, IsNil)
+	c.Assert(string(v.A), Equals, "hello")
+	c.Assert(string(v.B), Equals, "world")
+	c.Assert(buf.String(), Equals, "first line")
+	c.Assert(v.D, Equals, "text")
+
+	v.C = failingWriter{}
+	err = yaml.Unmarshal([]byte("c: !!binary aGVsbG8="), &v)
+	c.Assert(err, ErrorMatches, "yaml: cannot write binary value: write failed")
+	c.Assert(errors.Is(err, errWriteFailed), Equals, true)
+
+	v.C = &buf
+	err = yaml.Unmarshal([]byte("c: !!binary aGVs*G8="), &v)
+	c.Assert(err, ErrorMatches, "yaml: !!binary value contains invalid base64 data")
+
+	// Slices of other byte types can't hold binary data.
+	type myByte uint8
+	var w struct{ A []myByte }
+	err = yaml.Unmarshal([]byte("a: !!binary aGk="), &w)
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n  line 1: cannot unmarshal !!binary `aGk=` into \\[\\]yaml_test.myByte")
+}
+
 func (s *S) TestUnmarshalNaN(c *C) {
 	value := map[string]interface{}{}
 	err := yaml.Unmarshal([]byte("notanum: .NaN"), &value)
@@ -937,16 +1047,26 @@ var unmarshalErrorTests = []struct {
 	{"v: [A,", "yaml: line 1: did not find expected node content"},
 	{"v:\n- [A,", "yaml: line 2: did not find expected node content"},
 	{"a:\n- b: *,", "yaml: line 2: did not find expected alphabetic or numeric character"},
-	{"a: *b\n", "yaml: unknown anchor 'b' referenced"},
+	{"a: *b\n", "yaml: line 1, column 4: unknown anchor 'b' referenced"},
+	{"a: &value 1\nb: *valeu\n", "yaml: line 2, column 4: unknown anchor 'valeu' referenced, did you mean 'value'\\?"},
+	{"a: &value 1\nb: *other\n", "yaml: line 2, column 4: unknown anchor 'other' referenced"},
 	{"a: &a\n  b: *a\n", "yaml: anchor 'a' value contains itself"},
 	{"value: -", "yaml: block sequence entries are not allowed in this context"},
 	{"a: !!binary ==", "yaml: !!binary value contains invalid base64 data"},
+	{"a: !!omap [b: 1, b: 2]", "yaml: unmarshal errors:\n  line 1, column 18: mapping key \"b\" already defined at line 1, column 12"},
+	{"a: !!omap [b: 1, c]", "yaml: unmarshal errors:\n  line 1: cannot unmarshal !!str `c` into yaml.OrderedMap"},
 	{"{[.]}", `yaml: invalid map key: \[\]interface \{\}\{"\."\}`},
 	{"{{.}}", `yaml: invalid map key: map\[string]interface \{\}\{".":interface \{\}\(nil\)\}`},
-	{"b: *a\na: &a {c: 1}", `yaml: unknown anchor 'a' referenced`},
+	{"b: *a\na: &a {c: 1}", `yaml: line 1, column 4: unknown anchor 'a' referenced`},
 	{"%TAG !%79! tag:yaml.org,2002:\n---\nv: !%79!int '1'", "yaml: did not find expected whitespace"},
 	{"a:\n  1:\nb\n  2:", ".*could not find expected ':'"},
 	{"a: 1\nb: 2\nc 2\nd: 3\n", "^yaml: line 3: could not find expected ':'$"},
+	{"a:\n\tb: 1\n", `yaml: line 2, column 1: found a tab character used for indentation; did you mean to indent with spaces\?`},
+	{"a:\n  b: 1\n  \tc: 2\n", `yaml: line 3, column 3: found a tab character used for indentation; did you mean to indent with spaces\?`},
+	{"a: b: c # d\n", `yaml: line 1, column 5: found ": " in a plain scalar; did you mean to quote it, as in a: "b: c"\?`},
+	{"a:\n  b:1\n  c: 2\n", `yaml: line 2, column 4: missing space after ':'; did you mean "b: 1"\?`},
+	{"- a\n-b\n- c\n", `yaml: line 2, column 1: missing space after '-'; did you mean "- b"\?`},
+	{"a:\n - b\n -c: 1\n", `yaml: line 3, column 2: missing space after '-'; did you mean "- c: 1"\?`},
 	{
 		"a: &a [00,00,00,00,00,00,00,00,00]\n" +
 			"b: &b [*a,*a,*a,*a,*a,*a,*a,*a,*a]\n" +
@@ -978,6 +1098,34 @@ func (s *S) TestDecoderErrors(c *C) {
 	}
 }
 
+func (s *S) TestSyntaxError(c *C) {
+	data := "\xef\xbb\xbfa: 1\nb: \"é\" c: 2\n"
+	check := func(err error) {
+		c.Assert(err, ErrorMatches, "yaml: line 2: mapping values are not allowed in this context")
+		serr, ok := err.(*yaml.SyntaxError)
+		c.Assert(ok, Equals, true)
+		c.Assert(serr.Message, Equals, "mapping values are not allowed in this context")
+		c.Assert(serr.Line, Equals, 2)
+		c.Assert(serr.Column, Equals, 9)
+		c.Assert(serr.Offset, Equals, 17)
+		c.Assert(serr.Snippet, Equals, `b: "é" c: 2`)
+		c.Assert(serr.Caret, Equals, 8)
+	}
+	var v interface{}
+	check(yaml.Unmarshal([]byte(data), &v))
+	check(yaml.NewDecoder(strings.NewReader(data)).Decode(&v))
+
+	// Only the recently read lines are kept with a reader.
+	data = strings.Repeat("- x\n", 100000) + "- a: b: c\n"
+	err := yaml.NewDecoder(strings.NewReader(data)).Decode(&v)
+	serr, ok := err.(*yaml.SyntaxError)
+	c.Assert(ok, Equals, true)
+	c.Assert(serr.Line, Equals, 100001)
+	c.Assert(serr.Snippet, Equals, "- a: b: c")
+	c.Assert(serr.Offset, Equals, len(data)-4)
+	c.Assert(serr.Caret, Equals, 6)
+}
+
 var unmarshalerTests = []struct {
 	data, tag string
 	value     interface{}
@@ -1109,9 +1257,49 @@ func (s *S) TestUnmarshalerWholeDocument(c *C) {
 	c.Assert(value["_"], DeepEquals, unmarshalerTests[0].value)
 }
 
+func (s *S) TestTypeErrorEntries(c *C) {
+	type T struct {
+		A int
+		B []int
+	}
+	var v T
+	dec := yaml.NewDecoder(strings.NewReader("a: x\nb: [1, y]\nc: 3\n"))
+	dec.KnownFields(true)
+	err := dec.Decode(&v)
+	c.Assert(err, ErrorMatches, ""+
+		"yaml: unmarshal errors:\n"+
+		"  line 1: cannot unmarshal !!str `x` into int\n"+
+		"  line 2: cannot unmarshal !!str `y` into int\n"+
+		"  line 3: field c not found in type yaml_test.T")
+	terr, ok := err.(*yaml.TypeError)
+	c.Assert(ok, Equals, true)
+	c.Assert(terr.Details, DeepEquals, []*yaml.UnmarshalError{{
+		Message:      "line 1: cannot unmarshal !!str `x` into int",
+		Path:         "a",
+		Line:         1,
+		Column:       4,
+		ExpectedType: reflect.TypeOf(0),
+		ActualTag:    "!!str",
+	}, {
+		Message:      "line 2: cannot unmarshal !!str `y` into int",
+		Path:         "b[1]",
+		Line:         2,
+		Column:       8,
+		ExpectedType: reflect.TypeOf(0),
+		ActualTag:    "!!str",
+	}, {
+		Message:      "line 3: field c not found in type yaml_test.T",
+		Path:         "c",
+		Line:         3,
+		Column:       1,
+		ExpectedType: reflect.TypeOf(v),
+		UnknownField: &yaml.UnknownField{Name: "c", Path: "c", Line: 3, Column: 1},
+	}})
+}
+
 func (s *S) TestUnmarshalerTypeError(c *C) {
-	unmarshalerResult[2] = &yaml.TypeError{[]string{"foo"}}
-	unmarshalerResult[4] = &yaml.TypeError{[]string{"bar"}}
+	unmarshalerResult[2] = &yaml.TypeError{Errors: []string{"foo"}}
+	unmarshalerResult[4] = &yaml.TypeError{Errors: []string{"bar"}}
 	defer func() {
 		delete(unmarshalerResult, 2)
 		delete(unmarshalerResult, 4)
@@ -1141,8 +1329,8 @@ func (s *S) TestUnmarshalerTypeError(c *C) {
 }
 
 func (s *S) TestObsoleteUnmarshalerTypeError(c *C) {
-	unmarshalerResult[2] = &yaml.TypeError{[]string{"foo"}}
-	unmarshalerResult[4] = &yaml.TypeError{[]string{"bar"}}
+	unmarshalerResult[2] = &yaml.TypeError{Errors: []string{"foo"}}
+	unmarshalerResult[4] = &yaml.TypeError{Errors: []string{"bar"}}
 	defer func() {
 		delete(unmarshalerResult, 2)
 		delete(unmarshalerResult, 4)
@@ -1528,7 +1716,7 @@ var unmarshalStrictTests = []struct {
 	unique: true,
 	data:   "a: 1\nb: 2\na: 3\n",
 	value:  struct{ A, B int }{A: 3, B: 2},
-	error:  `yaml: unmarshal errors:\n  line 3: mapping key "a" already defined at line 1`,
+	error:  `yaml: unmarshal errors:\n  line 3, column 1: mapping key "a" already defined at line 1, column 1`,
 }, {
 	unique: true,
 	data:   "c: 3\na: 1\nb: 2\nc: 4\n",
@@ -1544,7 +1732,7 @@ var unmarshalStrictTests = []struct {
 			},
 		},
 	},
-	error: `yaml: unmarshal errors:\n  line 4: mapping key "c" already defined at line 1`,
+	error: `yaml: unmarshal errors:\n  line 4, column 1: mapping key "c" already defined at line 1, column 1`,
 }, {
 	unique: true,
 	data:   "c: 0\na: 1\nb: 2\nc: 1\n",
@@ -1560,7 +1748,7 @@ var unmarshalStrictTests = []struct {
 			},
 		},
 	},
-	error: `yaml: unmarshal errors:\n  line 4: mapping key "c" already defined at line 1`,
+	error: `yaml: unmarshal errors:\n  line 4, column 1: mapping key "c" already defined at line 1, column 1`,
 }, {
 	unique: true,
 	data:   "c: 1\na: 1\nb: 2\nc: 3\n",
@@ -1574,7 +1762,7 @@ var unmarshalStrictTests = []struct {
 			"c": 3,
 		},
 	},
-	error: `yaml: unmarshal errors:\n  line 4: mapping key "c" already defined at line 1`,
+	error: `yaml: unmarshal errors:\n  line 4, column 1: mapping key "c" already defined at line 1, column 1`,
 }, {
 	unique: true,
 	data:   "a: 1\n9: 2\nnull: 3\n9: 4",
@@ -1583,7 +1771,7 @@ var unmarshalStrictTests = []struct {
 		nil: 3,
 		9:   4,
 	},
-	error: `yaml: unmarshal errors:\n  line 4: mapping key "9" already defined at line 2`,
+	error: `yaml: unmarshal errors:\n  line 4, column 1: mapping key "9" already defined at line 2, column 1`,
 }}
 
 func (s *S) TestUnmarshalKnownFields(c *C) {
@@ -1608,6 +1796,924 @@ func (s *S) TestUnmarshalKnownFields(c *C) {
 	}
 }
 
+func (s *S) TestDecoderDuplicateKeyPolicy(c *C) {
+	data := "a: 1\nb: 2\na: 3\nc:\n  x: 4\n  x: 5\n"
+	tests := []struct {
+		policy yaml.DuplicateKeyPolicy
+		want   map[string]interface{}
+		error  string
+	}{{
+		policy: yaml.DuplicateKeyError,
+		error:  `yaml: unmarshal errors:\n  line 3, column 1: mapping key "a" already defined at line 1, column 1`,
+	}, {
+		policy: yaml.DuplicateKeyTakeFirst,
+		want:   map[string]interface{}{"a": 1, "b": 2, "c": map[string]interface{}{"x": 4}},
+	}, {
+		policy: yaml.DuplicateKeyTakeLast,
+		want:   map[string]interface{}{"a": 3, "b": 2, "c": map[string]interface{}{"x": 5}},
+	}}
+	for _, test := range tests {
+		var v map[string]interface{}
+		dec := yaml.NewDecoder(strings.NewReader(data))
+		dec.DuplicateKeyPolicy(test.policy)
+		err := dec.Decode(&v)
+		if test.error != "" {
+			c.Assert(err, ErrorMatches, test.error)
+			continue
+		}
+		c.Assert(err, IsNil)
+		c.Assert(v, DeepEquals, test.want)
+
+		var st struct{ A, B int }
+		dec = yaml.NewDecoder(strings.NewReader("a: 1\nb: 2\na: 3\n"))
+		dec.DuplicateKeyPolicy(test.policy)
+		c.Assert(dec.Decode(&st), IsNil)
+		c.Assert(st.A, Equals, test.want["a"])
+	}
+}
+
+func (s *S) TestDecoderSetSchema(c *C) {
+	tests := []struct {
+		schema yaml.Schema
+		data   string
+		want   []interface{}
+		error  string
+	}{{
+		schema: yaml.DefaultSchema,
+		data:   "[~, True, 0777, 0x1F, 1_000, 2001-12-14, foo]",
+		want:   []interface{}{nil, true, 511, 31, 1000, time.Date(2001, 12, 14, 0, 0, 0, 0, time.UTC), "foo"},
+	}, {
+		schema: yaml.FailsafeSchema,
+		data:   "[~, true, 10, 1.5, '2', !!int 3]",
+		want:   []interface{}{"~", "true", "10", "1.5", "2", 3},
+	}, {
+		schema: yaml.JSONSchema,
+		data:   `[null, true, false, -10, 1.5e3, "foo", !!str bar]`,
+		want:   []interface{}{nil, true, false, -10, 1500.0, "foo", "bar"},
+	}, {
+		schema: yaml.JSONSchema,
+		data:   "[1, True]",
+		error:  "yaml: line 1: cannot resolve plain scalar `True` with the JSON schema",
+	}, {
+		schema: yaml.CoreSchema,
+		data:   "[~, True, 0777, 0o17, 0x1F, 1_000, 2001-12-14, -.inf]",
+		want:   []interface{}{nil, true, 777, 15, 31, "1_000", "2001-12-14", math.Inf(-1)},
+	}, {
+		schema: yaml.CoreSchema,
+		data:   "[0x-1, 0x+1, 0o-7, 0o+7, 0o8, 0xG, 0x, -0x1]",
+		want:   []interface{}{"0x-1", "0x+1", "0o-7", "0o+7", "0o8", "0xG", "0x", "-0x1"},
+	}}
+	for _, test := range tests {
+		var v []interface{}
+		dec := yaml.NewDecoder(strings.NewReader(test.data))
+		dec.SetSchema(test.schema)
+		err := dec.Decode(&v)
+		if test.error != "" {
+			c.Assert(err, ErrorMatches, test.error)
+			continue
+		}
+		c.Assert(err, IsNil)
+		c.Assert(v, DeepEquals, test.want)
+	}
+}
+
+func (s *S) TestDecoderPlainScalarsAsStrings(c *C) {
+	data := "a: 1\nb: true\nc: ~\nd:\n1.5: !!int 2\nf: [0x1F, '3']\ng: 4\n"
+	var v map[string]interface{}
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.PlainScalarsAsStrings(true)
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(v, DeepEquals, map[string]interface{}{
+		"a":   "1",
+		"b":   "true",
+		"c":   "~",
+		"d":   "",
+		"1.5": 2,
+		"f":   []interface{}{"0x1F", "3"},
+		"g":   "4",
+	})
+
+	var st struct {
+		A int
+		B bool
+		G interface{}
+	}
+	dec = yaml.NewDecoder(strings.NewReader(data))
+	dec.PlainScalarsAsStrings(true)
+	c.Assert(dec.Decode(&st), IsNil)
+	c.Assert(st.A, Equals, 1)
+	c.Assert(st.B, Equals, true)
+	c.Assert(st.G, Equals, "4")
+}
+
+func (s *S) TestDecoderStringKeys(c *C) {
+	data := "1: a\n0x1F: b\ntrue: c\n~: d\n1.50: e\n2001-12-14: f\nnested:\n  2: g\n"
+	var v interface{}
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.StringKeys(true)
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(v, DeepEquals, map[string]interface{}{
+		"1":                    "a",
+		"31":                   "b",
+		"true":                 "c",
+		"null":                 "d",
+		"1.5":                  "e",
+		"2001-12-14T00:00:00Z": "f",
+		"nested":               map[string]interface{}{"2": "g"},
+	})
+
+	dec = yaml.NewDecoder(strings.NewReader("[1, 2]: a\n"))
+	dec.StringKeys(true)
+	c.Assert(dec.Decode(&v), ErrorMatches, `yaml: invalid map key: \[\]interface \{\}\{1, 2\}`)
+}
+
+func (s *S) TestUnmarshalRawNode(c *C) {
+	data := "a: &x {b: 1}\nc: [2, 3]\nd: *x\ne: ~\n"
+	var v map[string]yaml.RawNode
+	c.Assert(yaml.Unmarshal([]byte(data), &v), IsNil)
+	c.Assert(v, HasLen, 4)
+	c.Assert(v["a"].Node().Kind, Equals, yaml.MappingNode)
+	c.Assert(v["a"].Node().Line, Equals, 1)
+	c.Assert(v["e"].Node().ShortTag(), Equals, "!!null")
+
+	var c1 []int
+	c.Assert(v["c"].Decode(&c1), IsNil)
+	c.Assert(c1, DeepEquals, []int{2, 3})
+	var d map[string]int
+	c.Assert(v["d"].Decode(&d), IsNil)
+	c.Assert(d, DeepEquals, map[string]int{"b": 1})
+	c.Assert(yaml.RawNode{}.Decode(&d), IsNil)
+
+	var p map[string]*yaml.RawNode
+	c.Assert(yaml.Unmarshal([]byte(data), &p), IsNil)
+	c.Assert(p["c"].Node().Kind, Equals, yaml.SequenceNode)
+
+	out, err := yaml.Marshal(map[string]yaml.RawNode{"a": v["c"], "b": {}})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "a: [2, 3]\nb: null\n")
+}
+
+var decodePathTests = []struct {
+	data  string
+	path  string
+	value interface{}
+}{
+	{"metadata:\n  name: foo\n", "metadata.name", "foo"},
+	{"metadata:\n  name: foo\n", "metadata", map[string]interface{}{"name": "foo"}},
+	{"a: 1\n", "", map[string]interface{}{"a": 1}},
+	{"spec:\n  containers:\n  - name: a\n  - name: b\n    image: c\n", "spec.containers.1.image", "c"},
+	{"base: &b {x: 1, y: 2}\nv:\n  <<: *b\n  y: 3\n", "v.x", 1},
+	{"base: &b {x: 1, y: 2}\nv:\n  <<: *b\n  y: 3\n", "v.y", 3},
+	{"a: &a {b: 1}\nc: *a\n", "c.b", 1},
+	// Parsing stops once the value is read.
+	{"a: 1\nb: [\n", "a", 1},
+}
+
+func (s *S) TestDecodePath(c *C) {
+	for _, item := range decodePathTests {
+		var v interface{}
+		err := yaml.DecodePath([]byte(item.data), item.path, &v)
+		c.Assert(err, IsNil, Commentf("path %q in %q", item.path, item.data))
+		c.Assert(v, DeepEquals, item.value, Commentf("path %q in %q", item.path, item.data))
+	}
+
+	var name string
+	c.Assert(yaml.DecodePath([]byte("a: {b: 1}\n"), "a.c", &name), Equals, yaml.ErrPathNotFound)
+	c.Assert(yaml.DecodePath([]byte("a: [1]\n"), "a.1", &name), Equals, yaml.ErrPathNotFound)
+	c.Assert(yaml.DecodePath([]byte("a: 1\n"), "a.b", &name), Equals, yaml.ErrPathNotFound)
+	c.Assert(yaml.DecodePath([]byte(""), "a", &name), Equals, yaml.ErrPathNotFound)
+	c.Assert(yaml.DecodePath([]byte("a: [\n"), "b", &name), ErrorMatches, "yaml: line 1: did not find expected node content")
+}
+
+func (s *S) TestDecoderSkip(c *C) {
+	data := "a: [1, {b: 2}]\n---\n# c\nc: 3\n...\n%TAG !e! tag:example.com,2000:\n--- !e!d\nd: 4\n---\ne: 5\n"
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	c.Assert(dec.Skip(), IsNil)
+	c.Assert(dec.Skip(), IsNil)
+	var v map[string]int
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(v, DeepEquals, map[string]int{"d": 4})
+	c.Assert(dec.Skip(), IsNil)
+	c.Assert(dec.Skip(), Equals, io.EOF)
+	c.Assert(dec.Decode(&v), Equals, io.EOF)
+
+	dec = yaml.NewDecoder(strings.NewReader("a: 1\n"))
+	c.Assert(dec.Skip(), IsNil)
+	c.Assert(dec.Decode(&v), Equals, io.EOF)
+}
+
+func (s *S) TestDecoderStreamSequence(c *C) {
+	data := "kind: List\nitems:\n- name: a\n  tags: [x]\n- name: b\n- name: c\nmetadata: {items: [d]}\n---\nitems: [e, f]\n"
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	var names []string
+	dec.StreamSequence("items", func(item *yaml.Node) error {
+		var v struct{ Name string }
+		if err := item.Decode(&v); err != nil {
+			return err
+		}
+		names = append(names, v.Name)
+		return nil
+	})
+	var v struct {
+		Kind     string
+		Items    []interface{}
+		Metadata map[string][]string
+	}
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(names, DeepEquals, []string{"a", "b", "c"})
+	c.Assert(v.Kind, Equals, "List")
+	c.Assert(v.Items, HasLen, 0)
+	c.Assert(v.Metadata, DeepEquals, map[string][]string{"items": {"d"}})
+
+	var items []string
+	dec.StreamSequence("items", func(item *yaml.Node) error {
+		items = append(items, item.Value)
+		return nil
+	})
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(items, DeepEquals, []string{"e", "f"})
+
+	// Sequences within the elements are kept whole.
+	dec = yaml.NewDecoder(strings.NewReader("items:\n- [1, 2]\n- {a: [3]}\n- - [4]\n"))
+	var nested []interface{}
+	dec.StreamSequence("items", func(item *yaml.Node) error {
+		var v interface{}
+		if err := item.Decode(&v); err != nil {
+			return err
+		}
+		nested = append(nested, v)
+		return nil
+	})
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(nested, DeepEquals, []interface{}{
+		[]interface{}{1, 2},
+		map[string]interface{}{"a": []interface{}{3}},
+		[]interface{}{[]interface{}{4}},
+	})
+
+	// Nested paths, and errors from the callback.
+	errStop := errors.New("stop")
+	dec = yaml.NewDecoder(strings.NewReader("spec:\n  lists:\n  - [1, 2]\n  - [3, 4, 5]\n"))
+	var seen []string
+	dec.StreamSequence("spec.lists.1", func(item *yaml.Node) error {
+		seen = append(seen, item.Value)
+		if item.Value == "4" {
+			return errStop
+		}
+		return nil
+	})
+	c.Assert(dec.Decode(&v), Equals, errStop)
+	c.Assert(seen, DeepEquals, []string{"3", "4"})
+
+	// Without streaming, the sequence is decoded as usual.
+	dec = yaml.NewDecoder(strings.NewReader("items: [1, 2]\n"))
+	dec.StreamSequence("items", func(item *yaml.Node) error {
+		c.Fatalf("unexpected item %v", item.Value)
+		return nil
+	})
+	dec.StreamSequence("items", nil)
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(v.Items, DeepEquals, []interface{}{1, 2})
+}
+
+func (s *S) TestDecoderReset(c *C) {
+	dec := yaml.NewDecoder(strings.NewReader("a: 1\n---\nb: 2\n"))
+	dec.KnownFields(true)
+	var v struct{ A, B int }
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(dec.BytesRead(), Not(Equals), int64(0))
+
+	dec.Reset(strings.NewReader("b: 3\n"))
+	c.Assert(dec.BytesRead(), Equals, int64(0))
+	v.A, v.B = 0, 0
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(v.B, Equals, 3)
+	c.Assert(dec.Decode(&v), Equals, io.EOF)
+
+	dec.Reset(strings.NewReader("c: 4\n"))
+	c.Assert(dec.Decode(&v), ErrorMatches, "yaml: unmarshal errors:\n  line 1: field c not found in type .*")
+
+	dec.Reset(strings.NewReader("a: [1\n"))
+	c.Assert(dec.Decode(&v), NotNil)
+	dec.Reset(strings.NewReader("a: 5\n"))
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(v.A, Equals, 5)
+}
+
+func (s *S) TestDecoderCopyAliases(c *C) {
+	type T struct {
+		A, B struct{ C yaml.Node }
+	}
+	data := "a: &x {c: [1, 2]}\nb: *x\n"
+	for _, copyAliases := range []bool{false, true} {
+		var v T
+		dec := yaml.NewDecoder(strings.NewReader(data))
+		dec.CopyAliases(copyAliases)
+		c.Assert(dec.Decode(&v), IsNil)
+		v.B.C.Content[0].Value = "3"
+		v.B.C.Content = append(v.B.C.Content[:1], v.B.C.Content[2:]...)
+		c.Assert(v.B.C.Content, HasLen, 1)
+		if copyAliases {
+			c.Assert(v.A.C.Content, HasLen, 2)
+			c.Assert(v.A.C.Content[0].Value, Equals, "1")
+		} else {
+			c.Assert(v.A.C.Content[0].Value, Equals, "3")
+		}
+	}
+}
+
+func (s *S) TestDecoderPreserveTemplates(c *C) {
+	data := "image: {{ .Values.image }}:{{ .Values.tag | default \"latest\" }}\n" +
+		"labels: {{- include \"labels\" . | nindent 2 }}\n" +
+		"ports: [{{ .Values.port }}, 80]\n" +
+		"{{ .Values.key }}: value # comment\n"
+	var v map[string]interface{}
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.PreserveTemplates(true)
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(v, DeepEquals, map[string]interface{}{
+		"image":             `{{ .Values.image }}:{{ .Values.tag | default "latest" }}`,
+		"labels":            `{{- include "labels" . | nindent 2 }}`,
+		"ports":             []interface{}{"{{ .Values.port }}", 80},
+		"{{ .Values.key }}": "value",
+	})
+
+	dec = yaml.NewDecoder(strings.NewReader(data))
+	c.Assert(dec.Decode(&v), NotNil)
+
+	dec = yaml.NewDecoder(strings.NewReader("a: 1\nb: {{ .b\n}}\n"))
+	dec.PreserveTemplates(true)
+	c.Assert(dec.Decode(&v), ErrorMatches, "yaml: line 2: found unterminated template action")
+}
+
+func (s *S) TestDecoderExpandVariables(c *C) {
+	vars := map[string]string{"N": "3", "HOST": "example.com", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	data := "replicas: $N\nurl: 'https://${HOST}:$PORT/'\nprice: $$5 $5\nempty: ${EMPTY}\n$N: !!str $N\n"
+	var v map[interface{}]interface{}
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.ExpandVariables(lookup)
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(v, DeepEquals, map[interface{}]interface{}{
+		"replicas": 3,
+		"url":      "https://example.com:/",
+		"price":    "$5 $5",
+		"empty":    nil,
+		3:          "$N",
+	})
+
+	dec = yaml.NewDecoder(strings.NewReader(data))
+	dec.ExpandVariables(lookup)
+	dec.KnownFields(true)
+	c.Assert(dec.Decode(&v), ErrorMatches, `yaml: unmarshal errors:\n  line 2: variable "PORT" is not defined`)
+
+	var node yaml.Node
+	c.Assert(yaml.Unmarshal([]byte("a: ${HOST}\n"), &node), IsNil)
+	var a struct{ A string }
+	c.Assert(node.DecodeWithOptions(&a, yaml.WithVariables(lookup)), IsNil)
+	c.Assert(a.A, Equals, "example.com")
+}
+
+func (s *S) TestDecoderIncludes(c *C) {
+	files := map[string]string{
+		"base/db.yaml":     "host: db\nport: !include port.yaml\n",
+		"base/port.yaml":   "5432\n",
+		"base/bad.yaml":    "port: x\n",
+		"base/broken.yaml": "a: [\n",
+		"base/cycle.yaml":  "next: !include ../cycle.yaml\n",
+		"cycle.yaml":       "next: !include base/cycle.yaml\n",
+		"chain/1.yaml":     "!include 2.yaml\n",
+		"chain/2.yaml":     "!include 3.yaml\n",
+		"chain/3.yaml":     "!include 4.yaml\n",
+		"chain/4.yaml":     "!include 5.yaml\n",
+		"chain/5.yaml":     "five\n",
+	}
+	load := func(path string) ([]byte, error) {
+		data, ok := files[path]
+		if !ok {
+			return nil, errors.New("file not found")
+		}
+		return []byte(data), nil
+	}
+	type DB struct {
+		Host string
+		Port int
+	}
+	decode := func(data string, v interface{}) error {
+		dec := yaml.NewDecoder(strings.NewReader(data))
+		dec.SetIncludeLoader(load)
+		dec.SetMaxIncludeDepth(4)
+		return dec.Decode(v)
+	}
+
+	var v struct {
+		DB   DB
+		Name string
+	}
+	c.Assert(decode("db: !include base/db.yaml\nname: app\n", &v), IsNil)
+	c.Assert(v.DB, Equals, DB{"db", 5432})
+	c.Assert(v.Name, Equals, "app")
+
+	var m map[string]interface{}
+	c.Assert(decode("a: !include missing.yaml\n", &m), ErrorMatches, `yaml: line 1: cannot include "missing.yaml": file not found`)
+	c.Assert(decode("a: !include cycle.yaml\n", &m), ErrorMatches, `yaml: line 1: !include cycle: cycle.yaml -> base/cycle.yaml -> cycle.yaml`)
+	c.Assert(decode("a: !include chain/2.yaml\n", &m), IsNil)
+	c.Assert(m["a"], Equals, "five")
+	c.Assert(decode("a: !include chain/1.yaml\n", &m), ErrorMatches, `yaml: line 1: cannot include "chain/5.yaml": exceeds the maximum depth of 4 files`)
+	c.Assert(decode("a: !include base/broken.yaml\n", &m), ErrorMatches, `yaml: base/broken.yaml: line 1: did not find expected node content`)
+	var db struct{ A DB }
+	c.Assert(decode("a: !include base/bad.yaml\n", &db), ErrorMatches, "yaml: unmarshal errors:\n  base/bad.yaml: line 1: cannot unmarshal !!str `x` into int")
+
+	// Without a loader, the tag is decoded as any other local tag.
+	c.Assert(yaml.Unmarshal([]byte("a: !include base/db.yaml\n"), &m), IsNil)
+	c.Assert(m["a"], Equals, "base/db.yaml")
+}
+
+type resolvedRef string
+
+func (s *S) TestDecoderRegisterTagResolver(c *C) {
+	data := "a: !Ref x\nb: !Ref [y]\nc: 0x10\nd: !Env HOME\ne: !Ref ''\n"
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.RegisterTagResolver("!Ref", func(n *yaml.Node) (interface{}, error) {
+		if n.Kind != yaml.ScalarNode {
+			return nil, errors.New("!Ref needs a scalar")
+		}
+		if n.Value == "" {
+			return nil, nil
+		}
+		return "ref:" + n.Value, nil
+	})
+	dec.RegisterTagResolver("!!int", func(n *yaml.Node) (interface{}, error) {
+		return int64(len(n.Value)), nil
+	})
+	dec.RegisterTagResolver("!Env", func(n *yaml.Node) (interface{}, error) {
+		return 42, nil
+	})
+	var v struct {
+		A resolvedRef
+		B string
+		C *int
+		D string
+		E *string
+	}
+	v.E = new(string)
+	err := dec.Decode(&v)
+	c.Assert(err, ErrorMatches, "!Ref needs a scalar")
+
+	dec.RegisterTagResolver("!Ref", func(n *yaml.Node) (interface{}, error) {
+		if n.Value == "" {
+			return nil, nil
+		}
+		return "ref:" + n.Value, nil
+	})
+	dec.Reset(strings.NewReader(data))
+	err = dec.Decode(&v)
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n  line 4: cannot unmarshal !Env `HOME` into string")
+	c.Assert(v.A, Equals, resolvedRef("ref:x"))
+	c.Assert(v.B, Equals, "")
+	c.Assert(*v.C, Equals, 4)
+	c.Assert(v.E, IsNil)
+
+	var m map[string]interface{}
+	dec.RegisterTagResolver("!Env", nil)
+	dec.Reset(strings.NewReader(data))
+	c.Assert(dec.Decode(&m), IsNil)
+	c.Assert(m["c"], Equals, int64(4))
+	c.Assert(m["d"], Equals, "HOME")
+}
+
+func (s *S) TestDecoderRegisterTagResolverRange(c *C) {
+	decode := func(data string, v interface{}, value interface{}) error {
+		dec := yaml.NewDecoder(strings.NewReader(data))
+		dec.RegisterTagResolver("!N", func(n *yaml.Node) (interface{}, error) {
+			return value, nil
+		})
+		return dec.Decode(v)
+	}
+
+	var u struct{ A uint64 }
+	err := decode("a: !N x\n", &u, int64(-1))
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n  line 1: cannot unmarshal !N `x` into uint64")
+	c.Assert(u.A, Equals, uint64(0))
+
+	var i8 struct{ A int8 }
+	err = decode("a: !N x\n", &i8, 300)
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n  line 1: cannot unmarshal !N `x` into int8")
+
+	var i64 struct{ A int64 }
+	err = decode("a: !N x\n", &i64, uint64(math.MaxUint64))
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n  line 1: cannot unmarshal !N `x` into int64")
+
+	var f32 struct{ A float32 }
+	err = decode("a: !N x\n", &f32, 1e300)
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n  line 1: cannot unmarshal !N `x` into float32")
+
+	// Values within range, and infinities, are converted.
+	c.Assert(decode("a: !N x\n", &u, 7), IsNil)
+	c.Assert(u.A, Equals, uint64(7))
+	c.Assert(decode("a: !N x\n", &i8, uint(127)), IsNil)
+	c.Assert(i8.A, Equals, int8(127))
+	c.Assert(decode("a: !N x\n", &f32, math.Inf(-1)), IsNil)
+	c.Assert(math.IsInf(float64(f32.A), -1), Equals, true)
+}
+
+func (s *S) TestDecoderProgress(c *C) {
+	data := "a: 1\n---\nb: 2\n"
+	dec := yaml.NewDecoder(iotest.OneByteReader(strings.NewReader(data)))
+	var reported []int64
+	dec.SetProgress(func(bytesRead int64) error {
+		reported = append(reported, bytesRead)
+		return nil
+	})
+	c.Assert(dec.BytesRead(), Equals, int64(0))
+	var v map[string]int
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(dec.Decode(&v), Equals, io.EOF)
+	c.Assert(dec.BytesRead(), Equals, int64(len(data)))
+	c.Assert(reported, HasLen, len(data))
+	c.Assert(reported[len(reported)-1], Equals, int64(len(data)))
+
+	limitErr := errors.New("too much input")
+	dec = yaml.NewDecoder(iotest.OneByteReader(strings.NewReader(data)))
+	dec.SetProgress(func(bytesRead int64) error {
+		if bytesRead > 3 {
+			return limitErr
+		}
+		return nil
+	})
+	c.Assert(dec.Decode(&v), Equals, limitErr)
+	c.Assert(dec.BytesRead(), Equals, int64(4))
+}
+
+func (s *S) TestDecoderDecodeContext(c *C) {
+	var v []int
+	dec := yaml.NewDecoder(strings.NewReader("[1, 2]\n"))
+	c.Assert(dec.DecodeContext(context.Background(), &v), IsNil)
+	c.Assert(v, DeepEquals, []int{1, 2})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dec = yaml.NewDecoder(iotest.OneByteReader(strings.NewReader("[1, 2, 3, 4]\n")))
+	dec.SetProgress(func(bytesRead int64) error {
+		if bytesRead == 4 {
+			cancel()
+		}
+		return nil
+	})
+	c.Assert(dec.DecodeContext(ctx, &v), Equals, context.Canceled)
+}
+
+func (s *S) TestMapSliceComments(c *C) {
+	data := "" +
+		"# head\n" +
+		"z: 1 # line\n" +
+		"# foot\n" +
+		"\n" +
+		"a: # seq\n" +
+		"    - 1\n" +
+		"    - 2\n" +
+		"m:\n" +
+		"    <<: {p: 1, z: 3}\n" +
+		"    z: 4\n"
+	var v yaml.MapSlice
+	c.Assert(yaml.Unmarshal([]byte(data), &v), IsNil)
+	c.Assert(v, DeepEquals, yaml.MapSlice{
+		{Key: "z", Value: 1, HeadComment: "# head", LineComment: "# line", FootComment: "# foot"},
+		{Key: "a", Value: []interface{}{1, 2}, LineComment: "# seq"},
+		{Key: "m", Value: yaml.MapSlice{{Key: "p", Value: 1}, {Key: "z", Value: 4}}},
+	})
+	out, err := yaml.Marshal(v)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, strings.Replace(data, "    <<: {p: 1, z: 3}\n", "    p: 1\n", 1))
+}
+
+func (s *S) TestDecoderMergeKeyPolicy(c *C) {
+	data := "" +
+		"base: &base {a: 1, b: 2}\n" +
+		"x:\n" +
+		"  <<: [*base, {b: 3, c: 4}]\n" +
+		"  a: 0\n"
+	decode := func(policy yaml.MergeKeyPolicy, v interface{}) {
+		dec := yaml.NewDecoder(strings.NewReader(data))
+		dec.MergeKeyPolicy(policy)
+		c.Assert(dec.Decode(v), IsNil)
+	}
+	encode := func(n *yaml.Node) string {
+		out, err := yaml.Marshal(n)
+		c.Assert(err, IsNil)
+		return string(out)
+	}
+
+	var m map[string]map[string]interface{}
+	decode(yaml.MergeKeyDefault, &m)
+	c.Assert(m["x"], DeepEquals, map[string]interface{}{"a": 0, "b": 2, "c": 4})
+	var n yaml.Node
+	decode(yaml.MergeKeyDefault, &n)
+	c.Assert(encode(&n), Equals, "base: &base {a: 1, b: 2}\nx:\n    !!merge <<: [*base, {b: 3, c: 4}]\n    a: 0\n")
+
+	m = nil
+	decode(yaml.MergeKeyExpand, &m)
+	c.Assert(m["x"], DeepEquals, map[string]interface{}{"a": 0, "b": 2, "c": 4})
+	n = yaml.Node{}
+	decode(yaml.MergeKeyExpand, &n)
+	c.Assert(encode(&n), Equals, "base: &base {a: 1, b: 2}\nx:\n    b: 2\n    c: 4\n    a: 0\n")
+
+	m = nil
+	decode(yaml.MergeKeyKeep, &m)
+	c.Assert(m["x"], DeepEquals, map[string]interface{}{
+		"<<": []interface{}{
+			map[string]interface{}{"a": 1, "b": 2},
+			map[string]interface{}{"b": 3, "c": 4},
+		},
+		"a": 0,
+	})
+	n = yaml.Node{}
+	decode(yaml.MergeKeyKeep, &n)
+	c.Assert(encode(&n), Equals, "base: &base {a: 1, b: 2}\nx:\n    !!merge <<: [*base, {b: 3, c: 4}]\n    a: 0\n")
+}
+
+func (s *S) TestDecoderCaseInsensitiveFields(c *C) {
+	type T struct {
+		Name    string
+		MaxSize int    `yaml:"maxSize"`
+		Other   string `yaml:"name2"`
+		Exact   string `yaml:"NAME2"`
+	}
+	data := "NAME: a\nmaxsize: 1\nName2: b\nNAME2: c\n"
+
+	var v T
+	c.Assert(yaml.Unmarshal([]byte(data), &v), IsNil)
+	c.Assert(v, DeepEquals, T{Exact: "c"})
+
+	v = T{}
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.CaseInsensitiveFields(true)
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(v, DeepEquals, T{Name: "a", MaxSize: 1, Other: "b", Exact: "c"})
+}
+
+func (s *S) TestDecoderReportUnknownFields(c *C) {
+	type Container struct {
+		Name string
+	}
+	type Spec struct {
+		Replicas   int
+		Containers []Container
+	}
+	var v struct {
+		Spec Spec
+	}
+	data := "spec:\n  replicas: 1\n  containers:\n  - name: a\n    image: b\n  extra: c\nkind: d\n"
+
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.ReportUnknownFields(true)
+	err := dec.Decode(&v)
+	c.Assert(err, IsNil)
+	c.Assert(v.Spec.Replicas, Equals, 1)
+	c.Assert(v.Spec.Containers, DeepEquals, []Container{{Name: "a"}})
+	c.Assert(dec.UnknownFields(), DeepEquals, []yaml.UnknownField{
+		{Name: "image", Path: "spec.containers[0].image", Line: 5, Column: 5},
+		{Name: "extra", Path: "spec.extra", Line: 6, Column: 3},
+		{Name: "kind", Path: "kind", Line: 7, Column: 1},
+	})
+
+	// Errors take precedence over reporting.
+	dec = yaml.NewDecoder(strings.NewReader(data))
+	dec.ReportUnknownFields(true)
+	dec.KnownFields(true)
+	err = dec.Decode(&v)
+	c.Assert(err, ErrorMatches, "(?s).*field image not found.*")
+	c.Assert(dec.UnknownFields(), HasLen, 0)
+}
+
+func (s *S) TestDecoderWarnings(c *C) {
+	var v struct {
+		Name  string
+		Ratio float64
+		Small float32
+		Tags  map[string]int
+	}
+	data := "name: a\nratio: 9007199254740993\nsmall: 1e39\ntags: {x: 1, y: 2, x: 3}\nname: b\nextra: c\n"
+	var warnings []yaml.Warning
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.DuplicateKeyPolicy(yaml.DuplicateKeyTakeFirst)
+	dec.SetWarningHandler(func(w yaml.Warning) {
+		warnings = append(warnings, w)
+	})
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(v.Name, Equals, "a")
+	c.Assert(v.Tags, DeepEquals, map[string]int{"x": 1, "y": 2})
+	c.Assert(warnings, DeepEquals, []yaml.Warning{
+		{Message: "line 5: mapping key \"name\" already defined at line 1, ignoring this value", Path: "name", Line: 5, Column: 1},
+		{Message: "line 2: value 9007199254740993 is stored as 9.007199254740992e+15 in float64", Path: "ratio", Line: 2, Column: 8},
+		{Message: "line 3: value 1e39 is stored as +Inf in float32", Path: "small", Line: 3, Column: 8},
+		{Message: "line 4: mapping key \"x\" already defined at line 4, ignoring this value", Path: "tags.x", Line: 4, Column: 20},
+		{Message: "line 6: field extra not found in type struct { Name string; Ratio float64; Small float32; Tags map[string]int }", Path: "extra", Line: 6, Column: 1},
+	})
+
+	// Decoding a node with options.
+	var node yaml.Node
+	c.Assert(yaml.Unmarshal([]byte("a: 1\nb: 2\n"), &node), IsNil)
+	warnings = nil
+	var w struct{ A int }
+	c.Assert(node.DecodeWithOptions(&w, yaml.WithWarnings(func(w yaml.Warning) {
+		warnings = append(warnings, w)
+	})), IsNil)
+	c.Assert(warnings, HasLen, 1)
+	c.Assert(warnings[0].Message, Equals, "line 2: field b not found in type struct { A int }")
+}
+
+func (s *S) TestDecoderUnknownFieldError(c *C) {
+	var v struct {
+		A []struct{ B int }
+	}
+	dec := yaml.NewDecoder(strings.NewReader("a:\n- b: 1\n  c: 2\nd: x\ne: [1]\n"))
+	dec.KnownFields(true)
+	err := dec.Decode(&v)
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n  line 3: field c not found in type .*\n  line 4: field d not found in type .*\n  line 5: field e not found in type .*")
+
+	var uerr *yaml.UnknownFieldError
+	c.Assert(errors.As(err, &uerr), Equals, true)
+	c.Assert(uerr.Fields, DeepEquals, []yaml.UnknownField{
+		{Name: "c", Path: "a[0].c", Line: 3, Column: 3},
+		{Name: "d", Path: "d", Line: 4, Column: 1},
+		{Name: "e", Path: "e", Line: 5, Column: 1},
+	})
+	c.Assert(uerr.Error(), Equals, "yaml: unknown fields:\n  line 3: field a[0].c\n  line 4: field d\n  line 5: field e")
+
+	// Other decoding errors don't make an UnknownFieldError.
+	err = yaml.Unmarshal([]byte("a: x\n"), &v)
+	c.Assert(err, NotNil)
+	c.Assert(errors.As(err, &uerr), Equals, false)
+}
+
+func (s *S) TestDecoderUnknownFieldSuggestion(c *C) {
+	var v struct {
+		Containers []struct {
+			Name            string `yaml:"name"`
+			ImagePullPolicy string `yaml:"imagePullPolicy"`
+		} `yaml:"containers"`
+	}
+	data := "containers:\n- name: a\n  imagePulPolicy: Always\n  command: [sh]\n"
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.KnownFields(true)
+	err := dec.Decode(&v)
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n"+
+		"  line 3: field imagePulPolicy not found in type .*, did you mean \"imagePullPolicy\"\\?\n"+
+		"  line 4: field command not found in type .*")
+
+	var uerr *yaml.UnknownFieldError
+	c.Assert(errors.As(err, &uerr), Equals, true)
+	c.Assert(uerr.Fields, DeepEquals, []yaml.UnknownField{
+		{Name: "imagePulPolicy", Path: "containers[0].imagePulPolicy", Line: 3, Column: 3, Suggestion: "imagePullPolicy"},
+		{Name: "command", Path: "containers[0].command", Line: 4, Column: 3},
+	})
+	c.Assert(uerr.Error(), Equals, "yaml: unknown fields:\n"+
+		"  line 3: field containers[0].imagePulPolicy, did you mean \"imagePullPolicy\"?\n"+
+		"  line 4: field containers[0].command")
+}
+
+func (s *S) TestUnmarshalRequiredFields(c *C) {
+	type Container struct {
+		Name  string `yaml:"name,required"`
+		Image string `yaml:"image,required"`
+		Args  []string
+	}
+	type Spec struct {
+		Replicas   int         `yaml:"replicas"`
+		Containers []Container `yaml:"containers"`
+	}
+	var v struct {
+		Kind string `yaml:"kind,required"`
+		Spec Spec   `yaml:"spec,required"`
+	}
+	err := yaml.Unmarshal([]byte("spec:\n  containers:\n  - name: a\n    image: b\n  - name: c\n"), &v)
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n"+
+		"  line 5: missing required field image in type yaml_test.Container\n"+
+		"  line 1: missing required field kind in type .*")
+	terr := err.(*yaml.TypeError)
+	c.Assert(terr.Details[0].Path, Equals, "spec.containers[1].image")
+	c.Assert(terr.Details[0].Line, Equals, 5)
+	c.Assert(terr.Details[0].Column, Equals, 5)
+	c.Assert(terr.Details[1].Path, Equals, "kind")
+
+	// Merge keys may provide required fields.
+	data := "base: &base {name: a}\ncontainers:\n- <<: *base\n  image: b\n- <<: [{image: c}, *base]\n"
+	var w struct {
+		Containers []Container
+	}
+	c.Assert(yaml.Unmarshal([]byte(data), &w), IsNil)
+	c.Assert(w.Containers, DeepEquals, []Container{{Name: "a", Image: "b"}, {Name: "a", Image: "c"}})
+
+	// The values in a mapping merged into a map still need their fields.
+	var m struct {
+		M map[string]Container
+	}
+	err = yaml.Unmarshal([]byte("b: &b {a: {image: x}}\nm: {<<: *b}\n"), &m)
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n  line 1: missing required field name in type yaml_test.Container")
+	err = yaml.Unmarshal([]byte("m: {<<: [{a: {image: x}}]}\n"), &m)
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n  line 1: missing required field name in type yaml_test.Container")
+
+	// Required fields don't change encoding.
+	out, err := yaml.Marshal(Container{Name: "a"})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "name: a\nimage: \"\"\nargs: []\n")
+}
+
+type squashMeta struct {
+	Name   string
+	Labels map[string]string
+}
+
+type squashSpec struct {
+	Replicas int
+}
+
+func (s *S) TestUnmarshalSquash(c *C) {
+	var v struct {
+		squashMeta `yaml:",squash"`
+		Spec       *squashSpec `yaml:",squash"`
+		Kind       string
+	}
+	data := "name: a\nlabels: {app: web}\nreplicas: 3\nkind: Deployment\n"
+	c.Assert(yaml.Unmarshal([]byte(data), &v), IsNil)
+	c.Assert(v.Name, Equals, "a")
+	c.Assert(v.Labels, DeepEquals, map[string]string{"app": "web"})
+	c.Assert(v.Spec, DeepEquals, &squashSpec{Replicas: 3})
+	c.Assert(v.Kind, Equals, "Deployment")
+
+	out, err := yaml.Marshal(&v)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "name: a\nlabels:\n    app: web\nreplicas: 3\nkind: Deployment\n")
+
+	var conflict struct {
+		squashMeta `yaml:",squash"`
+		Other      struct{ Name int } `yaml:",squash"`
+	}
+	c.Assert(func() { yaml.Unmarshal([]byte(data), &conflict) }, PanicMatches, "duplicated key 'name' in struct .*: field Other.Name conflicts with field squashMeta.Name")
+}
+
+func (s *S) TestUnmarshalRestField(c *C) {
+	var v struct {
+		A    int
+		Rest map[string]yaml.Node `yaml:",rest"`
+	}
+	data := "a: 1\nb: [x]\nc: y # comment\n"
+	c.Assert(yaml.Unmarshal([]byte(data), &v), IsNil)
+	c.Assert(v.A, Equals, 1)
+	c.Assert(v.Rest, HasLen, 2)
+	c.Assert(v.Rest["b"].Kind, Equals, yaml.SequenceNode)
+	c.Assert(v.Rest["c"].Value, Equals, "y")
+	c.Assert(v.Rest["c"].LineComment, Equals, "# comment")
+
+	// KnownFields accepts keys captured by the rest field.
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.KnownFields(true)
+	c.Assert(dec.Decode(&v), IsNil)
+
+	out, err := yaml.Marshal(&v)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, data)
+
+	var bad struct {
+		Rest []string `yaml:",rest"`
+	}
+	c.Assert(func() { yaml.Unmarshal([]byte(data), &bad) }, PanicMatches, "option ,rest needs a map with string keys in struct .*")
+}
+
+func (s *S) TestUnmarshalInlineInterface(c *C) {
+	type Plugin struct {
+		Kind string
+		Spec interface{} `yaml:",inline"`
+	}
+	data := "kind: b\nb: 2\nc: 3\n"
+
+	v := Plugin{Spec: &inlineB{}}
+	c.Assert(yaml.Unmarshal([]byte(data), &v), IsNil)
+	c.Assert(v, DeepEquals, Plugin{Kind: "b", Spec: &inlineB{B: 2, inlineC: inlineC{C: 3}}})
+
+	v = Plugin{Spec: inlineB{B: 1}}
+	c.Assert(yaml.Unmarshal([]byte("kind: b\nc: 3\n"), &v), IsNil)
+	c.Assert(v, DeepEquals, Plugin{Kind: "b", Spec: inlineB{B: 1, inlineC: inlineC{C: 3}}})
+
+	v = Plugin{Spec: (*inlineB)(nil)}
+	c.Assert(yaml.Unmarshal([]byte(data), &v), IsNil)
+	c.Assert(v, DeepEquals, Plugin{Kind: "b", Spec: &inlineB{B: 2, inlineC: inlineC{C: 3}}})
+
+	v = Plugin{}
+	c.Assert(yaml.Unmarshal([]byte("kind: b\n"), &v), IsNil)
+	c.Assert(v, DeepEquals, Plugin{Kind: "b"})
+
+	// Unknown keys are those unknown to the dynamic value.
+	v = Plugin{Spec: &inlineB{}}
+	dec := yaml.NewDecoder(strings.NewReader(data + "d: 4\n"))
+	dec.KnownFields(true)
+	c.Assert(dec.Decode(&v), ErrorMatches, "(?s).*line 4: field d not found in type yaml_test.inlineB")
+}
+
 type textUnmarshaler struct {
 	S string
 }
@@ -0,0 +1,163 @@
+//
+// Copyright (c) 2011-2019 Canonical Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// diagnose rewrites err, reporting the problem found by the parser, when
+// the problem comes from a common mistake, so that it tells how to fix the
+// mistake, at the exact line and column where it was made.
+func (p *parser) diagnose(err *SyntaxError) {
+	switch p.parser.problem {
+	case "found character that cannot start any token", "found a tab character that violates indentation":
+		p.diagnoseTab(err, p.parser.problem_mark)
+	case "mapping values are not allowed in this context":
+		if !p.diagnoseValueColon(err, p.parser.problem_mark) {
+			p.diagnoseKeyColon(err, p.parser.problem_mark)
+		}
+	case "could not find expected ':'":
+		p.diagnoseDash(err, p.parser.context_mark)
+	case "did not find expected '-' indicator":
+		p.diagnoseDash(err, p.parser.problem_mark)
+	}
+}
+
+// diagnoseTab reports a tab character used to indent a line.
+func (p *parser) diagnoseTab(err *SyntaxError, mark yaml_mark_t) {
+	line, i, ok := p.sourceColumn(mark)
+	if !ok || i >= len(line) || line[i] != '\t' || len(bytes.TrimLeft(line[:i], " \t")) > 0 {
+		return
+	}
+	p.rewrite(err, mark, "found a tab character used for indentation; did you mean to indent with spaces?")
+}
+
+// diagnoseValueColon reports a plain scalar holding ": " in the value of
+// a mapping entry, as in "a: b: c", where mark is at the second colon.
+func (p *parser) diagnoseValueColon(err *SyntaxError, mark yaml_mark_t) bool {
+	line, i, ok := p.sourceColumn(mark)
+	if !ok {
+		return false
+	}
+	start := contentStart(line)
+	j := bytes.Index(line[start:], []byte(": "))
+	if j < 0 || start+j >= i {
+		return false
+	}
+	j += start
+	value := withoutComment(line[j+2:])
+	if len(value) == 0 || bytes.IndexByte(plainIndicators, value[0]) >= 0 {
+		return false
+	}
+	p.rewrite(err, mark, fmt.Sprintf("found \": \" in a plain scalar; did you mean to quote it, as in %s: %s?", line[start:j], strconv.Quote(string(value))))
+	return true
+}
+
+// diagnoseKeyColon reports a missing space after the colon of a mapping
+// key, as in "a:1", which makes a plain scalar of that line and the next
+// one, where mark is at the colon of the latter.
+func (p *parser) diagnoseKeyColon(err *SyntaxError, mark yaml_mark_t) {
+	if mark.line == 0 {
+		return
+	}
+	line, _, ok := p.sourceColumn(mark)
+	prev, _, prevOk := p.sourceColumn(yaml_mark_t{line: mark.line - 1})
+	if !ok || !prevOk {
+		return
+	}
+	start := contentStart(prev)
+	if start != contentStart(line) {
+		return
+	}
+	entry := withoutComment(prev[start:])
+	k := bytes.IndexByte(entry, ':')
+	if k < 1 || k+1 >= len(entry) || entry[k+1] == ' ' || entry[k+1] == '\t' || bytes.Contains(entry, []byte(": ")) {
+		return
+	}
+	if bytes.IndexByte(plainIndicators, entry[0]) >= 0 {
+		return
+	}
+	column := utf8.RuneCount(prev[:start+k])
+	fixed := string(entry[:k+1]) + " " + string(entry[k+1:])
+	p.rewrite(err, yaml_mark_t{line: mark.line - 1, column: column}, fmt.Sprintf("missing space after ':'; did you mean %q?", fixed))
+}
+
+// diagnoseDash reports a missing space after the "-" indicator of a
+// sequence entry, as in "-a", where mark is at the dash.
+func (p *parser) diagnoseDash(err *SyntaxError, mark yaml_mark_t) {
+	line, i, ok := p.sourceColumn(mark)
+	if !ok || i+1 >= len(line) || line[i] != '-' || len(bytes.TrimLeft(line[:i], " ")) > 0 {
+		return
+	}
+	if c := line[i+1]; c == ' ' || c == '\t' || c == '-' {
+		return
+	}
+	p.rewrite(err, mark, fmt.Sprintf("missing space after '-'; did you mean %q?", "- "+string(withoutComment(line[i+1:]))))
+}
+
+// plainIndicators holds the characters that can't start a plain scalar,
+// or that start a node property.
+var plainIndicators = []byte("\"'[]{}&*!|>%@`#,")
+
+// sourceColumn returns the text of the input line holding mark, and the
+// byte offset of mark within it.
+func (p *parser) sourceColumn(mark yaml_mark_t) (line []byte, i int, ok bool) {
+	line, _, ok = p.sourceLine(mark)
+	if !ok {
+		return nil, 0, false
+	}
+	for n := 0; n < mark.column && i < len(line); n++ {
+		_, size := utf8.DecodeRune(line[i:])
+		i += size
+	}
+	return line, i, true
+}
+
+// contentStart returns the offset of the content of line, past its
+// indentation and the indicators of the sequence entries it starts.
+func contentStart(line []byte) int {
+	i := 0
+	for {
+		for i < len(line) && line[i] == ' ' {
+			i++
+		}
+		if i+1 < len(line) && line[i] == '-' && line[i+1] == ' ' {
+			i++
+			continue
+		}
+		return i
+	}
+}
+
+// withoutComment returns text without the comment ending it, and without
+// surrounding spaces.
+func withoutComment(text []byte) []byte {
+	if i := bytes.Index(text, []byte(" #")); i >= 0 {
+		text = text[:i]
+	}
+	return bytes.TrimSpace(text)
+}
+
+// rewrite sets the message of err, and its position to mark.
+func (p *parser) rewrite(err *SyntaxError, mark yaml_mark_t, message string) {
+	err.Message = message
+	p.setPosition(err, mark)
+	err.line, err.column = err.Line, err.Column
+}
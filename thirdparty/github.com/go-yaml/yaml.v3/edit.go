@@ -0,0 +1,507 @@
+//
+// Copyright (c) 2011-2019 Canonical Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// An Editor changes values in the first document of a YAML text by
+// splicing their new text over the old one, so that everything else in
+// the text, including comments, blank lines, quoting and the layout of
+// the other values, is left exactly as written.
+//
+// Values are located by paths made of mapping keys and sequence indexes
+// separated by dots, as in DecodePath. Aliases and merge keys aren't
+// followed, since changing the values they refer to would change every
+// other place that uses them.
+type Editor struct {
+	src    []byte
+	indent int
+}
+
+// NewEditor returns an Editor for the first document in src.
+func NewEditor(src []byte) (*Editor, error) {
+	if _, err := parseEditTree(src); err != nil {
+		return nil, err
+	}
+	return &Editor{src: src, indent: 2}, nil
+}
+
+// SetIndent sets the number of spaces used to indent the block
+// collections added by the editor. It defaults to 2.
+func (e *Editor) SetIndent(spaces int) {
+	if spaces < 1 {
+		panic("yaml: cannot indent to less than one column")
+	}
+	e.indent = spaces
+}
+
+// Bytes returns the text with the changes made so far.
+func (e *Editor) Bytes() []byte {
+	return e.src
+}
+
+// Set sets the value at path to v, which is encoded as Marshal does, or
+// used as is if it's a *Node. The old value keeps its anchor, so that
+// aliases referring to it remain valid. A missing key is added at the
+// end of its mapping, and the index one past the last item of a sequence
+// appends an item to it. ErrPathNotFound is returned if the parent of the
+// value doesn't exist.
+func (e *Editor) Set(path string, v interface{}) error {
+	t, err := parseEditTree(e.src)
+	if err != nil {
+		return err
+	}
+	place, err := t.locate(splitEditPath(path))
+	if err != nil {
+		return err
+	}
+	var value *Node
+	if n, ok := v.(*Node); ok {
+		if n.Kind == DocumentNode && len(n.Content) == 1 {
+			n = n.Content[0]
+		}
+		c := *n
+		value = &c
+	} else {
+		value = &Node{}
+		if err := value.Encode(v); err != nil {
+			return err
+		}
+	}
+	var start, end int
+	var text string
+	if place.node == nil {
+		start, text, err = e.insert(t, place, value)
+		end = start
+	} else {
+		start, end, text, err = e.replace(t, place, value)
+	}
+	if err != nil {
+		return err
+	}
+	return e.splice(path, start, end, text)
+}
+
+// Delete removes the value at path, along with its key if it's in a
+// mapping. Removing the last entry of a block collection leaves an
+// empty flow collection in its place. ErrPathNotFound is returned if
+// there's no value at path.
+func (e *Editor) Delete(path string) error {
+	t, err := parseEditTree(e.src)
+	if err != nil {
+		return err
+	}
+	segments := splitEditPath(path)
+	place, err := t.locate(segments)
+	if err != nil {
+		return err
+	}
+	if place.node == nil {
+		return ErrPathNotFound
+	}
+	parent := place.parent
+	if parent == nil {
+		return errors.New("yaml: cannot delete the document content")
+	}
+	entry := place.index
+	size := 1
+	if parent.Kind == MappingNode {
+		entry--
+		size = 2
+	}
+	if len(parent.Content) == size && parent.Style&FlowStyle == 0 {
+		empty := &Node{Kind: parent.Kind, Style: FlowStyle}
+		return e.Set(strings.Join(segments[:len(segments)-1], "."), empty)
+	}
+	// The entry is removed along with the separator that precedes or
+	// follows it: a comma in flow collections, and its line, or the
+	// text up to the next entry, in block ones.
+	first, last := parent.Content[entry], parent.Content[entry+size-1]
+	start, end := t.spans[first].start, t.end(last)
+	if parent.Kind == SequenceNode && parent.Style&FlowStyle == 0 {
+		start = bytes.LastIndexByte(t.src[:start], '-')
+	}
+	next := entry + size
+	switch {
+	case parent.Style&FlowStyle != 0 && next < len(parent.Content):
+		end = t.spans[parent.Content[next]].start
+	case parent.Style&FlowStyle != 0 && entry > 0:
+		start = t.end(parent.Content[entry-1])
+	case parent.Style&FlowStyle != 0:
+	case t.onlySpaces(t.lineStart(start), start):
+		start = t.lineStart(start)
+		end = t.lineEnd(end)
+		if end < len(t.src) && t.src[end] == '\r' {
+			end++
+		}
+		if end < len(t.src) && t.src[end] == '\n' {
+			end++
+		}
+	default:
+		next := t.spans[parent.Content[next]].start
+		if parent.Kind == SequenceNode {
+			next = bytes.LastIndexByte(t.src[:next], '-')
+		}
+		end = next
+	}
+	return e.splice(path, start, end, "")
+}
+
+// splice replaces the text between start and end with text, making sure
+// the result is still valid YAML.
+func (e *Editor) splice(path string, start, end int, text string) error {
+	src := make([]byte, 0, len(e.src)-(end-start)+len(text))
+	src = append(src, e.src[:start]...)
+	src = append(src, text...)
+	src = append(src, e.src[end:]...)
+	if _, err := parseEditTree(src); err != nil {
+		return fmt.Errorf("yaml: cannot edit the value at %q: %v", path, err)
+	}
+	e.src = src
+	return nil
+}
+
+// replace returns the text to put in place of the value at place, and
+// where it goes.
+func (e *Editor) replace(t *editTree, place *editPlace, value *Node) (start, end int, text string, err error) {
+	old := place.node
+	if value.Anchor == "" && old.Kind != AliasNode {
+		value.Anchor = old.Anchor
+	}
+	start, end = t.spans[old].start, t.end(old)
+	parent := place.parent
+	flow := parent != nil && parent.Style&FlowStyle != 0
+	oldBlock := old.Kind != ScalarNode && old.Kind != AliasNode && old.Style&FlowStyle == 0
+	if flow || value.Kind == ScalarNode || value.Kind == AliasNode || len(value.Content) == 0 {
+		if text, err = e.render(value, flow); err != nil {
+			return 0, 0, "", err
+		}
+		if start == end {
+			// Empty scalars have no text after their indicator.
+			text = " " + text
+		}
+		if oldBlock && parent != nil && parent.Kind == MappingNode {
+			// Bring the value back to the line of its key when
+			// nothing but spaces lies between them.
+			colon := t.end(place.key)
+			colon += bytes.IndexByte(t.src[colon:], ':') + 1
+			if t.onlySpaces(colon, start) {
+				start = colon
+				text = " " + text
+			}
+		}
+		column := t.column(start)
+		if !flow && parent != nil && parent.Kind == MappingNode {
+			// Block scalars are indented from their key.
+			column = t.column(t.spans[place.key].start)
+		}
+		text = t.indentLines(text, column, false)
+		if i := strings.Index(text, t.lineBreak()); i >= 0 {
+			// A comment following the old value would become part of
+			// the last line of a block scalar, so it goes after its
+			// header instead.
+			if comment := t.lineComment(end); comment != "" {
+				text = text[:i] + " " + comment + text[i:]
+				end = t.lineEnd(end)
+			}
+		}
+		return start, end, text, nil
+	}
+	if text, err = e.render(value, false); err != nil {
+		return 0, 0, "", err
+	}
+	if parent == nil || parent.Kind == SequenceNode {
+		// Block collections may start right after the indicator of
+		// a sequence item, or at the document start.
+		column := t.column(start)
+		if start == end {
+			text = " " + text
+			column++
+		}
+		return start, end, t.indentLines(text, column, false), nil
+	}
+	indent := t.column(t.spans[place.key].start) + e.indent
+	if oldBlock && old.Kind == SequenceNode && value.Kind == SequenceNode {
+		// Keep the indentation of sequences written at the level
+		// of their key.
+		indent = t.column(bytes.LastIndexByte(t.src[:t.spans[old.Content[0]].start], '-'))
+	}
+	var props string
+	if i := strings.IndexByte(text, '\n'); i > 0 && (text[0] == '&' || text[0] == '!') && !strings.Contains(text[:i], ": ") {
+		props, text = text[:i], text[i+1:]
+	}
+	if oldBlock && t.onlySpaces(t.lineStart(start), start) {
+		start = t.lineStart(start)
+		if props != "" {
+			text = props + "\n" + text
+		}
+		return start, end, t.indentLines(text, indent, true), nil
+	}
+	for start > 0 && t.src[start-1] == ' ' {
+		start--
+	}
+	if props != "" {
+		props = " " + props
+	}
+	return start, end, props + t.lineBreak() + t.indentLines(text, indent, true), nil
+}
+
+// insert returns the text of a new entry for the missing value at place,
+// and where it goes.
+func (e *Editor) insert(t *editTree, place *editPlace, value *Node) (at int, text string, err error) {
+	parent := place.parent
+	entry := value
+	if parent.Kind == MappingNode {
+		key := &Node{}
+		key.SetString(place.name)
+		entry = &Node{Kind: MappingNode, Content: []*Node{key, value}}
+	} else {
+		entry = &Node{Kind: SequenceNode, Content: []*Node{value}}
+	}
+	if parent.Style&FlowStyle != 0 {
+		entry.Style = FlowStyle
+		if text, err = e.render(entry, false); err != nil {
+			return 0, "", err
+		}
+		// Drop the brackets around the single entry.
+		text = text[1 : len(text)-1]
+		at = t.spans[parent].end - 1
+		if len(parent.Content) > 0 {
+			at = t.end(parent.Content[len(parent.Content)-1])
+			text = ", " + text
+		}
+		return at, t.indentLines(text, t.column(at), false), nil
+	}
+	if text, err = e.render(entry, false); err != nil {
+		return 0, "", err
+	}
+	first := t.spans[parent.Content[0]].start
+	if parent.Kind == SequenceNode {
+		first = bytes.LastIndexByte(t.src[:first], '-')
+	}
+	at = t.lineEnd(t.end(parent.Content[len(parent.Content)-1]))
+	return at, t.lineBreak() + t.indentLines(text, t.column(first), true), nil
+}
+
+// render returns the text of n as encoded in a document of its own, on
+// a single line if flow is set.
+func (e *Editor) render(n *Node, flow bool) (string, error) {
+	if flow {
+		c := *n
+		switch n.Kind {
+		case MappingNode, SequenceNode:
+			c.Style |= FlowStyle
+		case ScalarNode:
+			if strings.ContainsAny(n.Value, "\n\r") {
+				c.Style = DoubleQuotedStyle
+			}
+		}
+		n = &c
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetIndent(e.indent)
+	enc.SetWidth(0)
+	if err := enc.Encode(n); err != nil {
+		return "", err
+	}
+	if err := enc.Close(); err != nil {
+		return "", err
+	}
+	return strings.TrimRight(buf.String(), "\n"), nil
+}
+
+// editTree holds the first document of a text along with the positions
+// of its nodes, in bytes.
+type editTree struct {
+	src   []byte
+	root  *Node
+	spans map[*Node]nodeSpan
+}
+
+func parseEditTree(src []byte) (t *editTree, err error) {
+	defer handleErr(&err)
+	p := newParser(src)
+	defer p.destroy()
+	p.spans = make(map[*Node]nodeSpan)
+	doc := p.parse()
+	if doc == nil || len(doc.Content) == 0 {
+		return nil, errors.New("yaml: no document to edit")
+	}
+	// Marks count characters, which may take more than a byte, and
+	// exclude the byte order mark.
+	base := 0
+	if bytes.HasPrefix(src, []byte("\xef\xbb\xbf")) {
+		base = 3
+	}
+	offsets := []int{}
+	for i := base; i < len(src); {
+		offsets = append(offsets, i)
+		_, size := utf8.DecodeRune(src[i:])
+		i += size
+	}
+	offsets = append(offsets, len(src))
+	offset := func(index int) int {
+		if index >= len(offsets) {
+			return len(src)
+		}
+		return offsets[index]
+	}
+	for n, s := range p.spans {
+		p.spans[n] = nodeSpan{offset(s.start), offset(s.end)}
+	}
+	return &editTree{src: src, root: doc.Content[0], spans: p.spans}, nil
+}
+
+// end returns the offset where the text of n ends, ignoring the line
+// breaks and spaces that follow it.
+func (t *editTree) end(n *Node) int {
+	if n.Kind == MappingNode || n.Kind == SequenceNode {
+		if n.Style&FlowStyle == 0 && len(n.Content) > 0 {
+			return t.end(n.Content[len(n.Content)-1])
+		}
+	}
+	s := t.spans[n]
+	end := s.end
+	for end > s.start && isSpaceOrBreak(t.src[end-1]) {
+		end--
+	}
+	return end
+}
+
+func isSpaceOrBreak(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
+
+// editPlace is the location of a value in an editTree.
+type editPlace struct {
+	// parent holds the collection of the value, or nil for the
+	// document content.
+	parent *Node
+	// key holds the key of the value in a mapping parent.
+	key *Node
+	// name holds the last path segment.
+	name string
+	// index holds the position of the value in parent.Content.
+	index int
+	// node holds the value, or nil if it's missing.
+	node *Node
+}
+
+// locate returns the place of the value at path. The value at the last
+// segment may be missing.
+func (t *editTree) locate(path []string) (*editPlace, error) {
+	place := &editPlace{node: t.root}
+	for i, name := range path {
+		parent := place.node
+		place = &editPlace{parent: parent, name: name}
+		switch parent.Kind {
+		case MappingNode:
+			place.index = len(parent.Content)
+			for j := 0; j+1 < len(parent.Content); j += 2 {
+				if k := parent.Content[j]; k.Kind == ScalarNode && k.Value == name {
+					place.key, place.node, place.index = k, parent.Content[j+1], j+1
+					break
+				}
+			}
+		case SequenceNode:
+			index, err := strconv.Atoi(name)
+			if err != nil || index < 0 || index > len(parent.Content) {
+				return nil, ErrPathNotFound
+			}
+			place.index = index
+			if index < len(parent.Content) {
+				place.node = parent.Content[index]
+			}
+		default:
+			return nil, ErrPathNotFound
+		}
+		if place.node == nil && i < len(path)-1 {
+			return nil, ErrPathNotFound
+		}
+	}
+	return place, nil
+}
+
+func splitEditPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, ".")
+}
+
+func (t *editTree) lineStart(at int) int {
+	return bytes.LastIndexByte(t.src[:at], '\n') + 1
+}
+
+func (t *editTree) lineEnd(at int) int {
+	if i := bytes.IndexByte(t.src[at:], '\n'); i >= 0 {
+		end := at + i
+		if end > at && t.src[end-1] == '\r' {
+			end--
+		}
+		return end
+	}
+	return len(t.src)
+}
+
+// lineComment returns the comment that follows at on its line, if
+// nothing else does.
+func (t *editTree) lineComment(at int) string {
+	rest := strings.TrimLeft(string(t.src[at:t.lineEnd(at)]), " \t")
+	if !strings.HasPrefix(rest, "#") {
+		return ""
+	}
+	return strings.TrimRight(rest, " \t")
+}
+
+// column returns the number of characters before at in its line.
+func (t *editTree) column(at int) int {
+	return utf8.RuneCount(t.src[t.lineStart(at):at])
+}
+
+func (t *editTree) onlySpaces(start, end int) bool {
+	return len(bytes.Trim(t.src[start:end], " \t\r\n")) == 0
+}
+
+// indentLines indents the lines of text by the given number of spaces,
+// skipping the first one unless first is set. The line breaks of the
+// source are used.
+func (t *editTree) indentLines(text string, spaces int, first bool) string {
+	prefix := strings.Repeat(" ", spaces)
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		if (i > 0 || first) && line != "" {
+			lines[i] = prefix + line
+		}
+	}
+	return strings.Join(lines, t.lineBreak())
+}
+
+// lineBreak returns the line break used in the source.
+func (t *editTree) lineBreak() string {
+	if bytes.Contains(t.src, []byte("\r\n")) {
+		return "\r\n"
+	}
+	return "\n"
+}
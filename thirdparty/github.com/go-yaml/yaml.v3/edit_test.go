@@ -0,0 +1,188 @@
+package yaml_test
+
+import (
+	. "gopkg.in/check.v1"
+	"sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+var editTests = []struct {
+	data   string
+	path   string
+	value  interface{}
+	delete bool
+	want   string
+}{{
+	data:  "# Head.\na: 1 # one\nb:   'two'\n",
+	path:  "a",
+	value: 10,
+	want:  "# Head.\na: 10 # one\nb:   'two'\n",
+}, {
+	data:  "a: 1\nb:   'two'\n",
+	path:  "b",
+	value: "three",
+	want:  "a: 1\nb:   three\n",
+}, {
+	data:  "a: 1\nb:\n",
+	path:  "b",
+	value: "x",
+	want:  "a: 1\nb: x\n",
+}, {
+	data:  "a: 1 # one\n\nb: 2\n",
+	path:  "c",
+	value: map[string]int{"x": 1},
+	want:  "a: 1 # one\n\nb: 2\nc:\n  x: 1\n",
+}, {
+	data:  "spec:\n    replicas: 1  # scale\n    name: web\n",
+	path:  "spec.image",
+	value: "nginx",
+	want:  "spec:\n    replicas: 1  # scale\n    name: web\n    image: nginx\n",
+}, {
+	data:  "a: 1\nb: c\n",
+	path:  "a",
+	value: map[string]interface{}{"x": 1, "z": []int{1, 2}},
+	want:  "a:\n  x: 1\n  z:\n    - 1\n    - 2\nb: c\n",
+}, {
+	data:  "a:\n  x: 1\n  y: 2\nb: c\n",
+	path:  "a",
+	value: "flat",
+	want:  "a: flat\nb: c\n",
+}, {
+	data:  "a:\n  x: 1\n  y: 2\nb: c\n",
+	path:  "a",
+	value: map[string]int{"z": 3},
+	want:  "a:\n  z: 3\nb: c\n",
+}, {
+	data:  "a: &x\n  k: 1\nb: *x\n",
+	path:  "a",
+	value: map[string]int{"k": 2},
+	want:  "a: &x\n  k: 2\nb: *x\n",
+}, {
+	data:  "list:\n- one\n- two # second\n",
+	path:  "list.1",
+	value: "deux",
+	want:  "list:\n- one\n- deux # second\n",
+}, {
+	data:  "list:\n  - one\n",
+	path:  "list.1",
+	value: map[string]int{"a": 1, "b": 2},
+	want:  "list:\n  - one\n  - a: 1\n    b: 2\n",
+}, {
+	data:  "list:\n- a: 1\n  b: 2\n",
+	path:  "list.0",
+	value: []int{1, 2},
+	want:  "list:\n- - 1\n  - 2\n",
+}, {
+	data:  "a: {x: 1, y: [1, 2]} # flow\n",
+	path:  "a.y",
+	value: map[string]int{"k": 1},
+	want:  "a: {x: 1, y: {k: 1}} # flow\n",
+}, {
+	data:  "a: {x: 1}\n",
+	path:  "a.z",
+	value: "multi\nline",
+	want:  "a: {x: 1, z: \"multi\\nline\"}\n",
+}, {
+	data:  "a: []\n",
+	path:  "a.0",
+	value: 1,
+	want:  "a: [1]\n",
+}, {
+	data:  "a: 1\ntext: old\n",
+	path:  "text",
+	value: "multi\nline",
+	want:  "a: 1\ntext: |-\n  multi\n  line\n",
+}, {
+	data:  "a: 1\r\nb: 2\r\n",
+	path:  "c",
+	value: []string{"x"},
+	want:  "a: 1\r\nb: 2\r\nc:\r\n  - x\r\n",
+}, {
+	data:  "név: 'é'\nb: 2\n",
+	path:  "b",
+	value: 3,
+	want:  "név: 'é'\nb: 3\n",
+}, {
+	data:  "a: 1\n---\na: 1\n",
+	path:  "a",
+	value: 2,
+	want:  "a: 2\n---\na: 1\n",
+}, {
+	data:   "a: 1 # one\nb: 2 # two\nc: 3\n",
+	path:   "b",
+	delete: true,
+	want:   "a: 1 # one\nc: 3\n",
+}, {
+	data:   "list:\n- a: 1\n  b: 2\n",
+	path:   "list.0.a",
+	delete: true,
+	want:   "list:\n- b: 2\n",
+}, {
+	data:   "list:\n  - 1\n  - 2\n",
+	path:   "list.0",
+	delete: true,
+	want:   "list:\n  - 2\n",
+}, {
+	data:   "a:\n  only: 1\nb: 2\n",
+	path:   "a.only",
+	delete: true,
+	want:   "a: {}\nb: 2\n",
+}, {
+	data:   "a: [1, 2, 3]\n",
+	path:   "a.1",
+	delete: true,
+	want:   "a: [1, 3]\n",
+}, {
+	data:   "a: {x: 1, y: 2}\n",
+	path:   "a.y",
+	delete: true,
+	want:   "a: {x: 1}\n",
+}}
+
+func (s *S) TestEditor(c *C) {
+	for i, item := range editTests {
+		c.Logf("test %d: %q", i, item.data)
+		e, err := yaml.NewEditor([]byte(item.data))
+		c.Assert(err, IsNil)
+		if item.delete {
+			err = e.Delete(item.path)
+		} else {
+			err = e.Set(item.path, item.value)
+		}
+		c.Assert(err, IsNil)
+		c.Assert(string(e.Bytes()), Equals, item.want)
+	}
+}
+
+func (s *S) TestEditorMultilineComment(c *C) {
+	for _, item := range []struct {
+		data, path, want string
+	}{
+		{"a: 1 # c\nb: 2\n", "a", "a: |- # c\n  multi\n  line\nb: 2\n"},
+		{"a:\n- 1   # c  \n- 2\n", "a.0", "a:\n- |- # c\n    multi\n    line\n- 2\n"},
+		{"a: 1 # c\r\nb: 2\r\n", "a", "a: |- # c\r\n  multi\r\n  line\r\nb: 2\r\n"},
+	} {
+		e, err := yaml.NewEditor([]byte(item.data))
+		c.Assert(err, IsNil)
+		c.Assert(e.Set(item.path, "multi\nline"), IsNil)
+		c.Assert(string(e.Bytes()), Equals, item.want)
+
+		var v string
+		c.Assert(yaml.DecodePath(e.Bytes(), item.path, &v), IsNil)
+		c.Assert(v, Equals, "multi\nline")
+	}
+}
+
+func (s *S) TestEditorErrors(c *C) {
+	e, err := yaml.NewEditor([]byte("a: &x 1\nb: *x\nc: [1]\n"))
+	c.Assert(err, IsNil)
+	c.Assert(e.Set("d.e", 1), Equals, yaml.ErrPathNotFound)
+	c.Assert(e.Set("c.2", 1), Equals, yaml.ErrPathNotFound)
+	c.Assert(e.Delete("d"), Equals, yaml.ErrPathNotFound)
+	c.Assert(e.Set("a", &yaml.Node{Kind: yaml.ScalarNode, Value: "2", Anchor: "y"}), ErrorMatches, `yaml: cannot edit the value at "a": .*unknown anchor 'x' referenced.*`)
+	c.Assert(string(e.Bytes()), Equals, "a: &x 1\nb: *x\nc: [1]\n")
+
+	_, err = yaml.NewEditor([]byte("a: [1"))
+	c.Assert(err, NotNil)
+	_, err = yaml.NewEditor(nil)
+	c.Assert(err, ErrorMatches, "yaml: no document to edit")
+}
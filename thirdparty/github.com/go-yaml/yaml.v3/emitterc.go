@@ -24,7 +24,10 @@ package yaml
 
 import (
 	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 )
 
 // Flush the buffer if needed.
@@ -227,6 +230,12 @@ func yaml_emitter_append_tag_directive(emitter *yaml_emitter_t, value *yaml_tag_
 
 // Increase the indentation level.
 func yaml_emitter_increase_indent(emitter *yaml_emitter_t, flow, indentless bool, compact_seq bool) bool {
+	return yaml_emitter_increase_indent_by(emitter, emitter.best_indent, flow, indentless, compact_seq)
+}
+
+// Increase the indentation level, aligning to a multiple of the provided
+// number of spaces rather than the default indentation.
+func yaml_emitter_increase_indent_by(emitter *yaml_emitter_t, spaces int, flow, indentless bool, compact_seq bool) bool {
 	emitter.indents = append(emitter.indents, emitter.indent)
 	if emitter.indent < 0 {
 		if flow {
@@ -239,9 +248,13 @@ func yaml_emitter_increase_indent(emitter *yaml_emitter_t, flow, indentless bool
 		if emitter.states[len(emitter.states)-1] == yaml_EMIT_BLOCK_SEQUENCE_ITEM_STATE {
 			// The first indent inside a sequence will just skip the "- " indicator.
 			emitter.indent += 2
+		} else if emitter.best_mapping_indent > 0 || emitter.best_sequence_indent > 0 {
+			// [Go] With distinct indentations per kind there's no common grid to
+			//      align to, so just indent past the enclosing level.
+			emitter.indent += spaces
 		} else {
 			// Everything else aligns to the chosen indentation.
-			emitter.indent = emitter.best_indent * ((emitter.indent + emitter.best_indent) / emitter.best_indent)
+			emitter.indent = spaces * ((emitter.indent + spaces) / spaces)
 		}
 		if compact_seq {
 			emitter.indent = emitter.indent - 2
@@ -250,6 +263,15 @@ func yaml_emitter_increase_indent(emitter *yaml_emitter_t, flow, indentless bool
 	return true
 }
 
+// Return the indentation for a kind of block collection, falling back to
+// the default indentation when none was set for it.
+func yaml_emitter_kind_indent(emitter *yaml_emitter_t, indent int) int {
+	if indent < 1 {
+		return emitter.best_indent
+	}
+	return indent
+}
+
 // State dispatcher.
 func yaml_emitter_state_machine(emitter *yaml_emitter_t, event *yaml_event_t) bool {
 	switch emitter.state {
@@ -328,7 +350,7 @@ func yaml_emitter_emit_stream_start(emitter *yaml_emitter_t, event *yaml_event_t
 			emitter.encoding = yaml_UTF8_ENCODING
 		}
 	}
-	if emitter.best_indent < 2 || emitter.best_indent > 9 {
+	if emitter.best_indent < 2 {
 		emitter.best_indent = 2
 	}
 	if emitter.best_width >= 0 && emitter.best_width <= emitter.best_indent*2 {
@@ -391,7 +413,7 @@ func yaml_emitter_emit_document_start(emitter *yaml_emitter_t, event *yaml_event
 			implicit = false
 		}
 
-		if emitter.open_ended && (event.version_directive != nil || len(event.tag_directives) > 0) {
+		if (emitter.open_ended || emitter.implicit_end) && (event.version_directive != nil || len(event.tag_directives) > 0) {
 			if !yaml_emitter_write_indicator(emitter, []byte("..."), true, false, false) {
 				return false
 			}
@@ -405,7 +427,8 @@ func yaml_emitter_emit_document_start(emitter *yaml_emitter_t, event *yaml_event
 			if !yaml_emitter_write_indicator(emitter, []byte("%YAML"), true, false, false) {
 				return false
 			}
-			if !yaml_emitter_write_indicator(emitter, []byte("1.1"), true, false, false) {
+			version := []byte{'1', '.', '0' + byte(event.version_directive.minor)}
+			if !yaml_emitter_write_indicator(emitter, version, true, false, false) {
 				return false
 			}
 			if !yaml_emitter_write_indent(emitter) {
@@ -435,6 +458,16 @@ func yaml_emitter_emit_document_start(emitter *yaml_emitter_t, event *yaml_event
 		if yaml_emitter_check_empty_document(emitter) {
 			implicit = false
 		}
+		if len(emitter.line_comment) > 0 {
+			// [Go] The line comment of the document follows the start
+			// indicator, which its head comment must then precede.
+			implicit = false
+			if len(emitter.head_comment) > 0 {
+				if !yaml_emitter_process_head_comment(emitter) {
+					return false
+				}
+			}
+		}
 		if !implicit {
 			if !yaml_emitter_write_indent(emitter) {
 				return false
@@ -442,6 +475,9 @@ func yaml_emitter_emit_document_start(emitter *yaml_emitter_t, event *yaml_event
 			if !yaml_emitter_write_indicator(emitter, []byte("---"), true, false, false) {
 				return false
 			}
+			if !yaml_emitter_process_line_comment(emitter, false) {
+				return false
+			}
 			if emitter.canonical || true {
 				if !yaml_emitter_write_indent(emitter) {
 					return false
@@ -514,6 +550,8 @@ func yaml_emitter_emit_document_end(emitter *yaml_emitter_t, event *yaml_event_t
 	if !yaml_emitter_write_indent(emitter) {
 		return false
 	}
+	// [Go] Directives of a following document must be preceded by an explicit end.
+	emitter.implicit_end = event.implicit
 	if !event.implicit {
 		// [Go] Allocate the slice elsewhere.
 		if !yaml_emitter_write_indicator(emitter, []byte("..."), true, false, false) {
@@ -541,14 +579,20 @@ func yaml_emitter_emit_flow_sequence_item(emitter *yaml_emitter_t, event *yaml_e
 			return false
 		}
 		emitter.flow_level++
+		if !yaml_emitter_process_open_comment(emitter) {
+			return false
+		}
 	}
 
 	if event.typ == yaml_SEQUENCE_END_EVENT {
-		if emitter.canonical && !first && !trail {
+		if (emitter.canonical || len(event.close_comment) > 0) && !first && !trail {
 			if !yaml_emitter_write_indicator(emitter, []byte{','}, false, false, false) {
 				return false
 			}
 		}
+		if !yaml_emitter_process_close_comment(emitter, event) {
+			return false
+		}
 		emitter.flow_level--
 		emitter.indent = emitter.indents[len(emitter.indents)-1]
 		emitter.indents = emitter.indents[:len(emitter.indents)-1]
@@ -624,10 +668,13 @@ func yaml_emitter_emit_flow_mapping_key(emitter *yaml_emitter_t, event *yaml_eve
 			return false
 		}
 		emitter.flow_level++
+		if !yaml_emitter_process_open_comment(emitter) {
+			return false
+		}
 	}
 
 	if event.typ == yaml_MAPPING_END_EVENT {
-		if (emitter.canonical || len(emitter.head_comment)+len(emitter.foot_comment)+len(emitter.tail_comment) > 0) && !first && !trail {
+		if (emitter.canonical || len(emitter.head_comment)+len(emitter.foot_comment)+len(emitter.tail_comment)+len(event.close_comment) > 0) && !first && !trail {
 			if !yaml_emitter_write_indicator(emitter, []byte{','}, false, false, false) {
 				return false
 			}
@@ -635,6 +682,9 @@ func yaml_emitter_emit_flow_mapping_key(emitter *yaml_emitter_t, event *yaml_eve
 		if !yaml_emitter_process_head_comment(emitter) {
 			return false
 		}
+		if !yaml_emitter_process_close_comment(emitter, event) {
+			return false
+		}
 		emitter.flow_level--
 		emitter.indent = emitter.indents[len(emitter.indents)-1]
 		emitter.indents = emitter.indents[:len(emitter.indents)-1]
@@ -733,7 +783,7 @@ func yaml_emitter_emit_block_sequence_item(emitter *yaml_emitter_t, event *yaml_
 	if first {
 		seq := emitter.mapping_context && (emitter.column == 0 || !emitter.indention) &&
 			emitter.compact_sequence_indent
-		if !yaml_emitter_increase_indent(emitter, false, false, seq) {
+		if !yaml_emitter_increase_indent_by(emitter, yaml_emitter_kind_indent(emitter, emitter.best_sequence_indent), false, false, seq) {
 			return false
 		}
 	}
@@ -744,6 +794,9 @@ func yaml_emitter_emit_block_sequence_item(emitter *yaml_emitter_t, event *yaml_
 		emitter.states = emitter.states[:len(emitter.states)-1]
 		return true
 	}
+	if !first {
+		emitter.empty_lines = event.empty_lines
+	}
 	if !yaml_emitter_process_head_comment(emitter) {
 		return false
 	}
@@ -769,9 +822,11 @@ func yaml_emitter_emit_block_sequence_item(emitter *yaml_emitter_t, event *yaml_
 // Expect a block key node.
 func yaml_emitter_emit_block_mapping_key(emitter *yaml_emitter_t, event *yaml_event_t, first bool) bool {
 	if first {
-		if !yaml_emitter_increase_indent(emitter, false, false, false) {
+		if !yaml_emitter_increase_indent_by(emitter, yaml_emitter_kind_indent(emitter, emitter.best_mapping_indent), false, false, false) {
 			return false
 		}
+	} else if event.typ != yaml_MAPPING_END_EVENT {
+		emitter.empty_lines = event.empty_lines
 	}
 	if !yaml_emitter_process_head_comment(emitter) {
 		return false
@@ -818,6 +873,8 @@ func yaml_emitter_emit_block_mapping_value(emitter *yaml_emitter_t, event *yaml_
 			return false
 		}
 	}
+	// [Go] The indentation to restore once the value is written on its own line.
+	restore_indent := emitter.indent
 	if len(emitter.key_line_comment) > 0 {
 		// [Go] Line comments are generally associated with the value, but when there's
 		//      no value on the same line as a mapping key they end up attached to the
@@ -825,10 +882,24 @@ func yaml_emitter_emit_block_mapping_value(emitter *yaml_emitter_t, event *yaml_
 		if event.typ == yaml_SCALAR_EVENT {
 			if len(emitter.line_comment) == 0 {
 				// A scalar is coming and it has no line comments by itself yet,
-				// so just let it handle the line comment as usual. If it has a
-				// line comment, we can't have both so the one from the key is lost.
+				// so just let it handle the line comment as usual.
 				emitter.line_comment = emitter.key_line_comment
 				emitter.key_line_comment = nil
+			} else {
+				// Both have line comments, so write the one from the key now
+				// and the scalar on the next line, as it was likely parsed.
+				emitter.line_comment, emitter.key_line_comment = emitter.key_line_comment, emitter.line_comment
+				if !yaml_emitter_process_line_comment(emitter, false) {
+					return false
+				}
+				emitter.line_comment, emitter.key_line_comment = emitter.key_line_comment, nil
+				if emitter.indent < 0 {
+					emitter.indent = 0
+				}
+				emitter.indent += emitter.best_indent
+				if !yaml_emitter_write_indent(emitter) {
+					return false
+				}
 			}
 		} else if event.sequence_style() != yaml_FLOW_SEQUENCE_STYLE && (event.typ == yaml_MAPPING_START_EVENT || event.typ == yaml_SEQUENCE_START_EVENT) {
 			// An indented block follows, so write the comment right now.
@@ -843,6 +914,7 @@ func yaml_emitter_emit_block_mapping_value(emitter *yaml_emitter_t, event *yaml_
 	if !yaml_emitter_emit_node(emitter, event, false, false, true, false) {
 		return false
 	}
+	emitter.indent = restore_indent
 	if !yaml_emitter_process_line_comment(emitter, false) {
 		return false
 	}
@@ -925,6 +997,7 @@ func yaml_emitter_emit_sequence_start(emitter *yaml_emitter_t, event *yaml_event
 	if emitter.flow_level > 0 || emitter.canonical || event.sequence_style() == yaml_FLOW_SEQUENCE_STYLE ||
 		yaml_emitter_check_empty_sequence(emitter) {
 		emitter.state = yaml_EMIT_FLOW_SEQUENCE_FIRST_ITEM_STATE
+		emitter.open_comment = event.open_comment
 	} else {
 		emitter.state = yaml_EMIT_BLOCK_SEQUENCE_FIRST_ITEM_STATE
 	}
@@ -942,6 +1015,7 @@ func yaml_emitter_emit_mapping_start(emitter *yaml_emitter_t, event *yaml_event_
 	if emitter.flow_level > 0 || emitter.canonical || event.mapping_style() == yaml_FLOW_MAPPING_STYLE ||
 		yaml_emitter_check_empty_mapping(emitter) {
 		emitter.state = yaml_EMIT_FLOW_MAPPING_FIRST_KEY_STATE
+		emitter.open_comment = event.open_comment
 	} else {
 		emitter.state = yaml_EMIT_BLOCK_MAPPING_FIRST_KEY_STATE
 	}
@@ -1100,15 +1174,20 @@ func yaml_emitter_process_tag(emitter *yaml_emitter_t) bool {
 
 // Write a scalar.
 func yaml_emitter_process_scalar(emitter *yaml_emitter_t) bool {
+	// [Go] Folding lines would split template actions.
+	allow_breaks := !emitter.simple_key_context && !emitter.scalar_data.template
+	if emitter.scalar_data.reader != nil {
+		return yaml_emitter_write_binary_scalar(emitter, emitter.scalar_data.reader)
+	}
 	switch emitter.scalar_data.style {
 	case yaml_PLAIN_SCALAR_STYLE:
-		return yaml_emitter_write_plain_scalar(emitter, emitter.scalar_data.value, !emitter.simple_key_context)
+		return yaml_emitter_write_plain_scalar(emitter, emitter.scalar_data.value, allow_breaks)
 
 	case yaml_SINGLE_QUOTED_SCALAR_STYLE:
-		return yaml_emitter_write_single_quoted_scalar(emitter, emitter.scalar_data.value, !emitter.simple_key_context)
+		return yaml_emitter_write_single_quoted_scalar(emitter, emitter.scalar_data.value, allow_breaks)
 
 	case yaml_DOUBLE_QUOTED_SCALAR_STYLE:
-		return yaml_emitter_write_double_quoted_scalar(emitter, emitter.scalar_data.value, !emitter.simple_key_context)
+		return yaml_emitter_write_double_quoted_scalar(emitter, emitter.scalar_data.value, allow_breaks)
 
 	case yaml_LITERAL_SCALAR_STYLE:
 		return yaml_emitter_write_literal_scalar(emitter, emitter.scalar_data.value)
@@ -1135,6 +1214,18 @@ func yaml_emitter_process_head_comment(emitter *yaml_emitter_t) bool {
 		}
 	}
 
+	if emitter.empty_lines > 0 {
+		if emitter.column > 0 && !put_break(emitter) {
+			return false
+		}
+		for ; emitter.empty_lines > 0; emitter.empty_lines-- {
+			if !put_break(emitter) {
+				return false
+			}
+		}
+		emitter.whitespace = true
+	}
+
 	if len(emitter.head_comment) == 0 {
 		return true
 	}
@@ -1168,6 +1259,32 @@ func yaml_emitter_process_line_comment(emitter *yaml_emitter_t, linebreak bool)
 	return true
 }
 
+// Write the comment following the start indicator of a flow collection.
+func yaml_emitter_process_open_comment(emitter *yaml_emitter_t) bool {
+	if len(emitter.open_comment) == 0 {
+		return true
+	}
+	if !put(emitter, ' ') {
+		return false
+	}
+	if !yaml_emitter_write_comment(emitter, emitter.open_comment) {
+		return false
+	}
+	emitter.open_comment = nil
+	return true
+}
+
+// Write the comment preceding the end indicator of a flow collection.
+func yaml_emitter_process_close_comment(emitter *yaml_emitter_t, event *yaml_event_t) bool {
+	if len(event.close_comment) == 0 {
+		return true
+	}
+	if !yaml_emitter_write_indent(emitter) {
+		return false
+	}
+	return yaml_emitter_write_comment(emitter, event.close_comment)
+}
+
 // Write a foot comment.
 func yaml_emitter_process_foot_comment(emitter *yaml_emitter_t) bool {
 	if len(emitter.foot_comment) == 0 {
@@ -1189,7 +1306,7 @@ func yaml_emitter_process_foot_comment(emitter *yaml_emitter_t) bool {
 
 // Check if a %YAML directive is valid.
 func yaml_emitter_analyze_version_directive(emitter *yaml_emitter_t, version_directive *yaml_version_directive_t) bool {
-	if version_directive.major != 1 || version_directive.minor != 1 {
+	if version_directive.major != 1 || (version_directive.minor != 1 && version_directive.minor != 2) {
 		return yaml_emitter_set_emitter_error(emitter, "incompatible %YAML directive")
 	}
 	return true
@@ -1259,6 +1376,30 @@ func yaml_emitter_analyze_tag(emitter *yaml_emitter_t, tag []byte) bool {
 	return true
 }
 
+// [Go] Return a copy of value with the characters of its template actions
+// replaced by letters, if it has any.
+func yaml_mask_template_actions(value []byte) ([]byte, bool) {
+	var masked []byte
+	for i := 0; i+1 < len(value); i++ {
+		if value[i] != '{' || value[i+1] != '{' {
+			continue
+		}
+		end := bytes.Index(value[i+2:], []byte("}}"))
+		if end < 0 || bytes.IndexAny(value[i:i+2+end], "\r\n") >= 0 {
+			break
+		}
+		if masked == nil {
+			masked = append([]byte(nil), value...)
+		}
+		end += i + 4
+		for ; i < end; i++ {
+			masked[i] = 'x'
+		}
+		i--
+	}
+	return masked, masked != nil
+}
+
 // Check if a scalar is valid.
 func yaml_emitter_analyze_scalar(emitter *yaml_emitter_t, value []byte) bool {
 	var (
@@ -1282,6 +1423,16 @@ func yaml_emitter_analyze_scalar(emitter *yaml_emitter_t, value []byte) bool {
 	)
 
 	emitter.scalar_data.value = value
+	emitter.scalar_data.template = false
+
+	// [Go] Template actions are read whole by a parser that expects them,
+	// so the scalar is analyzed as if they held no special characters.
+	if emitter.templates {
+		if masked, ok := yaml_mask_template_actions(value); ok {
+			emitter.scalar_data.template = true
+			value = masked
+		}
+	}
 
 	if len(value) == 0 {
 		emitter.scalar_data.multiline = false
@@ -1387,6 +1538,10 @@ func yaml_emitter_analyze_scalar(emitter *yaml_emitter_t, value []byte) bool {
 	if trailing_space {
 		emitter.scalar_data.block_allowed = false
 	}
+	if (leading_space || leading_break) && emitter.best_indent > 9 {
+		// [Go] The indentation indicator of block scalars is a single digit.
+		emitter.scalar_data.block_allowed = false
+	}
 	if break_space {
 		emitter.scalar_data.flow_plain_allowed = false
 		emitter.scalar_data.block_plain_allowed = false
@@ -1451,6 +1606,23 @@ func yaml_emitter_analyze_event(emitter *yaml_emitter_t, event *yaml_event_t) bo
 				return false
 			}
 		}
+		if event.value_reader != nil {
+			// [Go] The data is only read when written, so it can't be
+			// analyzed. Base64 fits either block or quoted styles.
+			emitter.scalar_data.multiline = true
+			emitter.scalar_data.flow_plain_allowed = false
+			emitter.scalar_data.block_plain_allowed = false
+			emitter.scalar_data.single_quoted_allowed = false
+			emitter.scalar_data.block_allowed = true
+			emitter.scalar_data.template = false
+			emitter.scalar_data.reader = event.value_reader
+			emitter.scalar_data.chomping = 0
+			emitter.scalar_data.increment = 0
+			break
+		}
+		emitter.scalar_data.reader = nil
+		emitter.scalar_data.chomping = event.chomping
+		emitter.scalar_data.increment = event.increment
 		if !yaml_emitter_analyze_scalar(emitter, event.value) {
 			return false
 		}
@@ -1854,8 +2026,24 @@ func yaml_emitter_write_double_quoted_scalar(emitter *yaml_emitter_t, value []by
 }
 
 func yaml_emitter_write_block_scalar_hints(emitter *yaml_emitter_t, value []byte) bool {
-	if is_space(value, 0) || is_break(value, 0) {
-		indent_hint := []byte{'0' + byte(emitter.best_indent)}
+	// [Go] The indentation indicator is relative to the indentation of
+	// the enclosing block collection, or to the first column for a
+	// scalar at the top level, which may differ from best_indent when
+	// sequences are compact or indentations are set per kind.
+	parent_indent := emitter.indents[len(emitter.indents)-1]
+	if parent_indent < 0 {
+		parent_indent = 0
+	}
+	increment := int(emitter.scalar_data.increment)
+	if increment > 0 {
+		// [Go] Indent the content as set by the indicator the scalar
+		// was read with.
+		emitter.indent = parent_indent + increment
+	} else if is_space(value, 0) || is_break(value, 0) {
+		increment = emitter.indent - parent_indent
+	}
+	if increment > 0 {
+		indent_hint := []byte{'0' + byte(increment)}
 		if !yaml_emitter_write_indicator(emitter, indent_hint, false, false, false) {
 			return false
 		}
@@ -1887,6 +2075,12 @@ func yaml_emitter_write_block_scalar_hints(emitter *yaml_emitter_t, value []byte
 			}
 		}
 	}
+	if chomp_hint[0] == 0 && emitter.scalar_data.chomping > 0 {
+		// [Go] Keep the indicator the scalar was read with, which
+		// doesn't change a value ending with a single line break.
+		chomp_hint[0] = '+'
+		emitter.open_ended = true
+	}
 	if chomp_hint[0] != 0 {
 		if !yaml_emitter_write_indicator(emitter, chomp_hint[:], false, false, false) {
 			return false
@@ -1932,6 +2126,74 @@ func yaml_emitter_write_literal_scalar(emitter *yaml_emitter_t, value []byte) bo
 	return true
 }
 
+// [Go] Write the base64 encoding of the data read from reader as it's
+// read, without holding it, in the literal style with lines of the binary
+// width, or in the double-quoted style on a single line where block
+// scalars aren't allowed.
+func yaml_emitter_write_binary_scalar(emitter *yaml_emitter_t, reader io.Reader) bool {
+	literal := emitter.scalar_data.style == yaml_LITERAL_SCALAR_STYLE
+	if literal {
+		if !yaml_emitter_write_indicator(emitter, []byte{'|'}, true, false, false) {
+			return false
+		}
+		if !yaml_emitter_process_line_comment(emitter, true) {
+			return false
+		}
+	} else if !yaml_emitter_write_indicator(emitter, []byte{'"'}, true, false, false) {
+		return false
+	}
+	w := &yaml_binary_writer{emitter: emitter, literal: literal, line_len: emitter.binary_width}
+	if w.line_len == 0 {
+		w.line_len = defaultBase64Width
+	}
+	enc := base64.NewEncoder(base64.StdEncoding, w)
+	if _, err := io.Copy(enc, reader); err != nil {
+		if w.failed {
+			return false
+		}
+		emitter.read_error = err
+		return yaml_emitter_set_emitter_error(emitter, "read error: "+err.Error())
+	}
+	if enc.Close() != nil {
+		return false
+	}
+	if !literal {
+		return yaml_emitter_write_indicator(emitter, []byte{'"'}, false, false, false)
+	}
+	return true
+}
+
+// [Go] yaml_binary_writer writes base64 data to an emitter, breaking it into
+// indented lines for literal scalars.
+type yaml_binary_writer struct {
+	emitter *yaml_emitter_t
+	literal  bool
+	line_len int
+	column   int
+	failed   bool
+}
+
+func (w *yaml_binary_writer) Write(data []byte) (int, error) {
+	for i, c := range data {
+		if w.literal && w.column == w.line_len {
+			w.column = 0
+		}
+		// Indenting starts a new line.
+		if w.literal && w.column == 0 && !yaml_emitter_write_indent(w.emitter) {
+			w.failed = true
+			return i, errors.New(w.emitter.problem)
+		}
+		if !put(w.emitter, c) {
+			w.failed = true
+			return i, errors.New(w.emitter.problem)
+		}
+		w.column++
+		w.emitter.indention = false
+		w.emitter.whitespace = false
+	}
+	return len(data), nil
+}
+
 func yaml_emitter_write_folded_scalar(emitter *yaml_emitter_t, value []byte) bool {
 	if !yaml_emitter_write_indicator(emitter, []byte{'>'}, true, false, false) {
 		return false
@@ -1973,7 +2235,7 @@ func yaml_emitter_write_folded_scalar(emitter *yaml_emitter_t, value []byte) boo
 				}
 				leading_spaces = is_blank(value, i)
 			}
-			if !breaks && is_space(value, i) && !is_space(value, i+1) && emitter.column > emitter.best_width {
+			if !breaks && is_space(value, i) && !is_space(value, i+1) && emitter.column > emitter.best_width && !emitter.scalar_data.template {
 				if !yaml_emitter_write_indent(emitter) {
 					return false
 				}
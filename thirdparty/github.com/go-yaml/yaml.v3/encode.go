@@ -16,7 +16,9 @@
 package yaml
 
 import (
+	"context"
 	"encoding"
+	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
@@ -35,6 +37,50 @@ type encoder struct {
 	flow     bool
 	indent   int
 	doneInit bool
+
+	preserveLexemes bool
+	emitters        map[reflect.Type]TagEmitter
+	timeLayout      string
+	durations       DurationFormat
+	shareMappings   bool
+	aliasRepeats    bool
+	tagHandles      []TagDirective
+	schema          Schema
+	anchorCycles    bool
+	multiline       MultilineRules
+	sets            bool
+
+	// visiting holds the pointers, maps and slices being encoded, with
+	// the length of path when they were reached, so that cycles can be
+	// reported rather than recursing forever.
+	visiting map[encodeVisit]int
+	path     []encodePathElem
+
+	// cycleAnchors holds the anchors of the values that are referred
+	// back to, when cycles are written as aliases. See findCycles.
+	cycleAnchors map[encodeVisit]string
+	// anchor is set for the next collection to start.
+	anchor string
+	// anchors holds the values of the struct fields written with an
+	// anchor flag in the current document, by anchor.
+	anchors map[string]reflect.Value
+	// style is set for the next string, the value of a struct field with
+	// a style flag.
+	style yaml_scalar_style_t
+	// headComment is set for the next scalar, the key of a struct field
+	// with a yamlcomment tag.
+	headComment []byte
+
+	// ctx is checked for cancellation before emitting each event, if set.
+	ctx context.Context
+
+	// skipReaders leaves the data of io.Reader values unread, when the
+	// output is discarded.
+	skipReaders bool
+
+	// writeErr holds the first error returned by the writer, which is
+	// returned again without writing anything once it's set.
+	writeErr error
 }
 
 func newEncoder() *encoder {
@@ -76,13 +122,78 @@ func (e *encoder) destroy() {
 	yaml_emitter_delete(&e.emitter)
 }
 
+// reset prepares the encoder for writing a new stream to w, keeping its
+// settings and discarding any output not yet flushed.
+func (e *encoder) reset(w io.Writer) {
+	yaml_emitter_reset(&e.emitter)
+	yaml_emitter_set_output_writer(&e.emitter, w)
+	e.event = yaml_event_t{}
+	e.out = nil
+	e.flow = false
+	e.doneInit = false
+	e.writeErr = nil
+}
+
 func (e *encoder) emit() {
+	if e.writeErr != nil {
+		fail(e.writeErr)
+	}
+	if e.ctx != nil {
+		if err := e.ctx.Err(); err != nil {
+			fail(err)
+		}
+	}
 	// This will internally delete the e.event value.
 	e.must(yaml_emitter_emit(&e.emitter, &e.event))
 }
 
+// directives returns the %YAML and %TAG directives for starting a document.
+func (e *encoder) directives(v string, tagDirectives []TagDirective) (*yaml_version_directive_t, []yaml_tag_directive_t) {
+	var version *yaml_version_directive_t
+	if v != "" {
+		var major, minor int8
+		if _, err := fmt.Sscanf(v, "%d.%d", &major, &minor); err != nil || fmt.Sprintf("%d.%d", major, minor) != v {
+			failf("invalid YAML version %q", v)
+		}
+		version = &yaml_version_directive_t{major: major, minor: minor}
+	}
+	var tags []yaml_tag_directive_t
+	for _, td := range tagDirectives {
+		tags = append(tags, yaml_tag_directive_t{handle: []byte(td.Handle), prefix: []byte(td.Prefix)})
+	}
+next:
+	for _, td := range e.tagHandles {
+		for _, declared := range tagDirectives {
+			if declared.Handle == td.Handle {
+				continue next
+			}
+		}
+		tags = append(tags, yaml_tag_directive_t{handle: []byte(td.Handle), prefix: []byte(td.Prefix)})
+	}
+	return version, tags
+}
+
+// expandTag returns the tag with a leading handle declared with
+// Encoder.SetTagHandle replaced by its prefix.
+func (e *encoder) expandTag(tag string) string {
+	for _, td := range e.tagHandles {
+		if len(tag) > len(td.Handle) && strings.HasPrefix(tag, td.Handle) {
+			return td.Prefix + tag[len(td.Handle):]
+		}
+	}
+	return tag
+}
+
 func (e *encoder) must(ok bool) {
 	if !ok {
+		if err := e.emitter.write_error; err != nil {
+			e.writeErr = fmt.Errorf("yaml: write error: %w", err)
+			fail(e.writeErr)
+		}
+		if err := e.emitter.read_error; err != nil {
+			e.emitter.read_error = nil
+			fail(fmt.Errorf("yaml: cannot read binary value: %w", err))
+		}
 		msg := e.emitter.problem
 		if msg == "" {
 			msg = "unknown problem generating YAML content"
@@ -97,12 +208,33 @@ func (e *encoder) marshalDoc(tag string, in reflect.Value) {
 	if in.IsValid() {
 		node, _ = in.Interface().(*Node)
 	}
+	// State left by a failed document is dropped.
+	e.visiting = nil
+	e.path = e.path[:0]
+	e.anchor = ""
+	e.anchors = nil
 	if node != nil && node.Kind == DocumentNode {
 		e.nodev(in)
 	} else {
-		yaml_document_start_event_initialize(&e.event, nil, nil, true)
+		version, tags := e.directives("", nil)
+		yaml_document_start_event_initialize(&e.event, version, tags, true)
 		e.emit()
-		e.marshal(tag, in)
+		if e.shareMappings || e.aliasRepeats {
+			node := e.valueNode(tag, in)
+			if e.shareMappings {
+				node = shareMappings(node)
+			}
+			if e.aliasRepeats {
+				node = aliasRepeats(node)
+			}
+			e.node(node, "")
+		} else {
+			if e.anchorCycles && e.cycleAnchors == nil {
+				e.cycleAnchors = e.findCycles(tag, in)
+				defer func() { e.cycleAnchors = nil }()
+			}
+			e.marshal(tag, in)
+		}
 		yaml_document_end_event_initialize(&e.event, true)
 		e.emit()
 	}
@@ -114,7 +246,30 @@ func (e *encoder) marshal(tag string, in reflect.Value) {
 		e.nilv()
 		return
 	}
+	if emit, v := e.tagEmitter(in); emit != nil {
+		node, err := emit(v.Interface())
+		if err != nil {
+			fail(err)
+		}
+		if node == nil {
+			e.nilv()
+			return
+		}
+		e.node(node, "")
+		return
+	}
+	if in.Kind() == reflect.Interface && in.Type().Implements(readerType) && !in.IsNil() {
+		e.readerv(tag, in.Elem().Interface().(io.Reader))
+		return
+	}
 	iface := in.Interface()
+	if in.Kind() != reflect.Ptr && in.CanAddr() && !isTextOrJSONMarshaler(iface) {
+		// Like encoding/json, use MarshalText and MarshalJSON methods
+		// with pointer receivers when the value is addressable.
+		if m := in.Addr().Interface(); isTextOrJSONMarshaler(m) {
+			iface = m
+		}
+	}
 	switch value := iface.(type) {
 	case *Node:
 		e.nodev(in)
@@ -134,7 +289,16 @@ func (e *encoder) marshal(tag string, in reflect.Value) {
 		e.timev(tag, in.Elem())
 		return
 	case time.Duration:
-		e.stringv(tag, reflect.ValueOf(value.String()))
+		e.durationv(tag, value)
+		return
+	case OrderedMap:
+		e.pairsv(tag, omapTag, value)
+		return
+	case Pairs:
+		e.pairsv(tag, pairsTag, value)
+		return
+	case MapSlice:
+		e.itemsv(tag, value)
 		return
 	case Marshaler:
 		v, err := value.MarshalYAML()
@@ -153,11 +317,29 @@ func (e *encoder) marshal(tag string, in reflect.Value) {
 			fail(err)
 		}
 		in = reflect.ValueOf(string(text))
+	case json.Marshaler:
+		e.jsonv(value)
+		return
 	case nil:
 		e.nilv()
 		return
 	}
 	switch in.Kind() {
+	case reflect.Map, reflect.Ptr, reflect.Slice:
+		if in.IsNil() || in.Kind() == reflect.Slice && in.Len() == 0 {
+			break
+		}
+		v := encodeVisit{ptr: in.Pointer(), typ: in.Type()}
+		if in.Kind() == reflect.Slice {
+			v.len = in.Len()
+		}
+		if start, cycle := e.visit(v); cycle {
+			e.cycle(v, start)
+			return
+		}
+		defer delete(e.visiting, v)
+	}
+	switch in.Kind() {
 	case reflect.Interface:
 		e.marshal(tag, in.Elem())
 	case reflect.Map:
@@ -183,17 +365,264 @@ func (e *encoder) marshal(tag string, in reflect.Value) {
 	}
 }
 
+// encodeVisit identifies a pointer, map or slice being encoded.
+type encodeVisit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// encodePathElem is a struct field or Node mapping key, a map key or a
+// sequence index in the path of the value being encoded. Map keys are
+// only formatted when reporting a cycle.
+type encodePathElem struct {
+	name  string
+	key   reflect.Value
+	index int
+}
+
+func (e *encoder) pushPath(name string) {
+	e.path = append(e.path, encodePathElem{name: name, index: -1})
+}
+
+func (e *encoder) pushKey(key reflect.Value) {
+	e.path = append(e.path, encodePathElem{key: key, index: -1})
+}
+
+func (e *encoder) pushIndex(i int) {
+	e.path = append(e.path, encodePathElem{index: i})
+}
+
+func (e *encoder) popPath() {
+	e.path = e.path[:len(e.path)-1]
+}
+
+// pathString returns the path made of the elements in path, such as
+// "spec.containers[0].image", or "the root value" if path is empty.
+func pathString(path []encodePathElem) string {
+	if len(path) == 0 {
+		return "the root value"
+	}
+	var b strings.Builder
+	for _, elem := range path {
+		if elem.index >= 0 {
+			b.WriteString("[" + strconv.Itoa(elem.index) + "]")
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		if elem.key.IsValid() {
+			fmt.Fprint(&b, elem.key.Interface())
+		} else {
+			b.WriteString(elem.name)
+		}
+	}
+	return b.String()
+}
+
+// visit records that v is being encoded at the current path, and sets
+// its anchor for the collection it starts, if any. If v is already being
+// encoded, it returns the length of the path when v was reached instead.
+func (e *encoder) visit(v encodeVisit) (start int, cycle bool) {
+	if start, ok := e.visiting[v]; ok {
+		return start, true
+	}
+	if e.visiting == nil {
+		e.visiting = make(map[encodeVisit]int)
+	}
+	e.visiting[v] = len(e.path)
+	if anchor, ok := e.cycleAnchors[v]; ok {
+		e.anchor = anchor
+	}
+	return 0, false
+}
+
+// cycleError fails naming the path of the value that refers back to the
+// value reached at e.path[:start].
+func (e *encoder) cycleError(start int) {
+	failf("encountered a cycle: the value at %s refers back to %s", pathString(e.path), pathString(e.path[:start]))
+}
+
+// cycle writes an alias to v, which refers back to itself through the
+// value reached at e.path[:start], when writing cycles as aliases, or
+// fails otherwise.
+func (e *encoder) cycle(v encodeVisit, start int) {
+	if e.cycleAnchors == nil {
+		e.cycleError(start)
+	}
+	anchor, ok := e.cycleAnchors[v]
+	if !ok {
+		anchor = "cycle"
+		if n := len(e.cycleAnchors); n > 0 {
+			anchor += strconv.Itoa(n + 1)
+		}
+		e.cycleAnchors[v] = anchor
+	}
+	yaml_alias_event_initialize(&e.event, []byte(anchor))
+	e.emit()
+}
+
+// findCycles encodes in without writing it, to return the anchors of
+// the values that are referred back to, so that they are anchored when
+// encoding in and then written as aliases of these anchors.
+func (e *encoder) findCycles(tag string, in reflect.Value) map[encodeVisit]string {
+	sub := newEncoder()
+	defer sub.destroy()
+	sub.emitters = e.emitters
+	sub.timeLayout = e.timeLayout
+	sub.durations = e.durations
+	sub.anchorCycles = true
+	sub.cycleAnchors = make(map[encodeVisit]string)
+	sub.skipReaders = true
+	sub.marshalDoc(tag, in)
+	return sub.cycleAnchors
+}
+
+// tagEmitter returns the registered tag emitter for the type of in, or
+// for the type it points to, along with the value to provide to it.
+func (e *encoder) tagEmitter(in reflect.Value) (TagEmitter, reflect.Value) {
+	if e.emitters == nil {
+		return nil, in
+	}
+	if emit, ok := e.emitters[in.Type()]; ok {
+		return emit, in
+	}
+	if in.Kind() == reflect.Ptr {
+		return e.emitters[in.Type().Elem()], in.Elem()
+	}
+	return nil, in
+}
+
+func isTextOrJSONMarshaler(v interface{}) bool {
+	switch v.(type) {
+	case encoding.TextMarshaler, json.Marshaler:
+		return true
+	}
+	return false
+}
+
+// jsonv encodes the JSON document produced by a json.Marshaler, which
+// is also valid YAML, as block style YAML.
+func (e *encoder) jsonv(m json.Marshaler) {
+	data, err := m.MarshalJSON()
+	if err != nil {
+		fail(err)
+	}
+	p := newParser(data)
+	defer p.destroy()
+	node := p.parse()
+	if node != nil && node.Kind == DocumentNode && len(node.Content) == 1 {
+		node = node.Content[0]
+	}
+	if node == nil || node.Kind == DocumentNode {
+		e.nilv()
+		return
+	}
+	resetStyle(node)
+	e.node(node, "")
+}
+
+// resetStyle clears the flow and quoting styles of node and its children.
+func resetStyle(node *Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
 func (e *encoder) mapv(tag string, in reflect.Value) {
+	// Maps with empty struct values are sets.
+	set := e.sets && in.Type().Elem() == emptyStructType
+	if set && tag == "" {
+		tag = longTag(setTag)
+	}
 	e.mappingv(tag, func() {
 		keys := keyList(in.MapKeys())
 		sort.Sort(keys)
 		for _, k := range keys {
 			e.marshal("", k)
-			e.marshal("", in.MapIndex(k))
+			if set {
+				e.emitScalar("", "", "", yaml_PLAIN_SCALAR_STYLE, nil, nil, nil, nil)
+			} else {
+				e.pushKey(k)
+				e.marshal("", in.MapIndex(k))
+				e.popPath()
+			}
 		}
 	})
 }
 
+// itemsv encodes the items as a mapping in order. Items with comments
+// are encoded as nodes, so that the comments are placed as in a Node.
+func (e *encoder) itemsv(tag string, items MapSlice) {
+	commented := false
+	for _, item := range items {
+		if item.HeadComment != "" || item.LineComment != "" || item.FootComment != "" {
+			commented = true
+			break
+		}
+	}
+	if !commented {
+		e.mappingv(tag, func() {
+			for _, item := range items {
+				e.marshal("", reflect.ValueOf(item.Key))
+				e.pushKey(reflect.ValueOf(item.Key))
+				e.marshal("", reflect.ValueOf(item.Value))
+				e.popPath()
+			}
+		})
+		return
+	}
+	node := &Node{Kind: MappingNode, Tag: tag}
+	if e.flow {
+		e.flow = false
+		node.Style = FlowStyle
+	}
+	for _, item := range items {
+		key := e.valueNode("", reflect.ValueOf(item.Key))
+		value := e.valueNode("", reflect.ValueOf(item.Value))
+		key.HeadComment = item.HeadComment
+		key.FootComment = item.FootComment
+		if value.Kind == MappingNode || value.Kind == SequenceNode {
+			key.LineComment = item.LineComment
+		} else {
+			value.LineComment = item.LineComment
+		}
+		node.Content = append(node.Content, key, value)
+	}
+	e.node(node, "")
+}
+
+// pairsv encodes the pairs as a sequence of single-pair mappings with
+// the tag, or with deftag if tag is empty.
+func (e *encoder) pairsv(tag, deftag string, pairs []Pair) {
+	if tag == "" {
+		tag = deftag
+	}
+	flow := e.flow
+	e.flow = false
+	style := yaml_BLOCK_SEQUENCE_STYLE
+	if flow {
+		style = yaml_FLOW_SEQUENCE_STYLE
+	}
+	e.must(yaml_sequence_start_event_initialize(&e.event, nil, []byte(longTag(tag)), false, style))
+	e.emit()
+	for i, pair := range pairs {
+		e.flow = flow
+		e.pushIndex(i)
+		e.mappingv("", func() {
+			e.marshal("", reflect.ValueOf(pair.Key))
+			e.pushKey(reflect.ValueOf(pair.Key))
+			e.marshal("", reflect.ValueOf(pair.Value))
+			e.popPath()
+		})
+		e.popPath()
+	}
+	e.must(yaml_sequence_end_event_initialize(&e.event))
+	e.emit()
+}
+
 func (e *encoder) fieldByIndex(v reflect.Value, index []int) (field reflect.Value) {
 	for _, num := range index {
 		for {
@@ -217,40 +646,137 @@ func (e *encoder) structv(tag string, in reflect.Value) {
 		panic(err)
 	}
 	e.mappingv(tag, func() {
-		for _, info := range sinfo.FieldsList {
-			var value reflect.Value
-			if info.Inline == nil {
-				value = in.Field(info.Num)
-			} else {
-				value = e.fieldByIndex(in, info.Inline)
-				if !value.IsValid() {
-					continue
-				}
-			}
-			if info.OmitEmpty && isZero(value) {
+		e.structFields(sinfo, in, nil)
+	})
+}
+
+// structFields encodes the keys and values of the fields in the struct
+// in, whose keys must not conflict with those in outer, if provided.
+func (e *encoder) structFields(sinfo *structInfo, in reflect.Value, outer map[string]fieldInfo) {
+	for _, info := range sinfo.FieldsList {
+		if _, found := outer[info.Key]; found {
+			panic(fmt.Sprintf("cannot have key %q in inlined interface: conflicts with struct field", info.Key))
+		}
+		var value reflect.Value
+		if info.Inline == nil {
+			value = in.Field(info.Num)
+		} else {
+			value = e.fieldByIndex(in, info.Inline)
+			if !value.IsValid() {
 				continue
 			}
-			e.marshal("", reflect.ValueOf(info.Key))
-			e.flow = info.Flow
-			e.marshal("", value)
-		}
-		if sinfo.InlineMap >= 0 {
-			m := in.Field(sinfo.InlineMap)
-			if m.Len() > 0 {
-				e.flow = false
-				keys := keyList(m.MapKeys())
-				sort.Sort(keys)
-				for _, k := range keys {
-					if _, found := sinfo.FieldsMap[k.String()]; found {
-						panic(fmt.Sprintf("cannot have key %q in inlined map: conflicts with struct field", k.String()))
-					}
-					e.marshal("", k)
-					e.flow = false
-					e.marshal("", m.MapIndex(k))
-				}
+		}
+		if info.OmitEmpty && isZero(value) || info.OmitZero && isZeroValue(value) {
+			continue
+		}
+		if info.HeadComment != "" {
+			e.headComment = []byte(info.HeadComment)
+		}
+		e.marshal("", reflect.ValueOf(info.Key))
+		e.headComment = nil
+		if e.aliasField(info, value) {
+			continue
+		}
+		e.flow = info.Flow
+		if info.Style != 0 && isStringValue(value) {
+			e.style = info.Style
+		}
+		if info.Anchor != "" {
+			if e.anchors == nil {
+				e.anchors = make(map[string]reflect.Value)
 			}
+			e.anchors[info.Anchor] = value
+			e.anchor = info.Anchor
 		}
-	})
+		e.pushPath(info.Key)
+		e.marshal("", value)
+		e.popPath()
+		if e.anchor != "" {
+			// The value was written without the anchor.
+			delete(e.anchors, e.anchor)
+		}
+		e.style = 0
+		e.anchor = ""
+	}
+	if outer == nil {
+		outer = sinfo.FieldsMap
+	}
+	if sinfo.InlineMap >= 0 {
+		e.inlineMap(in.Field(sinfo.InlineMap), outer, "map")
+	}
+	if sinfo.InlineIface >= 0 {
+		value := in.Field(sinfo.InlineIface)
+		for value.Kind() == reflect.Interface || value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				return
+			}
+			value = value.Elem()
+		}
+		switch value.Kind() {
+		case reflect.Struct:
+			isinfo, err := getStructInfo(value.Type())
+			if err != nil {
+				panic(err)
+			}
+			e.structFields(isinfo, value, outer)
+		case reflect.Map:
+			if value.Type().Key().Kind() != reflect.String {
+				panic("option ,inline needs a map with string keys in interface field of type " + in.Type().String())
+			}
+			e.inlineMap(value, outer, "interface")
+		default:
+			panic("option ,inline needs a struct or map value in interface field of type " + in.Type().String())
+		}
+	}
+}
+
+// aliasField writes the value of the field as an alias, and returns true,
+// if the field has an alias flag naming an anchor written earlier in the
+// document for an equal value.
+func (e *encoder) aliasField(info fieldInfo, value reflect.Value) bool {
+	if info.Alias == "" {
+		return false
+	}
+	anchored, ok := e.anchors[info.Alias]
+	if !ok || !anchored.CanInterface() || !value.CanInterface() || !reflect.DeepEqual(anchored.Interface(), value.Interface()) {
+		return false
+	}
+	yaml_alias_event_initialize(&e.event, []byte(info.Alias))
+	e.emit()
+	return true
+}
+
+// isStringValue returns whether v is a string, or a pointer or interface
+// holding one, so that the style of its field applies to it.
+func isStringValue(v reflect.Value) bool {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return false
+		}
+		v = v.Elem()
+	}
+	return v.Kind() == reflect.String
+}
+
+// inlineMap encodes the keys and values of the inlined map m, which
+// must not conflict with the struct fields in outer.
+func (e *encoder) inlineMap(m reflect.Value, outer map[string]fieldInfo, what string) {
+	if m.Len() == 0 {
+		return
+	}
+	e.flow = false
+	keys := keyList(m.MapKeys())
+	sort.Sort(keys)
+	for _, k := range keys {
+		if _, found := outer[k.String()]; found {
+			panic(fmt.Sprintf("cannot have key %q in inlined %s: conflicts with struct field", k.String(), what))
+		}
+		e.marshal("", k)
+		e.flow = false
+		e.pushKey(k)
+		e.marshal("", m.MapIndex(k))
+		e.popPath()
+	}
 }
 
 func (e *encoder) mappingv(tag string, f func()) {
@@ -260,7 +786,9 @@ func (e *encoder) mappingv(tag string, f func()) {
 		e.flow = false
 		style = yaml_FLOW_MAPPING_STYLE
 	}
-	yaml_mapping_start_event_initialize(&e.event, nil, []byte(tag), implicit, style)
+	anchor := e.anchor
+	e.anchor = ""
+	yaml_mapping_start_event_initialize(&e.event, []byte(anchor), []byte(tag), implicit, style)
 	e.emit()
 	f()
 	yaml_mapping_end_event_initialize(&e.event)
@@ -274,11 +802,15 @@ func (e *encoder) slicev(tag string, in reflect.Value) {
 		e.flow = false
 		style = yaml_FLOW_SEQUENCE_STYLE
 	}
-	e.must(yaml_sequence_start_event_initialize(&e.event, nil, []byte(tag), implicit, style))
+	anchor := e.anchor
+	e.anchor = ""
+	e.must(yaml_sequence_start_event_initialize(&e.event, []byte(anchor), []byte(tag), implicit, style))
 	e.emit()
 	n := in.Len()
 	for i := 0; i < n; i++ {
+		e.pushIndex(i)
 		e.marshal("", in.Index(i))
+		e.popPath()
 	}
 	e.must(yaml_sequence_end_event_initialize(&e.event))
 	e.emit()
@@ -336,12 +868,12 @@ func (e *encoder) stringv(tag string, in reflect.Value) {
 		// It can't be encoded directly as YAML so use a binary tag
 		// and encode it as base64.
 		tag = binaryTag
-		s = encodeBase64(s)
+		s = encodeBase64Width(s, e.base64Width())
 	case tag == "":
 		// Check to see if it would resolve to a specific
 		// tag when encoded unquoted. If it doesn't,
 		// there's no need to quote it.
-		rtag, _ := resolve("", s)
+		rtag, _, _ := e.schema.resolvePlain(s)
 		canUsePlain = rtag == strTag && !(isBase60Float(s) || isOldBool(s))
 	}
 	// Note: it's possible for user code to emit invalid YAML
@@ -351,17 +883,46 @@ func (e *encoder) stringv(tag string, in reflect.Value) {
 	case strings.Contains(s, "\n"):
 		if e.flow {
 			style = yaml_DOUBLE_QUOTED_SCALAR_STYLE
-		} else {
+		} else if tag == binaryTag {
 			style = yaml_LITERAL_SCALAR_STYLE
+		} else {
+			style = e.multilineStyle(s)
 		}
 	case canUsePlain:
 		style = yaml_PLAIN_SCALAR_STYLE
 	default:
 		style = yaml_DOUBLE_QUOTED_SCALAR_STYLE
 	}
+	if e.style != 0 {
+		// The emitter falls back to another style if this one can't
+		// be used.
+		style = e.style
+		e.style = 0
+	}
 	e.emitScalar(s, "", tag, style, nil, nil, nil, nil)
 }
 
+// multilineStyle returns the style of the string s spanning several
+// lines, in block context, following the rules of e.multiline.
+func (e *encoder) multilineStyle(s string) yaml_scalar_style_t {
+	r := &e.multiline
+	if r.QuoteUnterminated && !strings.HasSuffix(s, "\n") {
+		return yaml_DOUBLE_QUOTED_SCALAR_STYLE
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	if len(lines) < r.MinLines {
+		return yaml_DOUBLE_QUOTED_SCALAR_STYLE
+	}
+	if r.FoldLength > 0 {
+		for _, line := range lines {
+			if utf8.RuneCountInString(line) >= r.FoldLength {
+				return yaml_FOLDED_SCALAR_STYLE
+			}
+		}
+	}
+	return yaml_LITERAL_SCALAR_STYLE
+}
+
 func (e *encoder) boolv(tag string, in reflect.Value) {
 	var s string
 	if in.Bool() {
@@ -384,10 +945,27 @@ func (e *encoder) uintv(tag string, in reflect.Value) {
 
 func (e *encoder) timev(tag string, in reflect.Value) {
 	t := in.Interface().(time.Time)
-	s := t.Format(time.RFC3339Nano)
+	layout := e.timeLayout
+	if layout == "" {
+		layout = time.RFC3339Nano
+	}
+	s := t.Format(layout)
 	e.emitScalar(s, "", tag, yaml_PLAIN_SCALAR_STYLE, nil, nil, nil, nil)
 }
 
+func (e *encoder) durationv(tag string, d time.Duration) {
+	switch e.durations {
+	case DurationSeconds:
+		s := strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+		e.emitScalar(s, "", tag, yaml_PLAIN_SCALAR_STYLE, nil, nil, nil, nil)
+	case DurationNanoseconds:
+		s := strconv.FormatInt(int64(d), 10)
+		e.emitScalar(s, "", tag, yaml_PLAIN_SCALAR_STYLE, nil, nil, nil, nil)
+	default:
+		e.stringv(tag, reflect.ValueOf(d.String()))
+	}
+}
+
 func (e *encoder) floatv(tag string, in reflect.Value) {
 	// Issue #352: When formatting, use the precision of the underlying value
 	precision := 64
@@ -395,7 +973,14 @@ func (e *encoder) floatv(tag string, in reflect.Value) {
 		precision = 32
 	}
 
-	s := strconv.FormatFloat(in.Float(), 'g', -1, precision)
+	s := formatFloat(in.Float(), precision)
+	e.emitScalar(s, "", tag, yaml_PLAIN_SCALAR_STYLE, nil, nil, nil, nil)
+}
+
+// formatFloat returns the YAML representation of f, with the given
+// precision in bits.
+func formatFloat(f float64, precision int) string {
+	s := strconv.FormatFloat(f, 'g', -1, precision)
 	switch s {
 	case "+Inf":
 		s = ".inf"
@@ -404,7 +989,37 @@ func (e *encoder) floatv(tag string, in reflect.Value) {
 	case "NaN":
 		s = ".nan"
 	}
-	e.emitScalar(s, "", tag, yaml_PLAIN_SCALAR_STYLE, nil, nil, nil, nil)
+	return s
+}
+
+// base64Width returns the length of the lines of base64 text written for
+// binary values, or zero for a single line.
+func (e *encoder) base64Width() int {
+	switch w := e.emitter.binary_width; {
+	case w == 0:
+		return defaultBase64Width
+	case w < 0:
+		return 0
+	default:
+		return w
+	}
+}
+
+var readerType = reflect.TypeOf((*io.Reader)(nil)).Elem()
+
+// readerv writes the data read from r as a !!binary scalar, streaming its
+// base64 encoding to the output.
+func (e *encoder) readerv(tag string, r io.Reader) {
+	if tag != "" && tag != binaryTag {
+		failf("cannot marshal the data of an io.Reader as %s", tag)
+	}
+	anchor := e.anchor
+	e.anchor = ""
+	e.must(yaml_scalar_event_initialize(&e.event, []byte(anchor), []byte(longTag(binaryTag)), nil, false, false, yaml_LITERAL_SCALAR_STYLE))
+	if !e.skipReaders {
+		e.event.value_reader = r
+	}
+	e.emit()
 }
 
 func (e *encoder) nilv() {
@@ -417,6 +1032,14 @@ func (e *encoder) emitScalar(value, anchor, tag string, style yaml_scalar_style_
 	if !implicit {
 		tag = longTag(tag)
 	}
+	if anchor == "" {
+		anchor = e.anchor
+	}
+	e.anchor = ""
+	if head == nil {
+		head = e.headComment
+	}
+	e.headComment = nil
 	e.must(yaml_scalar_event_initialize(&e.event, []byte(anchor), []byte(tag), []byte(value), implicit, implicit, style))
 	e.event.head_comment = head
 	e.event.line_comment = line
@@ -436,9 +1059,31 @@ func (e *encoder) node(node *Node, tail string) {
 		return
 	}
 
+	// The anchor of a field applies to the node it's encoded as, unless
+	// the node can't hold it, in which case aliases can't refer to it.
+	if e.anchor != "" && node.Kind != DocumentNode {
+		anchor := e.anchor
+		e.anchor = ""
+		if node.Kind == AliasNode || node.Anchor != "" {
+			delete(e.anchors, anchor)
+		} else {
+			kopy := *node
+			kopy.Anchor = anchor
+			node = &kopy
+		}
+	}
+
+	if node.Kind == ScalarNode && node.Lexeme != "" {
+		if value, ok := e.lexemeValue(node); ok && value != node.Value {
+			kopy := *node
+			kopy.Value = value
+			node = &kopy
+		}
+	}
+
 	// If the tag was not explicitly requested, and dropping it won't change the
 	// implicit tag of the value, don't include it in the presentation.
-	var tag = node.Tag
+	var tag = e.expandTag(node.Tag)
 	var stag = shortTag(tag)
 	var forceQuoting bool
 	if tag != "" && node.Style&TaggedStyle == 0 {
@@ -446,7 +1091,7 @@ func (e *encoder) node(node *Node, tail string) {
 			if stag == strTag && node.Style&(SingleQuotedStyle|DoubleQuotedStyle|LiteralStyle|FoldedStyle) != 0 {
 				tag = ""
 			} else {
-				rtag, _ := resolve("", node.Value)
+				rtag, _, _ := e.schema.resolvePlain(node.Value)
 				if rtag == stag {
 					tag = ""
 				} else if stag == strTag {
@@ -468,10 +1113,21 @@ func (e *encoder) node(node *Node, tail string) {
 		}
 	}
 
+	switch node.Kind {
+	case DocumentNode, SequenceNode, MappingNode:
+		v := encodeVisit{ptr: reflect.ValueOf(node).Pointer(), typ: nodeType}
+		if start, cycle := e.visit(v); cycle {
+			e.cycleError(start)
+		}
+		defer delete(e.visiting, v)
+	}
+
 	switch node.Kind {
 	case DocumentNode:
-		yaml_document_start_event_initialize(&e.event, nil, nil, true)
+		version, tags := e.directives(node.Version, node.TagDirectives)
+		yaml_document_start_event_initialize(&e.event, version, tags, true)
 		e.event.head_comment = []byte(node.HeadComment)
+		e.event.line_comment = []byte(node.LineComment)
 		e.emit()
 		for _, node := range node.Content {
 			e.node(node, "")
@@ -487,13 +1143,18 @@ func (e *encoder) node(node *Node, tail string) {
 		}
 		e.must(yaml_sequence_start_event_initialize(&e.event, []byte(node.Anchor), []byte(longTag(tag)), tag == "", style))
 		e.event.head_comment = []byte(node.HeadComment)
+		e.event.open_comment = []byte(node.OpenComment)
+		e.event.empty_lines = node.EmptyLinesBefore
 		e.emit()
-		for _, node := range node.Content {
+		for i, node := range node.Content {
+			e.pushIndex(i)
 			e.node(node, "")
+			e.popPath()
 		}
 		e.must(yaml_sequence_end_event_initialize(&e.event))
 		e.event.line_comment = []byte(node.LineComment)
 		e.event.foot_comment = []byte(node.FootComment)
+		e.event.close_comment = []byte(node.CloseComment)
 		e.emit()
 
 	case MappingNode:
@@ -504,6 +1165,8 @@ func (e *encoder) node(node *Node, tail string) {
 		yaml_mapping_start_event_initialize(&e.event, []byte(node.Anchor), []byte(longTag(tag)), tag == "", style)
 		e.event.tail_comment = []byte(tail)
 		e.event.head_comment = []byte(node.HeadComment)
+		e.event.open_comment = []byte(node.OpenComment)
+		e.event.empty_lines = node.EmptyLinesBefore
 		e.emit()
 
 		// The tail logic below moves the foot comment of prior keys to the following key,
@@ -523,13 +1186,16 @@ func (e *encoder) node(node *Node, tail string) {
 			tail = foot
 
 			v := node.Content[i+1]
+			e.pushPath(k.Value)
 			e.node(v, "")
+			e.popPath()
 		}
 
 		yaml_mapping_end_event_initialize(&e.event)
 		e.event.tail_comment = []byte(tail)
 		e.event.line_comment = []byte(node.LineComment)
 		e.event.foot_comment = []byte(node.FootComment)
+		e.event.close_comment = []byte(node.CloseComment)
 		e.emit()
 
 	case AliasNode:
@@ -537,6 +1203,7 @@ func (e *encoder) node(node *Node, tail string) {
 		e.event.head_comment = []byte(node.HeadComment)
 		e.event.line_comment = []byte(node.LineComment)
 		e.event.foot_comment = []byte(node.FootComment)
+		e.event.empty_lines = node.EmptyLinesBefore
 		e.emit()
 
 	case ScalarNode:
@@ -551,7 +1218,7 @@ func (e *encoder) node(node *Node, tail string) {
 			// It can't be encoded directly as YAML so use a binary tag
 			// and encode it as base64.
 			tag = binaryTag
-			value = encodeBase64(value)
+			value = encodeBase64Width(value, e.base64Width())
 		}
 
 		style := yaml_PLAIN_SCALAR_STYLE
@@ -564,14 +1231,366 @@ func (e *encoder) node(node *Node, tail string) {
 			style = yaml_LITERAL_SCALAR_STYLE
 		case node.Style&FoldedStyle != 0:
 			style = yaml_FOLDED_SCALAR_STYLE
-		case strings.Contains(value, "\n"):
+		case strings.Contains(value, "\n") && tag == binaryTag:
 			style = yaml_LITERAL_SCALAR_STYLE
+		case strings.Contains(value, "\n"):
+			style = e.multilineStyle(value)
 		case forceQuoting:
 			style = yaml_DOUBLE_QUOTED_SCALAR_STYLE
 		}
 
-		e.emitScalar(value, node.Anchor, tag, style, []byte(node.HeadComment), []byte(node.LineComment), []byte(node.FootComment), []byte(tail))
+		implicit := tag == ""
+		if !implicit {
+			tag = longTag(tag)
+		}
+		e.must(yaml_scalar_event_initialize(&e.event, []byte(node.Anchor), []byte(tag), []byte(value), implicit, implicit, style))
+		switch node.Chomping {
+		case StripChomping:
+			e.event.chomping = -1
+		case KeepChomping:
+			e.event.chomping = 1
+		}
+		if node.IndentIndicator >= 1 && node.IndentIndicator <= 9 {
+			e.event.increment = int8(node.IndentIndicator)
+		}
+		e.event.head_comment = []byte(node.HeadComment)
+		e.event.line_comment = []byte(node.LineComment)
+		e.event.foot_comment = []byte(node.FootComment)
+		e.event.tail_comment = []byte(tail)
+		e.event.empty_lines = node.EmptyLinesBefore
+		e.emit()
 	default:
 		failf("cannot encode node with unknown kind %d", node.Kind)
 	}
 }
+
+// lexemeValue returns the text to use for the plain numeric scalar node
+// according to its recorded Lexeme.
+func (e *encoder) lexemeValue(node *Node) (string, bool) {
+	if node.Style&(SingleQuotedStyle|DoubleQuotedStyle|LiteralStyle|FoldedStyle) != 0 {
+		return "", false
+	}
+	if e.preserveLexemes && sameNumber(node.Value, node.Lexeme) {
+		return node.Lexeme, true
+	}
+	if node.ShortTag() == intTag {
+		if ltag, _ := resolve("", node.Lexeme); ltag == intTag {
+			return formatIntLexeme(node.Value, node.Lexeme)
+		}
+	}
+	return "", false
+}
+
+// valueNode returns the node representing in, as encoded with the
+// options of e.
+func (e *encoder) valueNode(tag string, in reflect.Value) *Node {
+	sub := newEncoder()
+	defer sub.destroy()
+	sub.emitters = e.emitters
+	sub.timeLayout = e.timeLayout
+	sub.durations = e.durations
+	sub.anchorCycles = e.anchorCycles
+	sub.emitter.binary_width = e.emitter.binary_width
+	sub.multiline = e.multiline
+	sub.sets = e.sets
+	sub.marshalDoc(tag, in)
+	sub.finish()
+	p := newParser(sub.out)
+	p.textless = true
+	defer p.destroy()
+	return p.parse().Content[0]
+}
+
+// updateNode changes n to represent the same value as updated, keeping
+// the presentation details of n that still apply.
+func updateNode(n, updated *Node) {
+	if n.Kind != updated.Kind {
+		anchor, head, line, foot := n.Anchor, n.HeadComment, n.LineComment, n.FootComment
+		*n = *updated
+		n.Anchor, n.HeadComment, n.LineComment, n.FootComment = anchor, head, line, foot
+		return
+	}
+	n.Tag = updated.Tag
+	switch n.Kind {
+	case ScalarNode:
+		// Strings keep their quoting, literal or folded style, which the
+		// emitter changes if the new value requires it. Other scalars,
+		// and strings that had none, take the style of updated.
+		if n.Style&scalarStyles == 0 || updated.ShortTag() != strTag {
+			n.Style = updated.Style
+		}
+		n.Value = updated.Value
+	case MappingNode:
+		index := make(map[string]int)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if k := n.Content[i]; k.Kind == ScalarNode {
+				index[k.Value] = i
+			}
+		}
+		found := make(map[int]bool)
+		var added []*Node
+		for i := 0; i+1 < len(updated.Content); i += 2 {
+			k, v := updated.Content[i], updated.Content[i+1]
+			if j, ok := index[k.Value]; ok && k.Kind == ScalarNode {
+				updateNode(n.Content[j+1], v)
+				found[j] = true
+			} else {
+				added = append(added, k, v)
+			}
+		}
+		content := n.Content[:0]
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if found[i] {
+				content = append(content, n.Content[i], n.Content[i+1])
+			}
+		}
+		n.Content = append(content, added...)
+	case SequenceNode:
+		for i, item := range updated.Content {
+			if i < len(n.Content) {
+				updateNode(n.Content[i], item)
+			} else {
+				n.Content = append(n.Content, item)
+			}
+		}
+		if len(n.Content) > len(updated.Content) {
+			n.Content = n.Content[:len(updated.Content)]
+		}
+	}
+}
+
+// sharedMapping is a mapping that later mappings may merge.
+type sharedMapping struct {
+	node *Node
+	name string
+	// content holds the original keys and values of the mapping.
+	content []*Node
+	// values holds the canonical form of the values by that of the keys.
+	values map[string]string
+}
+
+// shareMappings rewrites the mappings in the tree that hold the entries
+// of an earlier mapping as a "<<" merge of that mapping, anchored for
+// the purpose, followed by the entries that differ from it. At least
+// two entries must be shared for a mapping to be rewritten.
+func shareMappings(root *Node) *Node {
+	s := &mappingSharer{
+		canon:   make(map[*Node]string),
+		anchors: make(map[string]bool),
+		parents: make(map[*Node]bool),
+	}
+	s.findAnchors(root)
+	s.walk(root, "")
+	return root
+}
+
+type mappingSharer struct {
+	canon   map[*Node]string
+	anchors map[string]bool
+	// parents holds the mappings enclosing the node being walked,
+	// which may not be merged into it.
+	parents map[*Node]bool
+	shared  []*sharedMapping
+}
+
+func (s *mappingSharer) findAnchors(n *Node) {
+	if n.Anchor != "" {
+		s.anchors[n.Anchor] = true
+	}
+	for _, ni := range n.Content {
+		s.findAnchors(ni)
+	}
+}
+
+func (s *mappingSharer) walk(n *Node, name string) {
+	switch n.Kind {
+	case SequenceNode, DocumentNode:
+		for _, ni := range n.Content {
+			s.walk(ni, name)
+		}
+		return
+	case MappingNode:
+	default:
+		return
+	}
+	m := &sharedMapping{
+		node:    n,
+		name:    name,
+		content: n.Content,
+		values:  make(map[string]string),
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if isMerge(n.Content[i]) {
+			// Leave mappings with merges of their own alone.
+			m.values = nil
+			break
+		}
+		m.values[s.canonical(n.Content[i])] = s.canonical(n.Content[i+1])
+	}
+	if m.values != nil {
+		s.merge(m)
+	}
+	s.parents[n] = true
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		s.walk(n.Content[i+1], n.Content[i].Value)
+	}
+	delete(s.parents, n)
+	if m.values != nil {
+		s.shared = append(s.shared, m)
+	}
+}
+
+// merge rewrites m as a merge of the earlier mapping sharing the most
+// entries with it, if any.
+func (s *mappingSharer) merge(m *sharedMapping) {
+	var base *sharedMapping
+	var most int
+	for _, b := range s.shared {
+		if s.parents[b.node] || len(b.values) > len(m.values) {
+			continue
+		}
+		shared := 0
+		for k, v := range b.values {
+			mv, ok := m.values[k]
+			if !ok {
+				shared = -1
+				break
+			}
+			if mv == v {
+				shared++
+			}
+		}
+		if shared >= 2 && shared > most {
+			base, most = b, shared
+		}
+	}
+	if base == nil {
+		return
+	}
+	if base.node.Anchor == "" {
+		base.node.Anchor = s.anchor(base.name)
+	}
+	content := []*Node{
+		{Kind: ScalarNode, Value: "<<"},
+		{Kind: AliasNode, Value: base.node.Anchor, Alias: base.node},
+	}
+	for i := 0; i+1 < len(m.content); i += 2 {
+		k := s.canonical(m.content[i])
+		if v, ok := base.values[k]; !ok || v != m.values[k] {
+			content = append(content, m.content[i], m.content[i+1])
+		}
+	}
+	m.node.Content = content
+}
+
+// aliasRepeats rewrites the mappings and sequences in the tree that are
+// equal to an earlier one, holding at least two nodes, as aliases of that
+// one, anchored for the purpose. Collections that hold anchors are left
+// alone, as aliases of these anchors may follow.
+func aliasRepeats(root *Node) *Node {
+	s := &mappingSharer{
+		canon:   make(map[*Node]string),
+		anchors: make(map[string]bool),
+	}
+	s.findAnchors(root)
+	type repeated struct {
+		node *Node
+		name string
+	}
+	seen := make(map[string]repeated)
+	var walk func(n *Node, name string)
+	walk = func(n *Node, name string) {
+		for i, ni := range n.Content {
+			if n.Kind == MappingNode {
+				if i%2 == 0 {
+					continue
+				}
+				name = n.Content[i-1].Value
+			}
+			if (ni.Kind == MappingNode || ni.Kind == SequenceNode) && countNodes(ni) > 2 && !hasAnchor(ni) {
+				c := s.canonical(ni)
+				if first, ok := seen[c]; ok {
+					if first.node.Anchor == "" {
+						first.node.Anchor = s.anchor(first.name)
+					}
+					n.Content[i] = &Node{Kind: AliasNode, Value: first.node.Anchor, Alias: first.node}
+					continue
+				}
+				seen[c] = repeated{ni, name}
+			}
+			walk(ni, name)
+		}
+	}
+	walk(root, "")
+	return root
+}
+
+// countNodes returns the number of nodes in the tree of n.
+func countNodes(n *Node) int {
+	count := 1
+	for _, ni := range n.Content {
+		count += countNodes(ni)
+	}
+	return count
+}
+
+// hasAnchor returns whether a node in the tree of n has an anchor.
+func hasAnchor(n *Node) bool {
+	if n.Anchor != "" {
+		return true
+	}
+	for _, ni := range n.Content {
+		if hasAnchor(ni) {
+			return true
+		}
+	}
+	return false
+}
+
+// anchor returns an unused anchor name, based on name when possible.
+func (s *mappingSharer) anchor(name string) string {
+	valid := name != ""
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			valid = false
+			break
+		}
+	}
+	if !valid {
+		name = "base"
+	}
+	anchor := name
+	for i := 2; s.anchors[anchor]; i++ {
+		anchor = name + strconv.Itoa(i)
+	}
+	s.anchors[anchor] = true
+	return anchor
+}
+
+// canonical returns a string that is the same for nodes with the same
+// content, whatever their style.
+func (s *mappingSharer) canonical(n *Node) string {
+	if c, ok := s.canon[n]; ok {
+		return c
+	}
+	var b strings.Builder
+	switch n.Kind {
+	case AliasNode:
+		b.WriteString("*")
+		b.WriteString(strconv.Quote(n.Value))
+	case ScalarNode:
+		b.WriteString(n.ShortTag())
+		b.WriteString(" ")
+		b.WriteString(strconv.Quote(n.Value))
+	default:
+		b.WriteString(n.ShortTag())
+		b.WriteString("[")
+		for _, ni := range n.Content {
+			b.WriteString(s.canonical(ni))
+			b.WriteString(",")
+		}
+		b.WriteString("]")
+	}
+	s.canon[n] = b.String()
+	return s.canon[n]
+}
@@ -17,8 +17,13 @@ package yaml_test
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"math"
+	"reflect"
 	"strconv"
 	"strings"
 	"time"
@@ -293,6 +298,41 @@ var marshalTests = []struct {
 		"t2: 2018-01-09T10:40:47Z\nt4: 2098-01-09T10:40:47Z\n",
 		"t2: 2018-01-09T10:40:47Z\nt4: 2098-01-09T10:40:47Z\n",
 	},
+	{
+		&struct {
+			A int                "a,omitzero"
+			B []int              "b,omitzero"
+			C []int              "c,omitzero"
+			D map[string]int     "d,omitzero"
+			E struct{ X, y int } "e,omitzero,flow"
+			F struct{ X, y int } "f,omitzero,flow"
+			G time.Time          "g,omitzero"
+			H zeroer             "h,omitzero"
+			I zeroer             "i,omitzero"
+		}{
+			B: []int{},
+			E: struct{ X, y int }{0, 1},
+			H: zeroer{1},
+			I: zeroer{2},
+		},
+		"b: []\ne: {x: 0}\ni: 2\n",
+		"b: []\ne: {x: 0}\ni: 2\n",
+	},
+	{
+		&struct {
+			A zeroer  "a,omitempty"
+			B zeroer  "b,omitempty"
+			C *zeroer "c,omitempty"
+			D *zeroer "d,omitempty"
+		}{
+			A: zeroer{1},
+			B: zeroer{0},
+			C: &zeroer{1},
+			D: &zeroer{2},
+		},
+		"b: 0\nd: 2\n",
+		"b: 0\nd: 2\n",
+	},
 	// Nil interface that implements Marshaler.
 	{
 		map[string]yaml.Marshaler{
@@ -394,6 +434,43 @@ var marshalTests = []struct {
 		"a: 1\nb: 2\nc: 3\n",
 	},
 
+	// Fall back to json tags.
+	{
+		&struct {
+			A int    `json:"x"`
+			B int    `json:"b,omitempty,string"`
+			C string `json:"-"`
+			E int    `yaml:"e" json:"y"`
+			F int    `yaml:",omitempty" json:"z"`
+		}{1, 0, "c", 3, 4},
+		"x: 1\ne: 3\nf: 4\n",
+		"x: 1\ne: 3\nf: 4\n",
+	},
+
+	// Interface inlining
+	{
+		&struct {
+			A int
+			C interface{} `yaml:",inline"`
+		}{1, map[string]int{"b": 2, "c": 3}},
+		"a: 1\nb: 2\nc: 3\n",
+		"a: 1\nb: 2\nc: 3\n",
+	}, {
+		&struct {
+			A int
+			C interface{} `yaml:",inline"`
+		}{1, &inlineB{2, inlineC{3}}},
+		"a: 1\nb: 2\nc: 3\n",
+		"a: 1\nb: 2\nc: 3\n",
+	}, {
+		&struct {
+			A int
+			C interface{} `yaml:",inline"`
+		}{1, nil},
+		"a: 1\n",
+		"a: 1\n",
+	},
+
 	// Duration
 	{
 		map[string]time.Duration{"a": 3 * time.Second},
@@ -431,6 +508,36 @@ var marshalTests = []struct {
 		"a: !!binary |\n    " + strings.Repeat("kJCQ", 17) + "kJ\n    CQ\n",
 	},
 
+	// Ordered mappings.
+	{
+		map[string]interface{}{"a": yaml.OrderedMap{{"b", 1}, {"a", []int{2}}}},
+		"a: !!omap\n    - b: 1\n    - a:\n        - 2\n",
+		"a: !!omap\n  - b: 1\n  - a:\n      - 2\n",
+	}, {
+		map[string]interface{}{"a": yaml.Pairs{{"b", 1}, {"b", 2}}},
+		"a: !!pairs\n    - b: 1\n    - b: 2\n",
+		"a: !!pairs\n  - b: 1\n  - b: 2\n",
+	}, {
+		&struct {
+			A yaml.OrderedMap `yaml:"a,flow"`
+		}{yaml.OrderedMap{{"b", 1}}},
+		"a: !!omap [{b: 1}]\n",
+		"a: !!omap [{b: 1}]\n",
+	},
+
+	// MapSlice.
+	{
+		yaml.MapSlice{{Key: "b", Value: 1}, {Key: "a", Value: yaml.MapSlice{{Key: "d", Value: 2}, {Key: "c", Value: nil}}}},
+		"b: 1\na:\n    d: 2\n    c: null\n",
+		"b: 1\na:\n    d: 2\n    c: null\n",
+	}, {
+		&struct {
+			A yaml.MapSlice `yaml:"a,flow"`
+		}{yaml.MapSlice{{Key: "b", Value: 1}, {Key: "a", Value: 2}}},
+		"a: {b: 1, a: 2}\n",
+		"a: {b: 1, a: 2}\n",
+	},
+
 	// Encode unicode as utf-8 rather than in escaped form.
 	{
 		map[string]string{"a": "你好"},
@@ -444,6 +551,12 @@ var marshalTests = []struct {
 		"a: 1.2.3.4\n",
 		"a: 1.2.3.4\n",
 	},
+	// Support encoding.TextMarshaler with a pointer receiver on addressable values.
+	{
+		&struct{ A textMarshaler }{textMarshaler{"1.2.3.4"}},
+		"a: text:1.2.3.4\n",
+		"a: text:1.2.3.4\n",
+	},
 	// time.Time gets a timestamp tag.
 	{
 		map[string]time.Time{"a": time.Date(2015, 2, 24, 18, 19, 39, 0, time.UTC)},
@@ -641,12 +754,548 @@ func (s *S) TestEncoderWriteError(c *C) {
 	c.Assert(err, ErrorMatches, `yaml: write error: some write error`) // Data not flushed yet
 }
 
+func (s *S) TestEncoderWriteErrorStops(c *C) {
+	w := &brokenWriter{}
+	enc := yaml.NewEncoder(w)
+	err := enc.Encode(map[string]string{"a": "b"})
+	c.Assert(err, ErrorMatches, `yaml: write error: broken pipe`)
+	c.Assert(errors.Is(err, errBrokenPipe), Equals, true)
+	c.Assert(w.writes, Equals, 1)
+
+	c.Assert(enc.Encode(map[string]string{"c": "d"}), Equals, err)
+	c.Assert(enc.Close(), Equals, err)
+	c.Assert(w.writes, Equals, 1)
+
+	var buf bytes.Buffer
+	enc.Reset(&buf)
+	c.Assert(enc.Encode(map[string]string{"c": "d"}), IsNil)
+	c.Assert(enc.Close(), IsNil)
+	c.Assert(buf.String(), Equals, "c: d\n")
+}
+
+type commentedServer struct {
+	Host string `yaml:"host" yamlcomment:"Address to bind."`
+	Port int    `yaml:"port,omitempty" yamlcomment:"Port to listen on.\nDefaults to 8080."`
+}
+
+type commentedConfig struct {
+	Name    string          `yamlcomment:"# Name of the service."`
+	Server  commentedServer `yaml:"server" yamlcomment:"Server settings."`
+	Tags    []string        `yaml:"tags" yamlcomment:"Extra tags."`
+	Verbose bool            `yaml:"verbose"`
+}
+
+func (s *S) TestMarshalFieldComments(c *C) {
+	data, err := yaml.Marshal(&commentedConfig{
+		Name:   "web",
+		Server: commentedServer{Host: "localhost", Port: 80},
+		Tags:   []string{"a"},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, `# Name of the service.
+name: web
+# Server settings.
+server:
+    # Address to bind.
+    host: localhost
+    # Port to listen on.
+    # Defaults to 8080.
+    port: 80
+# Extra tags.
+tags:
+    - a
+verbose: false
+`)
+
+	// Omitted fields don't leave their comment behind.
+	data, err = yaml.Marshal(commentedServer{Host: "localhost"})
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "# Address to bind.\nhost: localhost\n")
+}
+
+type styledFields struct {
+	Cert    string            `yaml:"cert,literal"`
+	Desc    *string           `yaml:"desc,folded"`
+	Version string            `yaml:"version,doublequoted"`
+	Name    interface{}       `yaml:"name,singlequoted"`
+	Args    []string          `yaml:"args,flow"`
+	Labels  map[string]string `yaml:"labels,flow"`
+	Note    string            `yaml:"note,literal"`
+}
+
+func (s *S) TestMarshalFieldStyles(c *C) {
+	desc := "a long description"
+	data, err := yaml.Marshal(&styledFields{
+		Cert:    "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----\n",
+		Desc:    &desc,
+		Version: "1.2",
+		Name:    "web",
+		Args:    []string{"a", "b"},
+		Labels:  map[string]string{"app": "web"},
+		Note:    "trailing space ",
+	})
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, `cert: |
+    -----BEGIN CERTIFICATE-----
+    MIIB
+    -----END CERTIFICATE-----
+desc: >-
+    a long description
+version: "1.2"
+name: 'web'
+args: [a, b]
+labels: {app: web}
+note: "trailing space "
+`)
+
+	type conflict struct {
+		A string `yaml:"a,literal,folded"`
+	}
+	c.Assert(func() { yaml.Marshal(&conflict{}) }, PanicMatches, `conflicting style flags in tag "a,literal,folded" of type yaml_test.conflict`)
+}
+
+type anchoredResources struct {
+	CPU    string `yaml:"cpu"`
+	Memory string `yaml:"memory"`
+}
+
+type anchoredContainer struct {
+	Defaults anchoredResources  `yaml:"defaults,anchor=res"`
+	Requests anchoredResources  `yaml:"requests,alias=res"`
+	Limits   *anchoredResources `yaml:"limits,alias=res"`
+	Image    string             `yaml:"image,anchor=img"`
+	Sidecar  string             `yaml:"sidecar,alias=img"`
+}
+
+func (s *S) TestMarshalFieldAnchors(c *C) {
+	res := anchoredResources{CPU: "1", Memory: "1Gi"}
+	in := &anchoredContainer{
+		Defaults: res,
+		Requests: res,
+		Limits:   &anchoredResources{CPU: "2", Memory: "1Gi"},
+		Image:    "nginx",
+		Sidecar:  "nginx",
+	}
+	data, err := yaml.Marshal(in)
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, `defaults: &res
+    cpu: "1"
+    memory: 1Gi
+requests: *res
+limits:
+    cpu: "2"
+    memory: 1Gi
+image: &img nginx
+sidecar: *img
+`)
+
+	var out anchoredContainer
+	c.Assert(yaml.Unmarshal(data, &out), IsNil)
+	c.Assert(&out, DeepEquals, in)
+
+	// Anchors are only referred to in the document that sets them.
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	c.Assert(enc.Encode(struct {
+		A string `yaml:"a,anchor=x"`
+	}{"v"}), IsNil)
+	c.Assert(enc.Encode(struct {
+		B string `yaml:"b,alias=x"`
+	}{"v"}), IsNil)
+	c.Assert(enc.Close(), IsNil)
+	c.Assert(buf.String(), Equals, "a: &x v\n---\nb: v\n")
+
+	// Values encoded as nodes hold the anchor too.
+	data, err = yaml.Marshal(struct {
+		A jsonMarshaler `yaml:"a,anchor=base"`
+		B jsonMarshaler `yaml:"b,alias=base"`
+	}{jsonMarshaler{`{"x": 1}`}, jsonMarshaler{`{"x": 1}`}})
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "a: &base\n    x: 1\nb: *base\n")
+	var v map[string]map[string]int
+	c.Assert(yaml.Unmarshal(data, &v), IsNil)
+	c.Assert(v, DeepEquals, map[string]map[string]int{"a": {"x": 1}, "b": {"x": 1}})
+
+	node := yaml.Node{Kind: yaml.SequenceNode, Content: []*yaml.Node{{Kind: yaml.ScalarNode, Value: "1"}}}
+	data, err = yaml.Marshal(struct {
+		A yaml.Node `yaml:"a,anchor=list"`
+		B yaml.Node `yaml:"b,alias=list"`
+	}{node, node})
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "a: &list\n    - 1\nb: *list\n")
+	c.Assert(node.Anchor, Equals, "")
+
+	// Nodes with an anchor of their own keep it, and aren't aliased.
+	named := yaml.Node{Kind: yaml.ScalarNode, Value: "v", Anchor: "own"}
+	data, err = yaml.Marshal(struct {
+		A yaml.Node `yaml:"a,anchor=x"`
+		B yaml.Node `yaml:"b,alias=x"`
+	}{named, yaml.Node{Kind: yaml.ScalarNode, Value: "v", Anchor: "own"}})
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "a: &own v\nb: &own v\n")
+}
+
+func (s *S) TestEncoderTimeLayout(c *C) {
+	t := time.Date(2001, 12, 14, 21, 59, 43, .1e9, time.FixedZone("", -5*60*60))
+	for _, layout := range []string{"", time.RFC3339, "2006-01-02 15:04:05.999 -07:00", "2006-01-02"} {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetTimeLayout(layout)
+		c.Assert(enc.Encode(map[string]time.Time{"a": t}), IsNil)
+		c.Assert(enc.Close(), IsNil)
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		c.Assert(buf.String(), Equals, "a: "+t.Format(layout)+"\n")
+
+		var v map[string]time.Time
+		c.Assert(yaml.Unmarshal(buf.Bytes(), &v), IsNil)
+		want, err := time.Parse(layout, t.Format(layout))
+		c.Assert(err, IsNil)
+		c.Assert(v["a"].Equal(want), Equals, true, Commentf("layout: %s", layout))
+	}
+}
+
+func (s *S) TestEncoderShareMappings(c *C) {
+	type Res struct {
+		CPU    string `yaml:"cpu"`
+		Memory string `yaml:"memory"`
+		GPU    int    `yaml:"gpu,omitempty"`
+	}
+	type Container struct {
+		Name      string `yaml:"name"`
+		Image     string `yaml:"image"`
+		Pull      string `yaml:"pull"`
+		Resources Res    `yaml:"resources"`
+	}
+	type Spec struct {
+		Containers []Container `yaml:"containers"`
+	}
+	v := Spec{[]Container{
+		{"a", "img:1", "Always", Res{"1", "1Gi", 0}},
+		{"b", "img:1", "Always", Res{"1", "1Gi", 1}},
+		{"c", "img:2", "Never", Res{"1", "1Gi", 0}},
+	}}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	enc.ShareMappings(true)
+	c.Assert(enc.Encode(v), IsNil)
+	c.Assert(enc.Close(), IsNil)
+	c.Assert(buf.String(), Equals, `containers:
+  - &containers
+    name: a
+    image: img:1
+    pull: Always
+    resources: &resources
+      cpu: "1"
+      memory: 1Gi
+  - <<: *containers
+    name: b
+    resources:
+      <<: *resources
+      gpu: 1
+  - name: c
+    image: img:2
+    pull: Never
+    resources:
+      <<: *resources
+`)
+	var out Spec
+	c.Assert(yaml.Unmarshal(buf.Bytes(), &out), IsNil)
+	c.Assert(out, DeepEquals, v)
+}
+
+func (s *S) TestEncoderSets(c *C) {
+	v := map[string]map[string]struct{}{"a": {"z": {}, "x": {}}, "b": {}}
+
+	// Without EncodeSets, sets are written as plain mappings.
+	out, err := yaml.Marshal(v)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "a:\n    x: {}\n    z: {}\nb: {}\n")
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.EncodeSets(true)
+	c.Assert(enc.Encode(v), IsNil)
+	c.Assert(enc.Close(), IsNil)
+	c.Assert(buf.String(), Equals, "a: !!set\n    x:\n    z:\nb: !!set {}\n")
+
+	var got map[string]map[string]struct{}
+	c.Assert(yaml.Unmarshal(buf.Bytes(), &got), IsNil)
+	c.Assert(got, DeepEquals, v)
+}
+
+func (s *S) TestEncoderAliasRepeats(c *C) {
+	type Env struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	}
+	type Container struct {
+		Name  string   `yaml:"name"`
+		Env   []Env    `yaml:"env"`
+		Args  []string `yaml:"args"`
+		Ports []int    `yaml:"ports"`
+	}
+	env := []Env{{"A", "1"}, {"B", "2"}}
+	v := map[string][]Container{"containers": {
+		{"a", env, []string{"run"}, []int{80, 443}},
+		{"b", env, []string{"run"}, []int{80, 443}},
+		{"c", []Env{{"A", "1"}}, []string{}, []int{}},
+	}}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	enc.AliasRepeats(true)
+	c.Assert(enc.Encode(v), IsNil)
+	c.Assert(enc.Close(), IsNil)
+	c.Assert(buf.String(), Equals, `containers:
+  - name: a
+    env: &env
+      - &env2
+        name: A
+        value: "1"
+      - name: B
+        value: "2"
+    args:
+      - run
+    ports: &ports
+      - 80
+      - 443
+  - name: b
+    env: *env
+    args:
+      - run
+    ports: *ports
+  - name: c
+    env:
+      - *env2
+    args: []
+    ports: []
+`)
+	var out map[string][]Container
+	c.Assert(yaml.Unmarshal(buf.Bytes(), &out), IsNil)
+	c.Assert(out, DeepEquals, v)
+}
+
+// chunkReader returns its data a few bytes at a time, failing with err at
+// the end if set.
+type chunkReader struct {
+	data []byte
+	err  error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		return 0, io.EOF
+	}
+	if len(p) > 7 {
+		p = p[:7]
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func (s *S) TestMarshalReader(c *C) {
+	data := bytes.Repeat([]byte("binary\x00\xff"), 20)
+	type file struct {
+		Name string        `yaml:"name"`
+		Data io.Reader     `yaml:"data"`
+		More io.ReadCloser `yaml:"more,flow"`
+	}
+	v := struct {
+		Files []file               `yaml:"files"`
+		Small map[string]io.Reader `yaml:"small,flow"`
+	}{
+		Files: []file{{Name: "a", Data: &chunkReader{data: data}}},
+		Small: map[string]io.Reader{"x": strings.NewReader("hi"), "y": strings.NewReader("")},
+	}
+	out, err := yaml.Marshal(&v)
+	c.Assert(err, IsNil)
+	encoded := base64.StdEncoding.EncodeToString(data)
+	c.Assert(string(out), Equals, "files:\n"+
+		"    - name: a\n"+
+		"      data: !!binary |\n"+
+		"        "+encoded[:70]+"\n"+
+		"        "+encoded[70:140]+"\n"+
+		"        "+encoded[140:210]+"\n"+
+		"        "+encoded[210:]+"\n"+
+		"      more: null\n"+
+		"small: {x: !!binary \"aGk=\", \"y\": !!binary \"\"}\n")
+
+	var back struct {
+		Files []struct {
+			Data string `yaml:"data"`
+		} `yaml:"files"`
+		Small map[string]string `yaml:"small"`
+	}
+	c.Assert(yaml.Unmarshal(out, &back), IsNil)
+	c.Assert(back.Files[0].Data, Equals, string(data))
+	c.Assert(back.Small["x"], Equals, "hi")
+
+	readErr := errors.New("disk failure")
+	_, err = yaml.Marshal(map[string]io.Reader{"a": &chunkReader{data: data, err: readErr}})
+	c.Assert(err, ErrorMatches, "yaml: cannot read binary value: disk failure")
+	c.Assert(errors.Is(err, readErr), Equals, true)
+
+	// The data is written as it's read.
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	c.Assert(enc.Encode(map[string]io.Reader{"a": strings.NewReader(strings.Repeat("x", 1<<20))}), IsNil)
+	c.Assert(enc.Close(), IsNil)
+	c.Assert(buf.Len() > 1<<20, Equals, true)
+}
+
+func (s *S) TestEncoderSetBinaryWidth(c *C) {
+	data := strings.Repeat("\xff", 60)
+	encoded := base64.StdEncoding.EncodeToString([]byte(data))
+	for _, test := range []struct {
+		width    int
+		expected string
+	}{
+		{76, "a: !!binary |\n    " + encoded[:76] + "\n    " + encoded[76:] + "\n"},
+		{0, "a: !!binary " + encoded + "\n"},
+		{-1, "a: !!binary " + encoded + "\n"},
+	} {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetBinaryWidth(test.width)
+		c.Assert(enc.Encode(map[string]string{"a": data}), IsNil)
+		c.Assert(enc.Close(), IsNil)
+		c.Assert(buf.String(), Equals, test.expected, Commentf("width %d", test.width))
+
+		buf.Reset()
+		enc = yaml.NewEncoder(&buf)
+		enc.SetBinaryWidth(test.width)
+		c.Assert(enc.Encode(map[string]io.Reader{"a": strings.NewReader(data)}), IsNil)
+		c.Assert(enc.Close(), IsNil)
+		expected := strings.Replace(test.expected, "!!binary "+encoded, "!!binary |\n    "+encoded, 1)
+		c.Assert(buf.String(), Equals, expected, Commentf("reader, width %d", test.width))
+	}
+}
+
+func (s *S) TestEncoderSetMultilineRules(c *C) {
+	long := strings.Repeat("word ", 10) + "end"
+	value := yaml.MapSlice{
+		{Key: "a", Value: "one\ntwo\n"},
+		{Key: "b", Value: "one\ntwo"},
+		{Key: "c", Value: "one\ntwo\nthree\n"},
+		{Key: "d", Value: long + "\nshort\n"},
+	}
+	for _, test := range []struct {
+		rules    yaml.MultilineRules
+		expected string
+	}{
+		{yaml.MultilineRules{}, "a: |\n    one\n    two\nb: |-\n    one\n    two\nc: |\n    one\n    two\n    three\nd: |\n    " + long + "\n    short\n"},
+		{yaml.MultilineRules{MinLines: 3}, "a: \"one\\ntwo\\n\"\nb: \"one\\ntwo\"\nc: |\n    one\n    two\n    three\nd: \"" + long + "\\nshort\\n\"\n"},
+		{yaml.MultilineRules{QuoteUnterminated: true}, "a: |\n    one\n    two\nb: \"one\\ntwo\"\nc: |\n    one\n    two\n    three\nd: |\n    " + long + "\n    short\n"},
+		{yaml.MultilineRules{FoldLength: 40}, "a: |\n    one\n    two\nb: |-\n    one\n    two\nc: |\n    one\n    two\n    three\nd: >\n    " + long + "\n\n    short\n\n"},
+	} {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetMultilineRules(test.rules)
+		c.Assert(enc.Encode(value), IsNil)
+		c.Assert(enc.Close(), IsNil)
+		c.Assert(buf.String(), Equals, test.expected, Commentf("rules %+v", test.rules))
+
+		var decoded yaml.MapSlice
+		c.Assert(yaml.Unmarshal(buf.Bytes(), &decoded), IsNil)
+		c.Assert(decoded, DeepEquals, value)
+	}
+}
+
+func (s *S) TestEncoderDurationFormat(c *C) {
+	tests := []struct {
+		format yaml.DurationFormat
+		data   string
+	}{
+		{yaml.DurationString, "a: 1h30m0s\nb: 1.5s\n"},
+		{yaml.DurationSeconds, "a: 5400\nb: 1.5\n"},
+		{yaml.DurationNanoseconds, "a: 5400000000000\nb: 1500000000\n"},
+	}
+	type T struct {
+		A, B time.Duration
+	}
+	value := T{90 * time.Minute, 1500 * time.Millisecond}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetDurationFormat(test.format)
+		c.Assert(enc.Encode(value), IsNil)
+		c.Assert(enc.Close(), IsNil)
+		c.Assert(buf.String(), Equals, test.data)
+
+		var v T
+		dec := yaml.NewDecoder(&buf)
+		dec.SetDurationFormat(test.format)
+		c.Assert(dec.Decode(&v), IsNil)
+		c.Assert(v, Equals, value)
+	}
+
+	// Numbers are only accepted as durations when a unit is set.
+	var v struct{ A time.Duration }
+	err := yaml.Unmarshal([]byte("a: 5400"), &v)
+	c.Assert(err, ErrorMatches, "(?s).*cannot unmarshal !!int `5400` into time.Duration")
+	dec := yaml.NewDecoder(strings.NewReader("a: 0.5"))
+	dec.SetDurationFormat(yaml.DurationNanoseconds)
+	c.Assert(dec.Decode(&v), ErrorMatches, "(?s).*cannot unmarshal !!float `0.5` into time.Duration")
+}
+
+type cfnRef struct {
+	Name string
+}
+
+func (s *S) TestEncoderTagEmitter(c *C) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.RegisterTagEmitter(reflect.TypeOf(cfnRef{}), func(v interface{}) (*yaml.Node, error) {
+		ref := v.(cfnRef)
+		if ref.Name == "" {
+			return nil, fmt.Errorf("empty reference")
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!Ref", Value: ref.Name}, nil
+	})
+	err := enc.Encode(map[string]interface{}{
+		"a": cfnRef{"foo"},
+		"b": &cfnRef{"bar"},
+		"c": []cfnRef{{"baz"}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(enc.Close(), IsNil)
+	c.Assert(buf.String(), Equals, "a: !Ref foo\nb: !Ref bar\nc:\n    - !Ref baz\n")
+
+	err = enc.Encode(cfnRef{})
+	c.Assert(err, ErrorMatches, "empty reference")
+
+	buf.Reset()
+	enc = yaml.NewEncoder(&buf)
+	enc.RegisterTagEmitter(reflect.TypeOf(cfnRef{}), nil)
+	c.Assert(enc.Encode(cfnRef{"foo"}), IsNil)
+	c.Assert(buf.String(), Equals, "name: foo\n")
+}
+
 type errorWriter struct{}
 
 func (errorWriter) Write([]byte) (int, error) {
 	return 0, fmt.Errorf("some write error")
 }
 
+var errBrokenPipe = errors.New("broken pipe")
+
+// brokenWriter fails with errBrokenPipe, counting the writes.
+type brokenWriter struct {
+	writes int
+}
+
+func (w *brokenWriter) Write([]byte) (int, error) {
+	w.writes++
+	return 0, errBrokenPipe
+}
+
 var marshalErrorTests = []struct {
 	value interface{}
 	error string
@@ -657,12 +1306,35 @@ var marshalErrorTests = []struct {
 		inlineB ",inline"
 	}{1, inlineB{2, inlineC{3}}},
 	panic: `duplicated key 'b' in struct struct \{ B int; .*`,
+}, {
+	value: &struct {
+		B     int
+		Inner inlineB `yaml:",squash"`
+	}{1, inlineB{2, inlineC{3}}},
+	panic: `duplicated key 'b' in struct .*: field Inner.B conflicts with field B`,
+}, {
+	value: &struct {
+		A map[string]int `yaml:",squash"`
+	}{},
+	panic: `option ,squash may only be used on a struct field in struct .*`,
 }, {
 	value: &struct {
 		A int
 		B map[string]int ",inline"
 	}{1, map[string]int{"a": 2}},
 	panic: `cannot have key "a" in inlined map: conflicts with struct field`,
+}, {
+	value: &struct {
+		B int
+		C interface{} ",inline"
+	}{1, inlineB{2, inlineC{3}}},
+	panic: `cannot have key "b" in inlined interface: conflicts with struct field`,
+}, {
+	value: &struct {
+		B int
+		C interface{} ",inline"
+	}{1, 2},
+	panic: `option ,inline needs a struct or map value in interface field of type .*`,
 }}
 
 func (s *S) TestMarshalErrors(c *C) {
@@ -703,6 +1375,58 @@ var marshalerTests = []struct {
 	{"_: BAR!\n", "BAR!"},
 }
 
+type textMarshaler struct {
+	S string
+}
+
+func (t *textMarshaler) MarshalText() ([]byte, error) {
+	return []byte("text:" + t.S), nil
+}
+
+// zeroer is zero when holding 1, through a pointer receiver.
+type zeroer struct {
+	N int
+}
+
+func (z *zeroer) IsZero() bool {
+	return z.N == 1
+}
+
+func (z zeroer) MarshalYAML() (interface{}, error) {
+	return z.N, nil
+}
+
+type jsonMarshaler struct {
+	data string
+}
+
+func (m jsonMarshaler) MarshalJSON() ([]byte, error) {
+	if m.data == "" {
+		return nil, fmt.Errorf("no JSON data")
+	}
+	return []byte(m.data), nil
+}
+
+func (s *S) TestJSONMarshaler(c *C) {
+	tests := []struct {
+		value interface{}
+		data  string
+	}{
+		{jsonMarshaler{`{"kind":"x","n":1.50,"s":"123","l":[true,null,"a b"]}`}, "kind: x\nn: 1.50\ns: \"123\"\nl:\n    - true\n    - null\n    - a b\n"},
+		{map[string]interface{}{"a": jsonMarshaler{`"b"`}}, "a: b\n"},
+		{map[string]interface{}{"a": jsonMarshaler{`null`}}, "a: null\n"},
+		{[]jsonMarshaler{{`{}`}, {`[]`}}, "- {}\n- []\n"},
+	}
+	for _, test := range tests {
+		data, err := yaml.Marshal(test.value)
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, test.data)
+	}
+
+	_, err := yaml.Marshal(jsonMarshaler{})
+	c.Assert(err, ErrorMatches, "no JSON data")
+}
+
 type marshalerType struct {
 	value interface{}
 }
@@ -759,6 +1483,216 @@ func (s *S) TestSetIndent(c *C) {
 	c.Assert(buf.String(), Equals, "a:\n        b:\n                c: d\n")
 }
 
+func (s *S) TestSetIndentWide(c *C) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(12)
+	err := enc.Encode(map[string]interface{}{"a": map[string]string{"b": " c\n"}})
+	c.Assert(err, Equals, nil)
+	err = enc.Close()
+	c.Assert(err, Equals, nil)
+	c.Assert(buf.String(), Equals, "a:\n            b: \" c\\n\"\n")
+}
+
+func (s *S) TestSetMappingAndSequenceIndent(c *C) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(4)
+	enc.SetSequenceIndent(2)
+	err := enc.Encode(map[string]interface{}{"a": []interface{}{map[string]interface{}{"b": map[string]string{"c": "d"}, "e": []string{"f"}}}})
+	c.Assert(err, Equals, nil)
+	err = enc.Close()
+	c.Assert(err, Equals, nil)
+	c.Assert(buf.String(), Equals, `a:
+  - b:
+        c: d
+    e:
+      - f
+`)
+
+	buf.Reset()
+	enc = yaml.NewEncoder(&buf)
+	enc.SetMappingIndent(3)
+	err = enc.Encode(map[string]interface{}{"a": map[string]interface{}{"b": []string{"c"}}})
+	c.Assert(err, Equals, nil)
+	err = enc.Close()
+	c.Assert(err, Equals, nil)
+	c.Assert(buf.String(), Equals, `a:
+   b:
+       - c
+`)
+}
+
+func (s *S) TestSetTagHandle(c *C) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetTagHandle("!k8s!", "tag:kubernetes.io,2024:")
+	err := enc.Encode(&yaml.Node{
+		Kind: yaml.MappingNode,
+		Content: []*yaml.Node{
+			{Kind: yaml.ScalarNode, Value: "a"},
+			{Kind: yaml.ScalarNode, Tag: "tag:kubernetes.io,2024:Pod", Value: "b"},
+			{Kind: yaml.ScalarNode, Value: "c"},
+			{Kind: yaml.ScalarNode, Tag: "!k8s!Service", Value: "d"},
+		},
+	})
+	c.Assert(err, IsNil)
+	err = enc.Encode(map[string]int{"e": 1})
+	c.Assert(err, IsNil)
+	err = enc.Close()
+	c.Assert(err, IsNil)
+	c.Assert(buf.String(), Equals, "%TAG !k8s! tag:kubernetes.io,2024:\n---\na: !k8s!Pod b\nc: !k8s!Service d\n...\n%TAG !k8s! tag:kubernetes.io,2024:\n---\ne: 1\n")
+
+	var n yaml.Node
+	err = yaml.Unmarshal(buf.Bytes(), &n)
+	c.Assert(err, IsNil)
+	c.Assert(n.Content[0].Content[1].Tag, Equals, "tag:kubernetes.io,2024:Pod")
+}
+
+func (s *S) TestEncoderSetSchema(c *C) {
+	tests := []struct {
+		schema yaml.Schema
+		want   string
+	}{
+		{yaml.DefaultSchema, "- \"1_000\"\n- \"0777\"\n- \"true\"\n- \"2001-12-14\"\n- foo\n"},
+		{yaml.FailsafeSchema, "- 1_000\n- 0777\n- true\n- 2001-12-14\n- foo\n"},
+		{yaml.JSONSchema, "- \"1_000\"\n- \"0777\"\n- \"true\"\n- \"2001-12-14\"\n- \"foo\"\n"},
+		{yaml.CoreSchema, "- 1_000\n- \"0777\"\n- \"true\"\n- 2001-12-14\n- foo\n"},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetSchema(test.schema)
+		err := enc.Encode([]string{"1_000", "0777", "true", "2001-12-14", "foo"})
+		c.Assert(err, IsNil)
+		err = enc.Close()
+		c.Assert(err, IsNil)
+		c.Assert(buf.String(), Equals, test.want)
+	}
+}
+
+func (s *S) TestEncoderEncodeContext(c *C) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	c.Assert(enc.EncodeContext(context.Background(), []int{1, 2}), IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Assert(enc.EncodeContext(ctx, []int{3, 4}), Equals, context.Canceled)
+}
+
+func (s *S) TestEncoderReset(c *C) {
+	var buf1, buf2 bytes.Buffer
+	enc := yaml.NewEncoder(&buf1)
+	enc.SetIndent(2)
+	c.Assert(enc.Encode(map[string][]int{"a": {1}}), IsNil)
+	c.Assert(enc.Encode(map[string][]int{"b": {2}}), IsNil)
+
+	enc.Reset(&buf2)
+	c.Assert(enc.Encode(map[string][]int{"c": {3}}), IsNil)
+	c.Assert(enc.Close(), IsNil)
+	c.Assert(buf1.String(), Equals, "a:\n  - 1\n---\nb:\n  - 2\n")
+	c.Assert(buf2.String(), Equals, "c:\n  - 3\n")
+}
+
+type cycleNode struct {
+	Name string
+	Next *cycleNode               `yaml:",omitempty"`
+	Refs map[string][]interface{} `yaml:",omitempty"`
+}
+
+func (s *S) TestEncodeCycle(c *C) {
+	a := &cycleNode{Name: "a"}
+	a.Next = &cycleNode{Name: "b", Next: a}
+	_, err := yaml.Marshal(a)
+	c.Assert(err, ErrorMatches, "yaml: encountered a cycle: the value at next.next refers back to the root value")
+
+	m := map[string]interface{}{"x": 1}
+	l := []interface{}{"y", m}
+	m["list"] = l
+	_, err = yaml.Marshal(map[string]interface{}{"m": m})
+	c.Assert(err, ErrorMatches, "yaml: encountered a cycle: the value at m.list\\[1\\] refers back to m")
+
+	n := &yaml.Node{Kind: yaml.SequenceNode}
+	n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "z"}, n)
+	_, err = yaml.Marshal(n)
+	c.Assert(err, ErrorMatches, "yaml: encountered a cycle: the value at \\[1\\] refers back to the root value")
+
+	// Shared values that don't make a cycle are written as usual.
+	b := &cycleNode{Name: "b"}
+	data, err := yaml.Marshal([]*cycleNode{b, b})
+	c.Assert(err, IsNil)
+	c.Assert(string(data), Equals, "- name: b\n- name: b\n")
+}
+
+func (s *S) TestEncoderAnchorCycles(c *C) {
+	a := &cycleNode{Name: "a"}
+	a.Next = &cycleNode{Name: "b", Next: a}
+	a.Refs = map[string][]interface{}{"self": {a.Next}}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.AnchorCycles(true)
+	c.Assert(enc.Encode(a), IsNil)
+	c.Assert(enc.Encode(a.Next), IsNil)
+	c.Assert(enc.Close(), IsNil)
+	c.Assert(buf.String(), Equals, `&cycle
+name: a
+next:
+    name: b
+    next: *cycle
+refs:
+    self:
+        - name: b
+          next: *cycle
+---
+&cycle
+name: b
+next:
+    name: a
+    next: *cycle
+    refs:
+        self:
+            - *cycle
+`)
+
+	// The output is valid YAML, but the decoder rejects recursive anchors.
+	var v cycleNode
+	c.Assert(yaml.Unmarshal([]byte(strings.SplitN(buf.String(), "---", 2)[0]), &v), ErrorMatches, ".*anchor 'cycle' value contains itself")
+}
+
+func (s *S) TestEncoderPreserveTemplates(c *C) {
+	data := `name: {{ .Release.Name }}-web
+command: {{ .Values.command | toJson }}
+args: [{{ .Values.arg }}, "{{ .Values.other }}"]
+note: '{{ .Values.note }}: trailing'
+text: {{ .Values.first }} {{ .Values.second }} {{ .Values.third }} {{ .Values.fourth }}
+`
+	var doc yaml.Node
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.PreserveTemplates(true)
+	c.Assert(dec.Decode(&doc), IsNil)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	enc.SetWidth(40)
+	enc.PreserveTemplates(true)
+	c.Assert(enc.Encode(&doc), IsNil)
+	c.Assert(enc.Encode(map[string]string{"a": "{{ .a }}", "b": "{{ .b }}: x"}), IsNil)
+	c.Assert(enc.Close(), IsNil)
+	c.Assert(buf.String(), Equals, data+`---
+a: {{ .a }}
+b: '{{ .b }}: x'
+`)
+
+	// Without the setting, actions are quoted and folded as any text.
+	buf.Reset()
+	enc = yaml.NewEncoder(&buf)
+	enc.SetWidth(40)
+	c.Assert(enc.Encode(map[string]string{"a": "{{ .a }} {{ .b }} {{ .c }} {{ .d }} {{ .e }}"}), IsNil)
+	c.Assert(buf.String(), Equals, "a: '{{ .a }} {{ .b }} {{ .c }} {{ .d }} {{\n    .e }}'\n")
+}
+
 func (s *S) TestCompactSeqIndentDefault(c *C) {
 	var buf bytes.Buffer
 	enc := yaml.NewEncoder(&buf)
@@ -790,6 +1724,21 @@ func (s *S) TestCompactSequenceWithSetIndent(c *C) {
 `)
 }
 
+func (s *S) TestCompactSequenceIndentIndicator(c *C) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.CompactSeqIndent()
+	value := map[string][]string{"a": {"  b\nc\n"}}
+	c.Assert(enc.Encode(value), IsNil)
+	c.Assert(enc.Close(), IsNil)
+	// The indicator is relative to the sequence, not to the mapping.
+	c.Assert(buf.String(), Equals, "a:\n  - |2\n      b\n    c\n")
+
+	var decoded map[string][]string
+	c.Assert(yaml.Unmarshal(buf.Bytes(), &decoded), IsNil)
+	c.Assert(decoded, DeepEquals, value)
+}
+
 func (s *S) TestNewLinePreserved(c *C) {
 	obj := &marshalerValue{}
 	obj.Field.value = "a:\n        b:\n                c: d\n"
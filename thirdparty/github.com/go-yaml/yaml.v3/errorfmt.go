@@ -0,0 +1,163 @@
+//
+// Copyright (c) 2011-2019 Canonical Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FormatError renders err for people to read, showing for each problem
+// reported by a SyntaxError, TypeError, UnmarshalError, UnknownFieldError
+// or MaxDepthError the line of source it was found in, with a caret under
+// its column, and the path of the value in question, if known. Source is
+// the YAML text that was decoded, and may be nil if it's not available,
+// in which case only the lines kept by a SyntaxError are shown. Other
+// errors are rendered as err.Error() does. For example:
+//
+//	yaml: line 3, column 13: cannot unmarshal !!str `many` into int
+//	 --> spec.replicas
+//	  |
+//	3 |   replicas: many
+//	  |             ^
+//
+// Problems are separated by empty lines, and the result doesn't end with
+// a line break.
+func FormatError(err error, source []byte) string {
+	if err == nil {
+		return ""
+	}
+	var problems []errorProblem
+	var (
+		syntaxErr  *SyntaxError
+		typeErr    *TypeError
+		unmarshErr *UnmarshalError
+		fieldErr   *UnknownFieldError
+		depthErr   *MaxDepthError
+	)
+	switch {
+	case errors.As(err, &syntaxErr):
+		problems = append(problems, errorProblem{
+			message: syntaxErr.Message,
+			line:    syntaxErr.Line,
+			column:  syntaxErr.Column,
+			snippet: syntaxErr.Snippet,
+			caret:   syntaxErr.Caret,
+		})
+	case errors.As(err, &typeErr):
+		for _, e := range typeErr.details() {
+			problems = append(problems, unmarshalProblem(e))
+		}
+	case errors.As(err, &unmarshErr):
+		problems = append(problems, unmarshalProblem(unmarshErr))
+	case errors.As(err, &fieldErr):
+		for _, f := range fieldErr.Fields {
+			problems = append(problems, errorProblem{
+				message: "unknown field " + strconv.Quote(f.Name) + f.didYouMean(),
+				path:    f.Path,
+				line:    f.Line,
+				column:  f.Column,
+			})
+		}
+	case errors.As(err, &depthErr):
+		problems = append(problems, errorProblem{
+			message: fmt.Sprintf("exceeded max depth of %d", depthErr.Max),
+			line:    depthErr.Line,
+			column:  depthErr.Column,
+		})
+	}
+	if len(problems) == 0 {
+		return err.Error()
+	}
+	var lines [][]byte
+	if source != nil {
+		lines = bytes.Split(source, []byte("\n"))
+	}
+	var b strings.Builder
+	for i, p := range problems {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		p.write(&b, lines)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// errorProblem is a problem reported by an error, as shown by FormatError.
+type errorProblem struct {
+	message      string
+	path         string
+	line, column int
+
+	// snippet and caret hold the line of the problem and its column, as
+	// in SyntaxError, if source isn't needed to show them.
+	snippet string
+	caret   int
+}
+
+func unmarshalProblem(e *UnmarshalError) errorProblem {
+	p := errorProblem{message: e.Message, path: e.Path, line: e.Line, column: e.Column}
+	prefix := "line " + strconv.Itoa(e.Line) + ": "
+	if strings.HasPrefix(p.message, prefix) {
+		p.message = p.message[len(prefix):]
+	} else if e.Line > 0 {
+		// The position is that of another text, such as an included file.
+		p.line, p.column = 0, 0
+	}
+	return p
+}
+
+func (p *errorProblem) write(b *strings.Builder, lines [][]byte) {
+	b.WriteString("yaml: ")
+	if p.line > 0 {
+		fmt.Fprintf(b, "line %d, column %d: ", p.line, p.column)
+	}
+	b.WriteString(p.message)
+	b.WriteByte('\n')
+	width := len(strconv.Itoa(p.line))
+	if p.path != "" {
+		fmt.Fprintf(b, "%*s--> %s\n", width, "", p.path)
+	}
+	text, caret := p.snippet, p.caret
+	if p.line > 0 && p.line <= len(lines) {
+		text = strings.TrimSuffix(string(lines[p.line-1]), "\r")
+		caret = p.column - 1
+	}
+	if p.line == 0 || text == "" && caret == 0 {
+		return
+	}
+	// Tabs are kept so that the caret lines up with the text.
+	var pad strings.Builder
+	for _, r := range text {
+		if pad.Len() >= caret {
+			break
+		}
+		if r == '\t' {
+			pad.WriteByte('\t')
+		} else {
+			pad.WriteByte(' ')
+		}
+	}
+	for pad.Len() < caret {
+		pad.WriteByte(' ')
+	}
+	fmt.Fprintf(b, "%*s |\n", width, "")
+	fmt.Fprintf(b, "%d | %s\n", p.line, text)
+	fmt.Fprintf(b, "%*s | %s^\n", width, "", pad.String())
+}
@@ -0,0 +1,90 @@
+package yaml_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+var formatErrorTests = []struct {
+	data     string
+	value    interface{}
+	strict   bool
+	expected string
+}{{
+	data: "spec:\n  name: web\n  replicas: many\n",
+	value: &struct {
+		Spec struct {
+			Name     string
+			Replicas int
+		}
+	}{},
+	expected: "" +
+		"yaml: line 3, column 13: cannot unmarshal !!str `many` into int\n" +
+		" --> spec.replicas\n" +
+		"  |\n" +
+		"3 |   replicas: many\n" +
+		"  |             ^",
+}, {
+	data:  "a: 1\nb: [1,\t@]\n",
+	value: &map[string]interface{}{},
+	expected: "" +
+		"yaml: line 2, column 8: found character that cannot start any token\n" +
+		"  |\n" +
+		"2 | b: [1,\t@]\n" +
+		"  |       \t^",
+}, {
+	data:  "name:web\nport: 80\n",
+	value: &map[string]interface{}{},
+	expected: "" +
+		"yaml: line 1, column 5: missing space after ':'; did you mean \"name: web\"?\n" +
+		"  |\n" +
+		"1 | name:web\n" +
+		"  |     ^",
+}, {
+	data:   "name: web\nport: 80\nhost: example.com\n",
+	value:  &struct{ Name string }{},
+	strict: true,
+	expected: "" +
+		"yaml: line 2, column 1: field port not found in type struct { Name string }\n" +
+		" --> port\n" +
+		"  |\n" +
+		"2 | port: 80\n" +
+		"  | ^\n" +
+		"\n" +
+		"yaml: line 3, column 1: field host not found in type struct { Name string }\n" +
+		" --> host\n" +
+		"  |\n" +
+		"3 | host: example.com\n" +
+		"  | ^",
+}}
+
+func (s *S) TestFormatError(c *C) {
+	for i, item := range formatErrorTests {
+		c.Logf("test %d: %q", i, item.data)
+		dec := yaml.NewDecoder(strings.NewReader(item.data))
+		dec.KnownFields(item.strict)
+		err := dec.Decode(item.value)
+		c.Assert(err, NotNil)
+		c.Assert(yaml.FormatError(err, []byte(item.data)), Equals, item.expected)
+	}
+}
+
+func (s *S) TestFormatErrorWithoutSource(c *C) {
+	data := "a: 1\nb: 2\nc: @\n"
+	var v interface{}
+	err := yaml.Unmarshal([]byte(data), &v)
+	c.Assert(err, NotNil)
+	c.Assert(yaml.FormatError(err, nil), Equals, ""+
+		"yaml: line 3, column 4: found character that cannot start any token\n"+
+		"  |\n"+
+		"3 | c: @\n"+
+		"  |    ^")
+	c.Assert(yaml.FormatError(fmt.Errorf("loading: %w", err), nil), Equals, yaml.FormatError(err, []byte(data)))
+
+	err = errors.New("something else")
+	c.Assert(yaml.FormatError(err, nil), Equals, "something else")
+}
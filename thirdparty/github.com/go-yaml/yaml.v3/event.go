@@ -0,0 +1,294 @@
+//
+// Copyright (c) 2011-2019 Canonical Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"fmt"
+	"io"
+)
+
+// EventKind identifies the kind of an Event.
+type EventKind int
+
+const (
+	StreamStartEvent EventKind = iota + 1
+	StreamEndEvent
+	DocumentStartEvent
+	DocumentEndEvent
+	AliasEvent
+	ScalarEvent
+	SequenceStartEvent
+	SequenceEndEvent
+	MappingStartEvent
+	MappingEndEvent
+	TailCommentEvent
+)
+
+func (k EventKind) String() string {
+	return yaml_event_type_t(k).String()
+}
+
+// A Mark is a position in the YAML text.
+type Mark struct {
+	// Index holds the number of characters preceding the position.
+	Index int
+
+	// Line and Column hold the 1-based line and column numbers.
+	Line   int
+	Column int
+}
+
+// An Event is an item of the stream of events that make up YAML
+// content, as produced by a Parser.
+type Event struct {
+	Kind EventKind
+
+	// Start and End hold the positions of the event in the YAML text.
+	Start, End Mark
+
+	// Anchor holds the anchor defined by a scalar or collection start
+	// event, or the anchor referenced by an alias event.
+	Anchor string
+
+	// Tag holds the tag of a scalar or collection start event, in its
+	// short form when possible (such as "!!str"), or is empty if the
+	// tag is not explicit in the YAML text.
+	Tag string
+
+	// Value holds the value of a scalar event.
+	Value string
+
+	// Implicit reports whether the document start or end indicator is
+	// absent, or, for scalar and collection start events, whether the
+	// tag may be omitted. For scalars, Implicit refers to the plain
+	// style, and QuotedImplicit to the others.
+	Implicit       bool
+	QuotedImplicit bool
+
+	// Style holds the style of a scalar or collection start event.
+	// Only the quoting, literal, folded and flow styles are used.
+	Style Style
+
+	HeadComment string
+	LineComment string
+	FootComment string
+	TailComment string
+
+	// OpenComment and CloseComment hold the comments following the start
+	// indicator and preceding the end indicator of a flow collection, on
+	// its start and end events respectively.
+	OpenComment  string
+	CloseComment string
+
+	// Version and TagDirectives hold the %YAML and %TAG directives of
+	// a document start event.
+	Version       string
+	TagDirectives []TagDirective
+}
+
+// A Parser reads the stream of events that make up YAML content,
+// without building a tree of the content or decoding it into values,
+// so that large inputs can be processed incrementally.
+type Parser struct {
+	parser *parser
+	done   bool
+}
+
+// NewParser returns a new parser that reads from r.
+func NewParser(r io.Reader) *Parser {
+	return &Parser{parser: newParserFromReader(r)}
+}
+
+// Next returns the next event in the stream. It returns io.EOF after
+// the stream end event has been returned.
+func (p *Parser) Next() (ev Event, err error) {
+	if p.done {
+		return Event{}, io.EOF
+	}
+	defer handleErr(&err)
+	p.parser.peek()
+	ev = newEvent(&p.parser.event)
+	yaml_event_delete(&p.parser.event)
+	p.parser.event.typ = yaml_NO_EVENT
+	if ev.Kind == StreamEndEvent {
+		p.done = true
+	}
+	return ev, nil
+}
+
+// Close releases the resources held by the parser.
+func (p *Parser) Close() error {
+	p.parser.destroy()
+	p.done = true
+	return nil
+}
+
+func newEvent(e *yaml_event_t) Event {
+	ev := Event{
+		Kind:           EventKind(e.typ),
+		Start:          newMark(e.start_mark),
+		End:            newMark(e.end_mark),
+		Anchor:         string(e.anchor),
+		Value:          string(e.value),
+		Implicit:       e.implicit,
+		QuotedImplicit: e.quoted_implicit,
+		HeadComment:    string(e.head_comment),
+		LineComment:    string(e.line_comment),
+		FootComment:    string(e.foot_comment),
+		TailComment:    string(e.tail_comment),
+		OpenComment:    string(e.open_comment),
+		CloseComment:   string(e.close_comment),
+	}
+	if len(e.tag) > 0 {
+		ev.Tag = shortTag(string(e.tag))
+	}
+	if v := e.version_directive; v != nil {
+		ev.Version = fmt.Sprintf("%d.%d", v.major, v.minor)
+	}
+	for _, td := range e.tag_directives {
+		ev.TagDirectives = append(ev.TagDirectives, TagDirective{Handle: string(td.handle), Prefix: string(td.prefix)})
+	}
+	switch e.typ {
+	case yaml_SCALAR_EVENT:
+		switch e.scalar_style() {
+		case yaml_DOUBLE_QUOTED_SCALAR_STYLE:
+			ev.Style = DoubleQuotedStyle
+		case yaml_SINGLE_QUOTED_SCALAR_STYLE:
+			ev.Style = SingleQuotedStyle
+		case yaml_LITERAL_SCALAR_STYLE:
+			ev.Style = LiteralStyle
+		case yaml_FOLDED_SCALAR_STYLE:
+			ev.Style = FoldedStyle
+		}
+	case yaml_SEQUENCE_START_EVENT:
+		if e.sequence_style() == yaml_FLOW_SEQUENCE_STYLE {
+			ev.Style = FlowStyle
+		}
+	case yaml_MAPPING_START_EVENT:
+		if e.mapping_style() == yaml_FLOW_MAPPING_STYLE {
+			ev.Style = FlowStyle
+		}
+	}
+	return ev
+}
+
+func newMark(m yaml_mark_t) Mark {
+	return Mark{Index: m.index, Line: m.line + 1, Column: m.column + 1}
+}
+
+// An Emitter writes YAML content to an output stream from a sequence
+// of events, so that large documents may be generated incrementally.
+//
+// The events must form a valid stream: a StreamStartEvent, followed by
+// documents, each made of a DocumentStartEvent, a single node and a
+// DocumentEndEvent, and finally a StreamEndEvent. Scalar and collection
+// start events without a Tag must be Implicit, and scalars in a quoted,
+// literal or folded style without a Tag must be QuotedImplicit.
+type Emitter struct {
+	encoder *encoder
+}
+
+// NewEmitter returns a new emitter that writes to w.
+func NewEmitter(w io.Writer) *Emitter {
+	e := &Emitter{encoder: newEncoderWithWriter(w)}
+	e.encoder.emitter.best_indent = 4
+	return e
+}
+
+// SetIndent changes the indentation used when emitting.
+func (e *Emitter) SetIndent(spaces int) {
+	if spaces < 0 {
+		panic("yaml: cannot indent to a negative number of spaces")
+	}
+	e.encoder.emitter.best_indent = spaces
+}
+
+// EmitEvent writes the provided event. As the emitter looks ahead at
+// the events that follow document and collection starts, output may be
+// delayed and errors reported by a later call. Output may also be
+// buffered until the stream end event is emitted.
+func (e *Emitter) EmitEvent(ev Event) (err error) {
+	defer handleErr(&err)
+	event := &e.encoder.event
+	var tag []byte
+	if ev.Tag != "" {
+		tag = []byte(longTag(ev.Tag))
+	}
+	anchor := []byte(ev.Anchor)
+	if ev.Anchor == "" {
+		anchor = nil
+	}
+	switch ev.Kind {
+	case StreamStartEvent:
+		yaml_stream_start_event_initialize(event, yaml_UTF8_ENCODING)
+	case StreamEndEvent:
+		e.encoder.emitter.open_ended = false
+		yaml_stream_end_event_initialize(event)
+	case DocumentStartEvent:
+		version, tags := e.encoder.directives(ev.Version, ev.TagDirectives)
+		yaml_document_start_event_initialize(event, version, tags, ev.Implicit)
+	case DocumentEndEvent:
+		yaml_document_end_event_initialize(event, ev.Implicit)
+	case AliasEvent:
+		yaml_alias_event_initialize(event, anchor)
+	case ScalarEvent:
+		style := yaml_PLAIN_SCALAR_STYLE
+		switch {
+		case ev.Style&DoubleQuotedStyle != 0:
+			style = yaml_DOUBLE_QUOTED_SCALAR_STYLE
+		case ev.Style&SingleQuotedStyle != 0:
+			style = yaml_SINGLE_QUOTED_SCALAR_STYLE
+		case ev.Style&LiteralStyle != 0:
+			style = yaml_LITERAL_SCALAR_STYLE
+		case ev.Style&FoldedStyle != 0:
+			style = yaml_FOLDED_SCALAR_STYLE
+		}
+		yaml_scalar_event_initialize(event, anchor, tag, []byte(ev.Value), ev.Implicit, ev.QuotedImplicit, style)
+	case SequenceStartEvent:
+		style := yaml_BLOCK_SEQUENCE_STYLE
+		if ev.Style&FlowStyle != 0 {
+			style = yaml_FLOW_SEQUENCE_STYLE
+		}
+		yaml_sequence_start_event_initialize(event, anchor, tag, ev.Implicit, style)
+	case SequenceEndEvent:
+		yaml_sequence_end_event_initialize(event)
+	case MappingStartEvent:
+		style := yaml_BLOCK_MAPPING_STYLE
+		if ev.Style&FlowStyle != 0 {
+			style = yaml_FLOW_MAPPING_STYLE
+		}
+		yaml_mapping_start_event_initialize(event, anchor, tag, ev.Implicit, style)
+	case MappingEndEvent:
+		yaml_mapping_end_event_initialize(event)
+	default:
+		failf("cannot emit %s event", ev.Kind)
+	}
+	event.head_comment = []byte(ev.HeadComment)
+	event.line_comment = []byte(ev.LineComment)
+	event.foot_comment = []byte(ev.FootComment)
+	event.tail_comment = []byte(ev.TailComment)
+	event.open_comment = []byte(ev.OpenComment)
+	event.close_comment = []byte(ev.CloseComment)
+	e.encoder.emit()
+	return nil
+}
+
+// Close releases the resources held by the emitter. It does not emit
+// any pending events.
+func (e *Emitter) Close() error {
+	e.encoder.destroy()
+	return nil
+}
@@ -0,0 +1,110 @@
+package yaml_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+func (s *S) TestParserEvents(c *C) {
+	p := yaml.NewParser(strings.NewReader("# head\na: &x 1 # line\nb: [*x, 'q']\n--- !!str |\n  lit\n"))
+	defer p.Close()
+
+	type event struct {
+		kind   yaml.EventKind
+		line   int
+		column int
+		anchor string
+		tag    string
+		value  string
+		style  yaml.Style
+	}
+	want := []event{
+		{kind: yaml.StreamStartEvent, line: 1, column: 1},
+		{kind: yaml.DocumentStartEvent, line: 2, column: 1},
+		{kind: yaml.MappingStartEvent, line: 2, column: 1},
+		{kind: yaml.ScalarEvent, line: 2, column: 1, value: "a"},
+		{kind: yaml.ScalarEvent, line: 2, column: 4, anchor: "x", value: "1"},
+		{kind: yaml.ScalarEvent, line: 3, column: 1, value: "b"},
+		{kind: yaml.SequenceStartEvent, line: 3, column: 4, style: yaml.FlowStyle},
+		{kind: yaml.AliasEvent, line: 3, column: 5, anchor: "x"},
+		{kind: yaml.ScalarEvent, line: 3, column: 9, value: "q", style: yaml.SingleQuotedStyle},
+		{kind: yaml.SequenceEndEvent, line: 3, column: 12},
+		{kind: yaml.MappingEndEvent, line: 4, column: 1},
+		{kind: yaml.DocumentEndEvent, line: 4, column: 1},
+		{kind: yaml.DocumentStartEvent, line: 4, column: 1},
+		{kind: yaml.ScalarEvent, line: 4, column: 5, tag: "!!str", value: "lit\n", style: yaml.LiteralStyle},
+		{kind: yaml.DocumentEndEvent, line: 6, column: 1},
+		{kind: yaml.StreamEndEvent, line: 6, column: 1},
+	}
+	var got []event
+	var comments []string
+	for {
+		ev, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		got = append(got, event{ev.Kind, ev.Start.Line, ev.Start.Column, ev.Anchor, ev.Tag, ev.Value, ev.Style})
+		comments = append(comments, ev.HeadComment+ev.LineComment)
+	}
+	c.Assert(got, DeepEquals, want)
+	c.Assert(comments[3], Equals, "# head")
+	c.Assert(comments[4], Equals, "# line")
+
+	_, err := p.Next()
+	c.Assert(err, Equals, io.EOF)
+}
+
+func (s *S) TestParserError(c *C) {
+	p := yaml.NewParser(strings.NewReader("a: [1\nb: 2\n"))
+	defer p.Close()
+	var err error
+	for err == nil {
+		_, err = p.Next()
+	}
+	c.Assert(err, ErrorMatches, "yaml: line 1: did not find expected ',' or ']'")
+}
+
+func (s *S) TestEmitterEvents(c *C) {
+	var buf bytes.Buffer
+	e := yaml.NewEmitter(&buf)
+	defer e.Close()
+	e.SetIndent(2)
+	events := []yaml.Event{
+		{Kind: yaml.StreamStartEvent},
+		{Kind: yaml.DocumentStartEvent, Implicit: true},
+		{Kind: yaml.MappingStartEvent, Implicit: true},
+		{Kind: yaml.ScalarEvent, Value: "items", Implicit: true, HeadComment: "# head"},
+		{Kind: yaml.SequenceStartEvent, Implicit: true},
+	}
+	for i := 0; i < 3; i++ {
+		events = append(events, yaml.Event{Kind: yaml.ScalarEvent, Value: strings.Repeat("x", i+1), Implicit: true})
+	}
+	events = append(events,
+		yaml.Event{Kind: yaml.SequenceEndEvent},
+		yaml.Event{Kind: yaml.ScalarEvent, Value: "flow", Implicit: true},
+		yaml.Event{Kind: yaml.SequenceStartEvent, Anchor: "f", Implicit: true, Style: yaml.FlowStyle},
+		yaml.Event{Kind: yaml.ScalarEvent, Value: "1", Tag: "!!str", Style: yaml.DoubleQuotedStyle},
+		yaml.Event{Kind: yaml.SequenceEndEvent},
+		yaml.Event{Kind: yaml.ScalarEvent, Value: "alias", Implicit: true},
+		yaml.Event{Kind: yaml.AliasEvent, Anchor: "f"},
+		yaml.Event{Kind: yaml.MappingEndEvent},
+		yaml.Event{Kind: yaml.DocumentEndEvent, Implicit: true},
+		yaml.Event{Kind: yaml.StreamEndEvent},
+	)
+	for _, ev := range events {
+		c.Assert(e.EmitEvent(ev), IsNil)
+	}
+	c.Assert(buf.String(), Equals, "# head\nitems:\n  - x\n  - xx\n  - xxx\nflow: &f [!!str \"1\"]\nalias: *f\n")
+}
+
+func (s *S) TestEmitterError(c *C) {
+	e := yaml.NewEmitter(&bytes.Buffer{})
+	defer e.Close()
+	err := e.EmitEvent(yaml.Event{Kind: yaml.ScalarEvent, Value: "a", Implicit: true})
+	c.Assert(err, ErrorMatches, "yaml: expected STREAM-START")
+}
@@ -0,0 +1,92 @@
+//
+// Copyright (c) 2011-2019 Canonical Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"bytes"
+	"io"
+)
+
+// FormatOptions holds the layout settings used by Format.
+type FormatOptions struct {
+	// Indent holds the number of spaces used for indentation, or zero
+	// for the Encoder default of 4.
+	Indent int
+
+	// SequenceIndent holds the number of spaces used to indent block
+	// sequences, if different from Indent. See Encoder.SetSequenceIndent.
+	SequenceIndent int
+
+	// CompactSequences makes the "- " indicator of sequence items part
+	// of their indentation. See Encoder.CompactSeqIndent.
+	CompactSequences bool
+
+	// Width holds the preferred width of the lines, or zero for no limit.
+	// See Encoder.SetWidth.
+	Width int
+
+	// LineBreak holds the line breaks written, unless KeepLineBreak is
+	// set. See Encoder.SetLineBreak.
+	LineBreak LineBreak
+
+	// KeepLineBreak makes the line breaks written those of data, as
+	// found by DetectLineBreak.
+	KeepLineBreak bool
+}
+
+// Format rewrites the documents in data with the layout set by opts,
+// normalizing their indentation, spacing and line width, while keeping
+// their comments, anchors, tags, directives, scalar styles and numbers
+// as written, and the order of mapping keys.
+func Format(data []byte, opts FormatOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	dec := NewDecoder(bytes.NewReader(data))
+	enc := NewEncoder(&buf)
+	enc.SetIndent(opts.Indent)
+	enc.SetSequenceIndent(opts.SequenceIndent)
+	if opts.CompactSequences {
+		enc.CompactSeqIndent()
+	}
+	enc.SetWidth(opts.Width)
+	if opts.KeepLineBreak {
+		enc.SetLineBreak(DetectLineBreak(data))
+	} else {
+		enc.SetLineBreak(opts.LineBreak)
+	}
+	enc.PreserveLexemes(true)
+	docs := 0
+	for ; ; docs++ {
+		var doc Node
+		err := dec.Decode(&doc)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := enc.Encode(&doc); err != nil {
+			return nil, err
+		}
+	}
+	if docs == 0 {
+		// The encoder can't close a stream it hasn't started.
+		return nil, nil
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
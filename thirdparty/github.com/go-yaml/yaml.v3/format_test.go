@@ -0,0 +1,80 @@
+package yaml_test
+
+import (
+	. "gopkg.in/check.v1"
+	"sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+func (s *S) TestFormat(c *C) {
+	data := `# Head.
+b:   &base
+       x:    0x1F   # hex
+       y:   [ 1.50,   2 ]
+a: *base
+list:
+- one   # first
+-     'two'
+- !!str 3
+text: this is a rather long plain scalar that goes past the line width
+---
+z: 1
+`
+	out, err := yaml.Format([]byte(data), yaml.FormatOptions{Indent: 2, Width: 40})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, `# Head.
+b: &base
+  x: 0x1F # hex
+  y: [1.50, 2]
+a: *base
+list:
+  - one # first
+  - 'two'
+  - !!str 3
+text: this is a rather long plain scalar that
+  goes past the line width
+---
+z: 1
+`)
+
+	out, err = yaml.Format(out, yaml.FormatOptions{Indent: 4, CompactSequences: true})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, `# Head.
+b: &base
+    x: 0x1F # hex
+    y: [1.50, 2]
+a: *base
+list:
+  - one # first
+  - 'two'
+  - !!str 3
+text: this is a rather long plain scalar that goes past the line width
+---
+z: 1
+`)
+
+	out, err = yaml.Format(nil, yaml.FormatOptions{})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "")
+
+	_, err = yaml.Format([]byte("a: [1\n"), yaml.FormatOptions{})
+	c.Assert(err, ErrorMatches, "yaml: line 1: did not find expected ',' or ']'")
+}
+
+func (s *S) TestFormatLineBreaks(c *C) {
+	data := "# Head.\r\na: |\r\n  one\r\n  two\r\n\r\n# B.\r\nb: \"x\\ny\" # Line.\r\n"
+	c.Assert(yaml.DetectLineBreak([]byte(data)), Equals, yaml.CRLFBreak)
+	c.Assert(yaml.DetectLineBreak([]byte("a: 1\nb: 2\r\n")), Equals, yaml.LFBreak)
+	c.Assert(yaml.DetectLineBreak([]byte("a: 1")), Equals, yaml.LFBreak)
+
+	out, err := yaml.Format([]byte(data), yaml.FormatOptions{Indent: 2, KeepLineBreak: true})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, data)
+
+	out, err = yaml.Format([]byte(data), yaml.FormatOptions{Indent: 2})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "# Head.\na: |\n  one\n  two\n\n# B.\nb: \"x\\ny\" # Line.\n")
+
+	out, err = yaml.Format(out, yaml.FormatOptions{Indent: 2, LineBreak: yaml.CRLFBreak})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, data)
+}
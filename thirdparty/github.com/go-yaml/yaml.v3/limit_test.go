@@ -57,6 +57,60 @@ func (s *S) TestLimits(c *C) {
 	}
 }
 
+func (s *S) TestMaxAliasExpansion(c *C) {
+	data := "a: &a [0,0,0,0,0,0,0,0,0,0]\n" +
+		"b: &b [*a,*a,*a,*a,*a,*a,*a,*a,*a,*a]\n" +
+		"c: &c [*b,*b,*b,*b,*b,*b,*b,*b,*b,*b]\n" +
+		"d: [*c,*c,*c,*c,*c,*c,*c,*c,*c,*c]\n"
+	tests := []struct {
+		max   int
+		data  string
+		error string
+	}{
+		{max: 0, data: data, error: "yaml: document contains excessive aliasing"},
+		{max: -1, data: data},
+		{max: 20000, data: data},
+		{max: 10000, data: data, error: "yaml: document contains excessive aliasing"},
+		{max: 0, data: "a: &a [1,2,3]\nb: [*a,*a]\n"},
+		{max: 5, data: "a: &a [1,2,3]\nb: [*a,*a]\n", error: "yaml: document contains excessive aliasing"},
+	}
+	for _, tc := range tests {
+		var v interface{}
+		dec := NewDecoder(strings.NewReader(tc.data))
+		dec.SetMaxAliasExpansion(tc.max)
+		err := dec.Decode(&v)
+		if len(tc.error) > 0 {
+			c.Assert(err, ErrorMatches, tc.error, Commentf("max: %d", tc.max))
+		} else {
+			c.Assert(err, IsNil, Commentf("max: %d", tc.max))
+		}
+	}
+}
+
+func (s *S) TestMaxDepth(c *C) {
+	tests := []struct {
+		max   int
+		data  string
+		error *MaxDepthError
+	}{
+		{max: 3, data: "a: [[[1]]]\n"},
+		{max: 3, data: "a: [[{b: [1]}]]\n", error: &MaxDepthError{Max: 3, Line: 1, Column: 10}},
+		{max: 2, data: "a:\n  - - 1\n", error: &MaxDepthError{Max: 2, Line: 2, Column: 5}},
+		{max: 0, data: strings.Repeat("[", 10001), error: &MaxDepthError{Max: 10000, Line: 1, Column: 10001}},
+	}
+	for _, tc := range tests {
+		var v interface{}
+		dec := NewDecoder(strings.NewReader(tc.data))
+		dec.SetMaxDepth(tc.max)
+		err := dec.Decode(&v)
+		if tc.error == nil {
+			c.Assert(err, IsNil, Commentf("data: %q", tc.data))
+			continue
+		}
+		c.Assert(err, DeepEquals, tc.error, Commentf("data: %q", tc.data))
+	}
+}
+
 func Benchmark1000KB100Aliases(b *testing.B) {
 	benchmark(b, "1000kb of maps with 100 aliases")
 }
@@ -0,0 +1,203 @@
+//
+// Copyright (c) 2011-2019 Canonical Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A LintRule checks the scalar values of a document. See Lint.
+type LintRule struct {
+	// Name identifies the rule in findings.
+	Name string
+
+	// Check returns a message describing the problem with the scalar
+	// value, or an empty string if there's none. The key holding the
+	// value is nil for sequence items and the document content.
+	Check func(key, value *Node) string
+}
+
+// A LintFinding describes a value that breaks a LintRule.
+type LintFinding struct {
+	Rule    string
+	Message string
+
+	// Path holds the mapping keys and sequence indexes leading to the
+	// value, separated by dots as in DecodePath.
+	Path string
+
+	Line   int
+	Column int
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("line %d, column %d: %s: %s (%s)", f.Line, f.Column, f.Path, f.Message, f.Rule)
+}
+
+// Lint checks the scalar values of n against rules and returns the
+// findings in document order. Mapping keys are only checked as the
+// context of their values, and aliases aren't followed, so that each
+// value is checked once, where it's written.
+func Lint(n *Node, rules []LintRule) []LintFinding {
+	var findings []LintFinding
+	var walk func(n, key *Node, path []string)
+	walk = func(n, key *Node, path []string) {
+		switch n.Kind {
+		case DocumentNode:
+			for _, c := range n.Content {
+				walk(c, nil, path)
+			}
+		case MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				walk(n.Content[i+1], n.Content[i], append(path, n.Content[i].Value))
+			}
+		case SequenceNode:
+			for i, c := range n.Content {
+				walk(c, nil, append(path, strconv.Itoa(i)))
+			}
+		case ScalarNode:
+			for _, rule := range rules {
+				if msg := rule.Check(key, n); msg != "" {
+					findings = append(findings, LintFinding{
+						Rule:    rule.Name,
+						Message: msg,
+						Path:    strings.Join(path, "."),
+						Line:    n.Line,
+						Column:  n.Column,
+					})
+				}
+			}
+		}
+	}
+	walk(n, nil, nil)
+	return findings
+}
+
+// SecretRules returns rules that flag likely credentials, so that they
+// aren't committed by accident:
+//
+//   - "secret-key" flags non-empty strings held by keys such as password,
+//     client_secret or apiToken.
+//   - "aws-access-key" flags AWS access key IDs.
+//   - "private-key" flags PEM-encoded private keys.
+//   - "high-entropy" flags long hexadecimal or base64 strings that look
+//     random.
+//
+// Values that refer to a secret rather than hold it, such as ${TOKEN} or
+// {{ .Values.token }}, aren't flagged.
+func SecretRules() []LintRule {
+	return []LintRule{{
+		Name: "secret-key",
+		Check: func(key, value *Node) string {
+			if key == nil || !isSecretKey(key.Value) || !isSecretCandidate(value) {
+				return ""
+			}
+			return fmt.Sprintf("key %q holds what looks like a credential", key.Value)
+		},
+	}, {
+		Name: "aws-access-key",
+		Check: func(key, value *Node) string {
+			if !isSecretCandidate(value) || !awsAccessKey.MatchString(value.Value) {
+				return ""
+			}
+			return "value looks like an AWS access key ID"
+		},
+	}, {
+		Name: "private-key",
+		Check: func(key, value *Node) string {
+			if !isSecretCandidate(value) || !privateKey.MatchString(value.Value) {
+				return ""
+			}
+			return "value holds a private key"
+		},
+	}, {
+		Name: "high-entropy",
+		Check: func(key, value *Node) string {
+			if !isSecretCandidate(value) || !isHighEntropy(value.Value) {
+				return ""
+			}
+			return "value looks like a randomly generated secret"
+		},
+	}}
+}
+
+var (
+	awsAccessKey = regexp.MustCompile(`\b(AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b`)
+	privateKey   = regexp.MustCompile(`-----BEGIN ([A-Z]+ )*PRIVATE KEY( BLOCK)?-----`)
+	hexString    = regexp.MustCompile(`^[0-9a-fA-F]{32,}$`)
+	base64String = regexp.MustCompile(`^[0-9A-Za-z+/_\-]{24,}={0,2}$`)
+)
+
+// secretKeyWords holds the words of key names that usually hold secrets.
+var secretKeyWords = []string{
+	"password", "passwd", "pwd", "passphrase", "secret", "token",
+	"apikey", "accesskey", "privatekey", "credential", "credentials", "auth",
+}
+
+// isSecretKey returns whether the key name suggests that its value is a
+// secret, ignoring case and the separators in "api_key" or "api-key".
+func isSecretKey(name string) bool {
+	name = strings.ToLower(name)
+	name = strings.NewReplacer("_", "", "-", "", ".", "", " ", "").Replace(name)
+	for _, word := range secretKeyWords {
+		if strings.HasSuffix(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecretCandidate returns whether the scalar may hold a secret: it's a
+// non-empty string that isn't a reference to a variable or a template.
+func isSecretCandidate(n *Node) bool {
+	if n.ShortTag() != strTag && n.ShortTag() != binaryTag {
+		return false
+	}
+	v := strings.TrimSpace(n.Value)
+	return v != "" && !strings.HasPrefix(v, "${") && !strings.HasPrefix(v, "{{") && !strings.HasPrefix(v, "$(")
+}
+
+// isHighEntropy returns whether s is a long hexadecimal or base64 string
+// whose characters are spread enough to look random.
+func isHighEntropy(s string) bool {
+	switch {
+	case hexString.MatchString(s):
+		return entropy(s) > 3.0
+	case base64String.MatchString(s):
+		return entropy(s) > 4.2
+	}
+	return false
+}
+
+// entropy returns the Shannon entropy of the bytes of s, in bits.
+func entropy(s string) float64 {
+	var counts [256]int
+	for i := 0; i < len(s); i++ {
+		counts[s[i]]++
+	}
+	var e float64
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(len(s))
+			e -= p * math.Log2(p)
+		}
+	}
+	return e
+}
@@ -0,0 +1,144 @@
+//
+// Copyright (c) 2011-2019 Canonical Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SetSource sets the Source field of n and of the nodes within it that
+// don't have one yet to name. See Decoder.SetSource for setting it while
+// decoding.
+func (n *Node) SetSource(name string) {
+	if n.Source == "" {
+		n.Source = name
+	}
+	for _, c := range n.Content {
+		c.SetSource(name)
+	}
+}
+
+// Merge overlays src onto dst in place, as done when a stack of
+// configuration files is applied on top of a base file. Mappings are
+// merged key by key, recursively, and any other value of src replaces the
+// value of dst, so that sequences are replaced as a whole. Keys that are
+// only in src are appended to the mapping of dst. Merged values are the
+// nodes of src rather than copies, and keep their Source, Line and Column,
+// so that Origins can report which input contributed each value. A src
+// without content, as decoded from an empty or comment-only document,
+// leaves dst unchanged.
+func Merge(dst, src *Node) {
+	if src.Kind == 0 || src.Kind == DocumentNode && len(src.Content) == 0 {
+		return
+	}
+	if dst.Kind == DocumentNode && len(dst.Content) == 1 {
+		dst = dst.Content[0]
+	}
+	if src.Kind == DocumentNode && len(src.Content) == 1 {
+		src = src.Content[0]
+		if src.Kind == ScalarNode && src.Value == "" && src.ShortTag() == nullTag {
+			// The document has no content, as in "---" alone.
+			return
+		}
+	}
+	if dst.Kind == DocumentNode && len(dst.Content) == 0 {
+		dst.Content = []*Node{src}
+		return
+	}
+	if dst.Kind != MappingNode || src.Kind != MappingNode {
+		*dst = *src
+		return
+	}
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key, value := src.Content[i], src.Content[i+1]
+		j := mergeKeyIndex(dst, key)
+		switch {
+		case j < 0:
+			dst.Content = append(dst.Content, key, value)
+		case dst.Content[j+1].Kind == MappingNode && value.Kind == MappingNode:
+			Merge(dst.Content[j+1], value)
+		default:
+			dst.Content[j+1] = value
+		}
+	}
+}
+
+// mergeKeyIndex returns the index of the scalar key of the mapping m with
+// the same value as key, or -1 if there's none.
+func mergeKeyIndex(m, key *Node) int {
+	if key.Kind != ScalarNode {
+		return -1
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if k := m.Content[i]; k.Kind == ScalarNode && k.Value == key.Value {
+			return i
+		}
+	}
+	return -1
+}
+
+// An Origin tells where a value of a document comes from.
+type Origin struct {
+	// Path holds the mapping keys and sequence indexes leading to the
+	// value, separated by dots as in LintFinding.
+	Path string
+
+	Source string
+	Line   int
+	Column int
+}
+
+func (o Origin) String() string {
+	if o.Source == "" {
+		return fmt.Sprintf("%s: line %d, column %d", o.Path, o.Line, o.Column)
+	}
+	return fmt.Sprintf("%s: %s:%d:%d", o.Path, o.Source, o.Line, o.Column)
+}
+
+// Origins returns the origin of each scalar, alias and empty collection
+// within n, in document order, such as the files that contributed each
+// value of documents put together with Merge.
+func Origins(n *Node) []Origin {
+	var origins []Origin
+	var walk func(n *Node, path []string)
+	walk = func(n *Node, path []string) {
+		switch {
+		case n.Kind == DocumentNode:
+			for _, c := range n.Content {
+				walk(c, path)
+			}
+		case n.Kind == MappingNode && len(n.Content) > 0:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				walk(n.Content[i+1], append(path, n.Content[i].Value))
+			}
+		case n.Kind == SequenceNode && len(n.Content) > 0:
+			for i, c := range n.Content {
+				walk(c, append(path, strconv.Itoa(i)))
+			}
+		default:
+			origins = append(origins, Origin{
+				Path:   strings.Join(path, "."),
+				Source: n.Source,
+				Line:   n.Line,
+				Column: n.Column,
+			})
+		}
+	}
+	walk(n, nil)
+	return origins
+}
@@ -0,0 +1,98 @@
+package yaml_test
+
+import (
+	"bytes"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+func decodeWithSource(c *C, name, data string) *yaml.Node {
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.SetSource(name)
+	var doc yaml.Node
+	c.Assert(dec.Decode(&doc), IsNil)
+	return &doc
+}
+
+func (s *S) TestMergeOrigins(c *C) {
+	base := decodeWithSource(c, "base.yaml", `app:
+  name: web
+  replicas: 1
+  ports: [80]
+  env: {}
+`)
+	prod := decodeWithSource(c, "prod.yaml", `app:
+  replicas: 3
+  ports: [80, 443]
+  tls: true
+`)
+	yaml.Merge(base, prod)
+
+	var got []string
+	for _, o := range yaml.Origins(base) {
+		got = append(got, o.String())
+	}
+	c.Assert(got, DeepEquals, []string{
+		"app.name: base.yaml:2:9",
+		"app.replicas: prod.yaml:2:13",
+		"app.ports.0: prod.yaml:3:11",
+		"app.ports.1: prod.yaml:3:15",
+		"app.env: base.yaml:5:8",
+		"app.tls: prod.yaml:4:8",
+	})
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	c.Assert(enc.Encode(base), IsNil)
+	c.Assert(buf.String(), Equals, `app:
+  name: web
+  replicas: 3
+  ports: [80, 443]
+  env: {}
+  tls: true
+`)
+}
+
+func (s *S) TestMergeEmpty(c *C) {
+	for _, overlay := range []string{"", "# nothing here\n", "---\n"} {
+		var dst, src yaml.Node
+		c.Assert(yaml.Unmarshal([]byte("a: 1\nb: 2\n"), &dst), IsNil)
+		c.Assert(yaml.Unmarshal([]byte(overlay), &src), IsNil)
+		yaml.Merge(&dst, &src)
+		out, err := yaml.Marshal(&dst)
+		c.Assert(err, IsNil)
+		c.Assert(string(out), Equals, "a: 1\nb: 2\n", Commentf("overlay %q", overlay))
+	}
+
+	// An explicit null still replaces the value.
+	var dst, src yaml.Node
+	c.Assert(yaml.Unmarshal([]byte("a: 1\n"), &dst), IsNil)
+	c.Assert(yaml.Unmarshal([]byte("null\n"), &src), IsNil)
+	yaml.Merge(&dst, &src)
+	out, err := yaml.Marshal(&dst)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "null\n")
+}
+
+func (s *S) TestNodeSetSource(c *C) {
+	var doc yaml.Node
+	c.Assert(yaml.Unmarshal([]byte("a: [1, 2]\n"), &doc), IsNil)
+	doc.Content[0].Content[1].Content[0].Source = "other.yaml"
+	doc.SetSource("main.yaml")
+	var got []string
+	for _, o := range yaml.Origins(&doc) {
+		got = append(got, o.String())
+	}
+	c.Assert(got, DeepEquals, []string{
+		"a.0: other.yaml:1:5",
+		"a.1: main.yaml:1:8",
+	})
+	c.Assert(doc.Source, Equals, "main.yaml")
+
+	// Without a source, positions are still reported.
+	c.Assert(yaml.Unmarshal([]byte("a: 1\n"), &doc), IsNil)
+	c.Assert(yaml.Origins(&doc)[0].String(), Equals, "a: line 1, column 4")
+}
@@ -21,6 +21,7 @@ import (
 	"os"
 
 	"io"
+	"math"
 	"strings"
 
 	. "gopkg.in/check.v1"
@@ -92,6 +93,23 @@ var nodeTests = []struct {
 				Column: 1,
 			}},
 		},
+	}, {
+		"%YAML 1.2\n%TAG !e! tag:example.com,2000:\n---\n!e!foo bar\n",
+		Node{
+			Kind:          DocumentNode,
+			Line:          1,
+			Column:        1,
+			Version:       "1.2",
+			TagDirectives: []TagDirective{{Handle: "!e!", Prefix: "tag:example.com,2000:"}},
+			Content: []*Node{{
+				Kind:   ScalarNode,
+				Style:  TaggedStyle,
+				Value:  "bar",
+				Tag:    "tag:example.com,2000:foo",
+				Line:   4,
+				Column: 1,
+			}},
+		},
 	}, {
 		"!!str 123\n",
 		Node{
@@ -308,6 +326,21 @@ var nodeTests = []struct {
 				Column: 1,
 			}},
 		},
+	}, {
+		"0x1A\n",
+		Node{
+			Kind:   DocumentNode,
+			Line:   1,
+			Column: 1,
+			Content: []*Node{{
+				Kind:   ScalarNode,
+				Value:  "0x1A",
+				Tag:    "!!int",
+				Lexeme: "0x1A",
+				Line:   1,
+				Column: 1,
+			}},
+		},
 	}, {
 		"0.1000\n",
 		Node{
@@ -318,6 +351,7 @@ var nodeTests = []struct {
 				Kind:   ScalarNode,
 				Value:  "0.1000",
 				Tag:    "!!float",
+				Lexeme: "0.1000",
 				Line:   1,
 				Column: 1,
 			}},
@@ -392,6 +426,58 @@ var nodeTests = []struct {
 				}},
 			}},
 		},
+	}, {
+		"a: 1\n\nb:\n  - x\n\n\n  - y\n",
+		Node{
+			Kind:   DocumentNode,
+			Line:   1,
+			Column: 1,
+			Content: []*Node{{
+				Kind:   MappingNode,
+				Tag:    "!!map",
+				Line:   1,
+				Column: 1,
+				Content: []*Node{{
+					Kind:   ScalarNode,
+					Value:  "a",
+					Tag:    "!!str",
+					Line:   1,
+					Column: 1,
+				}, {
+					Kind:   ScalarNode,
+					Value:  "1",
+					Tag:    "!!int",
+					Line:   1,
+					Column: 4,
+				}, {
+					Kind:             ScalarNode,
+					Value:            "b",
+					Tag:              "!!str",
+					EmptyLinesBefore: 1,
+					Line:             3,
+					Column:           1,
+				}, {
+					Kind:   SequenceNode,
+					Tag:    "!!seq",
+					Line:   4,
+					Column: 3,
+					Content: []*Node{{
+						Kind:   ScalarNode,
+						Value:  "x",
+						Tag:    "!!str",
+						Line:   4,
+						Column: 5,
+					}, {
+						Kind:             ScalarNode,
+						Value:            "y",
+						Tag:              "!!str",
+						EmptyLinesBefore: 2,
+						Line:             7,
+						Column:           5,
+					}},
+				}},
+			}},
+		},
 	}, {
 		"a:\n  b: c\n  d: e\n",
 		Node{
@@ -550,57 +636,7 @@ var nodeTests = []struct {
 			}},
 		},
 	}, {
-		"[decode]a:\n  # HM\n  - # HB1\n    # HB2\n    b: # IB\n      c # IC\n",
-		Node{
-			Kind:   DocumentNode,
-			Line:   1,
-			Column: 1,
-			Content: []*Node{{
-				Kind:   MappingNode,
-				Tag:    "!!map",
-				Line:   1,
-				Column: 1,
-				Content: []*Node{{
-					Kind:   ScalarNode,
-					Style:  0x0,
-					Tag:    "!!str",
-					Value:  "a",
-					Line:   1,
-					Column: 1,
-				}, {
-					Kind:   SequenceNode,
-					Tag:    "!!seq",
-					Line:   3,
-					Column: 3,
-					Content: []*Node{{
-						Kind:        MappingNode,
-						Tag:         "!!map",
-						HeadComment: "# HM",
-						Line:        5,
-						Column:      5,
-						Content: []*Node{{
-							Kind:        ScalarNode,
-							Tag:         "!!str",
-							Value:       "b",
-							HeadComment: "# HB1\n# HB2",
-							LineComment: "# IB",
-							Line:        5,
-							Column:      5,
-						}, {
-							Kind:        ScalarNode,
-							Tag:         "!!str",
-							Value:       "c",
-							LineComment: "# IC",
-							Line:        6,
-							Column:      7,
-						}},
-					}},
-				}},
-			}},
-		},
-	}, {
-		// When encoding the value above, it loses b's inline comment.
-		"[encode]a:\n  # HM\n  - # HB1\n    # HB2\n    b: c # IC\n",
+		"a:\n  # HM\n  - # HB1\n    # HB2\n    b: # IB\n      c # IC\n",
 		Node{
 			Kind:   DocumentNode,
 			Line:   1,
@@ -686,6 +722,7 @@ var nodeTests = []struct {
 					Tag:         "!!str",
 					Value:       "str",
 					LineComment: "# IB",
+					Chomping:    StripChomping,
 					Line:        3,
 					Column:      4,
 				}, {
@@ -1970,8 +2007,7 @@ var nodeTests = []struct {
 			}},
 		},
 	}, {
-		// Decode only due to empty line before HC1.
-		"[decode]ka:\n  kb: vb\n\n# HC1\nkc: vc\n",
+		"ka:\n  kb: vb\n\n# HC1\nkc: vc\n",
 		Node{
 			Kind:   DocumentNode,
 			Line:   1,
@@ -2006,12 +2042,13 @@ var nodeTests = []struct {
 						Column: 7,
 					}},
 				}, {
-					Kind:        ScalarNode,
-					Tag:         "!!str",
-					Value:       "kc",
-					HeadComment: "# HC1",
-					Line:        5,
-					Column:      1,
+					Kind:             ScalarNode,
+					Tag:              "!!str",
+					Value:            "kc",
+					HeadComment:      "# HC1",
+					EmptyLinesBefore: 1,
+					Line:             5,
+					Column:           1,
 				}, {
 					Kind:   ScalarNode,
 					Tag:    "!!str",
@@ -2058,12 +2095,13 @@ var nodeTests = []struct {
 						Column: 7,
 					}},
 				}, {
-					Kind:        ScalarNode,
-					Tag:         "!!str",
-					Value:       "kc",
-					HeadComment: "# HC1\n",
-					Line:        6,
-					Column:      1,
+					Kind:             ScalarNode,
+					Tag:              "!!str",
+					Value:            "kc",
+					HeadComment:      "# HC1\n",
+					EmptyLinesBefore: 1,
+					Line:             6,
+					Column:           1,
 				}, {
 					Kind:   ScalarNode,
 					Tag:    "!!str",
@@ -2168,8 +2206,8 @@ var nodeTests = []struct {
 			}},
 		},
 	}, {
-		// Same as above, but decode only due to empty line between ka's value and kc's headers.
-		"[decode]# HA1\nka:\n  # HB1\n  kb: vb\n  # FB1\n\n# HC1\n# HC2\nkc: vc\n# FC1\n# FC2\n",
+		// Same as above, but with an empty line between ka's value and kc's headers.
+		"# HA1\nka:\n  # HB1\n  kb: vb\n  # FB1\n\n# HC1\n# HC2\nkc: vc\n# FC1\n# FC2\n",
 		Node{
 			Kind:   DocumentNode,
 			Line:   2,
@@ -2207,13 +2245,14 @@ var nodeTests = []struct {
 						Column: 7,
 					}},
 				}, {
-					Kind:        ScalarNode,
-					Tag:         "!!str",
-					Value:       "kc",
-					HeadComment: "# HC1\n# HC2",
-					FootComment: "# FC1\n# FC2",
-					Line:        9,
-					Column:      1,
+					Kind:             ScalarNode,
+					Tag:              "!!str",
+					Value:            "kc",
+					HeadComment:      "# HC1\n# HC2",
+					FootComment:      "# FC1\n# FC2",
+					EmptyLinesBefore: 1,
+					Line:             9,
+					Column:           1,
 				}, {
 					Kind:   ScalarNode,
 					Tag:    "!!str",
@@ -2514,6 +2553,106 @@ var nodeTests = []struct {
 				}},
 			}},
 		},
+	}, {
+		"# SH1\n[ # SO1\n  la, # IA\n  # FA1\n\n  # SC1\n]\n# SF1\n",
+		Node{
+			Kind:   DocumentNode,
+			Line:   2,
+			Column: 1,
+			Content: []*Node{{
+				Kind:         SequenceNode,
+				Tag:          "!!seq",
+				Style:        FlowStyle,
+				Line:         2,
+				Column:       1,
+				HeadComment:  "# SH1",
+				FootComment:  "# SF1",
+				OpenComment:  "# SO1",
+				CloseComment: "# SC1",
+				Content: []*Node{{
+					Kind:        ScalarNode,
+					Tag:         "!!str",
+					Line:        3,
+					Column:      3,
+					Value:       "la",
+					LineComment: "# IA",
+					FootComment: "# FA1",
+				}},
+			}},
+		},
+	}, {
+		"# MH1\n{ # MO1\n  ka: va, # IA\n  # FA1\n\n  # MC1\n}\n# MF1\n",
+		Node{
+			Kind:   DocumentNode,
+			Line:   2,
+			Column: 1,
+			Content: []*Node{{
+				Kind:         MappingNode,
+				Tag:          "!!map",
+				Style:        FlowStyle,
+				Line:         2,
+				Column:       1,
+				HeadComment:  "# MH1",
+				FootComment:  "# MF1",
+				OpenComment:  "# MO1",
+				CloseComment: "# MC1",
+				Content: []*Node{{
+					Kind:        ScalarNode,
+					Tag:         "!!str",
+					Line:        3,
+					Column:      3,
+					Value:       "ka",
+					FootComment: "# FA1",
+				}, {
+					Kind:        ScalarNode,
+					Tag:         "!!str",
+					Line:        3,
+					Column:      7,
+					Value:       "va",
+					LineComment: "# IA",
+				}},
+			}},
+		},
+	}, {
+		"ka: [\n  # SC1\n]\nkb: { # MO1\n}\n",
+		Node{
+			Kind:   DocumentNode,
+			Line:   1,
+			Column: 1,
+			Content: []*Node{{
+				Kind:   MappingNode,
+				Tag:    "!!map",
+				Line:   1,
+				Column: 1,
+				Content: []*Node{{
+					Kind:   ScalarNode,
+					Tag:    "!!str",
+					Value:  "ka",
+					Line:   1,
+					Column: 1,
+				}, {
+					Kind:         SequenceNode,
+					Tag:          "!!seq",
+					Style:        FlowStyle,
+					Line:         1,
+					Column:       5,
+					CloseComment: "# SC1",
+				}, {
+					Kind:   ScalarNode,
+					Tag:    "!!str",
+					Value:  "kb",
+					Line:   4,
+					Column: 1,
+				}, {
+					Kind:        MappingNode,
+					Tag:         "!!map",
+					Style:       FlowStyle,
+					Line:        4,
+					Column:      5,
+					OpenComment: "# MO1",
+				}},
+			}},
+		},
 	}, {
 		"# DH1\n\n# DH2\n\n# HA1\n# HA2\n- &x la # IA\n# FA1\n# FA2\n\n# HB1\n# HB2\n- *x # IB\n# FB1\n# FB2\n\n# DF1\n\n# DF2\n",
 		Node{
@@ -2551,6 +2690,61 @@ var nodeTests = []struct {
 				},
 			}},
 		},
+	}, {
+		"# head\n--- # c\nka: va\n",
+		Node{
+			Kind:        DocumentNode,
+			Line:        2,
+			Column:      1,
+			HeadComment: "# head",
+			LineComment: "# c",
+			Content: []*Node{{
+				Kind:   MappingNode,
+				Tag:    "!!map",
+				Line:   3,
+				Column: 1,
+				Content: []*Node{{
+					Kind:   ScalarNode,
+					Tag:    "!!str",
+					Value:  "ka",
+					Line:   3,
+					Column: 1,
+				}, {
+					Kind:   ScalarNode,
+					Tag:    "!!str",
+					Value:  "va",
+					Line:   3,
+					Column: 5,
+				}},
+			}},
+		},
+	}, {
+		"--- # source: app/deployment.yaml\nka: va\n",
+		Node{
+			Kind:        DocumentNode,
+			Line:        1,
+			Column:      1,
+			LineComment: "# source: app/deployment.yaml",
+			Content: []*Node{{
+				Kind:   MappingNode,
+				Tag:    "!!map",
+				Line:   2,
+				Column: 1,
+				Content: []*Node{{
+					Kind:   ScalarNode,
+					Tag:    "!!str",
+					Value:  "ka",
+					Line:   2,
+					Column: 1,
+				}, {
+					Kind:   ScalarNode,
+					Tag:    "!!str",
+					Value:  "va",
+					Line:   2,
+					Column: 5,
+				}},
+			}},
+		},
 	},
 }
 
@@ -2607,6 +2801,36 @@ func (s *S) TestNodeRoundtrip(c *C) {
 	}
 }
 
+func (s *S) TestNodeDocumentStartComment(c *C) {
+	data := "--- # source: app/service.yaml\nkind: Service\n--- # source: app/deployment.yaml\nkind: Deployment\n...\n--- # source: app/empty.yaml\n---\n- 1 # one\n"
+	dec := NewDecoder(strings.NewReader(data))
+	var comments []string
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for {
+		var node Node
+		if err := dec.Decode(&node); err == io.EOF {
+			break
+		} else {
+			c.Assert(err, IsNil)
+		}
+		comments = append(comments, node.LineComment)
+		c.Assert(enc.Encode(&node), IsNil)
+	}
+	c.Assert(enc.Close(), IsNil)
+	c.Assert(comments, DeepEquals, []string{"# source: app/service.yaml", "# source: app/deployment.yaml", "# source: app/empty.yaml", ""})
+	c.Assert(buf.String(), Equals, "--- # source: app/service.yaml\nkind: Service\n--- # source: app/deployment.yaml\nkind: Deployment\n--- # source: app/empty.yaml\n\n---\n- 1 # one\n")
+
+	// The comment makes the start indicator explicit on the first document.
+	out, err := Marshal(&Node{
+		Kind:        DocumentNode,
+		LineComment: "# generated",
+		Content:     []*Node{{Kind: ScalarNode, Value: "a"}},
+	})
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "--- # generated\na\n")
+}
+
 func deepCopyNode(node *Node, cache map[*Node]*Node) *Node {
 	if n, ok := cache[node]; ok {
 		return n
@@ -2733,6 +2957,167 @@ func (s *S) TestSetString(c *C) {
 	}
 }
 
+func (s *S) TestNodeEncodeInto(c *C) {
+	type Port struct {
+		Name string
+		Port int
+	}
+	type Spec struct {
+		Image string   `yaml:"image"`
+		Ports []Port   `yaml:"ports"`
+		Args  []string `yaml:"args,flow"`
+		Note  string   `yaml:"note,omitempty"`
+		Label string   `yaml:"label,omitempty"`
+		Size  int      `yaml:"size"`
+	}
+	data := `# The spec.
+
+note: 'keep me' # removed
+ports:
+  # The web port.
+  - name: web
+    port: 80 # http
+  - name: metrics
+    port: 9090
+image: "nginx:1.0" # pinned
+args: [-v]
+size: 1
+`
+	var doc Node
+	c.Assert(Unmarshal([]byte(data), &doc), IsNil)
+
+	var v Spec
+	c.Assert(doc.Decode(&v), IsNil)
+	v.Image = "nginx:1.1"
+	v.Ports = v.Ports[:1]
+	v.Ports[0].Port = 8080
+	v.Args = append(v.Args, "--debug")
+	v.Note = ""
+	v.Label = "new"
+	v.Size = 0
+	c.Assert(doc.EncodeInto(&v), IsNil)
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetIndent(2)
+	c.Assert(enc.Encode(&doc), IsNil)
+	c.Assert(buf.String(), Equals, `# The spec.
+
+ports:
+  # The web port.
+  - name: web
+    port: 8080 # http
+image: "nginx:1.1" # pinned
+args: [-v, --debug]
+size: 0
+label: new
+`)
+
+	var n Node
+	c.Assert(n.EncodeInto(map[string]int{"a": 1}), IsNil)
+	c.Assert(n.Kind, Equals, MappingNode)
+	n.Content[1].LineComment = "# one"
+	c.Assert(n.EncodeInto(map[string]string{"a": "123"}), IsNil)
+	out, err := Marshal(&n)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "a: \"123\" # one\n")
+}
+
+func (s *S) TestNodeDecodeWithOptions(c *C) {
+	var doc Node
+	err := Unmarshal([]byte("spec:\n  size: 0x10\n  name: !Name web\n  extra: 1\n"), &doc)
+	c.Assert(err, IsNil)
+	spec := doc.Content[0].Content[1]
+
+	type Spec struct {
+		Size int
+		Name string
+	}
+	var v Spec
+	c.Assert(spec.Decode(&v), IsNil)
+	c.Assert(v, DeepEquals, Spec{Size: 16, Name: "web"})
+
+	err = spec.DecodeWithOptions(&v, WithKnownFields(true))
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n  line 4: field extra not found in type yaml_test.Spec")
+
+	var m map[string]interface{}
+	err = spec.DecodeWithOptions(&m, WithSchema(JSONSchema))
+	c.Assert(err, ErrorMatches, "yaml: cannot resolve plain scalar `0x10` with the JSON schema")
+
+	v = Spec{}
+	err = spec.DecodeWithOptions(&v, WithTagResolver("!Name", func(n *Node) (interface{}, error) {
+		return strings.ToUpper(n.Value), nil
+	}))
+	c.Assert(err, IsNil)
+	c.Assert(v, DeepEquals, Spec{Size: 16, Name: "WEB"})
+}
+
+func (s *S) TestNodeKindPredicates(c *C) {
+	var doc Node
+	err := Unmarshal([]byte("a: &x 1\nb: *x\nc: [~]\nd: null\ne: !!null ''\nf: 'null'\ng: &n ~\nh: *n\n"), &doc)
+	c.Assert(err, IsNil)
+	m := doc.Content[0]
+	c.Assert(m.IsMapping(), Equals, true)
+	c.Assert(m.IsSequence(), Equals, false)
+	c.Assert(m.Content[1].IsScalar(), Equals, true)
+	c.Assert(m.Content[3].IsAlias(), Equals, true)
+	c.Assert(m.Content[3].IsScalar(), Equals, false)
+	c.Assert(m.Content[5].IsSequence(), Equals, true)
+	c.Assert(m.Content[5].Content[0].IsNull(), Equals, true)
+	c.Assert(m.Content[7].IsNull(), Equals, true)
+	c.Assert(m.Content[9].IsNull(), Equals, true)
+	c.Assert(m.Content[11].IsNull(), Equals, false)
+	c.Assert(m.Content[15].IsNull(), Equals, true)
+	c.Assert(m.Content[1].IsNull(), Equals, false)
+	c.Assert((&Node{}).IsNull(), Equals, true)
+
+	c.Assert(DocumentNode.String(), Equals, "document")
+	c.Assert(SequenceNode.String(), Equals, "sequence")
+	c.Assert(MappingNode.String(), Equals, "mapping")
+	c.Assert(ScalarNode.String(), Equals, "scalar")
+	c.Assert(AliasNode.String(), Equals, "alias")
+	c.Assert(Kind(0).String(), Equals, "unknown kind 0")
+	c.Assert(fmt.Sprint(m.Kind), Equals, "mapping")
+}
+
+func (s *S) TestSetScalars(c *C) {
+	tests := []struct {
+		set   func(n *Node)
+		value interface{}
+		yaml  string
+	}{
+		{func(n *Node) { n.SetInt(-42) }, -42, "-42\n"},
+		{func(n *Node) { n.SetFloat(1.5) }, 1.5, "1.5\n"},
+		{func(n *Node) { n.SetFloat(1) }, 1.0, "!!float 1\n"},
+		{func(n *Node) { n.SetFloat(math.Inf(1)) }, math.Inf(1), ".inf\n"},
+		{func(n *Node) { n.SetBool(true) }, true, "true\n"},
+		{func(n *Node) { n.SetNull() }, nil, "null\n"},
+		{func(n *Node) { n.SetBinary([]byte("\x80\x81\x82")) }, "\x80\x81\x82", "!!binary gIGC\n"},
+	}
+	for i, item := range tests {
+		c.Logf("test %d: %q", i, item.yaml)
+
+		// The previous style is dropped so that the value isn't quoted.
+		node := Node{Style: DoubleQuotedStyle}
+		item.set(&node)
+
+		data, err := Marshal(&node)
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, item.yaml)
+
+		var v interface{}
+		c.Assert(node.Decode(&v), IsNil)
+		c.Assert(v, DeepEquals, item.value)
+	}
+
+	var node Node
+	node.SetBinary(bytes.Repeat([]byte{0xff}, 60))
+	c.Assert(node.Style, Equals, LiteralStyle)
+	var str string
+	c.Assert(node.Decode(&str), IsNil)
+	c.Assert(str, Equals, strings.Repeat("\xff", 60))
+}
+
 var nodeEncodeDecodeTests = []struct {
 	value interface{}
 	yaml  string
@@ -2812,6 +3197,137 @@ func (s *S) TestNodeEncodeDecode(c *C) {
 	}
 }
 
+var intLexemeTests = []struct {
+	yaml  string
+	value string
+	want  string
+}{
+	{"0x10", "32", "0x20"},
+	{"0X00ff", "4096", "0X1000"},
+	{"0xFF", "171", "0xAB"},
+	{"0o17", "8", "0o10"},
+	{"0b101", "6", "0b110"},
+	{"017", "8", "010"},
+	{"1_000", "1234567", "1_234_567"},
+	{"+0x10", "-16", "-0x10"},
+	{"0x10", "0x30", "0x30"},
+	{"0x10", "12.5", "12.5"},
+}
+
+func (s *S) TestNodeIntLexeme(c *C) {
+	for i, item := range intLexemeTests {
+		c.Logf("test %d: %q with value %q", i, item.yaml, item.value)
+		var doc Node
+		err := Unmarshal([]byte("a: "+item.yaml+"\n"), &doc)
+		c.Assert(err, IsNil)
+		value := doc.Content[0].Content[1]
+		c.Assert(value.Lexeme, Equals, item.yaml)
+
+		value.Value = item.value
+		value.Tag = ""
+		data, err := Marshal(&doc)
+		c.Assert(err, IsNil)
+		c.Assert(string(data), Equals, "a: "+item.want+"\n")
+	}
+}
+
+func (s *S) TestNodePreserveLexemes(c *C) {
+	var doc Node
+	err := Unmarshal([]byte("a: 1e3\nb: 1.50\nc: 0x10\nd: 2.5\n"), &doc)
+	c.Assert(err, IsNil)
+	m := doc.Content[0]
+	c.Assert(m.Content[1].Lexeme, Equals, "1e3")
+	c.Assert(m.Content[3].Lexeme, Equals, "1.50")
+	c.Assert(m.Content[7].Lexeme, Equals, "")
+
+	// Values rendered again from their numbers.
+	m.Content[1].Value = "1000"
+	m.Content[3].Value = "1.5"
+	m.Content[5].Value = "16"
+
+	for _, preserve := range []bool{false, true} {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		enc.PreserveLexemes(preserve)
+		c.Assert(enc.Encode(&doc), IsNil)
+		c.Assert(enc.Close(), IsNil)
+		if preserve {
+			c.Assert(buf.String(), Equals, "a: 1e3\nb: 1.50\nc: 0x10\nd: 2.5\n")
+		} else {
+			c.Assert(buf.String(), Equals, "a: !!float 1000\nb: 1.5\nc: 0x10\nd: 2.5\n")
+		}
+	}
+
+	// Modified values are rendered as they are.
+	m.Content[3].Value = "1.75"
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.PreserveLexemes(true)
+	c.Assert(enc.Encode(&doc), IsNil)
+	c.Assert(enc.Close(), IsNil)
+	c.Assert(buf.String(), Equals, "a: 1e3\nb: 1.75\nc: 0x10\nd: 2.5\n")
+}
+
+func (s *S) TestNodeChomping(c *C) {
+	data := "a: |+\n  x\nb: >-\n  y\nc: |\n  z\nd: |+\n  w\n\n"
+	var doc Node
+	err := Unmarshal([]byte(data), &doc)
+	c.Assert(err, IsNil)
+	m := doc.Content[0]
+	c.Assert(m.Content[1].Chomping, Equals, KeepChomping)
+	c.Assert(m.Content[3].Chomping, Equals, StripChomping)
+	c.Assert(m.Content[5].Chomping, Equals, ClipChomping)
+	c.Assert(m.Content[7].Chomping, Equals, KeepChomping)
+
+	out, err := Marshal(&doc)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, strings.Replace(data, "  ", "    ", -1))
+
+	// Indicators that don't fit the values are replaced.
+	m.Content[1].Value = "x"
+	m.Content[5].Chomping = KeepChomping
+	out, err = Marshal(&doc)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "a: |-\n    x\nb: >-\n    y\nc: |+\n    z\nd: |+\n    w\n\n")
+}
+
+func (s *S) TestNodeIndentIndicator(c *C) {
+	data := "a: |1\n  x\nb:\n    - >3-\n         y\n    - |\n      z\n"
+	var doc Node
+	err := Unmarshal([]byte(data), &doc)
+	c.Assert(err, IsNil)
+	m := doc.Content[0]
+	c.Assert(m.Content[1].IndentIndicator, Equals, 1)
+	c.Assert(m.Content[1].Value, Equals, " x\n")
+	c.Assert(m.Content[3].Content[0].IndentIndicator, Equals, 3)
+	c.Assert(m.Content[3].Content[0].Value, Equals, "  y")
+	c.Assert(m.Content[3].Content[1].IndentIndicator, Equals, 0)
+
+	out, err := Marshal(&doc)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, data)
+
+	// Content starting with spaces needs an indicator.
+	m.Content[3].Content[1].Value = " z\n"
+	out, err = Marshal(&doc)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "a: |1\n  x\nb:\n    - >3-\n         y\n    - |2\n       z\n")
+}
+
+func (s *S) TestNodeAnchors(c *C) {
+	var n Node
+	err := Unmarshal([]byte("a: &x 1\nb: &y [&z 2, *x]\nc: &x 3\nd: *y\n"), &n)
+	c.Assert(err, IsNil)
+	anchors := n.Anchors()
+	c.Assert(anchors, HasLen, 3)
+	m := n.Content[0]
+	c.Assert(anchors["x"], Equals, m.Content[5])
+	c.Assert(anchors["y"], Equals, m.Content[3])
+	c.Assert(anchors["z"], Equals, m.Content[3].Content[0])
+
+	c.Assert((&Node{Kind: ScalarNode, Value: "a"}).Anchors(), HasLen, 0)
+}
+
 func (s *S) TestNodeZeroEncodeDecode(c *C) {
 	// Zero node value behaves as nil when encoding...
 	var n Node
@@ -90,7 +90,14 @@ func yaml_parser_unfold_comments(parser *yaml_parser_t, token *yaml_token_t) {
 			}
 			parser.head_comment = append(parser.head_comment, comment.head...)
 		}
-		if len(comment.foot) > 0 {
+		if len(comment.foot) > 0 && yaml_comment_closes_flow(comment, token) {
+			// [Go] Comments right inside the end indicator of a flow collection
+			//      are kept apart from the foot of the collection itself.
+			if len(parser.close_comment) > 0 {
+				parser.close_comment = append(parser.close_comment, '\n')
+			}
+			parser.close_comment = append(parser.close_comment, comment.foot...)
+		} else if len(comment.foot) > 0 {
 			if len(parser.foot_comment) > 0 {
 				parser.foot_comment = append(parser.foot_comment, '\n')
 			}
@@ -107,6 +114,19 @@ func yaml_parser_unfold_comments(parser *yaml_parser_t, token *yaml_token_t) {
 	}
 }
 
+// yaml_comment_closes_flow reports whether the foot comment precedes the end
+// indicator of a flow collection, with the token being either that indicator
+// or the start indicator of an empty collection.
+func yaml_comment_closes_flow(comment *yaml_comment_t, token *yaml_token_t) bool {
+	switch token.typ {
+	case yaml_FLOW_SEQUENCE_START_TOKEN, yaml_FLOW_MAPPING_START_TOKEN:
+		return comment.start_mark.index > token.start_mark.index
+	case yaml_FLOW_SEQUENCE_END_TOKEN, yaml_FLOW_MAPPING_END_TOKEN:
+		return comment.start_mark.index < token.start_mark.index
+	}
+	return false
+}
+
 // Remove the next token from the queue (must be called after peek_token).
 func skip_token(parser *yaml_parser_t) {
 	parser.token_available = false
@@ -330,6 +350,14 @@ func yaml_parser_parse_document_start(parser *yaml_parser_t, event *yaml_event_t
 		parser.state = yaml_PARSE_DOCUMENT_CONTENT_STATE
 		end_mark := token.end_mark
 
+		// [Go] The comments above a start indicator with a line comment
+		//      belong to the document, rather than to its first node.
+		var head_comment []byte
+		if len(parser.line_comment) > 0 {
+			head_comment = parser.head_comment
+			parser.head_comment = nil
+		}
+
 		*event = yaml_event_t{
 			typ:               yaml_DOCUMENT_START_EVENT,
 			start_mark:        start_mark,
@@ -337,7 +365,10 @@ func yaml_parser_parse_document_start(parser *yaml_parser_t, event *yaml_event_t
 			version_directive: version_directive,
 			tag_directives:    tag_directives,
 			implicit:          false,
+			head_comment:      head_comment,
+			line_comment:      parser.line_comment,
 		}
+		parser.line_comment = nil
 		skip_token(parser)
 
 	} else {
@@ -407,7 +438,15 @@ func yaml_parser_parse_document_end(parser *yaml_parser_t, event *yaml_event_t)
 		end_mark:   end_mark,
 		implicit:   implicit,
 	}
+	// [Go] The line comment of the start indicator of the next document
+	//      belongs to that document.
+	var line_comment []byte
+	if token.typ == yaml_DOCUMENT_START_TOKEN {
+		line_comment = parser.line_comment
+		parser.line_comment = nil
+	}
 	yaml_parser_set_event_comments(parser, event)
+	parser.line_comment = line_comment
 	if len(event.head_comment) > 0 && len(event.foot_comment) == 0 {
 		event.foot_comment = event.head_comment
 		event.head_comment = nil
@@ -582,6 +621,8 @@ func yaml_parser_parse_node(parser *yaml_parser_t, event *yaml_event_t, block, i
 			implicit:        plain_implicit,
 			quoted_implicit: quoted_implicit,
 			style:           yaml_style_t(token.style),
+			chomping:        token.chomping,
+			increment:       token.increment,
 		}
 		yaml_parser_set_event_comments(parser, event)
 		skip_token(parser)
@@ -601,6 +642,7 @@ func yaml_parser_parse_node(parser *yaml_parser_t, event *yaml_event_t, block, i
 			style:      yaml_style_t(yaml_FLOW_SEQUENCE_STYLE),
 		}
 		yaml_parser_set_event_comments(parser, event)
+		event.open_comment, event.line_comment = event.line_comment, nil
 		return true
 	}
 	if token.typ == yaml_FLOW_MAPPING_START_TOKEN {
@@ -616,6 +658,7 @@ func yaml_parser_parse_node(parser *yaml_parser_t, event *yaml_event_t, block, i
 			style:      yaml_style_t(yaml_FLOW_MAPPING_STYLE),
 		}
 		yaml_parser_set_event_comments(parser, event)
+		event.open_comment, event.line_comment = event.line_comment, nil
 		return true
 	}
 	if block && token.typ == yaml_BLOCK_SEQUENCE_START_TOKEN {
@@ -929,6 +972,10 @@ func yaml_parser_parse_flow_sequence_entry(parser *yaml_parser_t, event *yaml_ev
 	if token == nil {
 		return false
 	}
+	if first && token.typ != yaml_FLOW_SEQUENCE_END_TOKEN {
+		// [Go] Comments following the start indicator only close empty collections.
+		parser.close_comment = nil
+	}
 	if token.typ != yaml_FLOW_SEQUENCE_END_TOKEN {
 		if !first {
 			if token.typ == yaml_FLOW_ENTRY_TOKEN {
@@ -973,6 +1020,8 @@ func yaml_parser_parse_flow_sequence_entry(parser *yaml_parser_t, event *yaml_ev
 		end_mark:   token.end_mark,
 	}
 	yaml_parser_set_event_comments(parser, event)
+	event.close_comment = parser.close_comment
+	parser.close_comment = nil
 
 	skip_token(parser)
 	return true
@@ -1066,6 +1115,10 @@ func yaml_parser_parse_flow_mapping_key(parser *yaml_parser_t, event *yaml_event
 		return false
 	}
 
+	if first && token.typ != yaml_FLOW_MAPPING_END_TOKEN {
+		// [Go] Comments following the start indicator only close empty collections.
+		parser.close_comment = nil
+	}
 	if token.typ != yaml_FLOW_MAPPING_END_TOKEN {
 		if !first {
 			if token.typ == yaml_FLOW_ENTRY_TOKEN {
@@ -1113,6 +1166,8 @@ func yaml_parser_parse_flow_mapping_key(parser *yaml_parser_t, event *yaml_event
 		end_mark:   token.end_mark,
 	}
 	yaml_parser_set_event_comments(parser, event)
+	event.close_comment = parser.close_comment
+	parser.close_comment = nil
 	skip_token(parser)
 	return true
 }
@@ -1183,7 +1238,7 @@ func yaml_parser_process_directives(parser *yaml_parser_t,
 					"found duplicate %YAML directive", token.start_mark)
 				return false
 			}
-			if token.major != 1 || token.minor != 1 {
+			if token.major != 1 || (token.minor != 1 && token.minor != 2) {
 				yaml_parser_set_parser_error(parser,
 					"found incompatible YAML document", token.start_mark)
 				return false
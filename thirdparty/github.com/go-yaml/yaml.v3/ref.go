@@ -0,0 +1,260 @@
+//
+// Copyright (c) 2011-2019 Canonical Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RefOptions holds the settings used by ResolveRefs.
+type RefOptions struct {
+	// Match returns the reference held by n, and whether n is a
+	// reference at all. If nil, MatchRefKey("$ref") and MatchRefTag("!ref")
+	// are both tried.
+	Match func(n *Node) (ref string, ok bool)
+
+	// Load returns the document with the given name, for references
+	// such as "common.yaml#/definitions/name" that point into other
+	// documents. Each document is loaded once, and the references it
+	// holds are resolved too. If nil, only references within the
+	// resolved document are allowed.
+	Load func(name string) (*Node, error)
+}
+
+// MatchRefKey returns a RefOptions.Match function for references written
+// as a mapping with key as its only key, such as {$ref: "#/a/b"} in JSON
+// Schema and OpenAPI documents.
+func MatchRefKey(key string) func(n *Node) (string, bool) {
+	return func(n *Node) (string, bool) {
+		if n.Kind != MappingNode || len(n.Content) != 2 {
+			return "", false
+		}
+		k, v := n.Content[0], n.Content[1]
+		if k.Kind != ScalarNode || k.Value != key || v.Kind != ScalarNode || v.ShortTag() != strTag {
+			return "", false
+		}
+		return v.Value, true
+	}
+}
+
+// MatchRefTag returns a RefOptions.Match function for references written
+// as scalars with the given tag, such as !ref "#/a/b".
+func MatchRefTag(tag string) func(n *Node) (string, bool) {
+	tag = shortTag(tag)
+	return func(n *Node) (string, bool) {
+		if n.Kind != ScalarNode || n.ShortTag() != tag {
+			return "", false
+		}
+		return n.Value, true
+	}
+}
+
+// ResolveRefs replaces in place each reference found in doc with a copy
+// of the value it points to, so that YAML bundles may be composed as
+// OpenAPI documents are. A reference is made of an optional document
+// name, as passed to opts.Load, and an optional fragment holding a JSON
+// Pointer (RFC 6901) to a value in that document, such as
+// "#/components/schemas/Pet" or "common.yaml#/definitions/0". Aliases in
+// the copied values are replaced with copies of their anchored values,
+// and the copies don't keep anchors. Cycles of references are reported
+// as errors.
+func ResolveRefs(doc *Node, opts RefOptions) error {
+	r := &refResolver{
+		opts:  opts,
+		docs:  map[string]*Node{"": doc},
+		state: make(map[*Node]refState),
+	}
+	if r.opts.Match == nil {
+		key, tag := MatchRefKey("$ref"), MatchRefTag("!ref")
+		r.opts.Match = func(n *Node) (string, bool) {
+			if ref, ok := key(n); ok {
+				return ref, true
+			}
+			return tag(n)
+		}
+	}
+	return r.resolve(doc, "")
+}
+
+type refState int
+
+const (
+	refResolving refState = iota + 1
+	refResolved
+)
+
+type refResolver struct {
+	opts  RefOptions
+	docs  map[string]*Node
+	state map[*Node]refState
+}
+
+// resolve replaces the references within n, which is in the named
+// document.
+func (r *refResolver) resolve(n *Node, name string) error {
+	switch r.state[n] {
+	case refResolved:
+		return nil
+	case refResolving:
+		return errors.New("reference cycle")
+	}
+	r.state[n] = refResolving
+	for i, child := range n.Content {
+		ref, ok := r.opts.Match(child)
+		if !ok {
+			if err := r.resolve(child, name); err != nil {
+				return err
+			}
+			continue
+		}
+		// References to references are followed.
+		target, targetName, err := r.find(ref, name)
+		for seen := map[*Node]bool{child: true}; err == nil; {
+			next, ok := r.opts.Match(target)
+			if !ok {
+				break
+			}
+			if seen[target] {
+				err = errors.New("reference cycle")
+				break
+			}
+			seen[target] = true
+			target, targetName, err = r.find(next, targetName)
+		}
+		if err == nil {
+			err = r.resolve(target, targetName)
+		}
+		var value *Node
+		if err == nil {
+			value, err = copyRefValue(target, make(map[*Node]bool))
+		}
+		if err != nil {
+			if strings.HasPrefix(err.Error(), "yaml: ") {
+				return err
+			}
+			prefix := ""
+			if name != "" {
+				prefix = name + ": "
+			}
+			return fmt.Errorf("yaml: %sline %d: cannot resolve reference %q: %v", prefix, child.Line, ref, err)
+		}
+		// The reference keeps its place and comments.
+		value.HeadComment = child.HeadComment
+		value.LineComment = child.LineComment
+		value.FootComment = child.FootComment
+		value.Line, value.Column = child.Line, child.Column
+		if n.Kind == MappingNode && i%2 == 1 && value.Style&FlowStyle == 0 && (value.Kind == MappingNode || value.Kind == SequenceNode) {
+			// Line comments of block collections are kept by their key.
+			if key := n.Content[i-1]; key.LineComment == "" {
+				key.LineComment, value.LineComment = value.LineComment, ""
+			}
+		}
+		*child = *value
+	}
+	r.state[n] = refResolved
+	return nil
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// find returns the value that ref points to from the named document,
+// and the name of the document holding it.
+func (r *refResolver) find(ref, name string) (*Node, string, error) {
+	docName, pointer := ref, ""
+	if i := strings.IndexByte(ref, '#'); i >= 0 {
+		docName, pointer = ref[:i], ref[i+1:]
+	}
+	if docName == "" {
+		docName = name
+	}
+	doc, ok := r.docs[docName]
+	if !ok {
+		if r.opts.Load == nil {
+			return nil, "", errors.New("no loader for other documents")
+		}
+		var err error
+		if doc, err = r.opts.Load(docName); err != nil {
+			return nil, "", err
+		}
+		if doc == nil {
+			return nil, "", fmt.Errorf("document %q is empty", docName)
+		}
+		r.docs[docName] = doc
+	}
+	n := doc
+	if n.Kind == DocumentNode && len(n.Content) == 1 {
+		n = n.Content[0]
+	}
+	if pointer == "" {
+		return n, docName, nil
+	}
+	if pointer[0] != '/' {
+		return nil, "", fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = pointerUnescaper.Replace(token)
+		for n.Kind == AliasNode {
+			n = n.Alias
+		}
+		var next *Node
+		switch n.Kind {
+		case MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Kind == ScalarNode && n.Content[i].Value == token {
+					next = n.Content[i+1]
+					break
+				}
+			}
+		case SequenceNode:
+			if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(n.Content) {
+				next = n.Content[i]
+			}
+		}
+		if next == nil {
+			return nil, "", fmt.Errorf("no value at %q", pointer)
+		}
+		n = next
+	}
+	return n, docName, nil
+}
+
+// copyRefValue returns a deep copy of n without anchors, with its
+// aliases replaced by copies of the values they refer to.
+func copyRefValue(n *Node, visiting map[*Node]bool) (*Node, error) {
+	if n.Kind == AliasNode {
+		if visiting[n.Alias] {
+			return nil, fmt.Errorf("anchor %q value contains itself", n.Value)
+		}
+		n = n.Alias
+	}
+	visiting[n] = true
+	defer delete(visiting, n)
+	c := *n
+	c.Anchor = ""
+	if n.Content != nil {
+		c.Content = make([]*Node, len(n.Content))
+		for i, child := range n.Content {
+			var err error
+			if c.Content[i], err = copyRefValue(child, visiting); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &c, nil
+}
@@ -0,0 +1,79 @@
+package yaml_test
+
+import (
+	"errors"
+
+	. "gopkg.in/check.v1"
+	"sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+func (s *S) TestResolveRefs(c *C) {
+	files := map[string]string{
+		"common.yaml": "definitions:\n  - &id {type: integer}\n  - {$ref: '#/names/a~1b'}\nnames:\n  a/b: {type: string}\n  ref: *id\n",
+	}
+	load := func(name string) (*yaml.Node, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, errors.New("not found")
+		}
+		var doc yaml.Node
+		err := yaml.Unmarshal([]byte(data), &doc)
+		return &doc, err
+	}
+	data := `schemas:
+  Pet:
+    id: {$ref: "common.yaml#/names/ref"}
+    name: {$ref: "common.yaml#/definitions/1"}
+  Owner:
+    pet: !ref "#/schemas/Pet" # the pet
+    kind: {$ref: extra, note: not a reference}
+`
+	var doc yaml.Node
+	c.Assert(yaml.Unmarshal([]byte(data), &doc), IsNil)
+	c.Assert(yaml.ResolveRefs(&doc, yaml.RefOptions{Load: load}), IsNil)
+	out, err := yaml.Marshal(&doc)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, `schemas:
+    Pet:
+        id: {type: integer}
+        name: {type: string}
+    Owner:
+        pet: # the pet
+            id: {type: integer}
+            name: {type: string}
+        kind: {$ref: extra, note: not a reference}
+`)
+}
+
+func (s *S) TestResolveRefsMatch(c *C) {
+	var doc yaml.Node
+	c.Assert(yaml.Unmarshal([]byte("a: 1\nb: {use: /a}\n"), &doc), IsNil)
+	match := yaml.MatchRefKey("use")
+	err := yaml.ResolveRefs(&doc, yaml.RefOptions{Match: func(n *yaml.Node) (string, bool) {
+		ref, ok := match(n)
+		return "#" + ref, ok
+	}})
+	c.Assert(err, IsNil)
+	out, err := yaml.Marshal(&doc)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Equals, "a: 1\nb: 1\n")
+}
+
+var resolveRefsErrorTests = []struct {
+	data, error string
+}{
+	{"a: !ref '#/b'\n", `yaml: line 1: cannot resolve reference "#/b": no value at "/b"`},
+	{"a: !ref 'b'\n", `yaml: line 1: cannot resolve reference "b": no loader for other documents`},
+	{"a: !ref '#b'\n", `yaml: line 1: cannot resolve reference "#b": invalid JSON pointer "b"`},
+	{"a:\n  b: !ref '#/a'\n", `yaml: line 2: cannot resolve reference "#/a": reference cycle`},
+	{"a: !ref '#/c'\nb: !ref '#/a'\nc: !ref '#/b'\n", `yaml: line 1: cannot resolve reference "#/c": reference cycle`},
+	{"a: !ref '#/b'\nb: !ref '#/a'\n", `yaml: line 1: cannot resolve reference "#/b": reference cycle`},
+}
+
+func (s *S) TestResolveRefsErrors(c *C) {
+	for _, item := range resolveRefsErrorTests {
+		var doc yaml.Node
+		c.Assert(yaml.Unmarshal([]byte(item.data), &doc), IsNil)
+		c.Assert(yaml.ResolveRefs(&doc, yaml.RefOptions{}), ErrorMatches, item.error, Commentf("data: %q", item.data))
+	}
+}
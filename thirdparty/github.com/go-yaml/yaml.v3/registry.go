@@ -0,0 +1,102 @@
+//
+// Copyright (c) 2011-2019 Canonical Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"reflect"
+)
+
+// A TypeRegistry maps the tags of nodes, and the values held by a
+// discriminator key of mappings, to the Go types they are decoded into
+// when decoding into interface values, so that heterogeneous sequences,
+// such as a list of Kubernetes objects with "kind: Deployment" or
+// "kind: Service", may be decoded into typed values directly.
+// See Decoder.SetTypeRegistry.
+type TypeRegistry struct {
+	key    string
+	tags   map[string]func() interface{}
+	values map[string]func() interface{}
+}
+
+// NewTypeRegistry returns an empty registry whose discriminator is the
+// mapping key key, such as "kind", or which only matches tags if key is
+// empty.
+func NewTypeRegistry(key string) *TypeRegistry {
+	return &TypeRegistry{
+		key:    key,
+		tags:   make(map[string]func() interface{}),
+		values: make(map[string]func() interface{}),
+	}
+}
+
+// RegisterTag makes the nodes with the tag, such as "!Deployment", be
+// decoded into the value returned by factory, which must be a non-nil
+// pointer such as &Deployment{}. The pointer, or the value it points to
+// if the pointer doesn't fit, is stored into the decoded interface value.
+// Tags take precedence over the discriminator key.
+func (r *TypeRegistry) RegisterTag(tag string, factory func() interface{}) {
+	r.tags[shortTag(tag)] = factory
+}
+
+// Register makes the mappings whose discriminator key holds value be
+// decoded into the value returned by factory, as RegisterTag does.
+func (r *TypeRegistry) Register(value string, factory func() interface{}) {
+	r.values[value] = factory
+}
+
+// factory returns the factory registered for n, if any, reading the
+// discriminator from key instead of the key of the registry if set.
+func (r *TypeRegistry) factory(n *Node, key string) func() interface{} {
+	if f, ok := r.tags[n.ShortTag()]; ok {
+		return f
+	}
+	if key == "" {
+		key = r.key
+	}
+	if key == "" || n.Kind != MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if k.Kind == ScalarNode && k.Value == key && v.Kind == ScalarNode {
+			return r.values[v.Value]
+		}
+	}
+	return nil
+}
+
+// registered decodes n into the value made by factory, and stores it
+// into the interface value out.
+func (d *decoder) registered(n *Node, factory func() interface{}, out reflect.Value) bool {
+	made := factory()
+	v := reflect.ValueOf(made)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		failf("line %d: type registry factory returned %#v, not a non-nil pointer", n.Line, made)
+	}
+	if !d.unmarshal(n, v.Elem()) {
+		return false
+	}
+	switch {
+	case v.Type().AssignableTo(out.Type()):
+		out.Set(v)
+	case v.Elem().Type().AssignableTo(out.Type()):
+		out.Set(v.Elem())
+	default:
+		d.terror(n, "", out)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+package yaml_test
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+type registryObject interface {
+	ObjectName() string
+}
+
+type registryDeployment struct {
+	Kind     string
+	Name     string
+	Replicas int
+}
+
+func (d *registryDeployment) ObjectName() string { return d.Name }
+
+type registryService struct {
+	Kind  string
+	Name  string
+	Ports []int
+}
+
+func (s *registryService) ObjectName() string { return s.Name }
+
+type registryNote struct {
+	Text string
+}
+
+func (s *S) TestTypeRegistry(c *C) {
+	r := yaml.NewTypeRegistry("kind")
+	r.Register("Deployment", func() interface{} { return &registryDeployment{} })
+	r.Register("Service", func() interface{} { return &registryService{} })
+	r.RegisterTag("!note", func() interface{} { return &registryNote{} })
+
+	data := `
+- kind: Deployment
+  name: web
+  replicas: 3
+- kind: Service
+  name: web
+  ports: [80]
+- !note {text: hello}
+- kind: Other
+  name: x
+`
+	var v []interface{}
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.SetTypeRegistry(r)
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(v, DeepEquals, []interface{}{
+		&registryDeployment{Kind: "Deployment", Name: "web", Replicas: 3},
+		&registryService{Kind: "Service", Name: "web", Ports: []int{80}},
+		&registryNote{Text: "hello"},
+		map[string]interface{}{"kind": "Other", "name": "x"},
+	})
+
+	// Values are stored into interface types they implement.
+	var objects []registryObject
+	var node yaml.Node
+	c.Assert(yaml.Unmarshal([]byte(data), &node), IsNil)
+	node.Content[0].Content = node.Content[0].Content[:2]
+	c.Assert(node.DecodeWithOptions(&objects, yaml.WithTypeRegistry(r)), IsNil)
+	c.Assert(objects, HasLen, 2)
+	c.Assert(objects[0].ObjectName(), Equals, "web")
+	c.Assert(objects[1], DeepEquals, &registryService{Kind: "Service", Name: "web", Ports: []int{80}})
+
+	// Types that don't fit are reported.
+	var notes []registryObject
+	c.Assert(yaml.Unmarshal([]byte("- !note {text: a}\n"), &node), IsNil)
+	err := node.DecodeWithOptions(&notes, yaml.WithTypeRegistry(r))
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n  line 1: cannot unmarshal !note `` into yaml_test.registryObject")
+}
+
+type unionSource interface {
+	isSource()
+}
+
+type unionFileSource struct {
+	Type string
+	Path string
+}
+
+func (*unionFileSource) isSource() {}
+
+type unionHTTPSource struct {
+	Type string
+	Kind string
+	URL  string
+}
+
+func (*unionHTTPSource) isSource() {}
+
+func (s *S) TestDecoderRegisterUnion(c *C) {
+	sources := yaml.NewTypeRegistry("type")
+	sources.Register("file", func() interface{} { return &unionFileSource{} })
+	sources.Register("http", func() interface{} { return &unionHTTPSource{} })
+
+	var config struct {
+		Main    unionSource
+		Sources map[string]unionSource
+		Backup  unionSource   `yaml:"backup,discriminator=kind"`
+		Other   []interface{} `yaml:"other"`
+	}
+	data := `
+main: {type: file, path: /etc/app.yaml}
+sources:
+  remote: {type: http, url: "https://example.com"}
+backup: {type: ignored, kind: http, url: "https://backup.example.com"}
+other:
+- {type: file, path: /tmp}
+`
+	dec := yaml.NewDecoder(strings.NewReader(data))
+	dec.RegisterUnion((*unionSource)(nil), sources)
+	c.Assert(dec.Decode(&config), IsNil)
+	c.Assert(config.Main, DeepEquals, &unionFileSource{Type: "file", Path: "/etc/app.yaml"})
+	c.Assert(config.Sources["remote"], DeepEquals, &unionHTTPSource{Type: "http", URL: "https://example.com"})
+	c.Assert(config.Backup, DeepEquals, &unionHTTPSource{Type: "ignored", Kind: "http", URL: "https://backup.example.com"})
+	// Unions only apply to their interface type.
+	c.Assert(config.Other, DeepEquals, []interface{}{map[string]interface{}{"type": "file", "path": "/tmp"}})
+
+	// Unknown members are reported.
+	dec = yaml.NewDecoder(strings.NewReader("main: {type: ftp}\n"))
+	dec.RegisterUnion((*unionSource)(nil), sources)
+	err := dec.Decode(&config)
+	c.Assert(err, ErrorMatches, "yaml: unmarshal errors:\n  line 1: cannot unmarshal !!map into yaml_test.unionSource")
+
+	c.Assert(func() { dec.RegisterUnion(unionFileSource{}, sources) }, PanicMatches, "yaml: RegisterUnion needs a pointer to an interface type.*")
+}
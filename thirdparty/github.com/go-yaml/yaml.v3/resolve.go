@@ -78,13 +78,16 @@ const (
 	mapTag       = "!!map"
 	binaryTag    = "!!binary"
 	mergeTag     = "!!merge"
+	setTag       = "!!set"
+	omapTag      = "!!omap"
+	pairsTag     = "!!pairs"
 )
 
 var longTags = make(map[string]string)
 var shortTags = make(map[string]string)
 
 func init() {
-	for _, stag := range []string{nullTag, boolTag, strTag, intTag, floatTag, timestampTag, seqTag, mapTag, binaryTag, mergeTag} {
+	for _, stag := range []string{nullTag, boolTag, strTag, intTag, floatTag, timestampTag, seqTag, mapTag, binaryTag, mergeTag, setTag, omapTag, pairsTag} {
 		ltag := longTag(stag)
 		longTags[stag] = ltag
 		shortTags[ltag] = stag
@@ -264,10 +267,251 @@ func resolve(tag string, in string) (rtag string, out interface{}) {
 	return strTag, in
 }
 
+var (
+	jsonIntPattern   = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+	jsonFloatPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]*)?([eE][-+]?[0-9]+)?$`)
+	coreIntPattern   = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	coreOctPattern   = regexp.MustCompile(`^0o[0-7]+$`)
+	coreHexPattern   = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+)
+
+// resolvePlain returns the tag and value of the untagged plain scalar in
+// as resolved with the schema. It reports false if the schema defines no
+// tag for the scalar, which only happens with the JSON schema.
+func (s Schema) resolvePlain(in string) (tag string, out interface{}, ok bool) {
+	switch s {
+	case FailsafeSchema:
+		return strTag, in, true
+
+	case JSONSchema:
+		switch in {
+		case "null":
+			return nullTag, nil, true
+		case "true":
+			return boolTag, true, true
+		case "false":
+			return boolTag, false, true
+		}
+		if jsonIntPattern.MatchString(in) {
+			if v, ok := parseSchemaInt(in, 10); ok {
+				return intTag, v, true
+			}
+		}
+		if jsonFloatPattern.MatchString(in) {
+			if v, err := strconv.ParseFloat(in, 64); err == nil {
+				return floatTag, v, true
+			}
+		}
+		return "", nil, false
+
+	case CoreSchema:
+		switch in {
+		case "", "~", "null", "Null", "NULL":
+			return nullTag, nil, true
+		case "true", "True", "TRUE":
+			return boolTag, true, true
+		case "false", "False", "FALSE":
+			return boolTag, false, true
+		case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+			return floatTag, math.Inf(+1), true
+		case "-.inf", "-.Inf", "-.INF":
+			return floatTag, math.Inf(-1), true
+		case ".nan", ".NaN", ".NAN":
+			return floatTag, math.NaN(), true
+		case "<<":
+			return mergeTag, in, true
+		}
+		var v interface{}
+		var ok bool
+		switch {
+		case coreIntPattern.MatchString(in):
+			v, ok = parseSchemaInt(in, 10)
+		case coreOctPattern.MatchString(in):
+			v, ok = parseSchemaInt(in[2:], 8)
+		case coreHexPattern.MatchString(in):
+			v, ok = parseSchemaInt(in[2:], 16)
+		}
+		if ok {
+			return intTag, v, true
+		}
+		if yamlStyleFloat.MatchString(in) {
+			if v, err := strconv.ParseFloat(in, 64); err == nil {
+				return floatTag, v, true
+			}
+		}
+		return strTag, in, true
+	}
+	tag, out = resolve("", in)
+	return tag, out, true
+}
+
+// resolve is like the resolve function, except that scalars which are
+// either untagged or tagged as the schema resolves them are resolved
+// with the schema.
+func (s Schema) resolve(tag string, in string) (rtag string, out interface{}) {
+	if s == DefaultSchema {
+		return resolve(tag, in)
+	}
+	rtag, out, ok := s.resolvePlain(in)
+	if ok && (tag == "" || shortTag(tag) == rtag) {
+		return rtag, out
+	}
+	if tag == "" {
+		failf("cannot resolve plain scalar `%s` with the JSON schema", in)
+	}
+	return resolve(tag, in)
+}
+
+// parseSchemaInt parses the digits in s, written in the provided base,
+// into the same types that resolve produces for integers.
+func parseSchemaInt(s string, base int) (interface{}, bool) {
+	if intv, err := strconv.ParseInt(s, base, 64); err == nil {
+		if intv == int64(int(intv)) {
+			return int(intv), true
+		}
+		return intv, true
+	}
+	if uintv, err := strconv.ParseUint(s, base, 64); err == nil {
+		return uintv, true
+	}
+	return nil, false
+}
+
+// numericLexeme returns in if it holds an int or float of the given tag
+// written differently from the canonical rendering of its value, or an
+// empty string otherwise.
+func numericLexeme(tag, in string) string {
+	switch tag {
+	case intTag:
+		if !isDecimalInt(in) {
+			return in
+		}
+	case floatTag:
+		_, v := resolve(floatTag, in)
+		if f, ok := v.(float64); ok && !math.IsInf(f, 0) && !math.IsNaN(f) && strconv.FormatFloat(f, 'g', -1, 64) != in {
+			return in
+		}
+	}
+	return ""
+}
+
+// sameNumber returns whether a and b hold plain int or float scalars
+// that resolve to the same number.
+func sameNumber(a, b string) bool {
+	af, aok := resolvedFloat(a)
+	bf, bok := resolvedFloat(b)
+	return aok && bok && af == bf
+}
+
+func resolvedFloat(in string) (float64, bool) {
+	_, out := resolve("", in)
+	switch v := out.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// isDecimalInt returns whether s is an integer in plain decimal form, with
+// no base prefix, plus sign, leading zeros or digit separators.
+func isDecimalInt(s string) bool {
+	if strings.HasPrefix(s, "-") {
+		s = s[1:]
+	}
+	if s == "" || len(s) > 1 && s[0] == '0' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// formatIntLexeme renders the decimal integer in value using the sign,
+// base prefix, digit case, zero padding and digit grouping found in lexeme.
+// It reports false if value cannot be rendered that way.
+func formatIntLexeme(value, lexeme string) (string, bool) {
+	if !isDecimalInt(value) {
+		return "", false
+	}
+	neg := strings.HasPrefix(value, "-")
+	u, err := strconv.ParseUint(strings.TrimPrefix(value, "-"), 10, 64)
+	if err != nil {
+		return "", false
+	}
+	var sign string
+	if neg {
+		sign = "-"
+	} else if strings.HasPrefix(lexeme, "+") {
+		sign = "+"
+	}
+	rest := strings.TrimLeft(lexeme, "+-")
+	base, prefix := 10, ""
+	switch lower := strings.ToLower(rest); {
+	case strings.HasPrefix(lower, "0x"):
+		base, prefix = 16, rest[:2]
+	case strings.HasPrefix(lower, "0o"):
+		base, prefix = 8, rest[:2]
+	case strings.HasPrefix(lower, "0b"):
+		base, prefix = 2, rest[:2]
+	case len(rest) > 1 && rest[0] == '0' && rest[1] >= '0' && rest[1] <= '7':
+		base, prefix = 8, "0"
+	}
+	digits := rest[len(prefix):]
+	group := 0
+	if i := strings.LastIndex(digits, "_"); i >= 0 {
+		group = len(digits) - i - 1
+	}
+	plain := strings.Replace(digits, "_", "", -1)
+
+	out := strconv.FormatUint(u, base)
+	if plain != strings.ToLower(plain) {
+		out = strings.ToUpper(out)
+	}
+	if prefix != "0" && len(plain) > len(out) && strings.HasPrefix(plain, "0") {
+		out = strings.Repeat("0", len(plain)-len(out)) + out
+	}
+	if group > 0 {
+		var grouped []byte
+		for i := 0; i < len(out); i++ {
+			if i > 0 && (len(out)-i)%group == 0 {
+				grouped = append(grouped, '_')
+			}
+			grouped = append(grouped, out[i])
+		}
+		out = string(grouped)
+	}
+	out = sign + prefix + out
+	if rtag, _ := resolve("", out); rtag != intTag {
+		return "", false
+	}
+	return out, true
+}
+
+// defaultBase64Width is the length of the lines of base64 text written
+// for binary values by default.
+const defaultBase64Width = 70
+
 // encodeBase64 encodes s as base64 that is broken up into multiple lines
 // as appropriate for the resulting length.
 func encodeBase64(s string) string {
-	const lineLen = 70
+	return encodeBase64Width(s, defaultBase64Width)
+}
+
+// encodeBase64Width is like encodeBase64, with lines of lineLen
+// characters, or a single line if lineLen isn't positive.
+func encodeBase64Width(s string, lineLen int) string {
+	if lineLen <= 0 {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
 	encLen := base64.StdEncoding.EncodedLen(len(s))
 	lines := encLen/lineLen + 1
 	buf := make([]byte, encLen*2+lines)
@@ -295,9 +539,30 @@ var allowedTimestampFormats = []string{
 	"2006-1-2T15:4:5.999999999Z07:00", // RCF3339Nano with short date fields.
 	"2006-1-2t15:4:5.999999999Z07:00", // RFC3339Nano with short date fields and lower-case "t".
 	"2006-1-2 15:4:5.999999999",       // space separated with no time zone
+	"2006-1-2 15:4:5.999999999Z07:00", // space separated with time zone
+	"2006-1-2 15:4:5.999999999Z07",    // space separated with hour-only time zone
 	"2006-1-2",                        // date only
-	// Notable exception: time.Parse cannot handle: "2001-12-14 21:59:43.10 -5"
-	// from the set of examples.
+}
+
+// normalizeTimestamp rewrites the space separated timestamp forms allowed
+// by the YAML timestamp type into ones handled by time.Parse: whitespace
+// between the date and the time becomes a single space, whitespace before
+// the time zone is dropped, and single digit zone hours are zero padded,
+// so that "2001-12-14 \t21:59:43.10 -5" becomes "2001-12-14 21:59:43.10-05".
+func normalizeTimestamp(s string) string {
+	i := strings.IndexAny(s, " \t")
+	if i < 0 {
+		return s
+	}
+	date, clock := s[:i], strings.TrimLeft(s[i:], " \t")
+	zone := ""
+	if j := strings.IndexAny(clock, " \tZ+-"); j >= 0 {
+		clock, zone = clock[:j], strings.TrimLeft(clock[j:], " \t")
+	}
+	if len(zone) == 2 && (zone[0] == '+' || zone[0] == '-') || len(zone) > 2 && zone[2] == ':' {
+		zone = zone[:1] + "0" + zone[1:]
+	}
+	return date + " " + clock + zone
 }
 
 // parseTimestamp parses s as a timestamp string and
@@ -317,6 +582,7 @@ func parseTimestamp(s string) (time.Time, bool) {
 	if i != 4 || i == len(s) || s[i] != '-' {
 		return time.Time{}, false
 	}
+	s = normalizeTimestamp(s)
 	for _, format := range allowedTimestampFormats {
 		if t, err := time.Parse(format, s); err == nil {
 			return t, true
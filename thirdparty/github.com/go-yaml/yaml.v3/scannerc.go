@@ -527,14 +527,23 @@ func skip_line(parser *yaml_parser_t) {
 		parser.mark.line++
 		parser.unread -= 2
 		parser.buffer_pos += 2
-		parser.newlines++
+		count_newline(parser)
 	} else if is_break(parser.buffer, parser.buffer_pos) {
 		parser.mark.index++
 		parser.mark.column = 0
 		parser.mark.line++
 		parser.unread--
 		parser.buffer_pos += width(parser.buffer[parser.buffer_pos])
-		parser.newlines++
+		count_newline(parser)
+	}
+}
+
+// Count a consumed line break, recording the line it ended if that line
+// had no content.
+func count_newline(parser *yaml_parser_t) {
+	parser.newlines++
+	if parser.newlines > 1 {
+		parser.empty_lines = append(parser.empty_lines, parser.mark.line-1)
 	}
 }
 
@@ -594,7 +603,7 @@ func read_line(parser *yaml_parser_t, s []byte) []byte {
 	parser.mark.column = 0
 	parser.mark.line++
 	parser.unread--
-	parser.newlines++
+	count_newline(parser)
 	return s
 }
 
@@ -765,6 +774,11 @@ func yaml_parser_fetch_next_token(parser *yaml_parser_t) (ok bool) {
 		return yaml_parser_fetch_flow_collection_start(parser, yaml_FLOW_SEQUENCE_START_TOKEN)
 	}
 
+	// [Go] Is it a template action starting a plain scalar?
+	if is_template_start(parser) {
+		return yaml_parser_fetch_plain_scalar(parser)
+	}
+
 	// Is it the flow mapping start indicator?
 	if parser.buffer[parser.buffer_pos] == '{' {
 		return yaml_parser_fetch_flow_collection_start(parser, yaml_FLOW_MAPPING_START_TOKEN)
@@ -952,9 +966,24 @@ func yaml_parser_remove_simple_key(parser *yaml_parser_t) bool {
 	return true
 }
 
-// max_flow_level limits the flow_level
+// max_flow_level limits the flow_level by default
 const max_flow_level = 10000
 
+// Return the maximum flow level or indents stack size allowed.
+func yaml_parser_max_depth(parser *yaml_parser_t, def int) int {
+	if parser.max_depth > 0 {
+		return parser.max_depth
+	}
+	return def
+}
+
+// Set a scanner error for exceeding the maximum nesting depth.
+func yaml_parser_set_depth_error(parser *yaml_parser_t, context string, context_mark yaml_mark_t, max_depth int) bool {
+	parser.depth_exceeded = max_depth
+	return yaml_parser_set_scanner_error(parser, context, context_mark,
+		fmt.Sprintf("exceeded max depth of %d", max_depth))
+}
+
 // Increase the flow level and resize the simple key list if needed.
 func yaml_parser_increase_flow_level(parser *yaml_parser_t) bool {
 	// Reset the simple key on the next level.
@@ -967,10 +996,9 @@ func yaml_parser_increase_flow_level(parser *yaml_parser_t) bool {
 
 	// Increase the flow level.
 	parser.flow_level++
-	if parser.flow_level > max_flow_level {
-		return yaml_parser_set_scanner_error(parser,
-			"while increasing flow level", parser.simple_keys[len(parser.simple_keys)-1].mark,
-			fmt.Sprintf("exceeded max depth of %d", max_flow_level))
+	if max_depth := yaml_parser_max_depth(parser, max_flow_level); parser.flow_level > max_depth {
+		return yaml_parser_set_depth_error(parser,
+			"while increasing flow level", parser.simple_keys[len(parser.simple_keys)-1].mark, max_depth)
 	}
 	return true
 }
@@ -986,7 +1014,7 @@ func yaml_parser_decrease_flow_level(parser *yaml_parser_t) bool {
 	return true
 }
 
-// max_indents limits the indents stack size
+// max_indents limits the indents stack size by default
 const max_indents = 10000
 
 // Push the current indentation level to the stack and set the new level
@@ -1003,10 +1031,9 @@ func yaml_parser_roll_indent(parser *yaml_parser_t, column, number int, typ yaml
 		// indentation level.
 		parser.indents = append(parser.indents, parser.indent)
 		parser.indent = column
-		if len(parser.indents) > max_indents {
-			return yaml_parser_set_scanner_error(parser,
-				"while increasing indent level", parser.simple_keys[len(parser.simple_keys)-1].mark,
-				fmt.Sprintf("exceeded max depth of %d", max_indents))
+		if max_depth := yaml_parser_max_depth(parser, max_indents); len(parser.indents) > max_depth {
+			return yaml_parser_set_depth_error(parser,
+				"while increasing indent level", mark, max_depth)
 		}
 
 		// Create a token and insert it into the queue.
@@ -1193,6 +1220,12 @@ func yaml_parser_fetch_document_indicator(parser *yaml_parser_t, typ yaml_token_
 	}
 	// Append the token to the queue.
 	yaml_insert_token(parser, -1, &token)
+
+	// [Go] A comment following the document start indicator on its line,
+	//      as in "--- # source: a.yaml", belongs to the document.
+	if typ == yaml_DOCUMENT_START_TOKEN {
+		return yaml_parser_scan_line_comment(parser, start_mark)
+	}
 	return true
 }
 
@@ -2369,6 +2402,8 @@ func yaml_parser_scan_block_scalar(parser *yaml_parser_t, token *yaml_token_t, l
 		end_mark:   end_mark,
 		value:      s,
 		style:      yaml_LITERAL_SCALAR_STYLE,
+		chomping:   int8(chomping),
+		increment:  int8(increment),
 	}
 	if !literal {
 		token.style = yaml_FOLDED_SCALAR_STYLE
@@ -2687,6 +2722,33 @@ func yaml_parser_scan_flow_scalar(parser *yaml_parser_t, token *yaml_token_t, si
 	return true
 }
 
+// [Go] Check if a template action such as {{ .Values.name }} starts at
+// the current position, when template actions are enabled. The buffer
+// must hold at least two characters.
+func is_template_start(parser *yaml_parser_t) bool {
+	return parser.templates &&
+		parser.buffer[parser.buffer_pos] == '{' && parser.buffer[parser.buffer_pos+1] == '{'
+}
+
+// [Go] Copy a template action, from its {{ to its }}, whatever it holds.
+func yaml_parser_scan_template_action(parser *yaml_parser_t, s *[]byte, start_mark yaml_mark_t) bool {
+	for {
+		if parser.unread < 2 && !yaml_parser_update_buffer(parser, 2) {
+			return false
+		}
+		if is_breakz(parser.buffer, parser.buffer_pos) {
+			return yaml_parser_set_scanner_error(parser, "while scanning a plain scalar",
+				start_mark, "found unterminated template action")
+		}
+		end := parser.buffer[parser.buffer_pos] == '}' && parser.buffer[parser.buffer_pos+1] == '}'
+		*s = read(parser, *s)
+		if end {
+			*s = read(parser, *s)
+			return true
+		}
+	}
+}
+
 // Scan a plain scalar.
 func yaml_parser_scan_plain_scalar(parser *yaml_parser_t, token *yaml_token_t) bool {
 
@@ -2721,14 +2783,15 @@ func yaml_parser_scan_plain_scalar(parser *yaml_parser_t, token *yaml_token_t) b
 
 		// Consume non-blank characters.
 		for !is_blankz(parser.buffer, parser.buffer_pos) {
+			template := is_template_start(parser)
 
 			// Check for indicators that may end a plain scalar.
-			if (parser.buffer[parser.buffer_pos] == ':' && is_blankz(parser.buffer, parser.buffer_pos+1)) ||
+			if !template && ((parser.buffer[parser.buffer_pos] == ':' && is_blankz(parser.buffer, parser.buffer_pos+1)) ||
 				(parser.flow_level > 0 &&
 					(parser.buffer[parser.buffer_pos] == ',' ||
 						parser.buffer[parser.buffer_pos] == '?' || parser.buffer[parser.buffer_pos] == '[' ||
 						parser.buffer[parser.buffer_pos] == ']' || parser.buffer[parser.buffer_pos] == '{' ||
-						parser.buffer[parser.buffer_pos] == '}')) {
+						parser.buffer[parser.buffer_pos] == '}'))) {
 				break
 			}
 
@@ -2755,8 +2818,14 @@ func yaml_parser_scan_plain_scalar(parser *yaml_parser_t, token *yaml_token_t) b
 				}
 			}
 
-			// Copy the character.
-			s = read(parser, s)
+			// Copy the character, or the whole template action.
+			if template {
+				if !yaml_parser_scan_template_action(parser, &s, start_mark) {
+					return false
+				}
+			} else {
+				s = read(parser, s)
+			}
 
 			end_mark = parser.mark
 			if parser.unread < 2 && !yaml_parser_update_buffer(parser, 2) {
@@ -2961,6 +3030,15 @@ func yaml_parser_scan_comments(parser *yaml_parser_t, scan_mark yaml_mark_t) boo
 			if !is_break(parser.buffer, parser.buffer_pos+peek) {
 				break
 			}
+			// [Go] Count a CR LF line break as a single one.
+			if parser.buffer[parser.buffer_pos+peek] == '\r' {
+				if parser.unread < peek+2 && !yaml_parser_update_buffer(parser, peek+2) {
+					break
+				}
+				if is_crlf(parser.buffer, parser.buffer_pos+peek) {
+					peek++
+				}
+			}
 			first_empty = false
 			recent_empty = true
 			column = 0
@@ -3017,6 +3095,14 @@ func yaml_parser_scan_comments(parser *yaml_parser_t, scan_mark yaml_mark_t) boo
 		}
 
 		peek = 0
+		// [Go] The line break ending the comment is skipped along with
+		// the next character, so skip both characters of a CR LF.
+		if parser.unread < 2 && !yaml_parser_update_buffer(parser, 2) {
+			return false
+		}
+		if is_crlf(parser.buffer, parser.buffer_pos) {
+			peek = 1
+		}
 		column = 0
 		line = parser.mark.line
 		next_indent = parser.indent
@@ -0,0 +1,63 @@
+//
+// Copyright (c) 2011-2019 Canonical Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DecodeStats describes the work done by a decoding operation, so that the
+// cost of processing YAML can be monitored. See SetStatsHook.
+type DecodeStats struct {
+	// Operation names the function that did the work, either "Unmarshal"
+	// or "Decoder.Decode".
+	Operation string
+
+	// Duration holds the time spent parsing and decoding.
+	Duration time.Duration
+
+	// InputSize holds the number of bytes read from the input. Decoders
+	// read their input ahead, so the bytes of a document may be counted
+	// by the operation decoding the one before.
+	InputSize int64
+
+	// Nodes holds the number of nodes parsed, and Aliases the number of
+	// nodes decoded again through aliases, which measures how much
+	// aliases expanded the document.
+	Nodes   int
+	Aliases int
+
+	// Err holds the error returned by the operation, if any.
+	Err error
+}
+
+// statsHook holds the func(DecodeStats) set by SetStatsHook, if any.
+var statsHook atomic.Value
+
+// SetStatsHook makes every call to Unmarshal and Decoder.Decode pass its
+// statistics to hook once it's done, so that hot spots can be found
+// without changing the code calling them. The hook is called from the
+// goroutine doing the work and must be safe for concurrent use. A nil hook
+// disables it, which is the default, and nothing is measured then.
+func SetStatsHook(hook func(stats DecodeStats)) {
+	statsHook.Store(hook)
+}
+
+func loadStatsHook() func(stats DecodeStats) {
+	hook, _ := statsHook.Load().(func(stats DecodeStats))
+	return hook
+}
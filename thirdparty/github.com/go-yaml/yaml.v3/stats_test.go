@@ -0,0 +1,49 @@
+package yaml_test
+
+import (
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+func (s *S) TestStatsHook(c *C) {
+	var stats []yaml.DecodeStats
+	yaml.SetStatsHook(func(st yaml.DecodeStats) {
+		stats = append(stats, st)
+	})
+	defer yaml.SetStatsHook(nil)
+
+	data := "a: &x [1, 2]\nb: *x\nc: *x\n"
+	var v map[string][]int
+	c.Assert(yaml.Unmarshal([]byte(data), &v), IsNil)
+	c.Assert(yaml.Unmarshal([]byte("a: [1"), &v), NotNil)
+
+	c.Assert(stats, HasLen, 2)
+	c.Assert(stats[0].Operation, Equals, "Unmarshal")
+	c.Assert(stats[0].InputSize, Equals, int64(len(data)))
+	// The document, the mapping, three keys, the sequence with its two
+	// items and two aliases.
+	c.Assert(stats[0].Nodes, Equals, 10)
+	// Each alias decodes the sequence and its items again.
+	c.Assert(stats[0].Aliases, Equals, 6)
+	c.Assert(stats[0].Duration > 0, Equals, true)
+	c.Assert(stats[0].Err, IsNil)
+	c.Assert(stats[1].Err, ErrorMatches, "yaml: .*")
+}
+
+func (s *S) TestDecoderStatsHandler(c *C) {
+	var stats []yaml.DecodeStats
+	dec := yaml.NewDecoder(strings.NewReader("a: 1\n---\n[1, 2, 3]\n"))
+	dec.SetStatsHandler(func(st yaml.DecodeStats) {
+		stats = append(stats, st)
+	})
+	var v interface{}
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(dec.Decode(&v), IsNil)
+	c.Assert(stats, HasLen, 2)
+	c.Assert(stats[0].Operation, Equals, "Decoder.Decode")
+	c.Assert(stats[0].Nodes, Equals, 4)
+	c.Assert(stats[1].Nodes, Equals, 5)
+	c.Assert(stats[0].InputSize+stats[1].InputSize, Equals, int64(19))
+}
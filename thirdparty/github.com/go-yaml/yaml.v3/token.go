@@ -0,0 +1,238 @@
+//
+// Copyright (c) 2011-2019 Canonical Ltd
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package yaml
+
+import (
+	"fmt"
+	"io"
+)
+
+// TokenKind identifies the kind of a Token.
+type TokenKind int
+
+const (
+	StreamStartToken TokenKind = iota + 1
+	StreamEndToken
+	VersionDirectiveToken
+	TagDirectiveToken
+	DocumentStartToken
+	DocumentEndToken
+	BlockSequenceStartToken
+	BlockMappingStartToken
+	BlockEndToken
+	FlowSequenceStartToken
+	FlowSequenceEndToken
+	FlowMappingStartToken
+	FlowMappingEndToken
+	BlockEntryToken
+	FlowEntryToken
+	KeyToken
+	ValueToken
+	AliasToken
+	AnchorToken
+	TagToken
+	ScalarToken
+	CommentToken
+)
+
+var tokenKindStrings = []string{
+	StreamStartToken:        "stream start",
+	StreamEndToken:          "stream end",
+	VersionDirectiveToken:   "version directive",
+	TagDirectiveToken:       "tag directive",
+	DocumentStartToken:      "document start",
+	DocumentEndToken:        "document end",
+	BlockSequenceStartToken: "block sequence start",
+	BlockMappingStartToken:  "block mapping start",
+	BlockEndToken:           "block end",
+	FlowSequenceStartToken:  "flow sequence start",
+	FlowSequenceEndToken:    "flow sequence end",
+	FlowMappingStartToken:   "flow mapping start",
+	FlowMappingEndToken:     "flow mapping end",
+	BlockEntryToken:         "block entry",
+	FlowEntryToken:          "flow entry",
+	KeyToken:                "key",
+	ValueToken:              "value",
+	AliasToken:              "alias",
+	AnchorToken:             "anchor",
+	TagToken:                "tag",
+	ScalarToken:             "scalar",
+	CommentToken:            "comment",
+}
+
+func (k TokenKind) String() string {
+	if k <= 0 || int(k) >= len(tokenKindStrings) {
+		return fmt.Sprintf("unknown token %d", int(k))
+	}
+	return tokenKindStrings[k]
+}
+
+// A Token is a lexical element of YAML text, as produced by a Scanner.
+type Token struct {
+	Kind TokenKind
+
+	// Start and End hold the span of the token in the YAML text.
+	// Some tokens, such as the key and block start tokens, are
+	// implied by the indentation and span no text.
+	Start, End Mark
+
+	// Value holds the name of alias and anchor tokens, the value of
+	// scalar tokens, the text of comment tokens, the handle and suffix
+	// of tag tokens (such as "!!str"), the handle and prefix of tag
+	// directive tokens separated by a space, and the version of version
+	// directive tokens (such as "1.1").
+	Value string
+
+	// Style holds the style of scalar tokens. Only the quoting, literal
+	// and folded styles are used.
+	Style Style
+}
+
+// A Scanner reads the tokens that make up YAML text, including its
+// comments, for use in tools such as syntax highlighters and linters.
+type Scanner struct {
+	parser   *parser
+	comments int
+	pending  []Token
+	last     int
+	done     bool
+}
+
+// NewScanner returns a new scanner that reads from r.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{parser: newParserFromReader(r)}
+}
+
+// Next returns the next token in the text, in order of position.
+// It returns io.EOF after the stream end token has been returned.
+func (s *Scanner) Next() (tok Token, err error) {
+	defer handleErr(&err)
+	for {
+		// The scanner collects comments on the side, possibly ahead of
+		// the tokens preceding them, so hold them back until no token
+		// may come before them.
+		if len(s.pending) > 0 && (s.done || s.pending[0].Start.Index <= s.last) {
+			tok = s.pending[0]
+			s.pending = s.pending[1:]
+			return tok, nil
+		}
+		if s.done {
+			return Token{}, io.EOF
+		}
+		var token yaml_token_t
+		if !yaml_parser_scan(&s.parser.parser, &token) {
+			s.parser.fail()
+		}
+		tok = newToken(&token)
+		s.last = tok.Start.Index
+		s.done = tok.Kind == StreamEndToken
+		s.pending = insertToken(s.pending, tok)
+		for i := s.comments; i < len(s.parser.parser.comments); i++ {
+			s.pending = insertToken(s.pending, newCommentToken(&s.parser.parser.comments[i]))
+		}
+		s.comments = len(s.parser.parser.comments)
+	}
+}
+
+// Close releases the resources held by the scanner.
+func (s *Scanner) Close() error {
+	s.parser.destroy()
+	s.done = true
+	s.pending = nil
+	return nil
+}
+
+// insertToken inserts tok into the list ordered by position, after the
+// tokens at the same position.
+func insertToken(list []Token, tok Token) []Token {
+	i := len(list)
+	for i > 0 && list[i-1].Start.Index > tok.Start.Index {
+		i--
+	}
+	list = append(list, Token{})
+	copy(list[i+1:], list[i:])
+	list[i] = tok
+	return list
+}
+
+func newToken(t *yaml_token_t) Token {
+	tok := Token{
+		Kind:  TokenKind(t.typ),
+		Start: newMark(t.start_mark),
+		End:   newMark(t.end_mark),
+	}
+	switch t.typ {
+	case yaml_ALIAS_TOKEN, yaml_ANCHOR_TOKEN:
+		tok.Value = string(t.value)
+	case yaml_TAG_TOKEN:
+		tok.Value = string(t.value) + string(t.suffix)
+	case yaml_TAG_DIRECTIVE_TOKEN:
+		tok.Value = string(t.value) + " " + string(t.prefix)
+	case yaml_VERSION_DIRECTIVE_TOKEN:
+		tok.Value = fmt.Sprintf("%d.%d", t.major, t.minor)
+	case yaml_SCALAR_TOKEN:
+		tok.Value = string(t.value)
+		switch t.style {
+		case yaml_DOUBLE_QUOTED_SCALAR_STYLE:
+			tok.Style = DoubleQuotedStyle
+		case yaml_SINGLE_QUOTED_SCALAR_STYLE:
+			tok.Style = SingleQuotedStyle
+		case yaml_LITERAL_SCALAR_STYLE:
+			tok.Style = LiteralStyle
+		case yaml_FOLDED_SCALAR_STYLE:
+			tok.Style = FoldedStyle
+		}
+	}
+	return tok
+}
+
+// newCommentToken returns a token for the comment, which may span several
+// lines. The scanner records comment positions with a 1-based column
+// and does not reliably record where they end, so the start is adjusted
+// and the end derived from the text, assuming any further lines are
+// aligned with the first one.
+func newCommentToken(c *yaml_comment_t) Token {
+	text := c.head
+	start := c.start_mark
+	if len(c.line) > 0 {
+		text = c.line
+	} else {
+		if len(c.foot) > 0 {
+			text = c.foot
+		}
+		start.column--
+	}
+	end := start
+	for _, r := range string(text) {
+		if r == '\n' {
+			end.line++
+			end.index++
+			end.column = start.column
+			continue
+		}
+		if end.column == start.column && end.line > start.line {
+			end.index += start.column
+		}
+		end.index++
+		end.column++
+	}
+	return Token{
+		Kind:  CommentToken,
+		Start: newMark(start),
+		End:   newMark(end),
+		Value: string(text),
+	}
+}
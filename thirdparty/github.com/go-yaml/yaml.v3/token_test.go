@@ -0,0 +1,97 @@
+package yaml_test
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	. "gopkg.in/check.v1"
+	"sigs.k8s.io/yaml/thirdparty/github.com/go-yaml/yaml.v3"
+)
+
+func (s *S) TestScannerTokens(c *C) {
+	sc := yaml.NewScanner(strings.NewReader("# head\na: &x 1 # line\nb: [*x, 'q']\n# foot\n--- !!str |\n  lit\n"))
+	defer sc.Close()
+
+	type token struct {
+		kind  yaml.TokenKind
+		start string
+		end   string
+		value string
+		style yaml.Style
+	}
+	pos := func(m yaml.Mark) string {
+		return fmt.Sprintf("%d:%d", m.Line, m.Column)
+	}
+	want := []token{
+		{yaml.StreamStartToken, "1:1", "1:1", "", 0},
+		{yaml.CommentToken, "1:1", "1:7", "# head", 0},
+		{yaml.BlockMappingStartToken, "2:1", "2:1", "", 0},
+		{yaml.KeyToken, "2:1", "2:1", "", 0},
+		{yaml.ScalarToken, "2:1", "2:2", "a", 0},
+		{yaml.ValueToken, "2:2", "2:3", "", 0},
+		{yaml.AnchorToken, "2:4", "2:6", "x", 0},
+		{yaml.ScalarToken, "2:7", "2:8", "1", 0},
+		{yaml.CommentToken, "2:9", "2:15", "# line", 0},
+		{yaml.KeyToken, "3:1", "3:1", "", 0},
+		{yaml.ScalarToken, "3:1", "3:2", "b", 0},
+		{yaml.ValueToken, "3:2", "3:3", "", 0},
+		{yaml.FlowSequenceStartToken, "3:4", "3:5", "", 0},
+		{yaml.AliasToken, "3:5", "3:7", "x", 0},
+		{yaml.FlowEntryToken, "3:7", "3:8", "", 0},
+		{yaml.ScalarToken, "3:9", "3:12", "q", yaml.SingleQuotedStyle},
+		{yaml.FlowSequenceEndToken, "3:12", "3:13", "", 0},
+		{yaml.CommentToken, "4:1", "4:7", "# foot", 0},
+		{yaml.BlockEndToken, "4:2", "4:2", "", 0},
+		{yaml.DocumentStartToken, "5:1", "5:4", "", 0},
+		{yaml.TagToken, "5:5", "5:10", "!!str", 0},
+		{yaml.ScalarToken, "5:11", "7:1", "lit\n", yaml.LiteralStyle},
+		{yaml.StreamEndToken, "7:1", "7:1", "", 0},
+	}
+	var got []token
+	for {
+		tok, err := sc.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		got = append(got, token{tok.Kind, pos(tok.Start), pos(tok.End), tok.Value, tok.Style})
+	}
+	c.Assert(got, DeepEquals, want)
+
+	_, err := sc.Next()
+	c.Assert(err, Equals, io.EOF)
+}
+
+func (s *S) TestScannerDirectives(c *C) {
+	sc := yaml.NewScanner(strings.NewReader("%YAML 1.1\n%TAG !e! tag:example.com,2000:\n--- !e!foo bar\n"))
+	defer sc.Close()
+	var got []string
+	for {
+		tok, err := sc.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, IsNil)
+		got = append(got, tok.Kind.String()+" "+tok.Value)
+	}
+	c.Assert(got, DeepEquals, []string{
+		"stream start ",
+		"version directive 1.1",
+		"tag directive !e! tag:example.com,2000:",
+		"document start ",
+		"tag !e!foo",
+		"scalar bar",
+		"stream end ",
+	})
+}
+
+func (s *S) TestScannerError(c *C) {
+	sc := yaml.NewScanner(strings.NewReader("a: 'b\n"))
+	defer sc.Close()
+	var err error
+	for err == nil {
+		_, err = sc.Next()
+	}
+	c.Assert(err, ErrorMatches, "yaml: line 2: found unexpected end of stream")
+}
@@ -237,6 +237,14 @@ type yaml_token_t struct {
 	// The scalar style (for yaml_SCALAR_TOKEN).
 	style yaml_scalar_style_t
 
+	// [Go] The chomping indicator of a block scalar, -1 for "-" and +1
+	// for "+" (for yaml_SCALAR_TOKEN).
+	chomping int8
+
+	// [Go] The indentation indicator of a block scalar, or 0 if it has
+	// none (for yaml_SCALAR_TOKEN).
+	increment int8
+
 	// The version directive major/minor (for yaml_VERSION_DIRECTIVE_TOKEN).
 	major, minor int8
 }
@@ -309,6 +317,12 @@ type yaml_event_t struct {
 	foot_comment []byte
 	tail_comment []byte
 
+	// The comments after the start indicator of a flow collection (for
+	// yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT) and before
+	// its end indicator (for yaml_SEQUENCE_END_EVENT, yaml_MAPPING_END_EVENT).
+	open_comment  []byte
+	close_comment []byte
+
 	// The anchor (for yaml_SCALAR_EVENT, yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT, yaml_ALIAS_EVENT).
 	anchor []byte
 
@@ -318,6 +332,10 @@ type yaml_event_t struct {
 	// The scalar value (for yaml_SCALAR_EVENT).
 	value []byte
 
+	// [Go] The reader of the binary data to write, base64-encoded, in place
+	// of the value (for yaml_SCALAR_EVENT).
+	value_reader io.Reader
+
 	// Is the document start/end indicator implicit, or the tag optional?
 	// (for yaml_DOCUMENT_START_EVENT, yaml_DOCUMENT_END_EVENT, yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT, yaml_SCALAR_EVENT).
 	implicit bool
@@ -327,6 +345,17 @@ type yaml_event_t struct {
 
 	// The style (for yaml_SCALAR_EVENT, yaml_SEQUENCE_START_EVENT, yaml_MAPPING_START_EVENT).
 	style yaml_style_t
+
+	// [Go] The chomping indicator of a block scalar, -1 for "-" and +1
+	// for "+" (for yaml_SCALAR_EVENT).
+	chomping int8
+
+	// [Go] The indentation indicator of a block scalar, or 0 if it has
+	// none (for yaml_SCALAR_EVENT).
+	increment int8
+
+	// The number of empty lines before the node (for entries of block collections).
+	empty_lines int
 }
 
 func (e *yaml_event_t) scalar_style() yaml_scalar_style_t     { return yaml_scalar_style_t(e.style) }
@@ -575,6 +604,10 @@ type yaml_parser_t struct {
 
 	input_reader io.Reader // File input data.
 	input        []byte    // String input data.
+
+	input_seen      []byte // [Go] The recently read file input data, kept for error reporting.
+	input_seen_pos  int    // [Go] The offset of the recently read data in the input.
+	input_seen_line int    // [Go] The line of the recently read data in the input.
 	input_pos    int
 
 	eof bool // EOF flag
@@ -586,6 +619,8 @@ type yaml_parser_t struct {
 
 	newlines int // The number of line breaks since last non-break/non-blank character
 
+	empty_lines []int // The lines with no content seen so far, in increasing order.
+
 	raw_buffer     []byte // The raw buffer.
 	raw_buffer_pos int    // The current position of the buffer.
 
@@ -602,6 +637,8 @@ type yaml_parser_t struct {
 	tail_comment []byte // Foot comment that happens at the end of a block.
 	stem_comment []byte // Comment in item preceding a nested structure (list inside list item, etc)
 
+	close_comment []byte // Comment preceding the end indicator of the current flow collection.
+
 	comments      []yaml_comment_t // The folded comments for all parsed tokens
 	comments_head int
 
@@ -612,6 +649,11 @@ type yaml_parser_t struct {
 
 	flow_level int // The number of unclosed '[' and '{' indicators.
 
+	max_depth      int // The maximum nesting depth, or zero for the default.
+	depth_exceeded int // The maximum nesting depth the scanner failed on, if any.
+
+	templates bool // [Go] Are template actions part of plain scalars?
+
 	tokens          []yaml_token_t // The tokens queue.
 	tokens_head     int            // The head of the tokens queue.
 	tokens_parsed   int            // The number of tokens fetched from the queue.
@@ -709,6 +751,9 @@ type yaml_emitter_t struct {
 	// Writer stuff
 
 	write_handler yaml_write_handler_t // Write handler.
+	write_error   error                // [Go] The error returned by the write handler.
+	read_error    error                // [Go] The error returned by the reader of a binary value.
+	binary_width  int                  // [Go] The length of base64 lines, 0 for the default, negative for a single line.
 
 	output_buffer *[]byte   // String output data.
 	output_writer io.Writer // File output data.
@@ -729,6 +774,9 @@ type yaml_emitter_t struct {
 	unicode     bool         // Allow unescaped non-ASCII characters?
 	line_break  yaml_break_t // The preferred line break.
 
+	best_mapping_indent  int // The number of indentation spaces for block mappings, if not best_indent.
+	best_sequence_indent int // The number of indentation spaces for block sequences, if not best_indent.
+
 	state  yaml_emitter_state_t   // The current emitter state.
 	states []yaml_emitter_state_t // The stack of states.
 
@@ -743,6 +791,8 @@ type yaml_emitter_t struct {
 
 	compact_sequence_indent bool // Is '- ' is considered part of the indentation for sequence elements?
 
+	templates bool // [Go] Are scalars holding template actions written as they are?
+
 	flow_level int // The current flow level.
 
 	root_context       bool // Is it the document root context?
@@ -756,6 +806,8 @@ type yaml_emitter_t struct {
 	indention  bool // If the last character was an indentation character (' ', '-', '?', ':')?
 	open_ended bool // If an explicit document end is required?
 
+	implicit_end bool // [Go] Did the last document end without an explicit document end indicator?
+
 	space_above bool // Is there's an empty line above?
 	foot_indent int  // The indent used to write the foot comment above, or -1 if none.
 
@@ -779,6 +831,10 @@ type yaml_emitter_t struct {
 		block_plain_allowed   bool                // Can the scalar be expressed in the block plain style?
 		single_quoted_allowed bool                // Can the scalar be expressed in the single quoted style?
 		block_allowed         bool                // Can the scalar be expressed in the literal or folded styles?
+		template              bool                // [Go] Does the scalar hold template actions?
+		reader                io.Reader           // [Go] The reader of the binary data written in place of the value.
+		chomping              int8                // [Go] The chomping indicator to use when the value allows it.
+		increment             int8                // [Go] The indentation indicator to use, if any.
 		style                 yaml_scalar_style_t // The output style.
 	}
 
@@ -790,6 +846,10 @@ type yaml_emitter_t struct {
 
 	key_line_comment []byte
 
+	open_comment []byte // The comment to write after the start indicator of the next flow collection.
+
+	empty_lines int // The number of empty lines to write before the next head comment.
+
 	// Dumper stuff
 
 	opened bool // If the stream was already opened?
@@ -22,12 +22,16 @@
 package yaml
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unicode/utf8"
 )
 
@@ -51,6 +55,41 @@ type Marshaler interface {
 	MarshalYAML() (interface{}, error)
 }
 
+// A Pair is a key and value in an ordered mapping.
+type Pair struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// An OrderedMap holds the entries of a mapping in order. It is encoded
+// as a "!!omap" sequence of single-pair mappings, and may be decoded
+// from such a sequence, a "!!pairs" one, or a plain mapping. The keys
+// must be unique. Decoding a "!!omap" value into an interface{} produces
+// an OrderedMap.
+type OrderedMap []Pair
+
+// Pairs holds the entries of a mapping in order, allowing keys to repeat.
+// It is encoded as a "!!pairs" sequence of single-pair mappings, and may
+// be decoded from such a sequence, an "!!omap" one, or a plain mapping.
+// Decoding a "!!pairs" value into an interface{} produces Pairs.
+type Pairs []Pair
+
+// MapSlice encodes and decodes as a YAML mapping, preserving the order of
+// its keys. Mappings within the values of a decoded MapSlice are decoded
+// as MapSlice values too when decoded into an interface{}.
+type MapSlice []MapItem
+
+// MapItem is an item in a MapSlice.
+type MapItem struct {
+	Key, Value interface{}
+
+	// HeadComment and FootComment hold the comments before and after
+	// the item, and LineComment the comment at the end of its line.
+	HeadComment string
+	LineComment string
+	FootComment string
+}
+
 // Unmarshal decodes the first document found within the in byte slice
 // and assigns decoded values into the out value.
 //
@@ -65,6 +104,11 @@ type Marshaler interface {
 // content, and a *yaml.TypeError is returned with details for all
 // missed values.
 //
+// The data of !!binary values may be decoded into strings and byte slices.
+// It's streamed to the writer held by a non-nil value whose type is
+// io.Writer, or an interface that embeds it, such as a field set to an
+// *os.File before decoding. Writing errors make decoding fail.
+//
 // Struct fields are only unmarshalled if they are exported (have an
 // upper case first letter), and are unmarshalled using the field name
 // lowercased as the default key. Custom keys may be defined via the
@@ -89,10 +133,60 @@ func Unmarshal(in []byte, out interface{}) (err error) {
 	return unmarshal(in, out, false)
 }
 
+// ErrPathNotFound is returned by DecodePath when there's no value at the
+// requested path.
+var ErrPathNotFound = errors.New("yaml: path not found")
+
+// DecodePath decodes the value found at path in the first document of in
+// and stores it in out, as Unmarshal does. The path is made of mapping
+// keys and sequence indexes separated by dots, such as "metadata.name" or
+// "spec.containers.0.image", and an empty path refers to the document
+// content. Parsing stops as soon as the value has been read, so the text
+// that follows it is not checked. ErrPathNotFound is returned if there's
+// no value at path.
+func DecodePath(in []byte, path string, out interface{}) (err error) {
+	defer handleErr(&err)
+	p := newParser(in)
+	defer p.destroy()
+	p.init()
+	if p.peek() == yaml_STREAM_END_EVENT {
+		return ErrPathNotFound
+	}
+	p.expect(yaml_DOCUMENT_START_EVENT)
+	var segments []string
+	if path != "" {
+		segments = strings.Split(path, ".")
+	}
+	node := p.findPath(segments)
+	if node == nil {
+		return ErrPathNotFound
+	}
+	return node.Decode(out)
+}
+
 // A Decoder reads and decodes YAML values from an input stream.
 type Decoder struct {
-	parser      *parser
-	knownFields bool
+	parser        *parser
+	knownFields   bool
+	reportUnknown bool
+	unknownFields []UnknownField
+	duplicateKeys DuplicateKeyPolicy
+	maxAliases    int
+	durations     DurationFormat
+	foldFields    bool
+	mergeKeys     MergeKeyPolicy
+	plainStrings  bool
+	stringKeys    bool
+	copyAliases   bool
+	resolvers     map[string]TagResolver
+	lookup        func(name string) (string, bool)
+	include       func(path string) ([]byte, error)
+	maxIncludes   int
+	warn          func(w Warning)
+	stats         func(stats DecodeStats)
+	types         *TypeRegistry
+	unions        map[reflect.Type]*TypeRegistry
+	input         *progressReader
 }
 
 // NewDecoder returns a new decoder that reads from r.
@@ -100,8 +194,65 @@ type Decoder struct {
 // The decoder introduces its own buffering and may read
 // data from r beyond the YAML values requested.
 func NewDecoder(r io.Reader) *Decoder {
+	input := &progressReader{r: r}
 	return &Decoder{
-		parser: newParserFromReader(r),
+		parser: newParserFromReader(input),
+		input:  input,
+	}
+}
+
+// Reset discards the state of the decoder and makes it read from r, as
+// a new decoder would, so that it may be reused. The settings of the
+// decoder, including its progress callback, are kept.
+func (dec *Decoder) Reset(r io.Reader) {
+	dec.input.r = r
+	dec.input.n = 0
+	dec.input.err = nil
+	dec.unknownFields = nil
+	dec.parser.reset(dec.input)
+}
+
+// BytesRead returns the number of bytes read so far from the input. As
+// the decoder buffers its input, that may include data beyond the values
+// decoded so far.
+func (dec *Decoder) BytesRead() int64 {
+	return dec.input.n
+}
+
+// SetProgress makes the decoder call progress with the total number of
+// bytes read from the input after each read. The callback may block to
+// limit the reading rate, or return an error to abort decoding, in which
+// case that error is returned by the pending call to Decode or Skip.
+func (dec *Decoder) SetProgress(progress func(bytesRead int64) error) {
+	dec.input.progress = progress
+}
+
+// progressReader counts the bytes read from r and reports them to the
+// progress callback, if any.
+type progressReader struct {
+	r        io.Reader
+	n        int64
+	progress func(bytesRead int64) error
+	err      error // The error returned by progress, if any.
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if r.progress != nil && n > 0 {
+		if perr := r.progress(r.n); perr != nil {
+			r.err = perr
+			return n, perr
+		}
+	}
+	return n, err
+}
+
+// progressErr replaces the error in err with the one returned by the
+// progress callback, if any, as the parser doesn't keep input errors.
+func (r *progressReader) progressErr(err *error) {
+	if *err != nil && r.err != nil {
+		*err = r.err
 	}
 }
 
@@ -111,14 +262,261 @@ func (dec *Decoder) KnownFields(enable bool) {
 	dec.knownFields = enable
 }
 
+// CaseInsensitiveFields makes the decoder match keys in decoded mappings
+// to struct fields ignoring case when there's no exact match, as
+// encoding/json does. It is disabled by default.
+func (dec *Decoder) CaseInsensitiveFields(enable bool) {
+	dec.foldFields = enable
+}
+
+// ReportUnknownFields makes the decoder record the keys in decoded
+// mappings that do not exist as fields in the struct being decoded
+// into, without failing the decoding. The keys found by the last call
+// to Decode are returned by UnknownFields.
+//
+// Unknown keys are reported as errors instead when KnownFields is enabled.
+func (dec *Decoder) ReportUnknownFields(enable bool) {
+	dec.reportUnknown = enable
+}
+
+// UnknownFields returns the unknown fields found by the last call to
+// Decode when ReportUnknownFields is enabled.
+func (dec *Decoder) UnknownFields() []UnknownField {
+	return dec.unknownFields
+}
+
+// A DuplicateKeyPolicy defines how the decoder handles a mapping key
+// that appears more than once in the same mapping.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeyError reports repeated keys as errors. This is the default.
+	DuplicateKeyError DuplicateKeyPolicy = iota
+
+	// DuplicateKeyTakeFirst ignores all but the first occurrence of a key.
+	DuplicateKeyTakeFirst
+
+	// DuplicateKeyTakeLast ignores all but the last occurrence of a key.
+	DuplicateKeyTakeLast
+)
+
+// A MergeKeyPolicy defines how the decoder handles "<<" merge keys.
+type MergeKeyPolicy int
+
+const (
+	// MergeKeyDefault expands merge keys when decoding into maps and
+	// structs, and keeps them as they are when decoding into a Node.
+	// This is the default.
+	MergeKeyDefault MergeKeyPolicy = iota
+
+	// MergeKeyExpand also expands merge keys when decoding into a Node,
+	// replacing them with the merged entries that the mapping does not
+	// define itself. The merged entries share the nodes of the mappings
+	// they come from.
+	MergeKeyExpand
+
+	// MergeKeyKeep handles merge keys as regular keys in all cases.
+	MergeKeyKeep
+)
+
+// MergeKeyPolicy sets how merge keys in decoded mappings are handled.
+func (dec *Decoder) MergeKeyPolicy(policy MergeKeyPolicy) {
+	dec.mergeKeys = policy
+}
+
+// SetMaxDepth limits the nesting depth of collections in the decoded
+// YAML content to n levels. Decoding content nested deeper than that
+// fails with a *MaxDepthError. A zero or negative n restores the
+// default limit of 10000 levels.
+func (dec *Decoder) SetMaxDepth(n int) {
+	dec.parser.parser.max_depth = n
+}
+
+// PreserveTemplates makes the decoder read Go template actions, such as
+// {{ .Values.name }} or {{- include "labels" . | nindent 4 }}, as part of
+// the plain scalars they appear in, whatever they hold, so that templated
+// documents such as Helm charts may be decoded. An action can't span lines.
+func (dec *Decoder) PreserveTemplates(enable bool) {
+	dec.parser.parser.templates = enable
+}
+
+// SetSource sets the Source field of the nodes decoded from now on to
+// name, such as the name of the file being read, so that merged documents
+// can tell where each value comes from.
+func (dec *Decoder) SetSource(name string) {
+	dec.parser.source = name
+}
+
+// SetSchema changes the schema used to resolve the tags of untagged plain
+// scalars when decoding.
+func (dec *Decoder) SetSchema(schema Schema) {
+	dec.parser.schema = schema
+}
+
+// PlainScalarsAsStrings makes the decoder store untagged plain scalars,
+// such as 42, true or ~, as their original text when decoding them into
+// interface values, including mapping keys, rather than guessing a bool,
+// number or nil value. Unlike with the FailsafeSchema, such scalars may
+// still be decoded into typed values such as an int field.
+func (dec *Decoder) PlainScalarsAsStrings(enable bool) {
+	dec.plainStrings = enable
+}
+
+// StringKeys makes the decoder store mappings decoded into interface
+// values as map[string]interface{} values, whatever their keys are. Keys
+// that are not strings are rendered in a canonical form, such as "null",
+// "true", "31" for 0x1F, or "1.5", and so are the keys decoded into
+// other maps with string keys. Collections used as keys are rejected.
+func (dec *Decoder) StringKeys(enable bool) {
+	dec.stringKeys = enable
+}
+
+// CopyAliases makes the decoder decode each alias from a copy of the
+// nodes of its anchor. Go values decoded from aliases never share maps
+// or slices, but the Node and RawNode values decoded from within the
+// anchored value, and the nodes passed to Unmarshaler implementations,
+// otherwise share the nodes of the anchor, so changing one of them would
+// change the others. Aliases within the copied nodes still refer to the
+// original nodes of their anchors.
+func (dec *Decoder) CopyAliases(enable bool) {
+	dec.copyAliases = enable
+}
+
+// ExpandVariables makes the decoder replace the variables written as
+// ${NAME} or $NAME in untagged scalars with the value returned by lookup,
+// before the tag of plain scalars is resolved, so that "replicas: $N" may
+// be decoded into an int. Names are made of letters, digits and
+// underscores, and $$ stands for a single $. Undefined variables are
+// replaced with nothing, or reported as errors when KnownFields is
+// enabled. Passing os.LookupEnv expands environment variables, and nil
+// disables expansion. Values decoded into Node and RawNode are left as
+// written.
+func (dec *Decoder) ExpandVariables(lookup func(name string) (value string, ok bool)) {
+	dec.lookup = lookup
+}
+
+// SetIncludeLoader enables the !include tag, which stands for the first
+// document of the file at the path held by the tagged scalar, as read by
+// load from the file system, an fs.FS, the network or any other source.
+// Relative paths found in included files are joined with the directory of
+// the file that includes them, using slash-separated paths, and the paths
+// found in the decoded input are passed as written. Including a file that
+// is already being included is an error, as is going past the depth set by
+// SetMaxIncludeDepth. Values decoded into Node and RawNode are left as
+// written. A nil load disables the tag, which is the default.
+func (dec *Decoder) SetIncludeLoader(load func(path string) ([]byte, error)) {
+	dec.include = load
+}
+
+// SetMaxIncludeDepth limits the nesting of files included with the
+// !include tag to n levels. A zero or negative n restores the default
+// limit of 32 levels. See SetIncludeLoader.
+func (dec *Decoder) SetMaxIncludeDepth(n int) {
+	dec.maxIncludes = n
+}
+
+// SetWarningHandler makes the decoder pass to warn the problems that
+// don't prevent decoding but may deserve attention, so that they can be
+// logged: repeated mapping keys ignored as set by DuplicateKeyPolicy,
+// mapping keys that don't exist as struct fields when KnownFields is
+// disabled, and numbers that can't be stored exactly in the value they
+// are decoded into, such as 9007199254740993 into a float64. A nil warn
+// disables warnings, which is the default.
+func (dec *Decoder) SetWarningHandler(warn func(w Warning)) {
+	dec.warn = warn
+}
+
+// SetStatsHandler makes the decoder pass to handle the statistics of each
+// call to Decode, in addition to the hook set with SetStatsHook. A nil
+// handle disables it, which is the default.
+func (dec *Decoder) SetStatsHandler(handle func(stats DecodeStats)) {
+	dec.stats = handle
+}
+
+// SetTypeRegistry makes the decoder decode the nodes matched by the
+// registry into the Go types registered for them when decoding into
+// interface values, including the elements of []interface{} values and
+// of slices of other interface types. A nil registry disables it, which
+// is the default.
+func (dec *Decoder) SetTypeRegistry(r *TypeRegistry) {
+	dec.types = r
+}
+
+// RegisterUnion makes the decoder decode the values of the interface type
+// pointed to by iface, such as (*Source)(nil), into the Go types registered
+// in r, so that the members of a union, such as plugin configurations
+// told apart by a "type" key, may be decoded without implementing
+// Unmarshaler. The union takes precedence over the registry set with
+// SetTypeRegistry, and struct fields of the interface type, or holding
+// values of it, may read the discriminator from another key with the
+// discriminator flag, as in `yaml:"source,discriminator=kind"`. A nil r
+// removes the union.
+func (dec *Decoder) RegisterUnion(iface interface{}, r *TypeRegistry) {
+	t := reflect.TypeOf(iface)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Interface {
+		panic("yaml: RegisterUnion needs a pointer to an interface type, such as (*T)(nil)")
+	}
+	if r == nil {
+		delete(dec.unions, t.Elem())
+		return
+	}
+	if dec.unions == nil {
+		dec.unions = make(map[reflect.Type]*TypeRegistry)
+	}
+	dec.unions[t.Elem()] = r
+}
+
+// SetDurationFormat makes the decoder also accept time.Duration values
+// written as numbers in the provided unit. Durations written as strings
+// such as "1h30m" are always accepted.
+func (dec *Decoder) SetDurationFormat(format DurationFormat) {
+	dec.durations = format
+}
+
+// DuplicateKeyPolicy sets how repeated keys in decoded mappings are handled.
+func (dec *Decoder) DuplicateKeyPolicy(policy DuplicateKeyPolicy) {
+	dec.duplicateKeys = policy
+}
+
+// SetMaxAliasExpansion limits the number of values the decoder may
+// produce by expanding aliases in a single document. By default the
+// limit scales with the size of the document, so that aliases may
+// contribute most of the values of small documents but only a fraction
+// of those of very large ones. A positive n replaces that default with
+// a fixed budget, a negative n removes the limit altogether, and zero
+// restores the default.
+func (dec *Decoder) SetMaxAliasExpansion(n int) {
+	dec.maxAliases = n
+}
+
 // Decode reads the next YAML-encoded value from its input
 // and stores it in the value pointed to by v.
 //
 // See the documentation for Unmarshal for details about the
 // conversion of YAML into a Go value.
 func (dec *Decoder) Decode(v interface{}) (err error) {
-	d := newDecoder()
-	d.knownFields = dec.knownFields
+	d := dec.newDecoder()
+	if hook, handler := loadStatsHook(), dec.stats; hook != nil || handler != nil {
+		start, read, nodes := time.Now(), dec.input.n, dec.parser.nodes
+		defer func() {
+			stats := DecodeStats{
+				Operation: "Decoder.Decode",
+				Duration:  time.Since(start),
+				InputSize: dec.input.n - read,
+				Nodes:     dec.parser.nodes - nodes,
+				Aliases:   d.aliasCount,
+				Err:       err,
+			}
+			if handler != nil {
+				handler(stats)
+			}
+			if hook != nil {
+				hook(stats)
+			}
+		}()
+	}
+	defer func() { dec.unknownFields = d.unknownFields }()
+	defer dec.input.progressErr(&err)
 	defer handleErr(&err)
 	node := dec.parser.parse()
 	if node == nil {
@@ -130,7 +528,97 @@ func (dec *Decoder) Decode(v interface{}) (err error) {
 	}
 	d.unmarshal(node, out)
 	if len(d.terrors) > 0 {
-		return &TypeError{d.terrors}
+		return newTypeError(d.terrors)
+	}
+	return nil
+}
+
+// newDecoder returns a decoder with the settings of dec.
+func (dec *Decoder) newDecoder() *decoder {
+	d := newDecoder()
+	d.knownFields = dec.knownFields
+	d.reportUnknown = dec.reportUnknown
+	d.duplicateKeys = dec.duplicateKeys
+	d.maxAliases = dec.maxAliases
+	d.durations = dec.durations
+	d.foldFields = dec.foldFields
+	d.mergeKeys = dec.mergeKeys
+	d.schema = dec.parser.schema
+	d.plainStrings = dec.plainStrings
+	d.stringKeys = dec.stringKeys
+	d.copyAliases = dec.copyAliases
+	d.resolvers = dec.resolvers
+	d.lookup = dec.lookup
+	d.include = dec.include
+	d.maxIncludes = dec.maxIncludes
+	d.warn = dec.warn
+	d.types = dec.types
+	d.unions = dec.unions
+	return d
+}
+
+// A TagResolver returns the value represented by a node when decoding.
+// See Decoder.RegisterTagResolver.
+type TagResolver func(n *Node) (interface{}, error)
+
+// RegisterTagResolver makes the decoder decode the nodes with the tag,
+// such as "!Ref", into the value returned by resolve, which must be nil,
+// or assignable to the decoded value, or an integer, float or other value
+// convertible to it without loss of range. The tag also
+// applies to the nodes whose tag is implied, such as "!!int" for 42.
+// Registered resolvers take precedence over the Unmarshaler interface,
+// but not over decoding into Node and RawNode values. A nil resolve
+// removes the resolver registered for the tag.
+func (dec *Decoder) RegisterTagResolver(tag string, resolve TagResolver) {
+	tag = shortTag(tag)
+	if resolve == nil {
+		delete(dec.resolvers, tag)
+		return
+	}
+	if dec.resolvers == nil {
+		dec.resolvers = make(map[string]TagResolver)
+	}
+	dec.resolvers[tag] = resolve
+}
+
+// DecodeContext is like Decode, but fails with the error of ctx once it's
+// done, as checked before parsing each event of the input.
+func (dec *Decoder) DecodeContext(ctx context.Context, v interface{}) error {
+	dec.parser.ctx = ctx
+	defer func() { dec.parser.ctx = nil }()
+	return dec.Decode(v)
+}
+
+// StreamSequence makes Decode call fn with each element of the sequence
+// found at path in the decoded document, as soon as the element has been
+// parsed, instead of keeping the elements in the sequence, so that very
+// long sequences can be processed with bounded memory. The path is made
+// of mapping keys and sequence indexes separated by dots, as for
+// DecodePath. The sequence is then decoded as an empty one, and values at
+// other paths as usual. An error returned by fn ends decoding, and is
+// returned by Decode. Elements already passed to fn aren't affected by
+// errors found later in the document. A nil fn disables streaming.
+func (dec *Decoder) StreamSequence(path string, fn func(item *Node) error) {
+	if fn == nil {
+		dec.parser.stream = nil
+		return
+	}
+	var segments []string
+	if path != "" {
+		segments = strings.Split(path, ".")
+	}
+	dec.parser.stream = &sequenceStream{path: segments, fn: fn}
+}
+
+// Skip advances past the next document in the input without decoding it.
+// The document is only scanned, so that skipping documents is much cheaper
+// than decoding them, but errors in its structure may go unnoticed. It
+// returns io.EOF if there are no more documents.
+func (dec *Decoder) Skip() (err error) {
+	defer dec.input.progressErr(&err)
+	defer handleErr(&err)
+	if !dec.parser.skipDocument() {
+		return io.EOF
 	}
 	return nil
 }
@@ -148,16 +636,91 @@ func (n *Node) Decode(v interface{}) (err error) {
 	}
 	d.unmarshal(n, out)
 	if len(d.terrors) > 0 {
-		return &TypeError{d.terrors}
+		return newTypeError(d.terrors)
 	}
 	return nil
 }
 
-func unmarshal(in []byte, out interface{}, strict bool) (err error) {
+// A DecodeOption changes the settings of the decoder used by
+// Node.DecodeWithOptions.
+type DecodeOption func(dec *Decoder)
+
+// WithKnownFields returns an option that calls Decoder.KnownFields.
+func WithKnownFields(enable bool) DecodeOption {
+	return func(dec *Decoder) { dec.KnownFields(enable) }
+}
+
+// WithSchema returns an option that calls Decoder.SetSchema.
+func WithSchema(schema Schema) DecodeOption {
+	return func(dec *Decoder) { dec.SetSchema(schema) }
+}
+
+// WithTagResolver returns an option that calls Decoder.RegisterTagResolver.
+func WithTagResolver(tag string, resolve TagResolver) DecodeOption {
+	return func(dec *Decoder) { dec.RegisterTagResolver(tag, resolve) }
+}
+
+// WithVariables returns an option that calls Decoder.ExpandVariables.
+func WithVariables(lookup func(name string) (value string, ok bool)) DecodeOption {
+	return func(dec *Decoder) { dec.ExpandVariables(lookup) }
+}
+
+// WithWarnings returns an option that calls Decoder.SetWarningHandler.
+func WithWarnings(warn func(w Warning)) DecodeOption {
+	return func(dec *Decoder) { dec.SetWarningHandler(warn) }
+}
+
+// WithTypeRegistry returns an option that calls Decoder.SetTypeRegistry.
+func WithTypeRegistry(r *TypeRegistry) DecodeOption {
+	return func(dec *Decoder) { dec.SetTypeRegistry(r) }
+}
+
+// WithUnion returns an option that calls Decoder.RegisterUnion.
+func WithUnion(iface interface{}, r *TypeRegistry) DecodeOption {
+	return func(dec *Decoder) { dec.RegisterUnion(iface, r) }
+}
+
+// DecodeWithOptions is like Decode, but decodes the node with the settings
+// of a Decoder changed by opts, such as WithKnownFields(true). Settings
+// that only apply to parsing, such as Decoder.SetMaxDepth, have no effect.
+// With a schema other than the DefaultSchema, the plain scalars whose tag
+// is the one implied by the DefaultSchema are resolved again.
+func (n *Node) DecodeWithOptions(v interface{}, opts ...DecodeOption) (err error) {
+	dec := &Decoder{parser: &parser{}, input: &progressReader{}}
+	for _, opt := range opts {
+		opt(dec)
+	}
+	d := dec.newDecoder()
 	defer handleErr(&err)
+	out := reflect.ValueOf(v)
+	if out.Kind() == reflect.Ptr && !out.IsNil() {
+		out = out.Elem()
+	}
+	d.unmarshal(n, out)
+	if len(d.terrors) > 0 {
+		return newTypeError(d.terrors)
+	}
+	return nil
+}
+
+func unmarshal(in []byte, out interface{}, strict bool) (err error) {
 	d := newDecoder()
 	p := newParser(in)
 	defer p.destroy()
+	if hook := loadStatsHook(); hook != nil {
+		start := time.Now()
+		defer func() {
+			hook(DecodeStats{
+				Operation: "Unmarshal",
+				Duration:  time.Since(start),
+				InputSize: int64(len(in)),
+				Nodes:     p.nodes,
+				Aliases:   d.aliasCount,
+				Err:       err,
+			})
+		}()
+	}
+	defer handleErr(&err)
 	node := p.parse()
 	if node != nil {
 		v := reflect.ValueOf(out)
@@ -167,7 +730,7 @@ func unmarshal(in []byte, out interface{}, strict bool) (err error) {
 		d.unmarshal(node, v)
 	}
 	if len(d.terrors) > 0 {
-		return &TypeError{d.terrors}
+		return newTypeError(d.terrors)
 	}
 	return nil
 }
@@ -195,17 +758,84 @@ func unmarshal(in []byte, out interface{}, strict bool) (err error) {
 //                  fields are zero, unless they implement an IsZero
 //                  method (see the IsZeroer interface type), in which
 //                  case the field will be excluded if IsZero returns true.
+//                  IsZero methods with pointer receivers are used on
+//                  addressable fields.
+//
+//     omitzero     Only include the field if it's not set to the zero
+//                  value for the type, or if it implements an IsZero
+//                  method, if IsZero returns false. Unlike omitempty,
+//                  empty but non-nil slices and maps are included, and
+//                  IsZero methods with pointer receivers are used on
+//                  addressable fields.
 //
 //     flow         Marshal using a flow style (useful for structs,
 //                  sequences and maps).
 //
+//     literal      Marshal a string field using the literal (|) block
+//                  style, or the folded (>) one with folded. Block
+//                  styles are only used where YAML allows them, such as
+//                  outside of flow collections.
+//
+//     singlequoted Marshal a string field within single quotes, or
+//                  double quotes with doublequoted, even if it could be
+//                  written as a plain scalar.
+//
 //     inline       Inline the field, which must be a struct or a map,
 //                  causing all of its fields or keys to be processed as if
 //                  they were part of the outer struct. For maps, keys must
 //                  not conflict with the yaml keys of other struct fields.
+//                  Interface fields may also be inlined, in which case
+//                  their dynamic value, which must be a struct or a map
+//                  or a pointer to one of those, is inlined when encoding,
+//                  and the keys that match no other field are decoded into
+//                  it, or into a new map if the interface is nil.
+//
+//     squash       Inline the field, which must be a struct or a pointer
+//                  to one, whether it's embedded or named, as mapstructure
+//                  does. Keys that collide with the keys of other fields
+//                  are reported as errors, naming both fields.
+//
+//     rest         Collect into the field, which must be a map with string
+//                  keys such as map[string]Node or map[string]interface{},
+//                  all the keys that match no other field when decoding,
+//                  and encode its keys as if they were part of the struct.
+//                  This is the same as inlining a map field.
+//
+//     discriminator=<key>
+//                  Read the discriminator of the values decoded into the
+//                  field from the key instead of the one of the registry
+//                  used for them. See Decoder.RegisterUnion.
+//
+//     anchor=<name>
+//                  Marshal the value of the field with the anchor, so
+//                  that fields marshalled after it may refer to it.
+//
+//     alias=<name> Marshal the field as an alias of the anchor if it was
+//                  set by a field marshalled earlier in the document with
+//                  a value deeply equal to the one of the field, or as
+//                  usual otherwise. Aliases are resolved when decoding.
+//
+//     required     Make decoding fail when a mapping decoded into the
+//                  struct doesn't have the key, directly or through a
+//                  merge key. The field is marshalled as usual.
 //
 // In addition, if the key is "-", the field is ignored.
 //
+// Fields without a yaml tag use their json tag instead, if any, keeping
+// only its omitempty, omitzero and inline flags.
+//
+// The text of a "yamlcomment" tag, if any, is written as a comment before
+// the key of the field, with a "# " prefix added to each of its lines:
+//
+//     type Config struct {
+//         Port int `yaml:"port" yamlcomment:"Port to listen on."`
+//     }
+//
+// Values of fields, map entries or sequence items whose type is io.Reader,
+// or an interface that embeds it, are written as !!binary scalars, whose
+// base64 text is written as the data is read, without holding it all, so
+// that large files may be encoded. Reading errors make marshalling fail.
+//
 // For example:
 //
 //     type T struct {
@@ -239,6 +869,13 @@ func NewEncoder(w io.Writer) *Encoder {
 	}
 }
 
+// Reset discards the state of the encoder, including any output not yet
+// flushed by Close, and makes it write to w, as a new encoder would, so
+// that it may be reused. The settings of the encoder are kept.
+func (e *Encoder) Reset(w io.Writer) {
+	e.encoder.reset(w)
+}
+
 // Encode writes the YAML encoding of v to the stream.
 // If multiple items are encoded to the stream, the
 // second and subsequent document will be preceded
@@ -246,12 +883,25 @@ func NewEncoder(w io.Writer) *Encoder {
 //
 // See the documentation for Marshal for details about the conversion of Go
 // values to YAML.
+//
+// Each document is written to the stream once encoded, and an error
+// returned by the writer is returned by the call that wrote it, wrapped so
+// that errors.Is and errors.As see it. The encoder then stops writing, and
+// later calls, including Close, return the same error.
 func (e *Encoder) Encode(v interface{}) (err error) {
 	defer handleErr(&err)
 	e.encoder.marshalDoc("", reflect.ValueOf(v))
 	return nil
 }
 
+// EncodeContext is like Encode, but fails with the error of ctx once it's
+// done, as checked before emitting each event of the output.
+func (e *Encoder) EncodeContext(ctx context.Context, v interface{}) error {
+	e.encoder.ctx = ctx
+	defer func() { e.encoder.ctx = nil }()
+	return e.Encode(v)
+}
+
 // Encode encodes value v and stores its representation in n.
 //
 // See the documentation for Marshal for details about the
@@ -270,6 +920,25 @@ func (n *Node) Encode(v interface{}) (err error) {
 	return nil
 }
 
+// EncodeInto encodes value v like Encode, but updates n in place rather
+// than replacing it, so that the comments, styles and anchors of the
+// nodes that remain are kept, as well as the order of the mapping keys
+// that remain. New mapping keys are added after the existing ones, and
+// keys that v doesn't have are removed. If n is a document node, its
+// content is updated.
+func (n *Node) EncodeInto(v interface{}) error {
+	var updated Node
+	if err := updated.Encode(v); err != nil {
+		return err
+	}
+	if n.Kind == DocumentNode && len(n.Content) == 1 {
+		updateNode(n.Content[0], &updated)
+	} else {
+		updateNode(n, &updated)
+	}
+	return nil
+}
+
 // SetIndent changes the used indentation used when encoding.
 func (e *Encoder) SetIndent(spaces int) {
 	if spaces < 0 {
@@ -278,6 +947,111 @@ func (e *Encoder) SetIndent(spaces int) {
 	e.encoder.indent = spaces
 }
 
+// SetMappingIndent changes the indentation used for block mappings when
+// encoding, overriding the one set with SetIndent. Zero restores the use
+// of the latter.
+func (e *Encoder) SetMappingIndent(spaces int) {
+	if spaces < 0 {
+		panic("yaml: cannot indent to a negative number of spaces")
+	}
+	e.encoder.emitter.best_mapping_indent = spaces
+}
+
+// SetSequenceIndent changes the indentation used for block sequences when
+// encoding, overriding the one set with SetIndent. Zero restores the use
+// of the latter. For example, with SetIndent(4) and SetSequenceIndent(2)
+// the items of a sequence in a mapping are written two spaces past the
+// key, while the entries of a nested mapping use four spaces.
+func (e *Encoder) SetSequenceIndent(spaces int) {
+	if spaces < 0 {
+		panic("yaml: cannot indent to a negative number of spaces")
+	}
+	e.encoder.emitter.best_sequence_indent = spaces
+}
+
+// SetWidth changes the preferred width of the lines written by the
+// encoder, past which long scalars are folded when their style allows
+// it. Zero or a negative width, the default, means no limit.
+func (e *Encoder) SetWidth(width int) {
+	if width <= 0 {
+		width = -1
+	}
+	yaml_emitter_set_width(&e.encoder.emitter, width)
+}
+
+// SetBinaryWidth changes the length of the lines of base64 text written
+// for binary values, such as invalid UTF-8 strings and io.Reader data,
+// which is 70 by default. Zero or a negative width writes the text on a
+// single line, in the plain style when possible.
+func (e *Encoder) SetBinaryWidth(width int) {
+	if width <= 0 {
+		width = -1
+	}
+	e.encoder.emitter.binary_width = width
+}
+
+// MultilineRules defines how the encoder chooses the style of strings
+// that span several lines and have no style set otherwise, such as with
+// a style flag. By default they are all written in the literal style.
+// Strings in flow collections are always double-quoted, and binary
+// values are always written in the literal style.
+type MultilineRules struct {
+	// MinLines is the number of lines from which strings are written in
+	// a block style, counting the last line whether or not it ends with
+	// a line break. Strings with fewer lines are double-quoted.
+	MinLines int
+
+	// FoldLength is the length, in characters, of the longest line from
+	// which strings are written in the folded style rather than the
+	// literal style, so that long lines are wrapped at the width set
+	// with SetWidth. Zero never selects the folded style.
+	FoldLength int
+
+	// QuoteUnterminated makes strings that don't end with a line break
+	// double-quoted, rather than written in a block style with the
+	// strip chomping indicator.
+	QuoteUnterminated bool
+}
+
+// SetMultilineRules changes the rules used to choose the style of
+// strings that span several lines.
+func (e *Encoder) SetMultilineRules(rules MultilineRules) {
+	e.encoder.multiline = rules
+}
+
+// A LineBreak defines the line breaks written by the encoder.
+type LineBreak int
+
+const (
+	// LFBreak writes "\n" line breaks. This is the default.
+	LFBreak LineBreak = iota
+
+	// CRLFBreak writes "\r\n" line breaks, as used on Windows.
+	CRLFBreak
+)
+
+// DetectLineBreak returns CRLFBreak if the first line of data ends with
+// "\r\n", and LFBreak otherwise, so that a document read from data may be
+// written back with the same line breaks.
+func DetectLineBreak(data []byte) LineBreak {
+	i := bytes.IndexByte(data, '\n')
+	if i > 0 && data[i-1] == '\r' {
+		return CRLFBreak
+	}
+	return LFBreak
+}
+
+// SetLineBreak changes the line breaks written by the encoder, including
+// those in the values of block and quoted scalars, which are decoded as
+// "\n" whatever the line breaks of the input.
+func (e *Encoder) SetLineBreak(lb LineBreak) {
+	if lb == CRLFBreak {
+		yaml_emitter_set_break(&e.encoder.emitter, yaml_CRLN_BREAK)
+	} else {
+		yaml_emitter_set_break(&e.encoder.emitter, yaml_LN_BREAK)
+	}
+}
+
 // CompactSeqIndent makes it so that '- ' is considered part of the indentation.
 func (e *Encoder) CompactSeqIndent() {
 	e.encoder.emitter.compact_sequence_indent = true
@@ -288,6 +1062,164 @@ func (e *Encoder) DefaultSeqIndent() {
 	e.encoder.emitter.compact_sequence_indent = false
 }
 
+// PreserveLexemes makes the encoder write the original text recorded in
+// Node.Lexeme for int and float scalars whose Value still holds the same
+// number, even if it has since been rendered differently (1e3 rather than
+// 1000, or 1.50 rather than 1.5).
+func (e *Encoder) PreserveLexemes(enable bool) {
+	e.encoder.preserveLexemes = enable
+}
+
+// PreserveTemplates makes the encoder write Go template actions, such as
+// {{ .Values.name }}, as they are: scalars holding them are never folded,
+// and are quoted or escaped only as the text around the actions requires.
+// The output is meant to be read by a decoder with the same setting; see
+// Decoder.PreserveTemplates.
+func (e *Encoder) PreserveTemplates(enable bool) {
+	e.encoder.emitter.templates = enable
+}
+
+// ShareMappings makes the encoder write a mapping that holds at least
+// two of the entries of an earlier mapping as a "<<" merge of that
+// mapping, followed by the entries it adds or overrides. The merged
+// mappings are anchored with the name of their key when possible. This
+// applies to Go values only, not to encoded Node values.
+func (e *Encoder) ShareMappings(enable bool) {
+	e.encoder.shareMappings = enable
+}
+
+// AliasRepeats makes the encoder write a mapping or sequence that is
+// equal to an earlier one in the document as an alias of that one, which
+// is anchored with the name of its key when possible, so that repeated
+// blocks are written once. Collections of a single scalar are written as
+// they are, as well as those holding anchors. This applies to Go values
+// only, not to encoded Node values, and is done after ShareMappings.
+func (e *Encoder) AliasRepeats(enable bool) {
+	e.encoder.aliasRepeats = enable
+}
+
+// AnchorCycles makes the encoder write the Go values that refer back to
+// themselves, through pointers, maps or slices, with an anchor, and the
+// references back to them as aliases of that anchor. Otherwise encoding
+// such values fails naming the path of the reference. Cycles in Node
+// values always fail, as these may hold aliases of their own.
+func (e *Encoder) AnchorCycles(enable bool) {
+	e.encoder.anchorCycles = enable
+}
+
+// EncodeSets makes the encoder write the Go maps with struct{} values as
+// !!set mappings, with null values. Otherwise they are written as plain
+// mappings of empty mappings.
+func (e *Encoder) EncodeSets(enable bool) {
+	e.encoder.sets = enable
+}
+
+// SetTagHandle declares a tag handle, such as "!k8s!", that abbreviates
+// the tags starting with prefix, such as "tag:kubernetes.io,2024:", so
+// that a "tag:kubernetes.io,2024:Pod" tag is written as "!k8s!Pod". The
+// tags of encoded nodes may also be given in that abbreviated form. Every
+// document written afterwards starts with a %TAG directive for each
+// declared handle, unless its document node declares that handle itself.
+// An empty prefix removes the handle.
+func (e *Encoder) SetTagHandle(handle, prefix string) {
+	handles := e.encoder.tagHandles[:0]
+	for _, td := range e.encoder.tagHandles {
+		if td.Handle != handle {
+			handles = append(handles, td)
+		}
+	}
+	if prefix != "" {
+		handles = append(handles, TagDirective{Handle: handle, Prefix: prefix})
+	}
+	e.encoder.tagHandles = handles
+}
+
+// SetSchema changes the schema used when encoding to tell which strings
+// must be quoted, and which scalar nodes need an explicit tag, so that
+// they are decoded back as the same values with that schema. Values of
+// other types are written as usual.
+func (e *Encoder) SetSchema(schema Schema) {
+	e.encoder.schema = schema
+}
+
+// SetTimeLayout changes the layout used to encode time.Time values, as
+// accepted by time.Time.Format. The default layout is time.RFC3339Nano.
+// Values encoded with layouts that are not among the forms of the YAML
+// timestamp type, such as time.RFC1123, are decoded back as strings
+// rather than timestamps.
+func (e *Encoder) SetTimeLayout(layout string) {
+	e.encoder.timeLayout = layout
+}
+
+// A Schema defines how the tags of untagged plain scalars, such as 42,
+// true or hello, are resolved.
+type Schema int
+
+const (
+	// DefaultSchema resolves plain scalars as the YAML 1.2 core schema
+	// does, and also accepts some YAML 1.1 forms such as timestamps,
+	// binary integers, octal integers written as 0777, and digits
+	// separated by underscores. This is the default.
+	DefaultSchema Schema = iota
+
+	// FailsafeSchema resolves all plain scalars as strings.
+	FailsafeSchema
+
+	// JSONSchema resolves the plain scalars null, true, false and
+	// numbers written as in JSON, and rejects all other plain scalars,
+	// which must be quoted to be strings.
+	JSONSchema
+
+	// CoreSchema resolves plain scalars as defined by the YAML 1.2
+	// core schema, which extends the JSON schema with other spellings
+	// of null and booleans, hexadecimal and 0o octal integers, and
+	// infinite and not-a-number floats. Other plain scalars are strings.
+	CoreSchema
+)
+
+// A DurationFormat defines how time.Duration values are represented.
+type DurationFormat int
+
+const (
+	// DurationString represents durations as strings such as "1h30m",
+	// as produced by time.Duration.String. This is the default.
+	DurationString DurationFormat = iota
+
+	// DurationSeconds represents durations as a number of seconds,
+	// such as 5400 or 1.5.
+	DurationSeconds
+
+	// DurationNanoseconds represents durations as an integer number
+	// of nanoseconds.
+	DurationNanoseconds
+)
+
+// SetDurationFormat changes how time.Duration values are encoded.
+func (e *Encoder) SetDurationFormat(format DurationFormat) {
+	e.encoder.durations = format
+}
+
+// A TagEmitter returns the node that represents the provided value
+// when encoding. See Encoder.RegisterTagEmitter.
+type TagEmitter func(v interface{}) (*Node, error)
+
+// RegisterTagEmitter makes the encoder represent values of type t, and
+// pointers to such values, with the node returned by emit. This allows
+// producing tagged nodes such as "!Ref foo" for types that cannot
+// implement the Marshaler interface. Registered emitters take precedence
+// over the Marshaler and encoding.TextMarshaler interfaces. A nil emit
+// removes the emitter registered for t.
+func (e *Encoder) RegisterTagEmitter(t reflect.Type, emit TagEmitter) {
+	if emit == nil {
+		delete(e.encoder.emitters, t)
+		return
+	}
+	if e.encoder.emitters == nil {
+		e.encoder.emitters = make(map[reflect.Type]TagEmitter)
+	}
+	e.encoder.emitters[t] = emit
+}
+
 // Close closes the encoder by writing any remaining data.
 // It does not write a stream terminating string "...".
 func (e *Encoder) Close() (err error) {
@@ -324,12 +1256,200 @@ func failf(format string, args ...interface{}) {
 // unmarshaled partially.
 type TypeError struct {
 	Errors []string
+
+	// Details holds the entries of Errors in structured form, when the
+	// error was reported by this package.
+	Details []*UnmarshalError
+}
+
+func newTypeError(details []*UnmarshalError) *TypeError {
+	errs := make([]string, len(details))
+	for i, d := range details {
+		errs[i] = d.Message
+	}
+	return &TypeError{Errors: errs, Details: details}
 }
 
 func (e *TypeError) Error() string {
 	return fmt.Sprintf("yaml: unmarshal errors:\n  %s", strings.Join(e.Errors, "\n  "))
 }
 
+// details returns the entries of e in structured form, making them up
+// from Errors when e was built without them, as by an Unmarshaler.
+func (e *TypeError) details() []*UnmarshalError {
+	if len(e.Details) == len(e.Errors) {
+		return e.Details
+	}
+	details := make([]*UnmarshalError, len(e.Errors))
+	for i, msg := range e.Errors {
+		details[i] = &UnmarshalError{Message: msg}
+	}
+	return details
+}
+
+// As makes errors.As find an UnknownFieldError holding the unknown fields
+// reported by e, if any, when decoding with KnownFields enabled.
+func (e *TypeError) As(target interface{}) bool {
+	t, ok := target.(**UnknownFieldError)
+	if !ok {
+		return false
+	}
+	var fields []UnknownField
+	for _, err := range e.Details {
+		if err.UnknownField != nil {
+			fields = append(fields, *err.UnknownField)
+		}
+	}
+	if len(fields) == 0 {
+		return false
+	}
+	*t = &UnknownFieldError{Fields: fields}
+	return true
+}
+
+// An UnmarshalError describes a value that could not be decoded, as
+// reported by a TypeError.
+type UnmarshalError struct {
+	// Message holds the description of the error, including its line.
+	Message string
+
+	// Path holds the location of the value in the decoded value, such
+	// as "spec.containers[0].image".
+	Path string
+
+	// Line and Column hold the value position in the decoded YAML text.
+	Line   int
+	Column int
+
+	// ExpectedType holds the type the value was decoded into, if any.
+	ExpectedType reflect.Type
+
+	// ActualTag holds the tag of the value, in its short form, when the
+	// error is due to the value not fitting ExpectedType.
+	ActualTag string
+
+	// UnknownField holds the mapping key when the error is due to it not
+	// existing as a field of ExpectedType, with KnownFields enabled.
+	UnknownField *UnknownField
+}
+
+func (e *UnmarshalError) Error() string {
+	return e.Message
+}
+
+// A Warning describes a problem found when decoding that didn't prevent
+// the value from being decoded. See Decoder.SetWarningHandler.
+type Warning struct {
+	// Message holds the description of the problem, including its line.
+	Message string
+
+	// Path holds the location of the value in the decoded value, such
+	// as "spec.containers[0].image".
+	Path string
+
+	// Line and Column hold the value position in the decoded YAML text.
+	Line   int
+	Column int
+}
+
+// A MaxDepthError is returned when the YAML content is nested deeper
+// than allowed by the decoder. See Decoder.SetMaxDepth.
+type MaxDepthError struct {
+	// Max holds the maximum depth that was exceeded.
+	Max int
+
+	// Line and Column hold the position of the collection that
+	// exceeded the maximum depth.
+	Line   int
+	Column int
+}
+
+func (e *MaxDepthError) Error() string {
+	return fmt.Sprintf("yaml: exceeded max depth of %d", e.Max)
+}
+
+// A SyntaxError is returned when the YAML text is malformed.
+type SyntaxError struct {
+	// Message describes the problem.
+	Message string
+
+	// Line and Column hold the 1-based position of the problem, and
+	// Offset the number of bytes preceding it in the input. They are
+	// zero, and Offset is -1, when the position is unknown.
+	Line   int
+	Column int
+	Offset int
+
+	// Snippet holds the text of the line with the problem, without
+	// the line break, and Caret the number of characters preceding
+	// the problem within it. Snippet is empty when the line is not
+	// available, such as when it was read from an io.Reader too long
+	// before the problem was found.
+	Snippet string
+	Caret   int
+
+	// line holds the line number reported by Error, which predates
+	// the position above and is kept for compatibility, and column the
+	// column reported along with it, for the problems that come from
+	// common mistakes, whose messages tell how to fix them.
+	line, column int
+}
+
+func (e *SyntaxError) Error() string {
+	if e.column != 0 {
+		return fmt.Sprintf("yaml: line %d, column %d: %s", e.line, e.column, e.Message)
+	}
+	if e.line != 0 {
+		return fmt.Sprintf("yaml: line %d: %s", e.line, e.Message)
+	}
+	return "yaml: " + e.Message
+}
+
+// An UnknownField describes a mapping key that does not exist as a
+// field in the struct it was decoded into.
+type UnknownField struct {
+	// Name holds the mapping key.
+	Name string
+
+	// Path holds the location of the key in the decoded value, such as
+	// "spec.containers[0].image".
+	Path string
+
+	// Line and Column hold the key position in the decoded YAML text.
+	Line   int
+	Column int
+
+	// Suggestion holds the key of the field of the struct closest to
+	// Name, if it's close enough to be a likely misspelling of it, such
+	// as "imagePullPolicy" for "imagePulPolicy".
+	Suggestion string
+}
+
+// didYouMean returns the end of a message about f suggesting the field
+// that was likely meant, if any.
+func (f *UnknownField) didYouMean() string {
+	if f.Suggestion == "" {
+		return ""
+	}
+	return ", did you mean " + strconv.Quote(f.Suggestion) + "?"
+}
+
+// An UnknownFieldError holds the mapping keys that do not exist as fields
+// in the structs they were decoded into. It may be obtained with errors.As
+// from the TypeError returned when decoding with KnownFields enabled.
+type UnknownFieldError struct {
+	Fields []UnknownField
+}
+
+func (e *UnknownFieldError) Error() string {
+	var b strings.Builder
+	b.WriteString("yaml: unknown fields:")
+	for _, f := range e.Fields {
+		fmt.Fprintf(&b, "\n  line %d: field %s%s", f.Line, f.Path, f.didYouMean())
+	}
+	return b.String()
+}
+
 type Kind uint32
 
 const (
@@ -340,6 +1460,21 @@ const (
 	AliasNode
 )
 
+var kindStrings = map[Kind]string{
+	DocumentNode: "document",
+	SequenceNode: "sequence",
+	MappingNode:  "mapping",
+	ScalarNode:   "scalar",
+	AliasNode:    "alias",
+}
+
+func (k Kind) String() string {
+	if s, ok := kindStrings[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("unknown kind %d", uint32(k))
+}
+
 type Style uint32
 
 const (
@@ -351,6 +1486,22 @@ const (
 	FlowStyle
 )
 
+// Chomping defines how the line breaks at the end of a literal or folded
+// scalar are kept, as set by its chomping indicator.
+type Chomping int8
+
+const (
+	// ClipChomping keeps a single line break, and is written without an
+	// indicator.
+	ClipChomping Chomping = iota
+
+	// StripChomping drops all line breaks, as written with "-".
+	StripChomping
+
+	// KeepChomping keeps all line breaks, as written with "+".
+	KeepChomping
+)
+
 // Node represents an element in the YAML document hierarchy. While documents
 // are typically encoded and decoded into higher level types, such as structs
 // and maps, Node is an intermediate representation that allows detailed
@@ -409,26 +1560,109 @@ type Node struct {
 	// Content holds contained nodes for documents, mappings, and sequences.
 	Content []*Node
 
+	// Version holds the version declared by the %YAML directive of a
+	// document node, such as "1.1", if any.
+	Version string
+
+	// TagDirectives holds the %TAG directives of a document node, which
+	// declare the handles that abbreviate tags within the document.
+	TagDirectives []TagDirective
+
 	// HeadComment holds any comments in the lines preceding the node and
 	// not separated by an empty line.
 	HeadComment string
 
 	// LineComment holds any comments at the end of the line where the node is in.
+	// The line of a document node is the one of its "---" start indicator.
 	LineComment string
 
 	// FootComment holds any comments following the node and before empty lines.
 	FootComment string
 
+	// OpenComment holds the comment following the opening '[' or '{' of a
+	// flow collection on the same line, and CloseComment holds any comments
+	// before its closing ']' or '}' that don't belong to the last entry.
+	OpenComment  string
+	CloseComment string
+
+	// EmptyLinesBefore holds the number of empty lines preceding the node and
+	// its head comment when it is a key or an item of a block mapping or
+	// sequence, other than the first one. The single empty line that always
+	// follows a foot comment of the prior entry is not included.
+	EmptyLinesBefore int
+
+	// Lexeme holds the original text of a plain int or float scalar when it
+	// differs from the canonical rendering of its value, such as 0x1A, 0o17,
+	// 1_000, 1e3 or 1.50. When encoding an integer scalar whose Value is in
+	// decimal form, the base, digit case and grouping found in Lexeme are
+	// used to render it. See Encoder.PreserveLexemes for reusing the exact
+	// original text of unmodified values.
+	Lexeme string
+
+	// Chomping holds the chomping indicator of a literal or folded scalar,
+	// which defines how the line breaks ending it are kept. When encoding,
+	// it's written if it fits the line breaks ending Value, and otherwise
+	// the indicator they need is written.
+	Chomping Chomping
+
+	// IndentIndicator holds the indentation indicator of a literal or
+	// folded scalar, such as 2 for "|2", which sets the number of spaces
+	// its content is indented past its parent node, or zero if it had
+	// none. When encoding, an indicator from 1 to 9 is written and the
+	// content indented accordingly. Otherwise the encoder only writes an
+	// indicator when the content starts with spaces or line breaks.
+	IndentIndicator int
+
 	// Line and Column hold the node position in the decoded YAML text.
 	// These fields are not respected when encoding the node.
 	Line   int
 	Column int
+
+	// Source names the input the node was decoded from, such as a file
+	// name, so that the origin of nodes is known once documents from
+	// several inputs are merged. See Decoder.SetSource and Merge. This
+	// field is not respected when encoding the node.
+	Source string
+}
+
+// TagDirective is a %TAG directive, declaring that tags written with
+// Handle, such as "!e!", start with Prefix, such as "tag:example.com,2000:".
+type TagDirective struct {
+	Handle string
+	Prefix string
 }
 
 // IsZero returns whether the node has all of its fields unset.
 func (n *Node) IsZero() bool {
 	return n.Kind == 0 && n.Style == 0 && n.Tag == "" && n.Value == "" && n.Anchor == "" && n.Alias == nil && n.Content == nil &&
-		n.HeadComment == "" && n.LineComment == "" && n.FootComment == "" && n.Line == 0 && n.Column == 0
+		n.Version == "" && n.TagDirectives == nil &&
+		n.HeadComment == "" && n.LineComment == "" && n.FootComment == "" && n.OpenComment == "" && n.CloseComment == "" && n.EmptyLinesBefore == 0 && n.Lexeme == "" && n.Chomping == 0 && n.IndentIndicator == 0 && n.Line == 0 && n.Column == 0 && n.Source == ""
+}
+
+// IsScalar returns whether n is a scalar node.
+func (n *Node) IsScalar() bool {
+	return n.Kind == ScalarNode
+}
+
+// IsMapping returns whether n is a mapping node.
+func (n *Node) IsMapping() bool {
+	return n.Kind == MappingNode
+}
+
+// IsSequence returns whether n is a sequence node.
+func (n *Node) IsSequence() bool {
+	return n.Kind == SequenceNode
+}
+
+// IsAlias returns whether n is an alias node.
+func (n *Node) IsAlias() bool {
+	return n.Kind == AliasNode
+}
+
+// IsNull returns whether n represents a null value, such as a null or ~
+// plain scalar, a scalar tagged !!null, an alias of one, or a zero node.
+func (n *Node) IsNull() bool {
+	return n.ShortTag() == nullTag
 }
 
 // LongTag returns the long form of the tag that indicates the data type for
@@ -491,6 +1725,103 @@ func (n *Node) SetString(s string) {
 	}
 }
 
+// SetBinary is a convenience function that sets the node to a binary
+// value, written as wrapped base64 text in the literal style if long.
+func (n *Node) SetBinary(b []byte) {
+	n.Kind = ScalarNode
+	n.Tag = binaryTag
+	n.Value = encodeBase64(string(b))
+	n.Style = 0
+	if strings.Contains(n.Value, "\n") {
+		n.Style = LiteralStyle
+	}
+}
+
+// SetInt is a convenience function that sets the node to an int value.
+func (n *Node) SetInt(i int64) {
+	n.setScalar(intTag, strconv.FormatInt(i, 10))
+}
+
+// SetFloat is a convenience function that sets the node to a float value,
+// written as .inf, -.inf or .nan for the special values.
+func (n *Node) SetFloat(f float64) {
+	n.setScalar(floatTag, formatFloat(f, 64))
+}
+
+// SetBool is a convenience function that sets the node to a bool value.
+func (n *Node) SetBool(b bool) {
+	n.setScalar(boolTag, strconv.FormatBool(b))
+}
+
+// SetNull is a convenience function that sets the node to a null value.
+func (n *Node) SetNull() {
+	n.setScalar(nullTag, "null")
+}
+
+// setScalar sets the node to a plain scalar with the tag and value.
+func (n *Node) setScalar(tag, value string) {
+	n.Kind = ScalarNode
+	n.Tag = tag
+	n.Value = value
+	n.Style = 0
+}
+
+// Anchors returns the nodes with an anchor within n, including n itself,
+// by anchor name. Aliases are not followed. When an anchor is defined more
+// than once, the last node defining it is returned, as it is the one
+// referred to by the aliases that follow.
+func (n *Node) Anchors() map[string]*Node {
+	anchors := make(map[string]*Node)
+	n.collectAnchors(anchors)
+	return anchors
+}
+
+func (n *Node) collectAnchors(anchors map[string]*Node) {
+	if n.Anchor != "" {
+		anchors[n.Anchor] = n
+	}
+	for _, ni := range n.Content {
+		ni.collectAnchors(anchors)
+	}
+}
+
+// A RawNode holds a subtree of a decoded YAML document without decoding
+// it into a Go value, so that doing so may be deferred or skipped. For
+// example, decoding into a map[string]RawNode only decodes the keys of
+// a mapping. Unlike a Node value, a RawNode does not copy the subtree.
+// A RawNode is encoded as the subtree it holds.
+type RawNode struct {
+	node *Node
+}
+
+// Node returns the subtree held by r, or nil if r is empty.
+func (r RawNode) Node() *Node {
+	return r.node
+}
+
+// Decode decodes the subtree held by r into v, as Node.Decode does.
+// Decoding an empty RawNode leaves v unchanged.
+func (r RawNode) Decode(v interface{}) error {
+	if r.node == nil {
+		return nil
+	}
+	return r.node.Decode(v)
+}
+
+// UnmarshalYAML implements the Unmarshaler interface.
+func (r *RawNode) UnmarshalYAML(value *Node) error {
+	r.node = value
+	return nil
+}
+
+// MarshalYAML implements the Marshaler interface.
+func (r RawNode) MarshalYAML() (interface{}, error) {
+	if r.node == nil {
+		return nil, nil
+	}
+	return r.node, nil
+}
+
 // --------------------------------------------------------------------------
 // Maintain a mapping of keys to structure field indexes
 
@@ -502,20 +1833,57 @@ type structInfo struct {
 	FieldsMap  map[string]fieldInfo
 	FieldsList []fieldInfo
 
+	// FieldsFold maps lower cased keys to the first field with
+	// a matching key, for case-insensitive matching.
+	FieldsFold map[string]fieldInfo
+
 	// InlineMap is the number of the field in the struct that
 	// contains an ,inline map, or -1 if there's none.
 	InlineMap int
 
+	// InlineIface is the number of the field in the struct that
+	// contains an ,inline interface, or -1 if there's none.
+	InlineIface int
+
 	// InlineUnmarshalers holds indexes to inlined fields that
 	// contain unmarshaler values.
 	InlineUnmarshalers [][]int
+
+	// Required is set if any field has the required flag.
+	Required bool
+}
+
+// keys returns the keys of the fields of the struct.
+func (sinfo *structInfo) keys() []string {
+	keys := make([]string, len(sinfo.FieldsList))
+	for i, finfo := range sinfo.FieldsList {
+		keys[i] = finfo.Key
+	}
+	return keys
 }
 
 type fieldInfo struct {
 	Key       string
 	Num       int
 	OmitEmpty bool
+	OmitZero  bool
 	Flow      bool
+	Required  bool
+
+	// Discriminator holds the key set by the discriminator flag, if any.
+	Discriminator string
+
+	// HeadComment holds the text of the yamlcomment tag, if any.
+	HeadComment string
+
+	// Style holds the scalar style set by a style flag, if any.
+	Style yaml_scalar_style_t
+
+	// Anchor and Alias hold the names set by the anchor and alias flags,
+	// if any.
+	Anchor string
+	Alias  string
+
 	// Id holds the unique field identifier, so we can cheaply
 	// check for field duplicates without maintaining an extra map.
 	Id int
@@ -524,6 +1892,14 @@ type fieldInfo struct {
 	Inline []int
 }
 
+// fieldStyles holds the scalar styles of the style flags of fields.
+var fieldStyles = map[string]yaml_scalar_style_t{
+	"literal":      yaml_LITERAL_SCALAR_STYLE,
+	"folded":       yaml_FOLDED_SCALAR_STYLE,
+	"singlequoted": yaml_SINGLE_QUOTED_SCALAR_STYLE,
+	"doublequoted": yaml_DOUBLE_QUOTED_SCALAR_STYLE,
+}
+
 var structMap = make(map[reflect.Type]*structInfo)
 var fieldMapMutex sync.RWMutex
 var unmarshalerType reflect.Type
@@ -545,6 +1921,7 @@ func getStructInfo(st reflect.Type) (*structInfo, error) {
 	fieldsMap := make(map[string]fieldInfo)
 	fieldsList := make([]fieldInfo, 0, n)
 	inlineMap := -1
+	inlineIface := -1
 	inlineUnmarshalers := [][]int(nil)
 	for i := 0; i != n; i++ {
 		field := st.Field(i)
@@ -552,44 +1929,102 @@ func getStructInfo(st reflect.Type) (*structInfo, error) {
 			continue // Private field
 		}
 
-		info := fieldInfo{Num: i}
+		info := fieldInfo{Num: i, HeadComment: field.Tag.Get("yamlcomment")}
 
-		tag := field.Tag.Get("yaml")
-		if tag == "" && strings.Index(string(field.Tag), ":") < 0 {
-			tag = string(field.Tag)
+		tag, ok := field.Tag.Lookup("yaml")
+		if !ok {
+			if strings.Index(string(field.Tag), ":") < 0 {
+				tag = string(field.Tag)
+			} else {
+				tag = jsonTag(field.Tag)
+			}
 		}
 		if tag == "-" {
 			continue
 		}
 
-		inline := false
+		inline, rest, squash := false, false, false
 		fields := strings.Split(tag, ",")
 		if len(fields) > 1 {
 			for _, flag := range fields[1:] {
+				if style, ok := fieldStyles[flag]; ok {
+					if info.Style != 0 {
+						return nil, errors.New(fmt.Sprintf("conflicting style flags in tag %q of type %s", tag, st))
+					}
+					info.Style = style
+					continue
+				}
 				switch flag {
 				case "omitempty":
 					info.OmitEmpty = true
+				case "omitzero":
+					info.OmitZero = true
 				case "flow":
 					info.Flow = true
+				case "required":
+					info.Required = true
 				case "inline":
 					inline = true
+				case "squash":
+					squash = true
+				case "rest":
+					rest = true
 				default:
+					if key := strings.TrimPrefix(flag, "discriminator="); key != flag && key != "" {
+						info.Discriminator = key
+						continue
+					}
+					if name := strings.TrimPrefix(flag, "anchor="); name != flag && name != "" {
+						info.Anchor = name
+						continue
+					}
+					if name := strings.TrimPrefix(flag, "alias="); name != flag && name != "" {
+						info.Alias = name
+						continue
+					}
 					return nil, errors.New(fmt.Sprintf("unsupported flag %q in tag %q of type %s", flag, tag, st))
 				}
 			}
 			tag = fields[0]
 		}
 
+		if rest {
+			if inline || field.Type.Kind() != reflect.Map || field.Type.Key() != reflect.TypeOf("") {
+				return nil, errors.New("option ,rest needs a map with string keys in struct " + st.String())
+			}
+			if inlineMap >= 0 || inlineIface >= 0 {
+				return nil, errors.New("multiple ,inline maps in struct " + st.String())
+			}
+			inlineMap = info.Num
+			continue
+		}
+
+		if squash {
+			ftype := field.Type
+			for ftype.Kind() == reflect.Ptr {
+				ftype = ftype.Elem()
+			}
+			if inline || rest || ftype.Kind() != reflect.Struct {
+				return nil, errors.New("option ,squash may only be used on a struct field in struct " + st.String())
+			}
+			inline = true
+		}
+
 		if inline {
 			switch field.Type.Kind() {
 			case reflect.Map:
-				if inlineMap >= 0 {
+				if inlineMap >= 0 || inlineIface >= 0 {
 					return nil, errors.New("multiple ,inline maps in struct " + st.String())
 				}
 				if field.Type.Key() != reflect.TypeOf("") {
 					return nil, errors.New("option ,inline needs a map with string keys in struct " + st.String())
 				}
 				inlineMap = info.Num
+			case reflect.Interface:
+				if inlineMap >= 0 || inlineIface >= 0 {
+					return nil, errors.New("multiple ,inline maps in struct " + st.String())
+				}
+				inlineIface = info.Num
 			case reflect.Struct, reflect.Ptr:
 				ftype := field.Type
 				for ftype.Kind() == reflect.Ptr {
@@ -609,22 +2044,21 @@ func getStructInfo(st reflect.Type) (*structInfo, error) {
 						inlineUnmarshalers = append(inlineUnmarshalers, append([]int{i}, index...))
 					}
 					for _, finfo := range sinfo.FieldsList {
-						if _, found := fieldsMap[finfo.Key]; found {
-							msg := "duplicated key '" + finfo.Key + "' in struct " + st.String()
-							return nil, errors.New(msg)
-						}
 						if finfo.Inline == nil {
 							finfo.Inline = []int{i, finfo.Num}
 						} else {
 							finfo.Inline = append([]int{i}, finfo.Inline...)
 						}
+						if other, found := fieldsMap[finfo.Key]; found {
+							return nil, duplicatedKeyError(st, finfo, other)
+						}
 						finfo.Id = len(fieldsList)
 						fieldsMap[finfo.Key] = finfo
 						fieldsList = append(fieldsList, finfo)
 					}
 				}
 			default:
-				return nil, errors.New("option ,inline may only be used on a struct or map field")
+				return nil, errors.New("option ,inline may only be used on a struct, map or interface field")
 			}
 			continue
 		}
@@ -635,9 +2069,8 @@ func getStructInfo(st reflect.Type) (*structInfo, error) {
 			info.Key = strings.ToLower(field.Name)
 		}
 
-		if _, found = fieldsMap[info.Key]; found {
-			msg := "duplicated key '" + info.Key + "' in struct " + st.String()
-			return nil, errors.New(msg)
+		if other, found := fieldsMap[info.Key]; found {
+			return nil, duplicatedKeyError(st, info, other)
 		}
 
 		info.Id = len(fieldsList)
@@ -645,11 +2078,24 @@ func getStructInfo(st reflect.Type) (*structInfo, error) {
 		fieldsMap[info.Key] = info
 	}
 
+	required := false
+	fieldsFold := make(map[string]fieldInfo, len(fieldsList))
+	for _, info := range fieldsList {
+		required = required || info.Required
+		key := strings.ToLower(info.Key)
+		if _, found := fieldsFold[key]; !found {
+			fieldsFold[key] = info
+		}
+	}
+
 	sinfo = &structInfo{
 		FieldsMap:          fieldsMap,
 		FieldsList:         fieldsList,
+		FieldsFold:         fieldsFold,
 		InlineMap:          inlineMap,
+		InlineIface:        inlineIface,
 		InlineUnmarshalers: inlineUnmarshalers,
+		Required:           required,
 	}
 
 	fieldMapMutex.Lock()
@@ -658,6 +2104,56 @@ func getStructInfo(st reflect.Type) (*structInfo, error) {
 	return sinfo, nil
 }
 
+// duplicatedKeyError returns the error for the fields of st that have the
+// same key, naming them by their path from st.
+func duplicatedKeyError(st reflect.Type, info, other fieldInfo) error {
+	return fmt.Errorf("duplicated key '%s' in struct %s: field %s conflicts with field %s", info.Key, st, fieldPath(st, info), fieldPath(st, other))
+}
+
+// fieldPath returns the names of the fields leading to the field of st
+// described by info, separated by dots.
+func fieldPath(st reflect.Type, info fieldInfo) string {
+	index := info.Inline
+	if index == nil {
+		index = []int{info.Num}
+	}
+	var names []string
+	t := st
+	for _, i := range index {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		f := t.Field(i)
+		names = append(names, f.Name)
+		t = f.Type
+	}
+	return strings.Join(names, ".")
+}
+
+// jsonTag returns the equivalent yaml tag for the json tag in the
+// provided field tag, keeping only the flags supported by both.
+func jsonTag(tag reflect.StructTag) string {
+	fields := strings.Split(tag.Get("json"), ",")
+	kept := fields[:1:1]
+	for _, flag := range fields[1:] {
+		switch flag {
+		case "omitempty", "omitzero", "inline":
+			kept = append(kept, flag)
+		}
+	}
+	return strings.Join(kept, ",")
+}
+
+// field returns the field matching the given key exactly or, if fold
+// is true and there's no exact match, ignoring case.
+func (sinfo *structInfo) field(key string, fold bool) (info fieldInfo, ok bool) {
+	info, ok = sinfo.FieldsMap[key]
+	if !ok && fold {
+		info, ok = sinfo.FieldsFold[strings.ToLower(key)]
+	}
+	return info, ok
+}
+
 // IsZeroer is used to check whether an object is zero to
 // determine whether it should be omitted when marshaling
 // with the omitempty flag. One notable implementation
@@ -666,6 +2162,25 @@ type IsZeroer interface {
 	IsZero() bool
 }
 
+// isZeroValue reports whether v holds the zero value for its type, as
+// used by the omitzero flag. Values implementing IsZeroer, directly or
+// through their address, are zero when IsZero returns true.
+func isZeroValue(v reflect.Value) bool {
+	kind := v.Kind()
+	if (kind == reflect.Ptr || kind == reflect.Interface) && v.IsNil() {
+		return true
+	}
+	if z, ok := v.Interface().(IsZeroer); ok {
+		return z.IsZero()
+	}
+	if v.CanAddr() {
+		if z, ok := v.Addr().Interface().(IsZeroer); ok {
+			return z.IsZero()
+		}
+	}
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
+
 func isZero(v reflect.Value) bool {
 	kind := v.Kind()
 	if z, ok := v.Interface().(IsZeroer); ok {
@@ -674,6 +2189,11 @@ func isZero(v reflect.Value) bool {
 		}
 		return z.IsZero()
 	}
+	if v.CanAddr() {
+		if z, ok := v.Addr().Interface().(IsZeroer); ok {
+			return z.IsZero()
+		}
+	}
 	switch kind {
 	case reflect.String:
 		return len(v.String()) == 0
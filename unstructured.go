@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"gopkg.in/yaml.v2"
+)
+
+// YAMLToUnstructuredObject converts a YAML document holding a Kubernetes
+// object into the form of unstructured objects, directly rather than
+// through JSON. Values of the result are only of the types string, bool,
+// int64, float64, []interface{}, map[string]interface{} or nil. Mapping
+// keys are converted to strings as done by YAMLToJSON, and an error is
+// returned for values that can't be represented, such as integers beyond
+// the range of int64 or non-finite floats. A document without content
+// returns a nil map, and any other document that isn't a mapping returns
+// an error.
+func YAMLToUnstructuredObject(y []byte) (map[string]interface{}, error) {
+	var yamlObj interface{}
+	if err := yaml.Unmarshal(y, &yamlObj); err != nil {
+		return nil, fmt.Errorf("error converting YAML to unstructured object: %w", err)
+	}
+	if yamlObj == nil {
+		return nil, nil
+	}
+	if _, ok := yamlObj.(map[interface{}]interface{}); !ok {
+		return nil, fmt.Errorf("error converting YAML to unstructured object: expected a mapping, got %s", reflect.TypeOf(yamlObj))
+	}
+	obj, err := convertToUnstructured(yamlObj, "")
+	if err != nil {
+		return nil, fmt.Errorf("error converting YAML to unstructured object: %w", err)
+	}
+	return obj.(map[string]interface{}), nil
+}
+
+// convertToUnstructured converts the value decoded by go-yaml at the given
+// path, which is used in errors.
+func convertToUnstructured(yamlObj interface{}, path string) (interface{}, error) {
+	switch v := yamlObj.(type) {
+	case nil, string, bool, int64:
+		return v, nil
+	case int:
+		return int64(v), nil
+	case uint64:
+		if v > math.MaxInt64 {
+			return nil, fmt.Errorf("%s: integer %d overflows int64", pathOrRoot(path), v)
+		}
+		return int64(v), nil
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return nil, fmt.Errorf("%s: unsupported float value %v", pathOrRoot(path), v)
+		}
+		return v, nil
+	case []interface{}:
+		arr := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if arr[i], err = convertToUnstructured(item, path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			key, ok := yamlKeyToString(k)
+			if !ok {
+				return nil, fmt.Errorf("%s: unsupported map key of type: %s, key: %+#v", pathOrRoot(path), reflect.TypeOf(k), k)
+			}
+			var err error
+			if m[key], err = convertToUnstructured(item, path+"."+key); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("%s: unsupported value of type %s", pathOrRoot(path), reflect.TypeOf(yamlObj))
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path[1:]
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAMLToUnstructuredObject(t *testing.T) {
+	obj, err := YAMLToUnstructuredObject([]byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+  generation: 3
+spec:
+  replicas: 9223372036854775807
+  ratio: 0.5
+  enabled: true
+  empty: null
+  items: [a, 1, {2: b, true: c}]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":       "settings",
+			"generation": int64(3),
+		},
+		"spec": map[string]interface{}{
+			"replicas": int64(9223372036854775807),
+			"ratio":    0.5,
+			"enabled":  true,
+			"empty":    nil,
+			"items": []interface{}{"a", int64(1), map[string]interface{}{
+				"2":    "b",
+				"true": "c",
+			}},
+		},
+	}
+	if !reflect.DeepEqual(obj, expected) {
+		t.Errorf("expected %#v, got %#v", expected, obj)
+	}
+
+	obj, err = YAMLToUnstructuredObject([]byte("# nothing\n"))
+	if err != nil || obj != nil {
+		t.Errorf("expected nil object, got %#v, %v", obj, err)
+	}
+
+	tests := map[string]struct {
+		yaml, err string
+	}{
+		"overflow":  {"spec:\n  size: 9223372036854775808\n", "spec.size: integer 9223372036854775808 overflows int64"},
+		"infinity":  {"spec:\n  items: [.inf]\n", "spec.items[0]: unsupported float value +Inf"},
+		"not a map": {"- a\n", "expected a mapping, got []interface {}"},
+	}
+	for name, test := range tests {
+		_, err := YAMLToUnstructuredObject([]byte(test.yaml))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: expected error containing %q, got %v", name, test.err, err)
+		}
+	}
+}
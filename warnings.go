@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v2"
+)
+
+// A WarningHandler receives the problems found when converting YAML that
+// don't prevent the conversion but may deserve attention, so that they can
+// be logged, as a message starting with the line of the problem when it's
+// known. Problems that don't have a line are passed in no particular
+// order.
+type WarningHandler func(warning string)
+
+// YAMLToJSONWithWarnings is like YAMLToJSON, but passes to warn the mapping
+// keys that are repeated, whose last value is kept.
+func YAMLToJSONWithWarnings(y []byte, warn WarningHandler) ([]byte, error) {
+	j, err := yamlToJSONTarget(y, nil, yaml.Unmarshal, warn)
+	if err != nil {
+		return nil, err
+	}
+	warnDuplicateKeys(y, warn)
+	return j, nil
+}
+
+// UnmarshalWithWarnings is like Unmarshal, but passes to warn the problems
+// that Unmarshal ignores: mapping keys that are repeated, numbers and
+// booleans converted to strings in a lossy way, and the fields that don't
+// exist in obj, unless the DisallowUnknownFields option is given.
+func UnmarshalWithWarnings(yamlBytes []byte, obj interface{}, warn WarningHandler, opts ...JSONOpt) error {
+	jsonTarget := reflect.ValueOf(obj)
+	jsonBytes, err := yamlToJSONTarget(yamlBytes, &jsonTarget, yaml.Unmarshal, warn)
+	if err != nil {
+		return fmt.Errorf("error converting YAML to JSON: %w", err)
+	}
+	warnDuplicateKeys(yamlBytes, warn)
+	if err := jsonUnmarshal(jsonBytes, obj, opts...); err != nil {
+		return fmt.Errorf("error unmarshaling JSON: %w", err)
+	}
+	warnUnknownFields(yamlBytes, jsonBytes, obj, warn)
+	return nil
+}
+
+// UnmarshalStrictOrWarn is like UnmarshalStrict, but passes the violations
+// of strict mode to warn instead of failing, and completes the decoding as
+// Unmarshal does, so that strictness can be rolled out gradually. All the
+// repeated mapping keys and the fields that don't exist in obj are
+// reported. Errors that Unmarshal would return are still returned.
+func UnmarshalStrictOrWarn(yamlBytes []byte, obj interface{}, warn WarningHandler, opts ...JSONOpt) error {
+	jsonTarget := reflect.ValueOf(obj)
+	jsonBytes, strictErr := yamlToJSONTarget(yamlBytes, &jsonTarget, yaml.UnmarshalStrict, nil)
+	if strictErr != nil {
+		var err error
+		jsonBytes, err = yamlToJSONTarget(yamlBytes, &jsonTarget, yaml.Unmarshal, nil)
+		if err != nil {
+			return fmt.Errorf("error converting YAML to JSON: %w", err)
+		}
+		var typeErr *yaml.TypeError
+		if errors.As(strictErr, &typeErr) {
+			for _, msg := range typeErr.Errors {
+				warn(msg)
+			}
+		} else {
+			warn(strictErr.Error())
+		}
+	}
+	// Unknown fields are looked for apart, so that obj is decoded once.
+	warnUnknownFields(yamlBytes, jsonBytes, obj, warn)
+	if err := jsonUnmarshal(jsonBytes, obj, opts...); err != nil {
+		return fmt.Errorf("error unmarshaling JSON: %w", err)
+	}
+	return nil
+}
+
+// warnDuplicateKeys passes to warn the keys of the mappings of y that are
+// repeated.
+func warnDuplicateKeys(y []byte, warn WarningHandler) {
+	for _, k := range findDuplicateKeys(y) {
+		warn(fmt.Sprintf("line %d: mapping key %q already defined at line %d, the last value is used", k.Line, k.Key, k.FirstLine))
+	}
+}
+
+// warnUnknownFields passes to warn the keys of j, the JSON conversion of
+// y, that don't match a field of obj.
+func warnUnknownFields(y, j []byte, obj interface{}, warn WarningHandler) {
+	fields, err := findUnknownFields(j, reflect.TypeOf(obj))
+	if err != nil || len(fields) == 0 {
+		return
+	}
+	for _, f := range locateFields(y, fields) {
+		if f.Line > 0 {
+			warn(fmt.Sprintf("line %d: %s", f.Line, f.message()))
+		} else {
+			warn(f.message())
+		}
+	}
+}
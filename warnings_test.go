@@ -0,0 +1,146 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnmarshalWithWarnings(t *testing.T) {
+	type Config struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		Debug   string `json:"debug"`
+	}
+	y := []byte(`name: a
+version: 1.23456789
+debug: yes
+name: b
+extra: true
+more: [1]
+`)
+	var warnings []string
+	var c Config
+	err := UnmarshalWithWarnings(y, &c, func(w string) {
+		warnings = append(warnings, w)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Config{Name: "b", Version: "1.2345679", Debug: "true"}
+	if c != expected {
+		t.Errorf("expected %+v, got %+v", expected, c)
+	}
+	// Values are converted in no particular order.
+	if len(warnings) > 1 && warnings[0] > warnings[1] {
+		warnings[0], warnings[1] = warnings[1], warnings[0]
+	}
+	expectedWarnings := []string{
+		`boolean converted to the string "true", whatever its original text`,
+		`number 1.23456789 converted to the string "1.2345679", losing precision`,
+		`line 4: mapping key "name" already defined at line 1, the last value is used`,
+		`line 5: unknown field "extra"`,
+		`line 6: unknown field "more"`,
+	}
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("expected warnings %q, got %q", expectedWarnings, warnings)
+	}
+
+	warnings = nil
+	j, err := YAMLToJSONWithWarnings(y, func(w string) {
+		warnings = append(warnings, w)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(j) != `{"debug":true,"extra":true,"more":[1],"name":"b","version":1.23456789}` {
+		t.Errorf("unexpected JSON: %s", j)
+	}
+	if !reflect.DeepEqual(warnings, expectedWarnings[2:3]) {
+		t.Errorf("expected warnings %q, got %q", expectedWarnings[2:3], warnings)
+	}
+}
+
+func TestUnmarshalStrictOrWarn(t *testing.T) {
+	type Config struct {
+		Name string `json:"name"`
+		Size int    `json:"size"`
+	}
+	tests := map[string]struct {
+		yaml     string
+		expected Config
+		warnings []string
+		err      bool
+	}{
+		"valid": {
+			yaml:     "name: a\nsize: 1\n",
+			expected: Config{Name: "a", Size: 1},
+		},
+		"duplicate keys": {
+			yaml:     "name: a\nsize: 1\nname: b\nsize: 2\n",
+			expected: Config{Name: "b", Size: 2},
+			warnings: []string{
+				`line 3: key "name" already set in map`,
+				`line 4: key "size" already set in map`,
+			},
+		},
+		"unknown fields": {
+			yaml:     "name: a\nextra: 1\nsize: 2\nsiez: 3\n",
+			expected: Config{Name: "a", Size: 2},
+			warnings: []string{
+				`line 2: unknown field "extra"`,
+				`line 4: unknown field "siez", did you mean "size"?`,
+			},
+		},
+		"duplicate keys and unknown fields": {
+			yaml:     "name: a\nextra: 1\nname: b\n",
+			expected: Config{Name: "b"},
+			warnings: []string{
+				`line 3: key "name" already set in map`,
+				`line 2: unknown field "extra"`,
+			},
+		},
+		"type error": {
+			yaml: "name: a\nsize: b\n",
+			err:  true,
+		},
+	}
+	for name, test := range tests {
+		var warnings []string
+		var c Config
+		err := UnmarshalStrictOrWarn([]byte(test.yaml), &c, func(w string) {
+			warnings = append(warnings, w)
+		})
+		if test.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+			continue
+		}
+		if c != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", name, test.expected, c)
+		}
+		if !reflect.DeepEqual(warnings, test.warnings) {
+			t.Errorf("%s: expected warnings %q, got %q", name, test.warnings, warnings)
+		}
+	}
+}
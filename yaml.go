@@ -20,7 +20,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io"
+	"math"
 	"reflect"
 	"strconv"
 
@@ -59,9 +59,10 @@ func Unmarshal(yamlBytes []byte, obj interface{}, opts ...JSONOpt) error {
 // UnmarshalStrict is similar to Unmarshal (please read its documentation for reference), with the following exceptions:
 //
 //  - Duplicate fields in an object yield an error. This is according to the YAML specification.
-//  - If obj, or any of its recursive children, is a struct, presence of fields in the serialized data unknown to the struct will yield an error.
+//  - If obj, or any of its recursive children, is a struct, presence of fields in the serialized data unknown to the struct will yield an error, which names the field likely meant, if the unknown one is a close misspelling of it.
+//  - The violations of these rules are all reported at once, with a *StrictError listing them, and obj is left untouched.
 func UnmarshalStrict(yamlBytes []byte, obj interface{}, opts ...JSONOpt) error {
-	return unmarshal(yamlBytes, obj, yaml.UnmarshalStrict, append(opts, DisallowUnknownFields)...)
+	return unmarshalStrict(yamlBytes, obj, opts...)
 }
 
 // unmarshal unmarshals the given YAML byte stream into the given interface,
@@ -69,12 +70,12 @@ func UnmarshalStrict(yamlBytes []byte, obj interface{}, opts ...JSONOpt) error {
 func unmarshal(yamlBytes []byte, obj interface{}, unmarshalFn func([]byte, interface{}) error, opts ...JSONOpt) error {
 	jsonTarget := reflect.ValueOf(obj)
 
-	jsonBytes, err := yamlToJSONTarget(yamlBytes, &jsonTarget, unmarshalFn)
+	jsonBytes, err := yamlToJSONTarget(yamlBytes, &jsonTarget, unmarshalFn, nil)
 	if err != nil {
 		return fmt.Errorf("error converting YAML to JSON: %w", err)
 	}
 
-	err = jsonUnmarshal(bytes.NewReader(jsonBytes), obj, opts...)
+	err = jsonUnmarshal(jsonBytes, obj, opts...)
 	if err != nil {
 		return fmt.Errorf("error unmarshaling JSON: %w", err)
 	}
@@ -82,16 +83,20 @@ func unmarshal(yamlBytes []byte, obj interface{}, unmarshalFn func([]byte, inter
 	return nil
 }
 
-// jsonUnmarshal unmarshals the JSON byte stream from the given reader into the
-// object, optionally applying decoder options prior to decoding.  We are not
+// jsonUnmarshal unmarshals the JSON byte stream j into the object,
+// optionally applying decoder options prior to decoding.  We are not
 // using json.Unmarshal directly as we want the chance to pass in non-default
-// options.
-func jsonUnmarshal(reader io.Reader, obj interface{}, opts ...JSONOpt) error {
-	d := json.NewDecoder(reader)
+// options. Errors reporting unknown fields tell the field that was likely
+// meant, if any.
+func jsonUnmarshal(j []byte, obj interface{}, opts ...JSONOpt) error {
+	d := json.NewDecoder(bytes.NewReader(j))
 	for _, opt := range opts {
 		d = opt(d)
 	}
-	return d.Decode(obj)
+	if err := d.Decode(obj); err != nil {
+		return withFieldSuggestion(err, j, obj)
+	}
+	return nil
 }
 
 // JSONToYAML converts JSON to YAML. Notable implementation details:
@@ -139,18 +144,38 @@ func JSONToYAML(j []byte) ([]byte, error) {
 // - Duplicate fields are case-sensitively ignored in an undefined order. Note that the YAML specification forbids duplicate fields, so this logic is more permissive than it needs to. See YAMLToJSONStrict for an alternative.
 // - As per the YAML 1.1 specification, which yaml.v2 used underneath implements, literal 'yes' and 'no' strings without quotation marks will be converted to true/false implicitly.
 // - Unlike Unmarshal, all integers, up to 64 bits, are preserved during this round-trip.
+// - See YAMLToJSONWithOptions to change how repeated keys, non-string keys and numbers are handled.
 // - There are no compatibility guarantees for returned error values.
 func YAMLToJSON(y []byte) ([]byte, error) {
-	return yamlToJSONTarget(y, nil, yaml.Unmarshal)
+	return yamlToJSONTarget(y, nil, yaml.Unmarshal, nil)
 }
 
 // YAMLToJSONStrict is like YAMLToJSON but enables strict YAML decoding,
 // returning an error on any duplicate field names.
+//
+// When the error is due to repeated keys, it holds a DuplicateKeyError
+// listing them, which may be obtained with errors.As.
 func YAMLToJSONStrict(y []byte) ([]byte, error) {
-	return yamlToJSONTarget(y, nil, yaml.UnmarshalStrict)
+	return YAMLToJSONWithOptions(y, DisallowDuplicateKeys)
 }
 
-func yamlToJSONTarget(yamlBytes []byte, jsonTarget *reflect.Value, unmarshalFn func([]byte, interface{}) error) ([]byte, error) {
+func yamlToJSONTarget(yamlBytes []byte, jsonTarget *reflect.Value, unmarshalFn func([]byte, interface{}) error, warn WarningHandler) ([]byte, error) {
+	jsonObj, err := yamlToJSONObject(yamlBytes, jsonTarget, unmarshalFn, warn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert this object to JSON and return the data.
+	jsonBytes, err := json.Marshal(jsonObj)
+	if err != nil {
+		return nil, fmt.Errorf("error converting YAML to JSON: %w", err)
+	}
+	return jsonBytes, nil
+}
+
+// yamlToJSONObject decodes yamlBytes into an object that can be marshaled
+// into JSON.
+func yamlToJSONObject(yamlBytes []byte, jsonTarget *reflect.Value, unmarshalFn func([]byte, interface{}) error, warn WarningHandler) (interface{}, error) {
 	// Convert the YAML to an object.
 	var yamlObj interface{}
 	err := unmarshalFn(yamlBytes, &yamlObj)
@@ -162,20 +187,14 @@ func yamlToJSONTarget(yamlBytes []byte, jsonTarget *reflect.Value, unmarshalFn f
 	// can have non-string keys in YAML). So, convert the YAML-compatible object
 	// to a JSON-compatible object, failing with an error if irrecoverable
 	// incompatibilties happen along the way.
-	jsonObj, err := convertToJSONableObject(yamlObj, jsonTarget)
-	if err != nil {
-		return nil, fmt.Errorf("error converting YAML to JSON: %w", err)
-	}
-
-	// Convert this object to JSON and return the data.
-	jsonBytes, err := json.Marshal(jsonObj)
+	jsonObj, err := convertToJSONableObject(yamlObj, jsonTarget, warn)
 	if err != nil {
 		return nil, fmt.Errorf("error converting YAML to JSON: %w", err)
 	}
-	return jsonBytes, nil
+	return jsonObj, nil
 }
 
-func convertToJSONableObject(yamlObj interface{}, jsonTarget *reflect.Value) (interface{}, error) {
+func convertToJSONableObject(yamlObj interface{}, jsonTarget *reflect.Value, warn WarningHandler) (interface{}, error) {
 	var err error
 
 	// Resolve jsonTarget to a concrete value (i.e. not a pointer or an
@@ -202,45 +221,11 @@ func convertToJSONableObject(yamlObj interface{}, jsonTarget *reflect.Value) (in
 	case map[interface{}]interface{}:
 		// JSON does not support arbitrary keys in a map, so we must convert
 		// these keys to strings.
-		//
-		// From my reading of go-yaml v2 (specifically the resolve function),
-		// keys can only have the types string, int, int64, float64, binary
-		// (unsupported), or null (unsupported).
 		strMap := make(map[string]interface{})
 		for k, v := range typedYAMLObj {
 			// Resolve the key to a string first.
-			var keyString string
-			switch typedKey := k.(type) {
-			case string:
-				keyString = typedKey
-			case int:
-				keyString = strconv.Itoa(typedKey)
-			case int64:
-				// go-yaml will only return an int64 as a key if the system
-				// architecture is 32-bit and the key's value is between 32-bit
-				// and 64-bit. Otherwise the key type will simply be int.
-				keyString = strconv.FormatInt(typedKey, 10)
-			case float64:
-				// Stolen from go-yaml to use the same conversion to string as
-				// the go-yaml library uses to convert float to string when
-				// Marshaling.
-				s := strconv.FormatFloat(typedKey, 'g', -1, 32)
-				switch s {
-				case "+Inf":
-					s = ".inf"
-				case "-Inf":
-					s = "-.inf"
-				case "NaN":
-					s = ".nan"
-				}
-				keyString = s
-			case bool:
-				if typedKey {
-					keyString = "true"
-				} else {
-					keyString = "false"
-				}
-			default:
+			keyString, ok := yamlKeyToString(k)
+			if !ok {
 				return nil, fmt.Errorf("unsupported map key of type: %s, key: %+#v, value: %+#v",
 					reflect.TypeOf(k), k, v)
 			}
@@ -272,7 +257,7 @@ func convertToJSONableObject(yamlObj interface{}, jsonTarget *reflect.Value) (in
 						// Find the reflect.Value of the most preferential
 						// struct field.
 						jtf := t.Field(f.index[0])
-						strMap[keyString], err = convertToJSONableObject(v, &jtf)
+						strMap[keyString], err = convertToJSONableObject(v, &jtf, warn)
 						if err != nil {
 							return nil, err
 						}
@@ -282,14 +267,14 @@ func convertToJSONableObject(yamlObj interface{}, jsonTarget *reflect.Value) (in
 					// Create a zero value of the map's element type to use as
 					// the JSON target.
 					jtv := reflect.Zero(t.Type().Elem())
-					strMap[keyString], err = convertToJSONableObject(v, &jtv)
+					strMap[keyString], err = convertToJSONableObject(v, &jtv, warn)
 					if err != nil {
 						return nil, err
 					}
 					continue
 				}
 			}
-			strMap[keyString], err = convertToJSONableObject(v, nil)
+			strMap[keyString], err = convertToJSONableObject(v, nil, warn)
 			if err != nil {
 				return nil, err
 			}
@@ -317,7 +302,7 @@ func convertToJSONableObject(yamlObj interface{}, jsonTarget *reflect.Value) (in
 		// Make and use a new array.
 		arr := make([]interface{}, len(typedYAMLObj))
 		for i, v := range typedYAMLObj {
-			arr[i], err = convertToJSONableObject(v, jsonSliceElemValue)
+			arr[i], err = convertToJSONableObject(v, jsonSliceElemValue, warn)
 			if err != nil {
 				return nil, err
 			}
@@ -337,6 +322,9 @@ func convertToJSONableObject(yamlObj interface{}, jsonTarget *reflect.Value) (in
 				s = strconv.FormatInt(typedVal, 10)
 			case float64:
 				s = strconv.FormatFloat(typedVal, 'g', -1, 32)
+				if f, _ := strconv.ParseFloat(s, 64); f != typedVal && !math.IsNaN(f) && warn != nil {
+					warn(fmt.Sprintf("number %v converted to the string %q, losing precision", typedVal, s))
+				}
 			case uint64:
 				s = strconv.FormatUint(typedVal, 10)
 			case bool:
@@ -345,6 +333,9 @@ func convertToJSONableObject(yamlObj interface{}, jsonTarget *reflect.Value) (in
 				} else {
 					s = "false"
 				}
+				if warn != nil {
+					warn(fmt.Sprintf("boolean converted to the string %q, whatever its original text", s))
+				}
 			}
 			if len(s) > 0 {
 				yamlObj = interface{}(s)
@@ -354,6 +345,45 @@ func convertToJSONableObject(yamlObj interface{}, jsonTarget *reflect.Value) (in
 	}
 }
 
+// yamlKeyToString returns the JSON object key for the YAML mapping key k.
+//
+// From my reading of go-yaml v2 (specifically the resolve function), keys
+// can only have the types string, int, int64, float64, binary (unsupported),
+// or null (unsupported).
+func yamlKeyToString(k interface{}) (string, bool) {
+	switch typedKey := k.(type) {
+	case string:
+		return typedKey, true
+	case int:
+		return strconv.Itoa(typedKey), true
+	case int64:
+		// go-yaml will only return an int64 as a key if the system
+		// architecture is 32-bit and the key's value is between 32-bit
+		// and 64-bit. Otherwise the key type will simply be int.
+		return strconv.FormatInt(typedKey, 10), true
+	case float64:
+		// Stolen from go-yaml to use the same conversion to string as
+		// the go-yaml library uses to convert float to string when
+		// Marshaling.
+		s := strconv.FormatFloat(typedKey, 'g', -1, 32)
+		switch s {
+		case "+Inf":
+			s = ".inf"
+		case "-Inf":
+			s = "-.inf"
+		case "NaN":
+			s = ".nan"
+		}
+		return s, true
+	case bool:
+		if typedKey {
+			return "true", true
+		}
+		return "false", true
+	}
+	return "", false
+}
+
 // JSONObjectToYAMLObject converts an in-memory JSON object into a YAML in-memory MapSlice,
 // without going through a byte representation. A nil or empty map[string]interface{} input is
 // converted to an empty map, i.e. yaml.MapSlice(nil).
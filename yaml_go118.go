@@ -0,0 +1,51 @@
+// This file contains changes that are only compatible with go 1.18 and onwards.
+
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"fmt"
+	"io"
+)
+
+// DecodeEachAs is like DecodeEach, but calls fn with each document
+// unmarshaled into a new value of type T, as done by Unmarshal with opts.
+// Unmarshaling errors are returned with the index of the document in the
+// stream, and stop reading.
+func DecodeEachAs[T any](r io.Reader, fn func(v T) error, opts ...JSONOpt) error {
+	f := NewDocumentFilter(r, nil)
+	for {
+		doc, err := f.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var v T
+		if err := Unmarshal(doc.Raw, &v, opts...); err != nil {
+			return fmt.Errorf("document %d: %w", doc.Index, err)
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,54 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeEachAs(t *testing.T) {
+	type Object struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+	var names []string
+	err := DecodeEachAs(strings.NewReader(filterStream), func(obj Object) error {
+		names = append(names, obj.Kind+"/"+obj.Metadata.Name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Service/web", "Deployment/web", "ConfigMap/settings"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+
+	err = DecodeEachAs(strings.NewReader("kind: a\n---\nkind: [b]\n"), func(obj Object) error {
+		return nil
+	}, DisallowUnknownFields)
+	if err == nil || !strings.HasPrefix(err.Error(), "document 1: error unmarshaling JSON") {
+		t.Errorf("expected an error in document 1, got %v", err)
+	}
+}
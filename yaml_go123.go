@@ -0,0 +1,81 @@
+// This file contains changes that are only compatible with go 1.23 and onwards.
+
+//go:build go1.23
+// +build go1.23
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"fmt"
+	"io"
+	"iter"
+)
+
+// Documents returns an iterator over the text of the documents of the YAML
+// stream read from r, split as done by DecodeEach. An error reading the
+// stream, or a document that isn't valid YAML, is yielded with a nil
+// document and ends the iteration.
+func Documents(r io.Reader) iter.Seq2[[]byte, error] {
+	return func(yield func([]byte, error) bool) {
+		f := NewDocumentFilter(r, nil)
+		for {
+			doc, err := f.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(doc.Raw, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Values returns an iterator over the documents of the YAML stream read
+// from r, each unmarshaled into a new value of type T as done by
+// Unmarshal with opts. Errors are yielded with the zero value of T, and
+// end the iteration; unmarshaling errors are given the index of the
+// document in the stream, as by DecodeEachAs.
+func Values[T any](r io.Reader, opts ...JSONOpt) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		var zero T
+		f := NewDocumentFilter(r, nil)
+		for {
+			doc, err := f.Next()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				yield(zero, err)
+				return
+			}
+			var v T
+			if err := Unmarshal(doc.Raw, &v, opts...); err != nil {
+				yield(zero, fmt.Errorf("document %d: %w", doc.Index, err))
+				return
+			}
+			if !yield(v, nil) {
+				return
+			}
+		}
+	}
+}
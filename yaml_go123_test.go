@@ -0,0 +1,111 @@
+//go:build go1.23
+// +build go1.23
+
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package yaml
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDocuments(t *testing.T) {
+	var docs []string
+	for doc, err := range Documents(strings.NewReader("a: 1\n---\n---\nb: 2\n")) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, string(doc))
+	}
+	expected := []string{"a: 1\n", "---\nb: 2\n"}
+	if !reflect.DeepEqual(docs, expected) {
+		t.Errorf("expected %q, got %q", expected, docs)
+	}
+
+	docs = nil
+	for doc, err := range Documents(strings.NewReader("a: 1\n...\nb: 2\n")) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		docs = append(docs, string(doc))
+	}
+	expected = []string{"a: 1\n...\n", "b: 2\n"}
+	if !reflect.DeepEqual(docs, expected) {
+		t.Errorf("expected %q, got %q", expected, docs)
+	}
+
+	// Breaking out of the loop stops reading.
+	n := 0
+	for range Documents(strings.NewReader(filterStream)) {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("expected 1 document, got %d", n)
+	}
+
+	var errs int
+	for doc, err := range Documents(strings.NewReader("a: 1\n---\nb: [2\n---\nc: 3\n")) {
+		if err != nil {
+			errs++
+			if doc != nil {
+				t.Errorf("expected no document with the error, got %q", doc)
+			}
+		}
+	}
+	if errs != 1 {
+		t.Errorf("expected 1 error, got %d", errs)
+	}
+}
+
+func TestValues(t *testing.T) {
+	type Object struct {
+		Kind     string `json:"kind"`
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	}
+	var names []string
+	for obj, err := range Values[Object](strings.NewReader(filterStream)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, obj.Kind+"/"+obj.Metadata.Name)
+	}
+	expected := []string{"Service/web", "Deployment/web", "ConfigMap/settings"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+
+	var kinds []string
+	var lastErr error
+	for obj, err := range Values[Object](strings.NewReader("kind: a\n---\nkind: [b]\n---\nkind: c\n"), DisallowUnknownFields) {
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		kinds = append(kinds, obj.Kind)
+	}
+	if lastErr == nil || !strings.HasPrefix(lastErr.Error(), "document 1: error unmarshaling JSON") {
+		t.Errorf("expected an error in document 1, got %v", lastErr)
+	}
+	if !reflect.DeepEqual(kinds, []string{"a"}) {
+		t.Errorf("expected the iteration to stop at the error, got %v", kinds)
+	}
+}